@@ -0,0 +1,312 @@
+// Package audit provides an append-only JSON-lines audit log of authentication,
+// session lifecycle and administrative actions, with size-based rotation.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event types recorded in the audit log.
+const (
+	EventLogin           = "auth.login"        // Successful OAuth login
+	EventLoginDenied     = "auth.login_denied" // Login rejected (allowlist, bad state, exchange failure)
+	EventTokenRefresh    = "auth.refresh"      // Access token refreshed
+	EventLockout         = "auth.lockout"      // Client IP or user locked out after repeated failures
+	EventIdleLock        = "auth.idle_lock"    // Connection locked after the idle timeout
+	EventUnlock          = "auth.unlock"       // Locked connection unlocked by re-authentication
+	EventSessionCreate   = "session.create"    // Terminal session created
+	EventSessionAttach   = "session.attach"    // Connection attached to an existing (shared) session
+	EventSessionDetach   = "session.detach"    // Connection detached (tmux detach or viewer leaving)
+	EventSessionClose    = "session.close"     // Session closed (WebSocket close, REST delete, disconnect)
+	EventTmuxAttach      = "tmux.attach"       // Session created as a `tmux attach` client
+	EventTmuxCreate      = "tmux.create"       // tmux session created from trex
+	EventTmuxRename      = "tmux.rename"       // tmux session renamed from trex
+	EventTmuxKill        = "tmux.kill"         // tmux session killed from trex
+	EventAllowlistReload = "allowlist.reload"  // Allowlist file reloaded
+)
+
+// Event is a single audit log record, written as one JSON line.
+type Event struct {
+	Time      time.Time         `json:"time"`
+	Type      string            `json:"type"`
+	Actor     string            `json:"actor,omitempty"`     // Username performing the action (empty when auth disabled)
+	SessionID string            `json:"sessionId,omitempty"` // Affected trex session, if any
+	Remote    string            `json:"remote,omitempty"`    // Client address
+	Outcome   string            `json:"outcome,omitempty"`   // "success" | "denied" | "error"
+	Detail    map[string]string `json:"detail,omitempty"`    // Event-specific fields
+}
+
+// Outcome values.
+const (
+	OutcomeSuccess = "success"
+	OutcomeDenied  = "denied"
+	OutcomeError   = "error"
+)
+
+// Default rotation settings.
+const (
+	DefaultMaxBytes   = 10 * 1024 * 1024
+	DefaultMaxBackups = 5
+)
+
+// Logger appends audit events to a file, rotating it when it exceeds MaxBytes.
+// A nil *Logger is valid and discards all events, so callers don't need to
+// guard every Record call when auditing is disabled.
+type Logger struct {
+	mu         sync.Mutex
+	path       string
+	maxBytes   int64
+	maxBackups int
+	file       *os.File
+	size       int64
+	now        func() time.Time
+}
+
+// NewLogger opens (or creates) the audit log at path. maxBytes <= 0 and
+// maxBackups <= 0 fall back to the defaults.
+func NewLogger(path string, maxBytes int64, maxBackups int) (*Logger, error) {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+	if maxBackups <= 0 {
+		maxBackups = DefaultMaxBackups
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	l := &Logger{
+		path:       path,
+		maxBytes:   maxBytes,
+		maxBackups: maxBackups,
+		now:        time.Now,
+	}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// open opens the active log file in append-only mode.
+func (l *Logger) open() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.file = f
+	l.size = info.Size()
+	return nil
+}
+
+// Record appends an event. Time is filled in if zero. Errors are logged,
+// not returned — auditing must never break the action being audited.
+func (l *Logger) Record(e Event) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if e.Time.IsZero() {
+		e.Time = l.now().UTC()
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("audit: marshal error: %v", err)
+		return
+	}
+	data = append(data, '\n')
+
+	if l.size+int64(len(data)) > l.maxBytes && l.size > 0 {
+		if err := l.rotate(); err != nil {
+			log.Printf("audit: rotate error: %v", err)
+		}
+	}
+	if l.file == nil {
+		return
+	}
+	n, err := l.file.Write(data)
+	l.size += int64(n)
+	if err != nil {
+		log.Printf("audit: write error: %v", err)
+	}
+}
+
+// rotate shifts path.N-1 → path.N ... path → path.1 and reopens path.
+// Caller must hold mu.
+func (l *Logger) rotate() error {
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
+	}
+	_ = os.Remove(l.backupPath(l.maxBackups))
+	for i := l.maxBackups - 1; i >= 1; i-- {
+		_ = os.Rename(l.backupPath(i), l.backupPath(i+1))
+	}
+	if err := os.Rename(l.path, l.backupPath(1)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return l.open()
+}
+
+// backupPath returns the path of the nth rotated file.
+func (l *Logger) backupPath(n int) string {
+	return fmt.Sprintf("%s.%d", l.path, n)
+}
+
+// Close closes the active log file.
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// Filter selects events in Query. Zero-valued fields match everything.
+type Filter struct {
+	Type      string    // Exact type, or a prefix ending in "." (e.g. "session.")
+	Actor     string    // Exact username
+	SessionID string    // Exact session ID
+	Since     time.Time // Inclusive lower bound
+	Until     time.Time // Exclusive upper bound
+	Limit     int       // Max events returned (most recent); 0 = no limit
+}
+
+// Match returns true if the event satisfies the filter (ignoring Limit).
+func (f Filter) Match(e Event) bool {
+	if f.Type != "" {
+		if strings.HasSuffix(f.Type, ".") {
+			if !strings.HasPrefix(e.Type, f.Type) {
+				return false
+			}
+		} else if e.Type != f.Type {
+			return false
+		}
+	}
+	if f.Actor != "" && e.Actor != f.Actor {
+		return false
+	}
+	if f.SessionID != "" && e.SessionID != f.SessionID {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !e.Time.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Query reads the active log and its rotated backups and returns matching
+// events in chronological order. Malformed lines are skipped.
+//
+// The files are opened under the lock and read outside it, so a slow query
+// never blocks Record. Files are read newest first and reading stops once
+// Limit events have matched.
+func (l *Logger) Query(f Filter) ([]Event, error) {
+	if l == nil {
+		return []Event{}, nil
+	}
+	files, err := l.openNewestFirst()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+
+	// chunks[i] holds the matches from files[i], each in file order
+	chunks := make([][]Event, 0, len(files))
+	total := 0
+	for _, file := range files {
+		matched, err := readEvents(file, f)
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, matched)
+		total += len(matched)
+		if f.Limit > 0 && total >= f.Limit {
+			break
+		}
+	}
+
+	events := make([]Event, 0, total)
+	for i := len(chunks) - 1; i >= 0; i-- {
+		events = append(events, chunks[i]...)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+	if f.Limit > 0 && len(events) > f.Limit {
+		events = events[len(events)-f.Limit:]
+	}
+	return events, nil
+}
+
+// openNewestFirst opens the active log and then each existing backup, newest
+// first. Open handles keep reading the right data if rotation renames the
+// files afterwards.
+func (l *Logger) openNewestFirst() ([]*os.File, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	paths := make([]string, 0, l.maxBackups+1)
+	paths = append(paths, l.path)
+	for i := 1; i <= l.maxBackups; i++ {
+		paths = append(paths, l.backupPath(i))
+	}
+
+	files := make([]*os.File, 0, len(paths))
+	for _, p := range paths {
+		file, err := os.Open(p)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			for _, opened := range files {
+				opened.Close()
+			}
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// readEvents returns the events in one file matching the filter.
+func readEvents(file *os.File, f Filter) ([]Event, error) {
+	var events []Event
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		if f.Match(e) {
+			events = append(events, e)
+		}
+	}
+	return events, scanner.Err()
+}
@@ -0,0 +1,156 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Test Doc:
+// - Why: Shared boxes need an append-only record of who did what
+// - Contract: Record appends one JSON line per event; the file rotates by size; Query filters across rotated files
+// - Usage Notes: A nil *Logger is a valid no-op so callers needn't guard on auditing being disabled
+// - Quality Contribution: Guards rotation ordering and filter semantics relied on by /api/audit
+// - Worked Example: Record(session.create), Record(auth.login) → Query(Type:"session.") returns only session.create
+
+func newTestLogger(t *testing.T, maxBytes int64, maxBackups int) (*Logger, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audit", "audit.log")
+	l, err := NewLogger(path, maxBytes, maxBackups)
+	if err != nil {
+		t.Fatalf("NewLogger error: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l, path
+}
+
+func TestLogger_RecordAndQuery(t *testing.T) {
+	l, _ := newTestLogger(t, 0, 0)
+
+	l.Record(Event{Type: EventLogin, Actor: "alice", Outcome: OutcomeSuccess})
+	l.Record(Event{Type: EventSessionCreate, Actor: "alice", SessionID: "s1"})
+	l.Record(Event{Type: EventSessionClose, Actor: "bob", SessionID: "s2"})
+
+	all, err := l.Query(Filter{})
+	if err != nil {
+		t.Fatalf("Query error: %v", err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("Query() len = %d, want 3", len(all))
+	}
+	if all[0].Time.IsZero() {
+		t.Error("Record should fill in Time")
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   int
+	}{
+		{"type prefix", Filter{Type: "session."}, 2},
+		{"exact type", Filter{Type: EventLogin}, 1},
+		{"actor", Filter{Actor: "alice"}, 2},
+		{"session", Filter{SessionID: "s2"}, 1},
+		{"limit keeps most recent", Filter{Limit: 1}, 1},
+		{"future since", Filter{Since: time.Now().Add(time.Hour)}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := l.Query(tt.filter)
+			if err != nil {
+				t.Fatalf("Query error: %v", err)
+			}
+			if len(got) != tt.want {
+				t.Errorf("Query(%+v) len = %d, want %d", tt.filter, len(got), tt.want)
+			}
+		})
+	}
+
+	last, _ := l.Query(Filter{Limit: 1})
+	if last[0].SessionID != "s2" {
+		t.Errorf("Limit should keep the newest event, got %+v", last[0])
+	}
+}
+
+func TestLogger_RotatesBySize(t *testing.T) {
+	l, path := newTestLogger(t, 200, 2)
+
+	for i := 0; i < 20; i++ {
+		l.Record(Event{Type: EventSessionCreate, Actor: "alice", SessionID: "s"})
+	}
+
+	for _, p := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(p)
+		if err != nil {
+			t.Fatalf("expected %s to exist: %v", p, err)
+		}
+		if info.Size() > 200 {
+			t.Errorf("%s size = %d, want <= 200", p, info.Size())
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("%s.3 should not exist beyond maxBackups", path)
+	}
+
+	// Query spans the active file and both backups
+	events, err := l.Query(Filter{})
+	if err != nil {
+		t.Fatalf("Query error: %v", err)
+	}
+	if len(events) == 0 || len(events) >= 20 {
+		t.Errorf("Query() len = %d, want between 1 and 19 (oldest rotated out)", len(events))
+	}
+}
+
+func TestLogger_AppendsAcrossReopen(t *testing.T) {
+	l, path := newTestLogger(t, 0, 0)
+	l.Record(Event{Type: EventLogin, Actor: "alice"})
+	l.Close()
+
+	l2, err := NewLogger(path, 0, 0)
+	if err != nil {
+		t.Fatalf("NewLogger error: %v", err)
+	}
+	defer l2.Close()
+	l2.Record(Event{Type: EventLogin, Actor: "bob"})
+
+	events, _ := l2.Query(Filter{})
+	if len(events) != 2 {
+		t.Errorf("Query() len = %d, want 2 (log must be append-only)", len(events))
+	}
+}
+
+func TestLogger_NilIsNoop(t *testing.T) {
+	var l *Logger
+	l.Record(Event{Type: EventLogin})
+	events, err := l.Query(Filter{})
+	if err != nil || len(events) != 0 {
+		t.Errorf("nil Query() = %v, %v; want empty, nil", events, err)
+	}
+	if err := l.Close(); err != nil {
+		t.Errorf("nil Close() = %v", err)
+	}
+}
+
+func TestLogger_QueryLimitAcrossBackups(t *testing.T) {
+	l, _ := newTestLogger(t, 200, 3)
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 12; i++ {
+		l.Record(Event{Time: base.Add(time.Duration(i) * time.Minute), Type: EventSessionCreate, Detail: map[string]string{"seq": strconv.Itoa(i)}})
+	}
+
+	events, err := l.Query(Filter{Limit: 4})
+	if err != nil {
+		t.Fatalf("Query error: %v", err)
+	}
+	var got []string
+	for _, e := range events {
+		got = append(got, e.Detail["seq"])
+	}
+	if want := []string{"8", "9", "10", "11"}; !slices.Equal(got, want) {
+		t.Errorf("Query(Limit: 4) seq = %v, want %v", got, want)
+	}
+}
@@ -4,10 +4,12 @@ import (
 	"encoding/json"
 	"log"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 
 	"github.com/fsnotify/fsnotify"
+	"github.com/vaughanknight/trex/internal/audit"
 )
 
 // AllowlistFile represents the JSON structure of the allowlist file.
@@ -19,9 +21,10 @@ type AllowlistFile struct {
 // AllowlistManager manages the set of allowed GitHub usernames.
 // Thread-safe for concurrent reads during hot reload.
 type AllowlistManager struct {
-	mu    sync.RWMutex
-	users map[string]bool
-	path  string
+	mu       sync.RWMutex
+	users    map[string]bool
+	path     string
+	auditLog *audit.Logger
 }
 
 // NewAllowlistManager creates an empty AllowlistManager.
@@ -70,6 +73,11 @@ func (m *AllowlistManager) SetUsers(users []string) {
 	}
 }
 
+// SetAuditLog sets the audit logger for allowlist reload events.
+func (m *AllowlistManager) SetAuditLog(l *audit.Logger) {
+	m.auditLog = l
+}
+
 // Reload reads the allowlist file and updates the user set.
 // On parse error, keeps the existing list and returns the error.
 func (m *AllowlistManager) Reload() error {
@@ -85,11 +93,21 @@ func (m *AllowlistManager) Reload() error {
 	var file AllowlistFile
 	if err := json.Unmarshal(data, &file); err != nil {
 		log.Printf("Allowlist parse error (keeping old list): %v", err)
+		m.auditLog.Record(audit.Event{
+			Type:    audit.EventAllowlistReload,
+			Outcome: audit.OutcomeError,
+			Detail:  map[string]string{"path": m.path, "error": err.Error()},
+		})
 		return err
 	}
 
 	m.SetUsers(file.Users)
 	log.Printf("Allowlist reloaded: %d users", len(file.Users))
+	m.auditLog.Record(audit.Event{
+		Type:    audit.EventAllowlistReload,
+		Outcome: audit.OutcomeSuccess,
+		Detail:  map[string]string{"path": m.path, "users": strconv.Itoa(len(file.Users))},
+	})
 	return nil
 }
 
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"net/http"
 	"time"
+
+	"github.com/vaughanknight/trex/internal/audit"
 )
 
 // AuthHandler holds dependencies for OAuth HTTP handlers.
@@ -12,6 +14,8 @@ type AuthHandler struct {
 	stateStore *StateStore
 	jwtService *JWTService
 	allowlist  *AllowlistManager
+	auditLog   *audit.Logger
+	limiter    *RateLimiter
 	enabled    bool
 }
 
@@ -31,6 +35,34 @@ func (h *AuthHandler) SetAllowlist(al *AllowlistManager) {
 	h.allowlist = al
 }
 
+// SetAuditLog sets the audit logger for login and refresh events.
+// A nil logger disables auditing.
+func (h *AuthHandler) SetAuditLog(l *audit.Logger) {
+	h.auditLog = l
+}
+
+// SetRateLimiter sets the limiter used for per-user throttling and lockout.
+// Per-IP throttling is applied by RateLimitMiddleware; the handlers record
+// per-IP failures for bad OAuth state or codes and invalid refresh tokens.
+// A nil limiter disables them.
+func (h *AuthHandler) SetRateLimiter(rl *RateLimiter) {
+	h.limiter = rl
+}
+
+// recordAuth appends an auth audit event for the request.
+func (h *AuthHandler) recordAuth(r *http.Request, eventType, actor, outcome, reason string) {
+	e := audit.Event{
+		Type:    eventType,
+		Actor:   actor,
+		Remote:  r.RemoteAddr,
+		Outcome: outcome,
+	}
+	if reason != "" {
+		e.Detail = map[string]string{"reason": reason}
+	}
+	h.auditLog.Record(e)
+}
+
 // HandleGitHubLogin redirects to GitHub's OAuth authorization page.
 func (h *AuthHandler) HandleGitHubLogin() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -67,18 +99,31 @@ func (h *AuthHandler) HandleCallback() http.HandlerFunc {
 		}
 
 		if !h.stateStore.Validate(state) {
+			h.recordAuth(r, audit.EventLoginDenied, "", audit.OutcomeDenied, "invalid_state")
+			h.limiter.RecordFailure(IPKey(r))
 			http.Error(w, "invalid or expired state parameter", http.StatusBadRequest)
 			return
 		}
 
 		user, err := h.provider.Exchange(code)
 		if err != nil {
+			h.recordAuth(r, audit.EventLoginDenied, "", audit.OutcomeError, "exchange_failed")
+			h.limiter.RecordFailure(IPKey(r))
 			http.Error(w, "failed to exchange authorization code", http.StatusBadGateway)
 			return
 		}
 
+		userKey := UserKey(user.Username)
+		if ok, wait := h.limiter.Allow(userKey); !ok {
+			h.recordAuth(r, audit.EventLoginDenied, user.Username, audit.OutcomeDenied, "rate_limited")
+			TooManyRequests(w, wait)
+			return
+		}
+
 		// Check allowlist if configured
 		if h.allowlist != nil && !h.allowlist.IsAllowed(user.Username) {
+			h.recordAuth(r, audit.EventLoginDenied, user.Username, audit.OutcomeDenied, "not_in_allowlist")
+			h.limiter.RecordFailure(userKey)
 			http.Error(w, "access denied: user not in allowlist", http.StatusForbidden)
 			return
 		}
@@ -115,6 +160,8 @@ func (h *AuthHandler) HandleCallback() http.HandlerFunc {
 			MaxAge:   604800, // 7 days
 		})
 
+		h.limiter.RecordSuccess(userKey)
+		h.recordAuth(r, audit.EventLogin, user.Username, audit.OutcomeSuccess, "")
 		http.Redirect(w, r, "/", http.StatusFound)
 	}
 }
@@ -166,13 +213,23 @@ func (h *AuthHandler) HandleRefresh() http.HandlerFunc {
 
 		claims, err := h.jwtService.ValidateToken(cookie.Value)
 		if err != nil {
+			h.recordAuth(r, audit.EventTokenRefresh, "", audit.OutcomeDenied, "invalid_refresh_token")
+			h.limiter.RecordFailure(IPKey(r))
 			http.Error(w, "invalid refresh token", http.StatusUnauthorized)
 			return
 		}
 
+		if ok, wait := h.limiter.Allow(UserKey(claims.Username)); !ok {
+			h.recordAuth(r, audit.EventTokenRefresh, claims.Username, audit.OutcomeDenied, "rate_limited")
+			TooManyRequests(w, wait)
+			return
+		}
+
+		// Keep the original login time so a refresh never counts as re-authentication
 		user := &GitHubUser{
 			Username:  claims.Username,
 			AvatarURL: claims.AvatarURL,
+			AuthTime:  claims.AuthTimeOf(),
 		}
 
 		accessToken, err := h.jwtService.GenerateAccessToken(user)
@@ -190,6 +247,7 @@ func (h *AuthHandler) HandleRefresh() http.HandlerFunc {
 			MaxAge:   900,
 		})
 
+		h.recordAuth(r, audit.EventTokenRefresh, user.Username, audit.OutcomeSuccess, "")
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]string{"status": "refreshed"})
 	}
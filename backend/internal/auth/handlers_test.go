@@ -7,6 +7,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/vaughanknight/trex/internal/audit"
 )
 
 func newTestHandler() *AuthHandler {
@@ -408,3 +410,45 @@ func TestHandleMe_NoToken(t *testing.T) {
 		t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
 	}
 }
+
+// =============================================================================
+// Audit logging tests
+// =============================================================================
+
+func TestHandleCallback_AuditsLoginAndDenial(t *testing.T) {
+	// Test Doc:
+	// - Why: Shared boxes need a record of logins and allowlist denials
+	// - Contract: Successful callback → auth.login; allowlist rejection → auth.login_denied with reason
+	// - Worked Example: testuser not in allowlist → {type: auth.login_denied, actor: testuser, detail.reason: not_in_allowlist}
+
+	auditLog, err := audit.NewLogger(t.TempDir()+"/audit.log", 0, 0)
+	if err != nil {
+		t.Fatalf("NewLogger error: %v", err)
+	}
+	defer auditLog.Close()
+
+	h := newTestHandler()
+	h.SetAuditLog(auditLog)
+	al := NewAllowlistManager()
+	h.SetAllowlist(al)
+
+	state, _ := h.stateStore.Generate()
+	req := httptest.NewRequest(http.MethodGet, "/auth/callback?code=valid-code&state="+state, nil)
+	h.HandleCallback().ServeHTTP(httptest.NewRecorder(), req)
+
+	al.SetUsers([]string{"testuser"})
+	state, _ = h.stateStore.Generate()
+	req = httptest.NewRequest(http.MethodGet, "/auth/callback?code=valid-code&state="+state, nil)
+	h.HandleCallback().ServeHTTP(httptest.NewRecorder(), req)
+
+	events, _ := auditLog.Query(audit.Filter{Type: "auth."})
+	if len(events) != 2 {
+		t.Fatalf("audit events = %d, want 2", len(events))
+	}
+	if events[0].Type != audit.EventLoginDenied || events[0].Actor != "testuser" || events[0].Detail["reason"] != "not_in_allowlist" {
+		t.Errorf("first event = %+v, want login_denied for testuser", events[0])
+	}
+	if events[1].Type != audit.EventLogin || events[1].Outcome != audit.OutcomeSuccess {
+		t.Errorf("second event = %+v, want successful login", events[1])
+	}
+}
@@ -11,6 +11,9 @@ import (
 type TokenClaims struct {
 	Username  string `json:"username"`
 	AvatarURL string `json:"avatar_url"`
+	// AuthTime is when the interactive OAuth login happened. Refreshes keep it,
+	// so it only advances when the user signs in again.
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -35,6 +38,7 @@ func (j *JWTService) GenerateAccessToken(user *GitHubUser) (string, error) {
 	claims := TokenClaims{
 		Username:  user.Username,
 		AvatarURL: user.AvatarURL,
+		AuthTime:  authTime(user),
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.accessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -52,6 +56,7 @@ func (j *JWTService) GenerateRefreshToken(user *GitHubUser) (string, error) {
 	claims := TokenClaims{
 		Username:  user.Username,
 		AvatarURL: user.AvatarURL,
+		AuthTime:  authTime(user),
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.refreshTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -64,6 +69,26 @@ func (j *JWTService) GenerateRefreshToken(user *GitHubUser) (string, error) {
 	return token.SignedString(j.secret)
 }
 
+// authTime returns the auth_time claim for user, defaulting to now (a fresh login).
+func authTime(user *GitHubUser) *jwt.NumericDate {
+	if user.AuthTime.IsZero() {
+		return jwt.NewNumericDate(time.Now())
+	}
+	return jwt.NewNumericDate(user.AuthTime)
+}
+
+// AuthTimeOf returns the claims' auth_time. Tokens issued before the claim
+// existed fall back to their issue time.
+func (c *TokenClaims) AuthTimeOf() time.Time {
+	switch {
+	case c.AuthTime != nil:
+		return c.AuthTime.Time
+	case c.IssuedAt != nil:
+		return c.IssuedAt.Time
+	}
+	return time.Time{}
+}
+
 // ValidateToken parses and validates a JWT token string.
 func (j *JWTService) ValidateToken(tokenString string) (*TokenClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &TokenClaims{}, func(token *jwt.Token) (interface{}, error) {
@@ -175,3 +175,28 @@ func TestJWT_RejectsNoneAlgorithm(t *testing.T) {
 		t.Fatal("expected error for none algorithm, got nil")
 	}
 }
+
+func TestJWT_AuthTimeCarriedThroughRefresh(t *testing.T) {
+	// Test Doc:
+	// - Why: Idle unlock requires a fresh interactive login, not just a refresh
+	// - Contract: New logins get auth_time=now; a user with AuthTime set keeps it in generated tokens
+	// - Worked Example: login 2h ago → refreshed access token still has auth_time 2h ago
+
+	svc := NewJWTService("test-secret")
+
+	token, _ := svc.GenerateAccessToken(&GitHubUser{Username: "alice"})
+	claims, err := svc.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("ValidateToken() error: %v", err)
+	}
+	if time.Since(claims.AuthTimeOf()) > time.Minute {
+		t.Errorf("fresh login auth_time = %v, want ~now", claims.AuthTimeOf())
+	}
+
+	loggedIn := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+	token, _ = svc.GenerateAccessToken(&GitHubUser{Username: "alice", AuthTime: loggedIn})
+	claims, _ = svc.ValidateToken(token)
+	if !claims.AuthTimeOf().Equal(loggedIn) {
+		t.Errorf("auth_time = %v, want %v", claims.AuthTimeOf(), loggedIn)
+	}
+}
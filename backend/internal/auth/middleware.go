@@ -59,6 +59,7 @@ func Middleware(jwtService *JWTService, authEnabled bool) func(http.Handler) htt
 			user := &GitHubUser{
 				Username:  claims.Username,
 				AvatarURL: claims.AvatarURL,
+				AuthTime:  claims.AuthTimeOf(),
 			}
 			ctx := WithUser(r.Context(), user)
 			next.ServeHTTP(w, r.WithContext(ctx))
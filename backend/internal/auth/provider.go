@@ -1,5 +1,7 @@
 package auth
 
+import "time"
+
 // OAuthProvider abstracts the GitHub OAuth flow for testability.
 // Production uses RealGitHubProvider; tests use FakeOAuthProvider.
 type OAuthProvider interface {
@@ -15,4 +17,8 @@ type OAuthProvider interface {
 type GitHubUser struct {
 	Username  string `json:"username"`
 	AvatarURL string `json:"avatar_url"`
+	// AuthTime is when the user last completed the OAuth login. Carried in
+	// tokens as auth_time so re-authentication can be verified; zero means
+	// "now" when a token is generated.
+	AuthTime time.Time `json:"-"`
 }
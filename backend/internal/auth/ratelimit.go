@@ -0,0 +1,304 @@
+package auth
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vaughanknight/trex/internal/audit"
+)
+
+// RateLimitConfig configures token-bucket throttling and failure lockouts.
+type RateLimitConfig struct {
+	// PerMinute is the sustained request rate per key. 0 disables throttling.
+	PerMinute int
+	// Burst is the bucket size (requests allowed at once).
+	Burst int
+	// LockoutThreshold is the number of failures within LockoutWindow that
+	// triggers a lockout. 0 disables lockouts.
+	LockoutThreshold int
+	// LockoutWindow is the sliding window failures are counted in.
+	LockoutWindow time.Duration
+	// LockoutDuration is how long a key stays locked out.
+	LockoutDuration time.Duration
+}
+
+// RateLimitStats are the counters exposed through diagnostics.
+type RateLimitStats struct {
+	Allowed        uint64 `json:"allowed"`
+	Throttled      uint64 `json:"throttled"`      // Rejected: bucket empty
+	Failures       uint64 `json:"failures"`       // Auth failures recorded
+	Lockouts       uint64 `json:"lockouts"`       // Lockouts triggered
+	LockedRejects  uint64 `json:"lockedRejects"`  // Rejected: key locked out
+	ActiveLockouts int    `json:"activeLockouts"` // Keys currently locked out
+	TrackedKeys    int    `json:"trackedKeys"`    // Keys with bucket or failure state
+}
+
+// bucket is a token bucket for one key.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// failureState tracks recent failures and lockout for one key.
+type failureState struct {
+	times       []time.Time
+	lockedUntil time.Time
+}
+
+// RateLimiter applies per-key (IP or user) token-bucket limits and locks
+// keys out after repeated failures. Thread-safe. A nil *RateLimiter allows
+// everything.
+type RateLimiter struct {
+	cfg RateLimitConfig
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	failures  map[string]*failureState
+	lastPrune time.Time
+	now       func() time.Time
+	auditLog  *audit.Logger
+
+	allowed       atomic.Uint64
+	throttled     atomic.Uint64
+	failed        atomic.Uint64
+	lockouts      atomic.Uint64
+	lockedRejects atomic.Uint64
+}
+
+// NewRateLimiter creates a limiter with the given configuration.
+func NewRateLimiter(cfg RateLimitConfig) *RateLimiter {
+	if cfg.Burst <= 0 {
+		cfg.Burst = 1
+	}
+	return &RateLimiter{
+		cfg:      cfg,
+		buckets:  make(map[string]*bucket),
+		failures: make(map[string]*failureState),
+		now:      time.Now,
+	}
+}
+
+// SetAuditLog sets the audit logger for lockout events.
+func (rl *RateLimiter) SetAuditLog(l *audit.Logger) {
+	rl.auditLog = l
+}
+
+// IPKey returns the limiter key for a request's client IP.
+func IPKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// UserKey returns the limiter key for a username.
+func UserKey(username string) string {
+	return "user:" + username
+}
+
+// Allow consumes a token for key. Returns false and the time to wait when
+// the key is locked out or its bucket is empty.
+func (rl *RateLimiter) Allow(key string) (bool, time.Duration) {
+	if rl == nil {
+		return true, 0
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.now()
+	rl.pruneLocked(now)
+
+	if f := rl.failures[key]; f != nil && now.Before(f.lockedUntil) {
+		rl.lockedRejects.Add(1)
+		return false, f.lockedUntil.Sub(now)
+	}
+
+	if rl.cfg.PerMinute <= 0 {
+		rl.allowed.Add(1)
+		return true, 0
+	}
+
+	rate := float64(rl.cfg.PerMinute) / 60 // tokens per second
+	b := rl.buckets[key]
+	if b == nil {
+		b = &bucket{tokens: float64(rl.cfg.Burst), last: now}
+		rl.buckets[key] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * rate
+	if b.tokens > float64(rl.cfg.Burst) {
+		b.tokens = float64(rl.cfg.Burst)
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		rl.throttled.Add(1)
+		wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+		return false, wait
+	}
+	b.tokens--
+	rl.allowed.Add(1)
+	return true, 0
+}
+
+// RecordFailure notes an authentication failure for key and locks the key out
+// once LockoutThreshold failures land within LockoutWindow. Returns true if
+// this failure triggered a lockout.
+func (rl *RateLimiter) RecordFailure(key string) bool {
+	if rl == nil {
+		return false
+	}
+	rl.failed.Add(1)
+	if rl.cfg.LockoutThreshold <= 0 {
+		return false
+	}
+
+	rl.mu.Lock()
+	now := rl.now()
+	f := rl.failures[key]
+	if f == nil {
+		f = &failureState{}
+		rl.failures[key] = f
+	}
+	cutoff := now.Add(-rl.cfg.LockoutWindow)
+	kept := f.times[:0]
+	for _, t := range f.times {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	f.times = append(kept, now)
+
+	locked := false
+	if len(f.times) >= rl.cfg.LockoutThreshold && !now.Before(f.lockedUntil) {
+		f.lockedUntil = now.Add(rl.cfg.LockoutDuration)
+		f.times = nil
+		locked = true
+	}
+	rl.mu.Unlock()
+
+	if locked {
+		rl.lockouts.Add(1)
+		rl.auditLog.Record(audit.Event{
+			Type:    audit.EventLockout,
+			Outcome: audit.OutcomeDenied,
+			Detail: map[string]string{
+				"key":      key,
+				"duration": rl.cfg.LockoutDuration.String(),
+			},
+		})
+	}
+	return locked
+}
+
+// RecordSuccess clears the failure history for key (but not an active lockout).
+func (rl *RateLimiter) RecordSuccess(key string) {
+	if rl == nil {
+		return
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if f := rl.failures[key]; f != nil {
+		f.times = nil
+	}
+}
+
+// Stats returns a snapshot of the limiter counters.
+func (rl *RateLimiter) Stats() RateLimitStats {
+	if rl == nil {
+		return RateLimitStats{}
+	}
+	rl.mu.Lock()
+	now := rl.now()
+	active := 0
+	for _, f := range rl.failures {
+		if now.Before(f.lockedUntil) {
+			active++
+		}
+	}
+	tracked := len(rl.buckets)
+	for k := range rl.failures {
+		if _, ok := rl.buckets[k]; !ok {
+			tracked++
+		}
+	}
+	rl.mu.Unlock()
+
+	return RateLimitStats{
+		Allowed:        rl.allowed.Load(),
+		Throttled:      rl.throttled.Load(),
+		Failures:       rl.failed.Load(),
+		Lockouts:       rl.lockouts.Load(),
+		LockedRejects:  rl.lockedRejects.Load(),
+		ActiveLockouts: active,
+		TrackedKeys:    tracked,
+	}
+}
+
+// pruneLocked drops full buckets and expired failure state at most once a
+// minute so the maps don't grow with every client ever seen. Caller holds mu.
+func (rl *RateLimiter) pruneLocked(now time.Time) {
+	if now.Sub(rl.lastPrune) < time.Minute {
+		return
+	}
+	rl.lastPrune = now
+	if rl.cfg.PerMinute > 0 {
+		refill := time.Duration(float64(rl.cfg.Burst) / (float64(rl.cfg.PerMinute) / 60) * float64(time.Second))
+		for k, b := range rl.buckets {
+			if now.Sub(b.last) > refill {
+				delete(rl.buckets, k)
+			}
+		}
+	}
+	for k, f := range rl.failures {
+		if now.After(f.lockedUntil) && (len(f.times) == 0 || now.Sub(f.times[len(f.times)-1]) > rl.cfg.LockoutWindow) {
+			delete(rl.failures, k)
+		}
+	}
+}
+
+// RateLimitMiddleware throttles requests to the given paths per client IP and
+// rejects IPs that are locked out. Throttled or locked-out requests get 429
+// with Retry-After. Failures are not inferred from response codes — an expired
+// access token on /ws is routine for a user's own tabs — so handlers call
+// RecordFailure with IPKey for failed credential checks. Passing a nil limiter
+// returns next unchanged.
+func RateLimitMiddleware(rl *RateLimiter, paths ...string) func(http.Handler) http.Handler {
+	limited := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		limited[p] = true
+	}
+	return func(next http.Handler) http.Handler {
+		if rl == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !limited[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := IPKey(r)
+			if ok, wait := rl.Allow(key); !ok {
+				TooManyRequests(w, wait)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// TooManyRequests writes a 429 response with a Retry-After header.
+func TooManyRequests(w http.ResponseWriter, wait time.Duration) {
+	secs := int(wait.Seconds() + 0.999)
+	if secs < 1 {
+		secs = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	http.Error(w, "too many requests", http.StatusTooManyRequests)
+}
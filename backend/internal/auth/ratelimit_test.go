@@ -0,0 +1,239 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestLimiter returns a limiter with a controllable clock.
+func newTestLimiter(cfg RateLimitConfig) (*RateLimiter, *time.Time) {
+	rl := NewRateLimiter(cfg)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	rl.now = func() time.Time { return now }
+	return rl, &now
+}
+
+func TestRateLimiter_TokenBucket(t *testing.T) {
+	// Test Doc:
+	// - Why: Throttle brute-force and reconnect storms on auth endpoints
+	// - Contract: Burst requests pass, the next is rejected with a wait; tokens refill at PerMinute
+	// - Worked Example: 60/min burst 2 → 2 allowed, 3rd throttled, 1s later one more allowed
+
+	rl, now := newTestLimiter(RateLimitConfig{PerMinute: 60, Burst: 2})
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := rl.Allow("ip:1.2.3.4"); !ok {
+			t.Fatalf("request %d throttled, want allowed", i+1)
+		}
+	}
+	ok, wait := rl.Allow("ip:1.2.3.4")
+	if ok {
+		t.Fatal("request beyond burst allowed, want throttled")
+	}
+	if wait <= 0 || wait > time.Second {
+		t.Errorf("wait = %v, want (0, 1s]", wait)
+	}
+
+	// Other keys have their own bucket
+	if ok, _ := rl.Allow("ip:5.6.7.8"); !ok {
+		t.Error("different key throttled, want allowed")
+	}
+
+	*now = now.Add(time.Second)
+	if ok, _ := rl.Allow("ip:1.2.3.4"); !ok {
+		t.Error("request after refill throttled, want allowed")
+	}
+
+	stats := rl.Stats()
+	if stats.Allowed != 4 || stats.Throttled != 1 {
+		t.Errorf("stats = %+v, want 4 allowed, 1 throttled", stats)
+	}
+}
+
+func TestRateLimiter_Lockout(t *testing.T) {
+	// Test Doc:
+	// - Why: Repeated failures must lock the client out for a while
+	// - Contract: Threshold failures within window → locked for LockoutDuration; old failures age out
+	// - Worked Example: threshold 3, window 1m, duration 5m → 3rd failure locks; Allow rejected until 5m later
+
+	rl, now := newTestLimiter(RateLimitConfig{
+		LockoutThreshold: 3,
+		LockoutWindow:    time.Minute,
+		LockoutDuration:  5 * time.Minute,
+	})
+
+	// Failures spread beyond the window don't lock
+	rl.RecordFailure("user:mallory")
+	rl.RecordFailure("user:mallory")
+	*now = now.Add(2 * time.Minute)
+	if rl.RecordFailure("user:mallory") {
+		t.Fatal("failure outside window triggered lockout")
+	}
+
+	rl.RecordFailure("user:mallory")
+	if !rl.RecordFailure("user:mallory") {
+		t.Fatal("3rd failure within window did not trigger lockout")
+	}
+	ok, wait := rl.Allow("user:mallory")
+	if ok {
+		t.Fatal("locked key allowed")
+	}
+	if wait != 5*time.Minute {
+		t.Errorf("wait = %v, want 5m", wait)
+	}
+	if stats := rl.Stats(); stats.Lockouts != 1 || stats.ActiveLockouts != 1 || stats.LockedRejects != 1 {
+		t.Errorf("stats = %+v, want 1 lockout, 1 active, 1 locked reject", stats)
+	}
+
+	*now = now.Add(5 * time.Minute)
+	if ok, _ := rl.Allow("user:mallory"); !ok {
+		t.Error("key still locked after LockoutDuration")
+	}
+}
+
+func TestRateLimiter_SuccessResetsFailures(t *testing.T) {
+	// Test Doc:
+	// - Why: A user who mistypes then logs in shouldn't carry failures forward
+	// - Contract: RecordSuccess clears the failure history for the key
+
+	rl, _ := newTestLimiter(RateLimitConfig{LockoutThreshold: 2, LockoutWindow: time.Minute, LockoutDuration: time.Minute})
+
+	rl.RecordFailure("user:alice")
+	rl.RecordSuccess("user:alice")
+	if rl.RecordFailure("user:alice") {
+		t.Error("lockout triggered despite intervening success")
+	}
+}
+
+func TestRateLimiter_NilAllowsEverything(t *testing.T) {
+	var rl *RateLimiter
+	if ok, _ := rl.Allow("ip:1.2.3.4"); !ok {
+		t.Error("nil limiter throttled")
+	}
+	if rl.RecordFailure("ip:1.2.3.4") {
+		t.Error("nil limiter locked out")
+	}
+	if rl.Stats() != (RateLimitStats{}) {
+		t.Error("nil limiter stats should be zero")
+	}
+}
+
+func TestRateLimitMiddleware_ThrottlesByIP(t *testing.T) {
+	// Test Doc:
+	// - Why: Per-IP limits apply before auth so unauthenticated floods are stopped
+	// - Contract: Only listed paths are limited; throttled → 429 + Retry-After; response codes alone never count as failures
+	// - Worked Example: burst 2 → two 401s on /ws, then 429 for that IP only; no lockout despite threshold 1
+
+	rl, _ := newTestLimiter(RateLimitConfig{
+		PerMinute:        60,
+		Burst:            2,
+		LockoutThreshold: 1,
+		LockoutWindow:    time.Minute,
+		LockoutDuration:  time.Minute,
+	})
+	handler := RateLimitMiddleware(rl, "/ws")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	}))
+
+	do := func(path, remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remote
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := 0; i < 2; i++ {
+		if rec := do("/ws", "10.0.0.1:1234"); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d status = %d, want 401", i+1, rec.Code)
+		}
+	}
+
+	rec := do("/ws", "10.0.0.1:5678")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("throttled IP status = %d, want 429", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("Retry-After = %q, want 1", rec.Header().Get("Retry-After"))
+	}
+
+	if rec := do("/ws", "10.0.0.2:1234"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("other IP status = %d, want 401", rec.Code)
+	}
+	if rec := do("/api/sessions", "10.0.0.1:1234"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("unlimited path status = %d, want 401 from handler", rec.Code)
+	}
+	if stats := rl.Stats(); stats.Failures != 0 || stats.Lockouts != 0 {
+		t.Errorf("stats = %+v, want no failures or lockouts from 401 responses", stats)
+	}
+}
+
+func TestHandleRefresh_InvalidTokenLocksOutIP(t *testing.T) {
+	// Test Doc:
+	// - Why: Guessing refresh tokens is a credential attack and must lock the source IP
+	// - Contract: an invalid refresh token counts against IPKey; a missing cookie does not
+	// - Worked Example: threshold 2 → missing cookie, two bad tokens → 429 for that IP
+
+	handler := NewAuthHandler(NewFakeOAuthProvider(), NewStateStore(time.Minute), NewJWTService("test-secret"), true)
+	rl, _ := newTestLimiter(RateLimitConfig{LockoutThreshold: 2, LockoutWindow: time.Minute, LockoutDuration: time.Minute})
+	handler.SetRateLimiter(rl)
+	refresh := RateLimitMiddleware(rl, "/auth/refresh")(handler.HandleRefresh())
+
+	do := func(token string) int {
+		req := httptest.NewRequest(http.MethodPost, "/auth/refresh", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		if token != "" {
+			req.AddCookie(&http.Cookie{Name: "trex_refresh_token", Value: token})
+		}
+		rec := httptest.NewRecorder()
+		refresh.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := do(""); code != http.StatusUnauthorized {
+		t.Fatalf("missing cookie status = %d, want 401", code)
+	}
+	for i := 0; i < 2; i++ {
+		if code := do("not-a-jwt"); code != http.StatusUnauthorized {
+			t.Fatalf("bad token attempt %d status = %d, want 401", i+1, code)
+		}
+	}
+	if code := do("not-a-jwt"); code != http.StatusTooManyRequests {
+		t.Errorf("locked IP status = %d, want 429", code)
+	}
+}
+
+func TestHandleCallback_UserLockout(t *testing.T) {
+	// Test Doc:
+	// - Why: Per-user lockout stops repeated denied logins for one account from any IP
+	// - Contract: allowlist denials count against the user; once locked the callback returns 429
+
+	provider := NewFakeOAuthProvider()
+	provider.AllowedCodes["mallory-code"] = &GitHubUser{Username: "mallory"}
+	stateStore := NewStateStore(time.Minute)
+	handler := NewAuthHandler(provider, stateStore, NewJWTService("test-secret"), true)
+	allowlist := NewAllowlistManager()
+	allowlist.SetUsers([]string{"alice"})
+	handler.SetAllowlist(allowlist)
+	rl, _ := newTestLimiter(RateLimitConfig{LockoutThreshold: 2, LockoutWindow: time.Minute, LockoutDuration: time.Minute})
+	handler.SetRateLimiter(rl)
+
+	callback := func() int {
+		state, _ := stateStore.Generate()
+		req := httptest.NewRequest(http.MethodGet, "/auth/callback?code=mallory-code&state="+state, nil)
+		rec := httptest.NewRecorder()
+		handler.HandleCallback().ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for i := 0; i < 2; i++ {
+		if code := callback(); code != http.StatusForbidden {
+			t.Fatalf("attempt %d status = %d, want 403", i+1, code)
+		}
+	}
+	if code := callback(); code != http.StatusTooManyRequests {
+		t.Errorf("locked user status = %d, want 429", code)
+	}
+}
@@ -3,6 +3,8 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -42,6 +44,135 @@ type Config struct {
 	// TmuxPollInterval is how often the tmux monitor polls for client changes.
 	// Read from TREX_TMUX_POLL_INTERVAL env var (default "2s"). Range: 500ms–30s.
 	TmuxPollInterval time.Duration
+
+	// TmuxControlMode keeps a `tmux -C` control-mode client attached so tmux
+	// pushes session/window/client changes instead of waiting for the next
+	// poll. Polling continues as a fallback. Read from TREX_TMUX_CONTROL_MODE
+	// (default true).
+	TmuxControlMode bool
+
+	// TmuxRemoteHosts are SSH destinations (e.g. "devvm1", "me@devvm2")
+	// whose tmux sessions are listed next to local ones and attached over SSH.
+	// Read from TREX_TMUX_REMOTE_HOSTS (comma-separated; default none).
+	TmuxRemoteHosts []string
+
+	// TmuxRemotePollInterval is how often each remote host's sessions are
+	// listed over SSH, independent of TmuxPollInterval. Read from
+	// TREX_TMUX_REMOTE_POLL_INTERVAL (default "30s"). Range: 5s–10m.
+	TmuxRemotePollInterval time.Duration
+
+	// TmuxSSHCommand is the ssh program and options used for remote hosts.
+	// Read from TREX_TMUX_SSH_COMMAND, split on whitespace
+	// (default "ssh -o BatchMode=yes -o ConnectTimeout=5").
+	TmuxSSHCommand []string
+
+	// TmuxSockets are extra local tmux servers to list alongside the default
+	// one: a name is used with `tmux -L`, a path (containing "/") with `-S`.
+	// Read from TREX_TMUX_SOCKETS (comma-separated; default none).
+	TmuxSockets []string
+
+	// TmuxHistoryLines is how many lines of pane history are captured with
+	// `tmux capture-pane` and sent ahead of the live screen when attaching to
+	// a tmux session. Read from TREX_TMUX_HISTORY_LINES (default 2000; 0
+	// disables). Range: 0–50000.
+	TmuxHistoryLines int
+
+	// SessionPollInterval is how often each session's working directory and
+	// process tree are checked (plugin collectors use their own interval).
+	// Read from TREX_SESSION_POLL_INTERVAL (default "5s"). Range: 1s–1m.
+	SessionPollInterval time.Duration
+
+	// AuditLogPath is the JSON-lines audit log file. Empty disables auditing.
+	// Read from TREX_AUDIT_LOG_PATH; defaults to $XDG_DATA_HOME/trex/audit.log
+	// (~/.local/share/trex/audit.log) when auth is enabled, per ADR-0006.
+	AuditLogPath string
+
+	// AuditMaxSizeMB is the size at which the audit log is rotated.
+	// Read from TREX_AUDIT_MAX_SIZE_MB (default 10).
+	AuditMaxSizeMB int
+
+	// WorkspacesPath is the JSON file that stores each user's workspaces
+	// (pane layouts). Read from TREX_WORKSPACES_PATH; defaults to
+	// $XDG_DATA_HOME/trex/workspaces.json. Empty keeps them in memory only.
+	WorkspacesPath string
+
+	// AdminUsers are GitHub usernames allowed to use administrative endpoints
+	// such as /api/audit. Read from TREX_ADMIN_USERS (comma-separated).
+	// When auth is disabled the local user is implicitly an admin.
+	AdminUsers []string
+
+	// InputAuditProfiles lists the session profiles whose keystrokes are
+	// reconstructed into lines and stored (opt-in; "*" enables all profiles).
+	// Read from TREX_INPUT_AUDIT_PROFILES (comma-separated, default none).
+	InputAuditProfiles []string
+
+	// InputAuditDir is where per-session input audit files are written.
+	// Read from TREX_INPUT_AUDIT_DIR; defaults to $XDG_DATA_HOME/trex/input-audit.
+	InputAuditDir string
+
+	// InputAuditRulesPath is an optional JSON redaction ruleset
+	// ({"passwordPrompts": [...], "patterns": [{"name", "regex"}]}).
+	// Read from TREX_INPUT_AUDIT_RULES; empty uses the built-in rules.
+	InputAuditRulesPath string
+
+	// NotifyRulesPath is an optional JSON file of per-profile notification
+	// rules ({"profiles": {"<profile>|*": {"patterns": [...], ...}}}).
+	// Read from TREX_NOTIFY_RULES; empty uses the built-in rules.
+	NotifyRulesPath string
+
+	// WebhooksPath is an optional JSON file of outgoing webhook endpoints
+	// ({"endpoints": [{"url", "secret", "events"}]}) for session, tmux,
+	// plugin and notification events. Read from TREX_WEBHOOKS_PATH; empty
+	// disables webhooks.
+	WebhooksPath string
+
+	// WebhookDeadLetterPath is the JSON-lines log of webhook deliveries that
+	// failed every retry. Read from TREX_WEBHOOK_DEAD_LETTER_PATH; defaults
+	// to $XDG_DATA_HOME/trex/webhook-dead-letter.jsonl.
+	WebhookDeadLetterPath string
+
+	// AuthRateLimit is the sustained number of requests per minute allowed per
+	// client IP (and per user) on /auth/callback, /auth/refresh and /ws.
+	// Read from TREX_AUTH_RATE_LIMIT (default 30, 0 disables throttling).
+	AuthRateLimit int
+
+	// AuthRateBurst is how many requests may arrive at once before throttling.
+	// Read from TREX_AUTH_RATE_BURST (default 10).
+	AuthRateBurst int
+
+	// AuthLockoutThreshold is the number of failed auth attempts within
+	// AuthLockoutWindow that locks a client IP or user out.
+	// Read from TREX_AUTH_LOCKOUT_THRESHOLD (default 10, 0 disables lockouts).
+	AuthLockoutThreshold int
+
+	// AuthLockoutWindow is the window failures are counted in.
+	// Read from TREX_AUTH_LOCKOUT_WINDOW (default "5m"). Range: 10s–24h.
+	AuthLockoutWindow time.Duration
+
+	// AuthLockoutDuration is how long a lockout lasts.
+	// Read from TREX_AUTH_LOCKOUT_DURATION (default "15m"). Range: 10s–24h.
+	AuthLockoutDuration time.Duration
+
+	// IdleTimeout locks a WebSocket connection after this long without input.
+	// A locked connection rejects all messages until the user signs in again.
+	// Only applies when auth is enabled. Read from TREX_IDLE_TIMEOUT
+	// (default 0 = disabled). Range: 1m–24h.
+	IdleTimeout time.Duration
+
+	// SessionMaxLifetime closes a terminal session's PTY this long after it
+	// was created, regardless of activity. Read from TREX_SESSION_MAX_LIFETIME
+	// (default 0 = unlimited). Range: 1m–720h.
+	SessionMaxLifetime time.Duration
+
+	// WSCompressionLevel is the flate level for permessage-deflate on /ws,
+	// used when the client offers it. Read from TREX_WS_COMPRESSION_LEVEL
+	// (default 1 = fastest; 0 disables compression). Range: 0–9.
+	WSCompressionLevel int
+
+	// WSCompressionThreshold is the smallest /ws message, in bytes, that is
+	// compressed; smaller ones (keystroke echoes) are sent as-is.
+	// Read from TREX_WS_COMPRESSION_THRESHOLD (default 256). Range: 0–1048576.
+	WSCompressionThreshold int
 }
 
 // Load reads configuration from TREX_* environment variables and returns
@@ -66,8 +197,41 @@ func Load() *Config {
 		}
 	}
 
+	tmuxSSHCommand := strings.Fields(os.Getenv("TREX_TMUX_SSH_COMMAND"))
+	if len(tmuxSSHCommand) == 0 {
+		tmuxSSHCommand = []string{"ssh", "-o", "BatchMode=yes", "-o", "ConnectTimeout=5"}
+	}
+
 	tmuxPollInterval := parseDuration(os.Getenv("TREX_TMUX_POLL_INTERVAL"), 2*time.Second, 500*time.Millisecond, 30*time.Second)
 
+	inputAuditDir := os.Getenv("TREX_INPUT_AUDIT_DIR")
+	if inputAuditDir == "" {
+		if dir := dataDir(); dir != "" {
+			inputAuditDir = filepath.Join(dir, "input-audit")
+		}
+	}
+
+	auditLogPath := os.Getenv("TREX_AUDIT_LOG_PATH")
+	if auditLogPath == "" && authEnabled {
+		if dir := dataDir(); dir != "" {
+			auditLogPath = filepath.Join(dir, "audit.log")
+		}
+	}
+
+	workspacesPath := os.Getenv("TREX_WORKSPACES_PATH")
+	if workspacesPath == "" {
+		if dir := dataDir(); dir != "" {
+			workspacesPath = filepath.Join(dir, "workspaces.json")
+		}
+	}
+
+	webhookDeadLetterPath := os.Getenv("TREX_WEBHOOK_DEAD_LETTER_PATH")
+	if webhookDeadLetterPath == "" {
+		if dir := dataDir(); dir != "" {
+			webhookDeadLetterPath = filepath.Join(dir, "webhook-dead-letter.jsonl")
+		}
+	}
+
 	return &Config{
 		BindAddress:        bindAddress,
 		AuthEnabled:        authEnabled,
@@ -77,7 +241,55 @@ func Load() *Config {
 		JWTSecret:          os.Getenv("TREX_JWT_SECRET"),
 		AllowlistPath:      allowlistPath,
 		TmuxPollInterval:   tmuxPollInterval,
+		TmuxControlMode:    parseBoolDefault(os.Getenv("TREX_TMUX_CONTROL_MODE"), true),
+		TmuxRemoteHosts:    parseList(os.Getenv("TREX_TMUX_REMOTE_HOSTS")),
+		TmuxSSHCommand:     tmuxSSHCommand,
+		TmuxSockets:        parseList(os.Getenv("TREX_TMUX_SOCKETS")),
+		TmuxHistoryLines:   parseInt(os.Getenv("TREX_TMUX_HISTORY_LINES"), 2000, 0, 50000),
+		AuditLogPath:       auditLogPath,
+		AuditMaxSizeMB:     parseInt(os.Getenv("TREX_AUDIT_MAX_SIZE_MB"), 10, 1, 1024),
+		WorkspacesPath:     workspacesPath,
+		AdminUsers:         parseList(os.Getenv("TREX_ADMIN_USERS")),
+
+		InputAuditProfiles:  parseList(os.Getenv("TREX_INPUT_AUDIT_PROFILES")),
+		InputAuditDir:       inputAuditDir,
+		InputAuditRulesPath: os.Getenv("TREX_INPUT_AUDIT_RULES"),
+
+		NotifyRulesPath: os.Getenv("TREX_NOTIFY_RULES"),
+
+		WebhooksPath:          os.Getenv("TREX_WEBHOOKS_PATH"),
+		WebhookDeadLetterPath: webhookDeadLetterPath,
+
+		AuthRateLimit:        parseInt(os.Getenv("TREX_AUTH_RATE_LIMIT"), 30, 0, 10000),
+		AuthRateBurst:        parseInt(os.Getenv("TREX_AUTH_RATE_BURST"), 10, 1, 1000),
+		AuthLockoutThreshold: parseInt(os.Getenv("TREX_AUTH_LOCKOUT_THRESHOLD"), 10, 0, 1000),
+		AuthLockoutWindow:    parseDuration(os.Getenv("TREX_AUTH_LOCKOUT_WINDOW"), 5*time.Minute, 10*time.Second, 24*time.Hour),
+		AuthLockoutDuration:  parseDuration(os.Getenv("TREX_AUTH_LOCKOUT_DURATION"), 15*time.Minute, 10*time.Second, 24*time.Hour),
+
+		IdleTimeout:        parseOptionalDuration(os.Getenv("TREX_IDLE_TIMEOUT"), time.Minute, 24*time.Hour),
+		SessionMaxLifetime: parseOptionalDuration(os.Getenv("TREX_SESSION_MAX_LIFETIME"), time.Minute, 720*time.Hour),
+
+		WSCompressionLevel:     parseInt(os.Getenv("TREX_WS_COMPRESSION_LEVEL"), 1, 0, 9),
+		WSCompressionThreshold: parseInt(os.Getenv("TREX_WS_COMPRESSION_THRESHOLD"), 256, 0, 1<<20),
+
+		SessionPollInterval: parseDuration(os.Getenv("TREX_SESSION_POLL_INTERVAL"), 5*time.Second, time.Second, time.Minute),
+
+		TmuxRemotePollInterval: parseDuration(os.Getenv("TREX_TMUX_REMOTE_POLL_INTERVAL"), 30*time.Second, 5*time.Second, 10*time.Minute),
+	}
+}
+
+// IsAdmin reports whether username may use administrative endpoints.
+// Comparison is case-insensitive (GitHub usernames are case-insensitive).
+func (c *Config) IsAdmin(username string) bool {
+	if !c.AuthEnabled {
+		return true
 	}
+	for _, u := range c.AdminUsers {
+		if strings.EqualFold(u, username) {
+			return true
+		}
+	}
+	return false
 }
 
 // Validate checks that the configuration is valid. When AuthEnabled is true,
@@ -88,6 +300,21 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid bind address %q: must be in host:port format (e.g., 127.0.0.1:3000)", c.BindAddress)
 	}
 
+	if len(c.InputAuditProfiles) > 0 && c.InputAuditDir == "" {
+		return fmt.Errorf("TREX_INPUT_AUDIT_DIR is required when TREX_INPUT_AUDIT_PROFILES is set")
+	}
+
+	for _, socket := range c.TmuxSockets {
+		if strings.HasPrefix(socket, "-") {
+			return fmt.Errorf("invalid tmux socket %q in TREX_TMUX_SOCKETS", socket)
+		}
+	}
+	for _, host := range c.TmuxRemoteHosts {
+		if strings.HasPrefix(host, "-") || strings.ContainsAny(host, " \t") {
+			return fmt.Errorf("invalid tmux remote host %q in TREX_TMUX_REMOTE_HOSTS", host)
+		}
+	}
+
 	if !c.AuthEnabled {
 		return nil
 	}
@@ -129,6 +356,69 @@ func parseDuration(s string, defaultVal, min, max time.Duration) time.Duration {
 	return d
 }
 
+// parseOptionalDuration is parseDuration for features that are off by default:
+// empty, "0" or unparseable values return 0 (disabled).
+func parseOptionalDuration(s string, min, max time.Duration) time.Duration {
+	d := parseDuration(s, 0, 0, max)
+	if d > 0 && d < min {
+		return min
+	}
+	return d
+}
+
+// parseInt parses an integer string, clamping to [min, max] range.
+// Returns defaultVal if the string is empty or unparseable.
+func parseInt(s string, defaultVal, min, max int) int {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return defaultVal
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return defaultVal
+	}
+	if n < min {
+		return min
+	}
+	if n > max {
+		return max
+	}
+	return n
+}
+
+// parseList splits a comma-separated string, trimming whitespace and
+// dropping empty entries. Returns nil for an empty string.
+func parseList(s string) []string {
+	var result []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// dataDir returns the trex data directory: $XDG_DATA_HOME/trex, falling back
+// to ~/.local/share/trex (ADR-0006). Returns "" if no home directory is known.
+func dataDir() string {
+	if xdg := os.Getenv("XDG_DATA_HOME"); xdg != "" {
+		return filepath.Join(xdg, "trex")
+	}
+	home, _ := os.UserHomeDir()
+	if home == "" {
+		return ""
+	}
+	return filepath.Join(home, ".local", "share", "trex")
+}
+
+// parseBoolDefault is parseBool with a default for an unset variable.
+func parseBoolDefault(s string, defaultVal bool) bool {
+	if strings.TrimSpace(s) == "" {
+		return defaultVal
+	}
+	return parseBool(s)
+}
+
 // parseBool parses common boolean string representations.
 // Returns true for "true", "TRUE", "True", "1"; false for everything else.
 func parseBool(s string) bool {
@@ -3,6 +3,7 @@ package config
 import (
 	"strings"
 	"testing"
+	"time"
 )
 
 // =============================================================================
@@ -339,3 +340,324 @@ func TestValidate_InvalidBindAddress_Empty(t *testing.T) {
 		t.Errorf("error = %q, want it to mention 'invalid bind address'", err.Error())
 	}
 }
+
+// =============================================================================
+// Audit log and admin configuration
+// =============================================================================
+
+func TestConfig_AuditLogDefaults(t *testing.T) {
+	// Test Doc:
+	// - Why: Audit logging should be on for shared (auth-enabled) boxes without extra setup
+	// - Contract: Auth enabled → AuditLogPath under $XDG_DATA_HOME/trex; auth disabled → empty
+	// - Worked Example: XDG_DATA_HOME=/data → AuditLogPath="/data/trex/audit.log"
+
+	t.Setenv("XDG_DATA_HOME", "/data")
+
+	if cfg := Load(); cfg.AuditLogPath != "" {
+		t.Errorf("AuditLogPath (auth disabled) = %q, want empty", cfg.AuditLogPath)
+	}
+
+	t.Setenv("TREX_AUTH_ENABLED", "true")
+	cfg := Load()
+	if cfg.AuditLogPath != "/data/trex/audit.log" {
+		t.Errorf("AuditLogPath = %q, want %q", cfg.AuditLogPath, "/data/trex/audit.log")
+	}
+	if cfg.AuditMaxSizeMB != 10 {
+		t.Errorf("AuditMaxSizeMB = %d, want 10", cfg.AuditMaxSizeMB)
+	}
+}
+
+func TestConfig_WorkspacesPath(t *testing.T) {
+	// Test Doc:
+	// - Why: Server-side workspaces persist per user without extra setup (per ADR-0006: XDG data path)
+	// - Contract: Default $XDG_DATA_HOME/trex/workspaces.json whether or not auth is enabled; TREX_WORKSPACES_PATH overrides
+
+	t.Setenv("XDG_DATA_HOME", "/data")
+	if cfg := Load(); cfg.WorkspacesPath != "/data/trex/workspaces.json" {
+		t.Errorf("WorkspacesPath = %q, want %q", cfg.WorkspacesPath, "/data/trex/workspaces.json")
+	}
+
+	t.Setenv("TREX_WORKSPACES_PATH", "/srv/ws.json")
+	if cfg := Load(); cfg.WorkspacesPath != "/srv/ws.json" {
+		t.Errorf("WorkspacesPath = %q, want %q", cfg.WorkspacesPath, "/srv/ws.json")
+	}
+}
+
+func TestConfig_AdminUsers(t *testing.T) {
+	// Test Doc:
+	// - Why: Only admins may query /api/audit on a shared box
+	// - Contract: TREX_ADMIN_USERS is comma-separated; IsAdmin is case-insensitive; auth disabled → everyone is admin
+
+	t.Setenv("TREX_AUTH_ENABLED", "true")
+	t.Setenv("TREX_ADMIN_USERS", " Alice, bob ,")
+	t.Setenv("TREX_AUDIT_MAX_SIZE_MB", "50")
+
+	cfg := Load()
+	if len(cfg.AdminUsers) != 2 {
+		t.Fatalf("AdminUsers = %v, want 2 entries", cfg.AdminUsers)
+	}
+	if !cfg.IsAdmin("alice") || !cfg.IsAdmin("BOB") {
+		t.Error("IsAdmin should match configured admins case-insensitively")
+	}
+	if cfg.IsAdmin("mallory") {
+		t.Error("IsAdmin(mallory) = true, want false")
+	}
+	if cfg.AuditMaxSizeMB != 50 {
+		t.Errorf("AuditMaxSizeMB = %d, want 50", cfg.AuditMaxSizeMB)
+	}
+
+	if !(&Config{AuthEnabled: false}).IsAdmin("") {
+		t.Error("IsAdmin with auth disabled should be true")
+	}
+}
+
+func TestConfig_InputAudit(t *testing.T) {
+	// Test Doc:
+	// - Why: Keystroke audit is opt-in per profile
+	// - Contract: TREX_INPUT_AUDIT_PROFILES lists profiles; dir defaults under the data dir; Validate requires a dir when profiles are set
+	// - Worked Example: TREX_INPUT_AUDIT_PROFILES=agents → InputAuditProfiles=["agents"], InputAuditDir=$XDG_DATA_HOME/trex/input-audit
+
+	t.Setenv("XDG_DATA_HOME", "/data")
+	t.Setenv("TREX_INPUT_AUDIT_PROFILES", "agents")
+
+	cfg := Load()
+	if len(cfg.InputAuditProfiles) != 1 || cfg.InputAuditProfiles[0] != "agents" {
+		t.Errorf("InputAuditProfiles = %v, want [agents]", cfg.InputAuditProfiles)
+	}
+	if cfg.InputAuditDir != "/data/trex/input-audit" {
+		t.Errorf("InputAuditDir = %q, want %q", cfg.InputAuditDir, "/data/trex/input-audit")
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	cfg.InputAuditDir = ""
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "TREX_INPUT_AUDIT_DIR") {
+		t.Errorf("Validate() error = %v, want TREX_INPUT_AUDIT_DIR error", err)
+	}
+}
+
+func TestConfig_Webhooks(t *testing.T) {
+	// Test Doc:
+	// - Why: Outgoing webhooks are opt-in; failed deliveries need somewhere durable to go
+	// - Contract: TREX_WEBHOOKS_PATH is read as-is (default empty → disabled); the dead-letter log defaults under the data dir
+
+	t.Setenv("XDG_DATA_HOME", "/data")
+
+	cfg := Load()
+	if cfg.WebhooksPath != "" {
+		t.Errorf("WebhooksPath = %q, want empty", cfg.WebhooksPath)
+	}
+	if cfg.WebhookDeadLetterPath != "/data/trex/webhook-dead-letter.jsonl" {
+		t.Errorf("WebhookDeadLetterPath = %q, want %q", cfg.WebhookDeadLetterPath, "/data/trex/webhook-dead-letter.jsonl")
+	}
+
+	t.Setenv("TREX_WEBHOOKS_PATH", "/etc/trex/webhooks.json")
+	t.Setenv("TREX_WEBHOOK_DEAD_LETTER_PATH", "/var/log/trex-dead.jsonl")
+	cfg = Load()
+	if cfg.WebhooksPath != "/etc/trex/webhooks.json" || cfg.WebhookDeadLetterPath != "/var/log/trex-dead.jsonl" {
+		t.Errorf("webhook paths = %q, %q", cfg.WebhooksPath, cfg.WebhookDeadLetterPath)
+	}
+}
+
+func TestConfig_WSCompression(t *testing.T) {
+	// Test Doc:
+	// - Why: Remote users on slow links want compressed output; keystroke echoes aren't worth compressing
+	// - Contract: Level defaults to 1 (fastest) and threshold to 256 bytes; 0 disables compression; values are clamped
+	// - Worked Example: TREX_WS_COMPRESSION_LEVEL=12 → 9; TREX_WS_COMPRESSION_THRESHOLD=-5 → 0
+
+	cfg := Load()
+	if cfg.WSCompressionLevel != 1 || cfg.WSCompressionThreshold != 256 {
+		t.Errorf("compression = level %d, threshold %d; want 1, 256", cfg.WSCompressionLevel, cfg.WSCompressionThreshold)
+	}
+
+	t.Setenv("TREX_WS_COMPRESSION_LEVEL", "12")
+	t.Setenv("TREX_WS_COMPRESSION_THRESHOLD", "-5")
+	cfg = Load()
+	if cfg.WSCompressionLevel != 9 || cfg.WSCompressionThreshold != 0 {
+		t.Errorf("clamped compression = level %d, threshold %d; want 9, 0", cfg.WSCompressionLevel, cfg.WSCompressionThreshold)
+	}
+
+	t.Setenv("TREX_WS_COMPRESSION_LEVEL", "0")
+	if cfg = Load(); cfg.WSCompressionLevel != 0 {
+		t.Errorf("WSCompressionLevel = %d, want 0 (disabled)", cfg.WSCompressionLevel)
+	}
+}
+
+func TestConfig_AuthRateLimits(t *testing.T) {
+	// Test Doc:
+	// - Why: Auth endpoints are throttled by default; operators tune limits via env
+	// - Contract: Defaults 30/min, burst 10, lockout after 10 failures in 5m for 15m; values are clamped
+	// - Worked Example: TREX_AUTH_RATE_LIMIT=0 → throttling disabled; TREX_AUTH_LOCKOUT_DURATION=1s → clamped to 10s
+
+	cfg := Load()
+	if cfg.AuthRateLimit != 30 || cfg.AuthRateBurst != 10 {
+		t.Errorf("rate = %d/min burst %d, want 30/min burst 10", cfg.AuthRateLimit, cfg.AuthRateBurst)
+	}
+	if cfg.AuthLockoutThreshold != 10 || cfg.AuthLockoutWindow != 5*time.Minute || cfg.AuthLockoutDuration != 15*time.Minute {
+		t.Errorf("lockout = %d in %v for %v, want 10 in 5m for 15m",
+			cfg.AuthLockoutThreshold, cfg.AuthLockoutWindow, cfg.AuthLockoutDuration)
+	}
+
+	t.Setenv("TREX_AUTH_RATE_LIMIT", "0")
+	t.Setenv("TREX_AUTH_RATE_BURST", "3")
+	t.Setenv("TREX_AUTH_LOCKOUT_THRESHOLD", "5")
+	t.Setenv("TREX_AUTH_LOCKOUT_DURATION", "1s")
+
+	cfg = Load()
+	if cfg.AuthRateLimit != 0 {
+		t.Errorf("AuthRateLimit = %d, want 0", cfg.AuthRateLimit)
+	}
+	if cfg.AuthRateBurst != 3 || cfg.AuthLockoutThreshold != 5 {
+		t.Errorf("burst/threshold = %d/%d, want 3/5", cfg.AuthRateBurst, cfg.AuthLockoutThreshold)
+	}
+	if cfg.AuthLockoutDuration != 10*time.Second {
+		t.Errorf("AuthLockoutDuration = %v, want clamped to 10s", cfg.AuthLockoutDuration)
+	}
+}
+
+func TestConfig_IdleTimeoutAndLifetime(t *testing.T) {
+	// Test Doc:
+	// - Why: Idle lock and absolute session lifetime are opt-in
+	// - Contract: Unset or "0" disables; values are clamped to their ranges
+	// - Worked Example: TREX_IDLE_TIMEOUT=10s → 1m (minimum); TREX_SESSION_MAX_LIFETIME=8h → 8h
+
+	cfg := Load()
+	if cfg.IdleTimeout != 0 || cfg.SessionMaxLifetime != 0 {
+		t.Errorf("defaults = %v/%v, want disabled", cfg.IdleTimeout, cfg.SessionMaxLifetime)
+	}
+
+	t.Setenv("TREX_IDLE_TIMEOUT", "10s")
+	t.Setenv("TREX_SESSION_MAX_LIFETIME", "8h")
+	cfg = Load()
+	if cfg.IdleTimeout != time.Minute {
+		t.Errorf("IdleTimeout = %v, want clamped to 1m", cfg.IdleTimeout)
+	}
+	if cfg.SessionMaxLifetime != 8*time.Hour {
+		t.Errorf("SessionMaxLifetime = %v, want 8h", cfg.SessionMaxLifetime)
+	}
+
+	t.Setenv("TREX_IDLE_TIMEOUT", "0")
+	if cfg = Load(); cfg.IdleTimeout != 0 {
+		t.Errorf("IdleTimeout = %v, want 0 (disabled)", cfg.IdleTimeout)
+	}
+}
+
+func TestConfig_TmuxControlMode(t *testing.T) {
+	// Test Doc:
+	// - Why: Control mode is the default; operators can fall back to pure polling
+	// - Contract: Unset → true; "false"/"0" → false
+
+	if cfg := Load(); !cfg.TmuxControlMode {
+		t.Error("TmuxControlMode default = false, want true")
+	}
+	t.Setenv("TREX_TMUX_CONTROL_MODE", "false")
+	if cfg := Load(); cfg.TmuxControlMode {
+		t.Error("TmuxControlMode = true with TREX_TMUX_CONTROL_MODE=false")
+	}
+}
+
+func TestConfig_TmuxRemoteHosts(t *testing.T) {
+	// Test Doc:
+	// - Why: Remote tmux hosts are opt-in and reached through a configurable ssh command
+	// - Contract: Hosts are comma-separated (default none) and polled every 30s unless TREX_TMUX_REMOTE_POLL_INTERVAL says otherwise; the ssh command splits on whitespace with a BatchMode default; hosts starting with '-' fail validation
+
+	cfg := Load()
+	if len(cfg.TmuxRemoteHosts) != 0 {
+		t.Errorf("TmuxRemoteHosts default = %v, want none", cfg.TmuxRemoteHosts)
+	}
+	if cfg.TmuxRemotePollInterval != 30*time.Second {
+		t.Errorf("TmuxRemotePollInterval default = %s, want 30s", cfg.TmuxRemotePollInterval)
+	}
+	if got := strings.Join(cfg.TmuxSSHCommand, " "); got != "ssh -o BatchMode=yes -o ConnectTimeout=5" {
+		t.Errorf("TmuxSSHCommand default = %q", got)
+	}
+
+	t.Setenv("TREX_TMUX_REMOTE_HOSTS", "devvm1, me@devvm2")
+	t.Setenv("TREX_TMUX_SSH_COMMAND", "ssh -F /etc/trex/ssh_config")
+	t.Setenv("TREX_TMUX_REMOTE_POLL_INTERVAL", "1m")
+	cfg = Load()
+	if cfg.TmuxRemotePollInterval != time.Minute {
+		t.Errorf("TmuxRemotePollInterval = %s, want 1m", cfg.TmuxRemotePollInterval)
+	}
+	if len(cfg.TmuxRemoteHosts) != 2 || cfg.TmuxRemoteHosts[0] != "devvm1" || cfg.TmuxRemoteHosts[1] != "me@devvm2" {
+		t.Errorf("TmuxRemoteHosts = %v", cfg.TmuxRemoteHosts)
+	}
+	if got := strings.Join(cfg.TmuxSSHCommand, " "); got != "ssh -F /etc/trex/ssh_config" {
+		t.Errorf("TmuxSSHCommand = %q", got)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() = %v, want nil", err)
+	}
+
+	cfg.TmuxRemoteHosts = []string{"-oProxyCommand=evil"}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() = nil for a host starting with '-'")
+	}
+}
+
+func TestConfig_TmuxSockets(t *testing.T) {
+	// Test Doc:
+	// - Why: Separate tmux servers (e.g. `tmux -L agents`) are listed next to the default one
+	// - Contract: TREX_TMUX_SOCKETS is comma-separated (default none); sockets starting with '-' fail validation
+
+	if cfg := Load(); len(cfg.TmuxSockets) != 0 {
+		t.Errorf("TmuxSockets default = %v, want none", cfg.TmuxSockets)
+	}
+
+	t.Setenv("TREX_TMUX_SOCKETS", "agents, /run/trex/humans.sock")
+	cfg := Load()
+	if len(cfg.TmuxSockets) != 2 || cfg.TmuxSockets[0] != "agents" || cfg.TmuxSockets[1] != "/run/trex/humans.sock" {
+		t.Errorf("TmuxSockets = %v", cfg.TmuxSockets)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() = %v, want nil", err)
+	}
+
+	cfg.TmuxSockets = []string{"-fevil.conf"}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() = nil for a socket starting with '-'")
+	}
+}
+
+func TestConfig_SessionPollInterval(t *testing.T) {
+	// Test Doc:
+	// - Why: The server-wide session poller's cadence is tunable for hosts with many sessions
+	// - Contract: Default 5s; clamped to 1s–1m
+
+	if got := Load().SessionPollInterval; got != 5*time.Second {
+		t.Errorf("default SessionPollInterval = %s, want 5s", got)
+	}
+	t.Setenv("TREX_SESSION_POLL_INTERVAL", "100ms")
+	if got := Load().SessionPollInterval; got != time.Second {
+		t.Errorf("SessionPollInterval(100ms) = %s, want clamped to 1s", got)
+	}
+	t.Setenv("TREX_SESSION_POLL_INTERVAL", "15s")
+	if got := Load().SessionPollInterval; got != 15*time.Second {
+		t.Errorf("SessionPollInterval(15s) = %s, want 15s", got)
+	}
+}
+
+func TestConfig_TmuxHistoryLines(t *testing.T) {
+	// Test Doc:
+	// - Why: Pane history sent on attach is bounded so huge scrollbacks don't flood the browser
+	// - Contract: Default 2000; 0 disables; values are clamped to 0–50000; garbage falls back to the default
+
+	tests := []struct {
+		env  string
+		want int
+	}{
+		{"", 2000},
+		{"0", 0},
+		{"500", 500},
+		{"999999", 50000},
+		{"-5", 0},
+		{"lots", 2000},
+	}
+	for _, tt := range tests {
+		t.Setenv("TREX_TMUX_HISTORY_LINES", tt.env)
+		if got := Load().TmuxHistoryLines; got != tt.want {
+			t.Errorf("TREX_TMUX_HISTORY_LINES=%q → %d, want %d", tt.env, got, tt.want)
+		}
+	}
+}
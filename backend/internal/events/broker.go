@@ -0,0 +1,230 @@
+// Package events fans server events (tmux changes, session cwd and plugin
+// updates, session lifecycle) out to stream subscribers such as the
+// GET /api/events SSE endpoint. Recent events are kept in a ring buffer so
+// a reconnecting subscriber can resume from the last event it saw.
+package events
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Lifecycle event types. The other types reuse the WebSocket message types
+// (tmux_sessions, tmux_status, cwd_update, plugin_data).
+const (
+	TypeSessionCreated = "session_created" // Data: the session's SessionInfo
+	TypeSessionExited  = "session_exited"  // Data: {"sessionId", "exitCode"}
+)
+
+const (
+	// DefaultBufferSize is how many recent events are kept for resuming.
+	DefaultBufferSize = 1024
+	// subscriberQueue bounds each subscriber's undelivered events. A
+	// subscriber that falls further behind is dropped and resumes on reconnect.
+	subscriberQueue = 256
+)
+
+// Event is one published event.
+type Event struct {
+	ID        string          // "<boot>-<seq>", for SSE id / Last-Event-ID
+	Type      string          // Event type (SSE event name)
+	SessionID string          // Session the event is about; empty for server-wide events
+	Owner     string          // Owner of that session; empty for server-wide events
+	Data      json.RawMessage // JSON payload
+	Time      time.Time
+
+	seq uint64
+}
+
+// Filter selects the events a subscriber receives. Zero value matches all.
+type Filter struct {
+	// Owner limits session events to sessions owned by this user, like
+	// SessionRegistry.ListByOwner. Empty (auth disabled) sees every session.
+	// Server-wide events are always included.
+	Owner string
+	// SessionIDs limits events to these sessions and excludes server-wide
+	// events. Empty means every session.
+	SessionIDs []string
+	// Types limits events to these types. Empty means every type.
+	Types []string
+}
+
+// Match reports whether e passes the filter.
+func (f Filter) Match(e Event) bool {
+	if f.Owner != "" && e.Owner != "" && e.Owner != f.Owner {
+		return false
+	}
+	if len(f.SessionIDs) > 0 && !contains(f.SessionIDs, e.SessionID) {
+		return false
+	}
+	if len(f.Types) > 0 && !contains(f.Types, e.Type) {
+		return false
+	}
+	return true
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscription is one subscriber's live event feed.
+type Subscription struct {
+	// C receives matching events in order. It is closed when the
+	// subscription is cancelled or the subscriber fell too far behind.
+	C <-chan Event
+
+	c      chan Event
+	filter Filter
+	broker *Broker
+	once   sync.Once
+}
+
+// Cancel stops the subscription and closes C.
+func (s *Subscription) Cancel() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.remove(s)
+}
+
+// Broker publishes events to subscribers and keeps the most recent ones for
+// resuming. A nil *Broker discards events.
+type Broker struct {
+	boot string // distinguishes this process's event IDs from a previous run's
+
+	mu   sync.Mutex
+	ring []Event // recent events; once full, the oldest is at ring[seq%cap]
+	seq  uint64  // last assigned sequence number
+	subs map[*Subscription]struct{}
+	now  func() time.Time
+}
+
+// NewBroker creates a broker keeping the last size events (DefaultBufferSize
+// if size <= 0).
+func NewBroker(size int) *Broker {
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+	return &Broker{
+		boot: strconv.FormatInt(time.Now().UnixNano(), 36),
+		ring: make([]Event, 0, size),
+		subs: make(map[*Subscription]struct{}),
+		now:  time.Now,
+	}
+}
+
+// Publish sends an event to every matching subscriber. data is marshalled
+// to JSON once.
+func (b *Broker) Publish(eventType, sessionID, owner string, data any) {
+	if b == nil {
+		return
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("events: marshal %s: %v", eventType, err)
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	e := Event{
+		ID:        b.boot + "-" + strconv.FormatUint(b.seq, 10),
+		Type:      eventType,
+		SessionID: sessionID,
+		Owner:     owner,
+		Data:      payload,
+		Time:      b.now().UTC(),
+		seq:       b.seq,
+	}
+	if len(b.ring) < cap(b.ring) {
+		b.ring = append(b.ring, e)
+	} else {
+		b.ring[(b.seq-1)%uint64(cap(b.ring))] = e
+	}
+
+	for sub := range b.subs {
+		if !sub.filter.Match(e) {
+			continue
+		}
+		select {
+		case sub.c <- e:
+		default:
+			log.Printf("events: dropping subscriber %d events behind", len(sub.c))
+			b.remove(sub)
+		}
+	}
+}
+
+// Subscribe starts a subscription. If lastEventID is set, the buffered
+// events after it that match the filter are returned for replay. resumed
+// is false when lastEventID is older than the buffer or from a previous
+// server run; the replay then holds every buffered match and the
+// subscriber may have missed events.
+func (b *Broker) Subscribe(filter Filter, lastEventID string) (sub *Subscription, replay []Event, resumed bool) {
+	c := make(chan Event, subscriberQueue)
+	sub = &Subscription{C: c, c: c, filter: filter, broker: b}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs[sub] = struct{}{}
+
+	if lastEventID == "" {
+		return sub, nil, true
+	}
+	after, resumed := b.resumePoint(lastEventID)
+	for _, e := range b.buffered() {
+		if e.seq > after && filter.Match(e) {
+			replay = append(replay, e)
+		}
+	}
+	return sub, replay, resumed
+}
+
+// resumePoint returns the sequence number to replay after, and whether no
+// events between lastEventID and the buffer were lost. Caller must hold mu.
+func (b *Broker) resumePoint(lastEventID string) (uint64, bool) {
+	boot, seqStr, ok := strings.Cut(lastEventID, "-")
+	seq, err := strconv.ParseUint(seqStr, 10, 64)
+	if !ok || err != nil || boot != b.boot || seq > b.seq {
+		return 0, false
+	}
+	oldest := b.seq - uint64(len(b.ring)) + 1
+	if seq+1 < oldest {
+		return 0, false
+	}
+	return seq, true
+}
+
+// buffered returns the ring in publish order. Caller must hold mu.
+func (b *Broker) buffered() []Event {
+	if len(b.ring) < cap(b.ring) {
+		return b.ring
+	}
+	start := b.seq % uint64(cap(b.ring))
+	return append(append([]Event(nil), b.ring[start:]...), b.ring[:start]...)
+}
+
+// remove unsubscribes sub and closes its channel. Caller must hold mu.
+func (b *Broker) remove(sub *Subscription) {
+	delete(b.subs, sub)
+	sub.once.Do(func() { close(sub.c) })
+}
+
+// Subscribers returns the number of active subscriptions.
+func (b *Broker) Subscribers() int {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
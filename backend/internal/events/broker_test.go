@@ -0,0 +1,113 @@
+package events
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// drain returns "type/session" for each event waiting on sub.
+func drain(sub *Subscription) []string {
+	var out []string
+	for len(sub.C) > 0 {
+		e := <-sub.C
+		out = append(out, e.Type+"/"+e.SessionID)
+	}
+	return out
+}
+
+func TestBroker_FilterAndScope(t *testing.T) {
+	// Test Doc:
+	// - Why: /api/events must not leak another user's sessions, and dashboards only want some events
+	// - Contract: Owner scoping matches ListByOwner (own sessions only; empty owner sees all); server-wide events reach every owner unless a session filter is set; type filters are exact
+	// - Worked Example: alice filtered to cwd_update sees her s1 cwd_update only; a subscriber filtered to s2 sees bob's s2 event but not tmux_sessions
+
+	b := NewBroker(0)
+	alice, _, _ := b.Subscribe(Filter{Owner: "alice"}, "")
+	aliceCwd, _, _ := b.Subscribe(Filter{Owner: "alice", Types: []string{"cwd_update"}}, "")
+	s2Only, _, _ := b.Subscribe(Filter{SessionIDs: []string{"s2"}}, "")
+	everyone, _, _ := b.Subscribe(Filter{}, "")
+
+	b.Publish("tmux_sessions", "", "", []string{"work"})
+	b.Publish("cwd_update", "s1", "alice", map[string]string{"cwd": "/a"})
+	b.Publish("cwd_update", "s2", "bob", map[string]string{"cwd": "/b"})
+	b.Publish("plugin_data", "s1", "alice", nil)
+
+	for _, tc := range []struct {
+		name string
+		sub  *Subscription
+		want []string
+	}{
+		{"alice", alice, []string{"tmux_sessions/", "cwd_update/s1", "plugin_data/s1"}},
+		{"alice cwd_update", aliceCwd, []string{"cwd_update/s1"}},
+		{"session s2", s2Only, []string{"cwd_update/s2"}},
+		{"auth disabled", everyone, []string{"tmux_sessions/", "cwd_update/s1", "cwd_update/s2", "plugin_data/s1"}},
+	} {
+		if got := drain(tc.sub); !slices.Equal(got, tc.want) {
+			t.Errorf("%s got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	alice.Cancel()
+	if _, open := <-alice.C; open {
+		t.Error("channel still open after Cancel")
+	}
+	if got := b.Subscribers(); got != 3 {
+		t.Errorf("Subscribers() = %d, want 3", got)
+	}
+}
+
+func TestBroker_Resume(t *testing.T) {
+	// Test Doc:
+	// - Why: SSE clients reconnect with Last-Event-ID and must not miss or repeat events
+	// - Contract: Subscribe replays buffered matching events after lastEventID (resumed=true); an ID older than the buffer, from another server run or malformed replays the whole buffer with resumed=false
+	// - Worked Example: buffer 4, events 1..7 → resume after 4 replays 5..7; resume after 1 (evicted) replays 4..7, resumed=false
+
+	b := NewBroker(4)
+	for i := 1; i <= 7; i++ {
+		b.Publish(fmt.Sprintf("e%d", i), "", "", i)
+	}
+
+	_, replay, resumed := b.Subscribe(Filter{}, b.boot+"-4")
+	if got := types(replay); !resumed || !slices.Equal(got, []string{"e5", "e6", "e7"}) {
+		t.Errorf("resume after 4 = %v (resumed=%v), want [e5 e6 e7]", got, resumed)
+	}
+	_, replay, resumed = b.Subscribe(Filter{}, b.boot+"-7")
+	if !resumed || len(replay) != 0 {
+		t.Errorf("resume after latest = %v (resumed=%v), want nothing", types(replay), resumed)
+	}
+	for _, stale := range []string{b.boot + "-1", "oldboot-5", "garbage"} {
+		_, replay, resumed = b.Subscribe(Filter{}, stale)
+		if got := types(replay); resumed || !slices.Equal(got, []string{"e4", "e5", "e6", "e7"}) {
+			t.Errorf("resume after %q = %v (resumed=%v), want whole buffer, resumed=false", stale, got, resumed)
+		}
+	}
+}
+
+func TestBroker_SlowSubscriberDropped(t *testing.T) {
+	// Test Doc:
+	// - Why: One stalled SSE client must not block publishers or grow memory without bound
+	// - Contract: A subscriber more than subscriberQueue events behind is removed and its channel closed after the queued events
+
+	b := NewBroker(0)
+	slow, _, _ := b.Subscribe(Filter{}, "")
+	for i := 0; i <= subscriberQueue; i++ {
+		b.Publish("tick", "", "", i)
+	}
+	received := 0
+	for range slow.C {
+		received++
+	}
+	if received != subscriberQueue || b.Subscribers() != 0 {
+		t.Errorf("received %d then closed with %d subscribers, want %d and 0", received, b.Subscribers(), subscriberQueue)
+	}
+}
+
+// types returns the event types in order.
+func types(events []Event) []string {
+	out := make([]string, len(events))
+	for i, e := range events {
+		out[i] = e.Type
+	}
+	return out
+}
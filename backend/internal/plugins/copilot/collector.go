@@ -7,6 +7,9 @@ import (
 	"github.com/vaughanknight/trex/internal/terminal"
 )
 
+// CollectorID is the plugin ID the collector's data is sent under.
+const CollectorID = "copilot-todos"
+
 // Collector implements terminal.DataCollector for Copilot CLI todo tracking.
 type Collector struct{}
 
@@ -17,7 +20,7 @@ func NewCollector() *Collector {
 	return &Collector{}
 }
 
-func (c *Collector) ID() string { return "copilot-todos" }
+func (c *Collector) ID() string { return CollectorID }
 
 func (c *Collector) ProcessMatch(processes []string) bool {
 	for _, p := range processes {
@@ -40,3 +43,20 @@ func (c *Collector) CollectForSession(pid int, cwd string) (json.RawMessage, err
 func (c *Collector) Interval() time.Duration {
 	return 3 * time.Second
 }
+
+// Status is the part of a Copilot session's state that event consumers
+// react to: its workflow phase and status line.
+type Status struct {
+	Phase  string `json:"phase"`
+	Status string `json:"status"`
+}
+
+// StatusOf extracts the Status from collected plugin data. Returns false
+// when the data has no plan context.
+func StatusOf(data json.RawMessage) (Status, bool) {
+	var cd CopilotData
+	if err := json.Unmarshal(data, &cd); err != nil || cd.Context == nil {
+		return Status{}, false
+	}
+	return Status{Phase: cd.Context.WorkflowPhase, Status: cd.Context.Status}, true
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/vaughanknight/trex/internal/audit"
+	"github.com/vaughanknight/trex/internal/auth"
+	"github.com/vaughanknight/trex/internal/terminal"
+)
+
+// recordSessionEvent appends a session lifecycle event to the audit log.
+// detail may be nil. No-op when auditing is disabled.
+func recordSessionEvent(l *audit.Logger, eventType, actor, remote string, session *terminal.Session, detail map[string]string) {
+	if l == nil {
+		return
+	}
+	if detail == nil {
+		detail = make(map[string]string)
+	}
+	if session.Owner != "" && session.Owner != actor {
+		detail["owner"] = session.Owner
+	}
+	if session.TmuxSessionName != "" {
+		detail["tmuxSession"] = session.TmuxSessionName
+	}
+	if session.TmuxHost != "" {
+		detail["tmuxHost"] = session.TmuxHost
+	}
+	if session.TmuxSocket != "" {
+		detail["tmuxSocket"] = session.TmuxSocket
+	}
+	if len(detail) == 0 {
+		detail = nil
+	}
+	l.Record(audit.Event{
+		Type:      eventType,
+		Actor:     actor,
+		SessionID: session.ID,
+		Remote:    remote,
+		Outcome:   audit.OutcomeSuccess,
+		Detail:    detail,
+	})
+}
+
+// recordSession appends a session lifecycle event attributed to this connection.
+func (h *connectionHandler) recordSession(eventType string, session *terminal.Session, detail map[string]string) {
+	if h.server == nil {
+		return
+	}
+	recordSessionEvent(h.server.auditLog, eventType, h.username(), h.remoteAddr, session, detail)
+}
+
+// handleAudit handles GET /api/audit for admins. Supported query parameters:
+// type (exact, or prefix ending in "."), actor, session, since and until
+// (RFC 3339), and limit (default 500, max 5000).
+func (s *Server) handleAudit() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var username string
+		if user := auth.UserFromContext(r.Context()); user != nil {
+			username = user.Username
+		}
+		if !s.config.IsAdmin(username) {
+			http.Error(w, "admin access required", http.StatusForbidden)
+			return
+		}
+
+		if s.auditLog == nil {
+			http.Error(w, "audit log not enabled", http.StatusNotFound)
+			return
+		}
+
+		q := r.URL.Query()
+		filter := audit.Filter{
+			Type:      q.Get("type"),
+			Actor:     q.Get("actor"),
+			SessionID: q.Get("session"),
+			Limit:     500,
+		}
+		for param, dst := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+			if v := q.Get(param); v != "" {
+				t, err := time.Parse(time.RFC3339, v)
+				if err != nil {
+					http.Error(w, "invalid "+param+": must be RFC 3339", http.StatusBadRequest)
+					return
+				}
+				*dst = t
+			}
+		}
+		if v := q.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+			filter.Limit = min(n, 5000)
+		}
+
+		events, err := s.auditLog.Query(filter)
+		if err != nil {
+			log.Printf("Audit query error: %v", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(events); err != nil {
+			log.Printf("Failed to encode audit events: %v", err)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/vaughanknight/trex/internal/audit"
+	"github.com/vaughanknight/trex/internal/auth"
+	"github.com/vaughanknight/trex/internal/config"
+	"github.com/vaughanknight/trex/internal/terminal"
+)
+
+// Test Doc:
+// - Why: Admins query who did what through /api/audit
+// - Contract: Admin-only; filters by type/actor/session; REST deletions are recorded as session.close
+// - Usage Notes: Handlers are exercised directly with a user injected via auth.WithUser
+// - Quality Contribution: Ensures non-admins can't read the audit trail
+// - Worked Example: DELETE /api/sessions/s1 by alice → GET /api/audit?type=session.close returns one event
+
+func newAuditTestServer(t *testing.T) *Server {
+	t.Helper()
+	srv := New("test-version", &config.Config{
+		BindAddress:  "127.0.0.1:0",
+		AuthEnabled:  true,
+		JWTSecret:    "test-secret-audit",
+		AuditLogPath: filepath.Join(t.TempDir(), "audit.log"),
+		AdminUsers:   []string{"root"},
+	})
+	t.Cleanup(srv.Shutdown)
+	return srv
+}
+
+func TestAudit_RequiresAdmin(t *testing.T) {
+	srv := newAuditTestServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/audit", nil)
+	req = req.WithContext(auth.WithUser(req.Context(), &auth.GitHubUser{Username: "alice"}))
+	rec := httptest.NewRecorder()
+	srv.handleAudit().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+func TestAudit_RecordsRESTDeleteAndFilters(t *testing.T) {
+	srv := newAuditTestServer(t)
+
+	session := terminal.NewSessionWithConn("s1", terminal.NewFakePTY(), terminal.NewFakeWebSocket())
+	session.Owner = "alice"
+	srv.registry.Add(session)
+
+	del := httptest.NewRequest(http.MethodDelete, "/api/sessions/s1", nil)
+	del = del.WithContext(auth.WithUser(del.Context(), &auth.GitHubUser{Username: "alice"}))
+	handleSessionDelete(srv.registry, srv.auditLog).ServeHTTP(httptest.NewRecorder(), del)
+
+	srv.auditLog.Record(audit.Event{Type: audit.EventLogin, Actor: "bob"})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/audit?type=session.close&actor=alice", nil)
+	req = req.WithContext(auth.WithUser(req.Context(), &auth.GitHubUser{Username: "root"}))
+	rec := httptest.NewRecorder()
+	srv.handleAudit().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	var events []audit.Event
+	if err := json.Unmarshal(rec.Body.Bytes(), &events); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("events = %d, want 1", len(events))
+	}
+	if events[0].SessionID != "s1" || events[0].Detail["via"] != "rest" {
+		t.Errorf("event = %+v, want session.close for s1 via rest", events[0])
+	}
+}
+
+func TestAudit_RejectsBadTime(t *testing.T) {
+	srv := newAuditTestServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/audit?since=yesterday", nil)
+	req = req.WithContext(auth.WithUser(req.Context(), &auth.GitHubUser{Username: "root"}))
+	rec := httptest.NewRecorder()
+	srv.handleAudit().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
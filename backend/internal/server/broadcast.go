@@ -0,0 +1,144 @@
+package server
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/vaughanknight/trex/internal/terminal"
+)
+
+// maxBroadcastTargets caps how many sessions one broadcast or sync group reaches.
+const maxBroadcastTargets = 64
+
+var (
+	errSessionNotFound  = errors.New("session not found")
+	errPermissionDenied = errors.New("permission denied")
+	errNoTargets        = errors.New("no target sessions")
+	errTooManyTargets   = errors.New("too many target sessions")
+)
+
+// writeInput writes data to a session this connection may write to: one it
+// owns, or one attached with collaborator permission. Input is recorded
+// against the user for input audit.
+func (h *connectionHandler) writeInput(sessionID, data string) error {
+	session := h.getSession(sessionID)
+	if session == nil {
+		return errSessionNotFound
+	}
+	if !h.canWrite(session) {
+		return errPermissionDenied
+	}
+	session.WriteInputFrom(h.username(), data)
+	return nil
+}
+
+// inputTargets resolves a broadcast_input or sync_input target group: the
+// message's sessionIds plus the live sessions in its workspace (see
+// Server.workspaceSessions), without duplicates.
+func (h *connectionHandler) inputTargets(msg *terminal.ClientMessage) ([]string, error) {
+	var ids []string
+	if msg.WorkspaceId != "" {
+		if h.server == nil || h.server.workspaces == nil {
+			return nil, errNoTargets
+		}
+		ws, err := h.server.workspaces.Get(h.username(), msg.WorkspaceId)
+		if err != nil {
+			return nil, err
+		}
+		for _, session := range h.server.workspaceSessions(ws) {
+			if !slices.Contains(ids, session.ID) {
+				ids = append(ids, session.ID)
+			}
+		}
+	}
+	for _, id := range msg.SessionIds {
+		if id != "" && !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) > maxBroadcastTargets {
+		return nil, errTooManyTargets
+	}
+	return ids, nil
+}
+
+// handleBroadcastInput writes the same data once to each target session.
+// Targets this connection can't write to get an error each; the rest still
+// receive the input.
+func (h *connectionHandler) handleBroadcastInput(msg *terminal.ClientMessage) {
+	targets, err := h.inputTargets(msg)
+	if err == nil && len(targets) == 0 {
+		err = errNoTargets
+	}
+	if err != nil {
+		h.replyError(msg, "", errorCode(err), err.Error())
+		return
+	}
+
+	h.touchInput()
+	for _, id := range targets {
+		if err := h.writeInput(id, msg.Data); err != nil {
+			h.replyError(msg, id, errorCode(err), err.Error())
+		}
+	}
+}
+
+// handleSyncInput sets the group that input to msg.SessionId is mirrored to,
+// like tmux synchronize-panes across trex sessions. No targets turns
+// mirroring off. Only targets this connection can write to are kept; the
+// reply lists them.
+func (h *connectionHandler) handleSyncInput(msg *terminal.ClientMessage) {
+	source := h.getSession(msg.SessionId)
+	if source == nil {
+		h.replyError(msg, msg.SessionId, terminal.ErrCodeNotFound, errSessionNotFound.Error())
+		return
+	}
+	if !h.canWrite(source) {
+		h.replyError(msg, msg.SessionId, terminal.ErrCodeForbidden, errPermissionDenied.Error())
+		return
+	}
+	targets, err := h.inputTargets(msg)
+	if err != nil {
+		h.replyError(msg, msg.SessionId, errorCode(err), err.Error())
+		return
+	}
+
+	var group []string
+	for _, id := range targets {
+		if id == source.ID {
+			continue
+		}
+		session := h.getSession(id)
+		if session == nil || !h.canWrite(session) {
+			continue
+		}
+		group = append(group, id)
+	}
+
+	h.mu.Lock()
+	if len(group) == 0 {
+		delete(h.syncGroups, source.ID)
+	} else {
+		h.syncGroups[source.ID] = group
+	}
+	h.mu.Unlock()
+
+	h.reply(msg, terminal.ServerMessage{
+		Type:       terminal.MsgTypeSyncStatus,
+		SessionId:  source.ID,
+		SessionIds: group,
+	})
+}
+
+// mirrorInput writes input sent to source to its sync group. Permission is
+// checked again on every write, so a revoked share or closed session simply
+// stops receiving input.
+func (h *connectionHandler) mirrorInput(source, data string) {
+	h.mu.Lock()
+	group := h.syncGroups[source]
+	h.mu.Unlock()
+
+	for _, id := range group {
+		h.writeInput(id, data)
+	}
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/vaughanknight/trex/internal/terminal"
+)
+
+// startSession creates a session on conn and starts its shell. The shell is
+// /bin/sh rather than $SHELL, so it is ready for input at once instead of
+// after the user's rc files (an interactive bash can take seconds).
+func startSession(t *testing.T, conn *websocket.Conn) string {
+	t.Helper()
+	t.Setenv("SHELL", "/bin/sh")
+	sendMsg(t, conn, terminal.ClientMessage{Type: terminal.MsgTypeCreate})
+	id := readUntil(t, conn, ofType(terminal.MsgTypeSessionCreated)).SessionId
+	sendMsg(t, conn, terminal.ClientMessage{Type: terminal.MsgTypeResize, SessionId: id, Cols: 100, Rows: 30})
+	return id
+}
+
+// waitForOutput reads until every session in ids has printed marker.
+func waitForOutput(t *testing.T, conn *websocket.Conn, marker string, ids ...string) {
+	t.Helper()
+	pending := slices.Clone(ids)
+	readUntil(t, conn, func(m terminal.ServerMessage) bool {
+		if m.Type == terminal.MsgTypeOutput && strings.Contains(m.Data, marker) {
+			pending = slices.DeleteFunc(pending, func(id string) bool { return id == m.SessionId })
+		}
+		return len(pending) == 0
+	})
+}
+
+// Test Doc:
+// - Why: Running the same command in several agent terminals at once (tmux synchronize-panes across trex sessions)
+// - Contract: broadcast_input writes Data to every target the caller can write to and errors per rejected target; sync_input mirrors a session's input to the writable part of a group until turned off
+// - Usage Notes: Auth enabled so ownership is enforced; bob's session is the one alice may not write to
+// - Quality Contribution: Guards both the fan-out and the ownership check on every target
+// - Worked Example: alice broadcasts to [bob's s1 s2] → s1 and s2 echo, bob's → "permission denied"; sync s1 → [s2] mirrors input
+func TestBroadcastInput(t *testing.T) {
+	const secret = "test-secret-broadcast"
+	_, ts := newAuthTestServer(t, secret)
+
+	alice := dialAs(t, ts.URL, secret, "alice")
+	defer alice.Close()
+	bob := dialAs(t, ts.URL, secret, "bob")
+	defer bob.Close()
+
+	s1 := startSession(t, alice)
+	s2 := startSession(t, alice)
+	bobSession := startSession(t, bob)
+
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeBroadcastInput, SessionIds: []string{bobSession, s1, s2}, Data: "echo bcast-$((6*7))\r"})
+	rejected := readUntil(t, alice, func(m terminal.ServerMessage) bool {
+		return m.Type == terminal.MsgTypeError && m.SessionId == bobSession
+	})
+	if rejected.Error != "permission denied" {
+		t.Errorf("broadcast to bob's session error = %q, want %q", rejected.Error, "permission denied")
+	}
+	waitForOutput(t, alice, "bcast-42", s1, s2)
+
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeSyncInput, SessionId: s1, SessionIds: []string{s1, s2, bobSession}})
+	status := readUntil(t, alice, ofType(terminal.MsgTypeSyncStatus))
+	if status.SessionId != s1 || !slices.Equal(status.SessionIds, []string{s2}) {
+		t.Fatalf("sync_status = %s → %v, want %s → [%s]", status.SessionId, status.SessionIds, s1, s2)
+	}
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeInput, SessionId: s1, Data: "echo sync-$((6*7))\r"})
+	waitForOutput(t, alice, "sync-42", s1, s2)
+
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeSyncInput, SessionId: s1})
+	if status := readUntil(t, alice, ofType(terminal.MsgTypeSyncStatus)); len(status.SessionIds) != 0 {
+		t.Errorf("sync off status = %v, want no sessions", status.SessionIds)
+	}
+
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeBroadcastInput, WorkspaceId: "missing", Data: "x"})
+	if msg := readUntil(t, alice, ofType(terminal.MsgTypeError)); msg.Error != "workspace not found" {
+		t.Errorf("unknown workspace error = %q, want %q", msg.Error, "workspace not found")
+	}
+}
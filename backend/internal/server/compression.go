@@ -0,0 +1,96 @@
+package server
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// CompressionStats describes permessage-deflate on /ws for diagnostics.
+// Only connections that negotiated compression are counted.
+type CompressionStats struct {
+	Enabled      bool    `json:"enabled"`      // Compression is offered to clients (level > 0)
+	Level        int     `json:"level"`        // flate compression level
+	Threshold    int     `json:"threshold"`    // Smallest message compressed, in bytes
+	Connections  int64   `json:"connections"`  // Connections that negotiated compression
+	PayloadBytes int64   `json:"payloadBytes"` // Message bytes sent before compression
+	WireBytes    int64   `json:"wireBytes"`    // Bytes written to those sockets, after framing and compression
+	Ratio        float64 `json:"ratio"`        // PayloadBytes / WireBytes (0 until something is sent)
+}
+
+// compressionCounters accumulates CompressionStats across connections.
+type compressionCounters struct {
+	connections  atomic.Int64
+	payloadBytes atomic.Int64
+	wireBytes    atomic.Int64
+}
+
+// wsCompression returns the configured compression level and threshold.
+// Level 0 means compression is off.
+func (s *Server) wsCompression() (level, threshold int) {
+	if s.config == nil {
+		return 0, 0
+	}
+	return s.config.WSCompressionLevel, s.config.WSCompressionThreshold
+}
+
+// compressionStats returns the compression counters for diagnostics.
+func (s *Server) compressionStats() CompressionStats {
+	level, threshold := s.wsCompression()
+	stats := CompressionStats{
+		Enabled:      level > 0,
+		Level:        level,
+		Threshold:    threshold,
+		Connections:  s.compression.connections.Load(),
+		PayloadBytes: s.compression.payloadBytes.Load(),
+		WireBytes:    s.compression.wireBytes.Load(),
+	}
+	if stats.WireBytes > 0 {
+		stats.Ratio = float64(stats.PayloadBytes) / float64(stats.WireBytes)
+	}
+	return stats
+}
+
+// offersDeflate reports whether the upgrade request offers
+// permessage-deflate, which the upgrader then accepts.
+func offersDeflate(r *http.Request) bool {
+	for _, header := range r.Header.Values("Sec-WebSocket-Extensions") {
+		for _, ext := range strings.Split(header, ",") {
+			name, _, _ := strings.Cut(ext, ";")
+			if strings.EqualFold(strings.TrimSpace(name), "permessage-deflate") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// countingResponseWriter hands the upgrader a hijacked connection that
+// counts the bytes written to it.
+type countingResponseWriter struct {
+	http.ResponseWriter
+	written *atomic.Int64
+}
+
+// Hijack implements http.Hijacker.
+func (w countingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	return &countingConn{Conn: conn, written: w.written}, brw, nil
+}
+
+// countingConn is a net.Conn that adds the bytes it writes to written.
+type countingConn struct {
+	net.Conn
+	written *atomic.Int64
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.written.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/vaughanknight/trex/internal/config"
+	"github.com/vaughanknight/trex/internal/terminal"
+)
+
+// tapConn records everything read from the server.
+type tapConn struct {
+	net.Conn
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (c *tapConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.mu.Lock()
+	c.buf.Write(p[:n])
+	c.mu.Unlock()
+	return n, err
+}
+
+// frames splits the recorded server stream (after the HTTP response) into
+// frames and reports, for each, whether RSV1 (compressed) was set.
+func (c *tapConn) frames() []bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data := c.buf.Bytes()
+	_, data, _ = bytes.Cut(data, []byte("\r\n\r\n"))
+	var compressed []bool
+	for len(data) >= 2 {
+		rsv1 := data[0]&0x40 != 0
+		n, header := uint64(data[1]&0x7f), 2
+		switch n {
+		case 126:
+			if len(data) < 4 {
+				return compressed
+			}
+			n, header = uint64(binary.BigEndian.Uint16(data[2:])), 4
+		case 127:
+			if len(data) < 10 {
+				return compressed
+			}
+			n, header = binary.BigEndian.Uint64(data[2:]), 10
+		}
+		if uint64(len(data)-header) < n {
+			return compressed
+		}
+		compressed = append(compressed, rsv1)
+		data = data[header+int(n):]
+	}
+	return compressed
+}
+
+// Test Doc:
+// - Why: Remote users on slow links get laggy output from verbose JSON full of ANSI escapes
+// - Contract: A client offering permessage-deflate gets messages at or above the threshold compressed and smaller ones sent as-is; diagnostics count payload vs wire bytes for a ratio above 1 on repetitive output
+// - Usage Notes: The client socket is tapped to read RSV1 on each raw frame, since gorilla hides it; session_created is below the 256-byte threshold
+// - Worked Example: 4 KB of repeated "trex" → one compressed output frame; session_created → uncompressed; ratio > 1
+func TestCompression_ThresholdAndStats(t *testing.T) {
+	srv := New("test-version", &config.Config{
+		BindAddress:            "127.0.0.1:0",
+		WSCompressionLevel:     1,
+		WSCompressionThreshold: 256,
+	})
+	ts := httptest.NewServer(srv)
+	t.Cleanup(func() {
+		ts.Close()
+		srv.Shutdown()
+	})
+
+	var tap *tapConn
+	dialer := websocket.Dialer{
+		EnableCompression: true,
+		NetDialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+			if err == nil {
+				tap = &tapConn{Conn: conn}
+				return tap, nil
+			}
+			return conn, err
+		},
+	}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("WebSocket dial error: %v", err)
+	}
+	defer conn.Close()
+
+	id := startSession(t, conn)
+	sendMsg(t, conn, terminal.ClientMessage{Type: terminal.MsgTypeInput, SessionId: id, Data: "printf 'trex%.0s' $(seq 1000); echo; echo done$((6*7))\r"})
+	waitForOutput(t, conn, "done42", id)
+
+	var small, large int
+	for _, compressed := range tap.frames() {
+		if compressed {
+			large++
+		} else {
+			small++
+		}
+	}
+	if small == 0 || large == 0 {
+		t.Errorf("frames: %d uncompressed, %d compressed; want both", small, large)
+	}
+
+	stats := srv.compressionStats()
+	if !stats.Enabled || stats.Connections != 1 || stats.WireBytes == 0 || stats.Ratio <= 1 {
+		t.Errorf("compression stats = %+v, want 1 connection with ratio > 1", stats)
+	}
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/vaughanknight/trex/internal/auth"
+	"github.com/vaughanknight/trex/internal/webhook"
+)
+
+// DiagnosticsResponse is the admin-only runtime diagnostics payload.
+type DiagnosticsResponse struct {
+	Sessions    int                 `json:"sessions"`    // Sessions in the registry
+	RateLimit   auth.RateLimitStats `json:"rateLimit"`   // Auth endpoint throttling counters (zero when auth disabled)
+	Webhooks    webhook.Stats       `json:"webhooks"`    // Webhook delivery counters (zero when webhooks are disabled)
+	Compression CompressionStats    `json:"compression"` // WebSocket permessage-deflate counters
+}
+
+// handleDiagnostics handles GET /api/diagnostics for admins.
+func (s *Server) handleDiagnostics() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var username string
+		if user := auth.UserFromContext(r.Context()); user != nil {
+			username = user.Username
+		}
+		if !s.config.IsAdmin(username) {
+			http.Error(w, "admin access required", http.StatusForbidden)
+			return
+		}
+
+		resp := DiagnosticsResponse{
+			Sessions:    s.registry.Count(),
+			RateLimit:   s.limiter.Stats(),
+			Webhooks:    s.webhooks.Stats(),
+			Compression: s.compressionStats(),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Printf("Failed to encode diagnostics: %v", err)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vaughanknight/trex/internal/auth"
+	"github.com/vaughanknight/trex/internal/config"
+)
+
+// Test Doc:
+// - Why: Operators need to see auth throttling in action
+// - Contract: /api/diagnostics is admin-only and reports rate limiter counters; /ws and /auth/refresh are throttled per IP
+// - Worked Example: burst 2 → third unauthenticated /ws upgrade gets 429; diagnostics shows throttled=1
+
+func TestDiagnostics_RateLimitCounters(t *testing.T) {
+	srv := New("test-version", &config.Config{
+		BindAddress:          "127.0.0.1:0",
+		AuthEnabled:          true,
+		JWTSecret:            "test-secret-diag",
+		AdminUsers:           []string{"root"},
+		AuthRateLimit:        1,
+		AuthRateBurst:        2,
+		AuthLockoutThreshold: 100,
+		AuthLockoutWindow:    time.Minute,
+		AuthLockoutDuration:  time.Minute,
+	})
+	t.Cleanup(srv.Shutdown)
+
+	codes := make([]int, 3)
+	for i := range codes {
+		req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+		req.RemoteAddr = "192.0.2.1:4000"
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		codes[i] = rec.Code
+	}
+	if codes[0] != http.StatusUnauthorized || codes[1] != http.StatusUnauthorized {
+		t.Errorf("first upgrades = %v, want 401 (no token)", codes[:2])
+	}
+	if codes[2] != http.StatusTooManyRequests {
+		t.Errorf("third upgrade = %d, want 429", codes[2])
+	}
+
+	// Non-admins are refused
+	req := httptest.NewRequest(http.MethodGet, "/api/diagnostics", nil)
+	req = req.WithContext(auth.WithUser(req.Context(), &auth.GitHubUser{Username: "alice"}))
+	rec := httptest.NewRecorder()
+	srv.handleDiagnostics().ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("non-admin status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/diagnostics", nil)
+	req = req.WithContext(auth.WithUser(req.Context(), &auth.GitHubUser{Username: "root"}))
+	rec = httptest.NewRecorder()
+	srv.handleDiagnostics().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("admin status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var resp DiagnosticsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if resp.RateLimit.Allowed != 2 || resp.RateLimit.Throttled != 1 || resp.RateLimit.Failures != 0 {
+		t.Errorf("rateLimit = %+v, want 2 allowed, 1 throttled, no failures from token-less upgrades", resp.RateLimit)
+	}
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/vaughanknight/trex/internal/auth"
+	"github.com/vaughanknight/trex/internal/events"
+	"github.com/vaughanknight/trex/internal/terminal"
+	"github.com/vaughanknight/trex/internal/webhook"
+)
+
+const (
+	// sseHeartbeat is how often an idle event stream sends a comment, so
+	// proxies don't time it out and dead clients are noticed.
+	sseHeartbeat = 15 * time.Second
+	// sseRetry is the reconnect delay suggested to EventSource clients.
+	sseRetry = 3 * time.Second
+)
+
+// watchSession forwards the session's cwd_update, plugin_data and exit to
+// the event stream and webhooks. Called from initSession, before RunReadPTY
+// starts.
+func (s *Server) watchSession(session *terminal.Session) {
+	session.OnEvent(func(msg terminal.ServerMessage) {
+		s.eventStream.Publish(msg.Type, session.ID, session.Owner, msg)
+	})
+	session.OnExit(func(code int) {
+		s.eventStream.Publish(events.TypeSessionExited, session.ID, session.Owner, map[string]any{"sessionId": session.ID, "exitCode": code})
+		if s.webhooks != nil {
+			data := sessionEventData(session)
+			data["exitCode"] = code
+			s.webhooks.Publish(webhook.EventSessionExited, data)
+		}
+
+		s.hookState.mu.Lock()
+		delete(s.hookState.pluginStatus, session.ID)
+		s.hookState.mu.Unlock()
+	})
+}
+
+// publishSessionCreated sends session_created to the event stream and
+// session.created to webhooks.
+func (h *connectionHandler) publishSessionCreated(session *terminal.Session) {
+	if h.server == nil {
+		return
+	}
+	h.server.eventStream.Publish(events.TypeSessionCreated, session.ID, session.Owner, session.Info())
+	h.server.webhooks.Publish(webhook.EventSessionCreated, sessionEventData(session))
+}
+
+// handleEvents handles GET /api/events, a Server-Sent Events stream of
+// tmux_sessions, tmux_status, cwd_update, plugin_data, session_created and
+// session_exited. Query parameters: session and type (repeated or
+// comma-separated) filter the stream. Session events are limited to the
+// caller's own sessions, like ListByOwner. Last-Event-ID (or lastEventId)
+// resumes after that event; a resync event is sent first if events may have
+// been missed.
+func (s *Server) handleEvents() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		var username string
+		if user := auth.UserFromContext(r.Context()); user != nil {
+			username = user.Username
+		}
+		query := r.URL.Query()
+		filter := events.Filter{
+			Owner:      username,
+			SessionIDs: splitParams(query["session"]),
+			Types:      splitParams(query["type"]),
+		}
+		lastEventID := r.Header.Get("Last-Event-ID")
+		if lastEventID == "" {
+			lastEventID = query.Get("lastEventId")
+		}
+
+		sub, replay, resumed := s.eventStream.Subscribe(filter, lastEventID)
+		defer sub.Cancel()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no") // Stop nginx buffering the stream
+		w.WriteHeader(http.StatusOK)
+
+		fmt.Fprintf(w, "retry: %d\n\n", sseRetry.Milliseconds())
+		if !resumed {
+			fmt.Fprint(w, "event: resync\ndata: {\"reason\":\"lastEventId not in buffer\"}\n\n")
+		}
+		for _, e := range replay {
+			if err := writeSSE(w, e); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(sseHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-s.ctx.Done():
+				return
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+					return
+				}
+			case e, ok := <-sub.C:
+				if !ok {
+					// Too far behind: the client reconnects and resumes from the buffer
+					log.Printf("Event stream for %q dropped (slow client)", username)
+					return
+				}
+				if err := writeSSE(w, e); err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeSSE writes one event in text/event-stream format. Data is
+// single-line JSON.
+func writeSSE(w http.ResponseWriter, e events.Event) error {
+	_, err := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data)
+	return err
+}
+
+// splitParams flattens repeated and comma-separated query values.
+func splitParams(values []string) []string {
+	var out []string
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				out = append(out, part)
+			}
+		}
+	}
+	return out
+}
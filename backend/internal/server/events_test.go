@@ -0,0 +1,120 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vaughanknight/trex/internal/auth"
+	"github.com/vaughanknight/trex/internal/events"
+	"github.com/vaughanknight/trex/internal/terminal"
+)
+
+// sseEvent is one parsed text/event-stream event.
+type sseEvent struct {
+	id, event, data string
+}
+
+// openEvents opens GET /api/events as username and returns a channel of its
+// events. The stream is closed when the test ends.
+func openEvents(t *testing.T, serverURL, secret, username, query, lastEventID string) <-chan sseEvent {
+	t.Helper()
+	token, _ := auth.NewJWTService(secret).GenerateAccessToken(&auth.GitHubUser{Username: username})
+	req, _ := http.NewRequest(http.MethodGet, serverURL+"/api/events"+query, nil)
+	req.Header.Set("Cookie", "trex_access_token="+token)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /api/events: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("GET /api/events = %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	ch := make(chan sseEvent, 16)
+	go func() {
+		defer close(ch)
+		scanner := bufio.NewScanner(resp.Body)
+		var e sseEvent
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				if e.event != "" {
+					ch <- e
+				}
+				e = sseEvent{}
+			case strings.HasPrefix(line, "id: "):
+				e.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				e.event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				e.data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}()
+	return ch
+}
+
+// nextEvent returns the next event, failing after three seconds.
+func nextEvent(t *testing.T, ch <-chan sseEvent) sseEvent {
+	t.Helper()
+	select {
+	case e, ok := <-ch:
+		if !ok {
+			t.Fatal("event stream closed")
+		}
+		return e
+	case <-time.After(3 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+	return sseEvent{}
+}
+
+// Test Doc:
+// - Why: Dashboards and scripts want session events without speaking the multiplexed /ws protocol
+// - Contract: GET /api/events streams SSE with id/event/data; the type filter applies; session events are scoped to the caller's own sessions; Last-Event-ID replays what was missed, and an unknown ID starts with resync
+// - Usage Notes: Auth enabled so owner scoping applies; bob's session is created first so a leak would arrive first
+// - Worked Example: alice streams session_created,session_exited → her create and exit only; resuming after the create replays the exit
+func TestEvents_StreamScopeAndResume(t *testing.T) {
+	const secret = "test-secret-events"
+	_, ts := newAuthTestServer(t, secret)
+
+	stream := openEvents(t, ts.URL, secret, "alice", "?type=session_created,session_exited", "")
+
+	bob := dialAs(t, ts.URL, secret, "bob")
+	defer bob.Close()
+	startSession(t, bob)
+	alice := dialAs(t, ts.URL, secret, "alice")
+	defer alice.Close()
+	id := startSession(t, alice)
+
+	created := nextEvent(t, stream)
+	var info terminal.SessionInfo
+	json.Unmarshal([]byte(created.data), &info)
+	if created.event != events.TypeSessionCreated || info.ID != id || info.Owner != "alice" || created.id == "" {
+		t.Fatalf("first event = %+v, want alice's session_created for %s", created, id)
+	}
+
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeInput, SessionId: id, Data: "exit 0\r"})
+	exited := nextEvent(t, stream)
+	if exited.event != events.TypeSessionExited || !strings.Contains(exited.data, `"sessionId":"`+id+`"`) {
+		t.Fatalf("second event = %+v, want session_exited for %s", exited, id)
+	}
+
+	resumed := openEvents(t, ts.URL, secret, "alice", "?type=session_exited", created.id)
+	if e := nextEvent(t, resumed); e.id != exited.id {
+		t.Errorf("resumed stream started with %+v, want replayed %s", e, exited.id)
+	}
+
+	stale := openEvents(t, ts.URL, secret, "alice", "", "previous-run-42")
+	if e := nextEvent(t, stale); e.event != "resync" {
+		t.Errorf("stale Last-Event-ID started with %+v, want resync", e)
+	}
+}
@@ -0,0 +1,230 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/vaughanknight/trex/internal/audit"
+	"github.com/vaughanknight/trex/internal/auth"
+	"github.com/vaughanknight/trex/internal/terminal"
+)
+
+// unlockTicketTTL bounds how long a ticket from POST /api/unlock can be redeemed.
+const unlockTicketTTL = time.Minute
+
+// unlockTicket binds a single-use ticket to the user and login time it was issued for.
+type unlockTicket struct {
+	username string
+	authTime time.Time
+	expires  time.Time
+}
+
+// unlockTicketStore hands out single-use tickets that carry a fresh login
+// from the HTTP cookie flow over to an existing (locked) WebSocket, which
+// can't see cookies set after the upgrade. Thread-safe.
+type unlockTicketStore struct {
+	mu      sync.Mutex
+	tickets map[string]unlockTicket
+}
+
+func newUnlockTicketStore() *unlockTicketStore {
+	return &unlockTicketStore{tickets: make(map[string]unlockTicket)}
+}
+
+// issue creates a ticket for username's login at authTime.
+func (s *unlockTicketStore) issue(username string, authTime time.Time) (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	ticket := hex.EncodeToString(b)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for k, t := range s.tickets {
+		if now.After(t.expires) {
+			delete(s.tickets, k)
+		}
+	}
+	s.tickets[ticket] = unlockTicket{username: username, authTime: authTime, expires: now.Add(unlockTicketTTL)}
+	return ticket, nil
+}
+
+// redeem consumes a ticket. Returns false if it is unknown or expired.
+func (s *unlockTicketStore) redeem(ticket string) (unlockTicket, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tickets[ticket]
+	delete(s.tickets, ticket)
+	if !ok || time.Now().After(t.expires) {
+		return unlockTicket{}, false
+	}
+	return t, true
+}
+
+// handleUnlock handles POST /api/unlock. After signing in again (the OAuth
+// flow, typically in a popup so the locked tab keeps its socket) the client
+// exchanges its fresh access token cookie for a ticket and sends it in an
+// unlock message.
+func (s *Server) handleUnlock() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		user := auth.UserFromContext(r.Context())
+		if user == nil {
+			http.Error(w, "idle lock requires auth", http.StatusNotFound)
+			return
+		}
+
+		ticket, err := s.unlockTickets.issue(user.Username, user.AuthTime)
+		if err != nil {
+			log.Printf("Unlock ticket error: %v", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"ticket": ticket})
+	}
+}
+
+// touchInput records user input, resetting the idle timer.
+func (h *connectionHandler) touchInput() {
+	h.lockMu.Lock()
+	h.lastInput = time.Now()
+	h.lockMu.Unlock()
+}
+
+// isLocked reports whether the connection is idle-locked.
+func (h *connectionHandler) isLocked() bool {
+	h.lockMu.Lock()
+	defer h.lockMu.Unlock()
+	return !h.lockedAt.IsZero()
+}
+
+// lockIfIdle locks the connection once no input has arrived for timeout.
+// Sessions keep running; the client masks the terminal while locked.
+func (h *connectionHandler) lockIfIdle(timeout time.Duration) {
+	h.lockMu.Lock()
+	if !h.lockedAt.IsZero() || time.Since(h.lastInput) < timeout {
+		h.lockMu.Unlock()
+		return
+	}
+	h.lockedAt = time.Now()
+	h.lockMu.Unlock()
+
+	log.Printf("Connection locked after %v idle (user: %s)", timeout, h.username())
+	h.recordAuth(audit.EventIdleLock, audit.OutcomeSuccess, map[string]string{"timeout": timeout.String()})
+	h.sendJSON(terminal.ServerMessage{Type: terminal.MsgTypeLocked, Data: "idle"})
+}
+
+// handleUnlock unlocks the connection if the ticket proves the same user
+// completed a login after the connection was locked. A refresh doesn't count.
+func (h *connectionHandler) handleUnlock(msg *terminal.ClientMessage) {
+	h.lockMu.Lock()
+	lockedAt := h.lockedAt
+	h.lockMu.Unlock()
+	if lockedAt.IsZero() {
+		h.reply(msg, terminal.ServerMessage{Type: terminal.MsgTypeUnlocked})
+		return
+	}
+
+	ticket, ok := h.server.unlockTickets.redeem(msg.UnlockTicket)
+	if !ok || ticket.username != h.username() || !ticket.authTime.After(lockedAt) {
+		h.recordAuth(audit.EventUnlock, audit.OutcomeDenied, nil)
+		h.replyError(msg, "", terminal.ErrCodeForbidden, "re-authentication required")
+		return
+	}
+
+	h.lockMu.Lock()
+	h.lockedAt = time.Time{}
+	h.lastInput = time.Now()
+	h.lockMu.Unlock()
+
+	log.Printf("Connection unlocked (user: %s)", h.username())
+	h.recordAuth(audit.EventUnlock, audit.OutcomeSuccess, nil)
+	h.reply(msg, terminal.ServerMessage{Type: terminal.MsgTypeUnlocked})
+}
+
+// recordAuth appends a connection-level auth event attributed to this connection.
+func (h *connectionHandler) recordAuth(eventType, outcome string, detail map[string]string) {
+	if h.server == nil {
+		return
+	}
+	h.server.auditLog.Record(audit.Event{
+		Type:    eventType,
+		Actor:   h.username(),
+		Remote:  h.remoteAddr,
+		Outcome: outcome,
+		Detail:  detail,
+	})
+}
+
+// expireSession closes an owned session whose absolute lifetime has passed.
+func (h *connectionHandler) expireSession(session *terminal.Session) {
+	h.mu.Lock()
+	delete(h.sessions, session.ID)
+	delete(h.pendingStarts, session.ID)
+	h.mu.Unlock()
+
+	log.Printf("Session %s reached its maximum lifetime, closing", session.ID)
+	session.CloseAttached()
+	session.CloseWithReason("session lifetime exceeded")
+	h.registry.Delete(session.ID)
+	h.recordSession(audit.EventSessionClose, session, map[string]string{"via": "lifetime"})
+}
+
+// timeoutCheckInterval picks how often watchTimeouts runs: a quarter of the
+// shortest enabled timeout, capped at 30s.
+func timeoutCheckInterval(timeouts ...time.Duration) time.Duration {
+	interval := 30 * time.Second
+	for _, d := range timeouts {
+		if d > 0 && d/4 < interval {
+			interval = d / 4
+		}
+	}
+	return max(interval, 10*time.Millisecond)
+}
+
+// watchTimeouts enforces the idle lock and absolute session lifetime for
+// this connection until ctx is cancelled.
+func (h *connectionHandler) watchTimeouts(ctx context.Context, idleTimeout, maxLifetime time.Duration) {
+	ticker := time.NewTicker(timeoutCheckInterval(idleTimeout, maxLifetime))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Idle lock needs a login to unlock with, so it only applies with auth
+			if idleTimeout > 0 && h.authUser != nil {
+				h.lockIfIdle(idleTimeout)
+			}
+			if maxLifetime <= 0 {
+				continue
+			}
+			h.mu.Lock()
+			var expired []*terminal.Session
+			for _, s := range h.sessions {
+				if time.Since(s.CreatedAt) >= maxLifetime {
+					expired = append(expired, s)
+				}
+			}
+			h.mu.Unlock()
+			for _, s := range expired {
+				h.expireSession(s)
+			}
+		}
+	}
+}
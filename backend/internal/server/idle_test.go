@@ -0,0 +1,127 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vaughanknight/trex/internal/auth"
+	"github.com/vaughanknight/trex/internal/config"
+	"github.com/vaughanknight/trex/internal/terminal"
+)
+
+// Test Doc:
+// - Why: Unattended browsers must not stay usable forever
+// - Contract: No input for IdleTimeout → "locked"; everything but unlock is refused; unlock needs a ticket from a login newer than the lock
+// - Usage Notes: Durations are set directly on Config (bypassing Load's 1m minimum) to keep the test fast
+// - Quality Contribution: Proves a silent token refresh can't unlock, and that SessionMaxLifetime closes PTYs
+// - Worked Example: idle 200ms → locked → ticket from old login rejected → ticket from new login → unlocked
+
+// requestUnlockTicket POSTs /api/unlock with an access token for user and returns the ticket.
+func requestUnlockTicket(t *testing.T, serverURL, secret string, user *auth.GitHubUser) string {
+	t.Helper()
+	token, err := auth.NewJWTService(secret).GenerateAccessToken(user)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	req, _ := http.NewRequest(http.MethodPost, serverURL+"/api/unlock", nil)
+	req.Header.Set("Cookie", "trex_access_token="+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST /api/unlock error: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /api/unlock status = %d, want 200", resp.StatusCode)
+	}
+	var body map[string]string
+	json.NewDecoder(resp.Body).Decode(&body)
+	return body["ticket"]
+}
+
+func newTimeoutTestServer(t *testing.T, secret string, idle, lifetime time.Duration) *httptest.Server {
+	t.Helper()
+	srv := New("test-version", &config.Config{
+		BindAddress:        "127.0.0.1:0",
+		AuthEnabled:        true,
+		JWTSecret:          secret,
+		IdleTimeout:        idle,
+		SessionMaxLifetime: lifetime,
+	})
+	ts := httptest.NewServer(srv)
+	t.Cleanup(func() {
+		ts.Close()
+		srv.Shutdown()
+	})
+	return ts
+}
+
+func TestIdleLock_RequiresFreshLogin(t *testing.T) {
+	const secret = "test-secret-idle"
+	ts := newTimeoutTestServer(t, secret, 200*time.Millisecond, 0)
+
+	loggedIn := time.Now().Add(-time.Hour)
+	alice := dialAs(t, ts.URL, secret, "alice")
+	defer alice.Close()
+
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeCreate})
+	sessionID := readUntil(t, alice, ofType(terminal.MsgTypeSessionCreated)).SessionId
+
+	if msg := readUntil(t, alice, ofType(terminal.MsgTypeLocked)); msg.Data != "idle" {
+		t.Errorf("locked data = %q, want %q", msg.Data, "idle")
+	}
+
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeInput, SessionId: sessionID, Data: "ls\r"})
+	if msg := readUntil(t, alice, ofType(terminal.MsgTypeError)); msg.Error != "connection locked" {
+		t.Errorf("input while locked error = %q, want %q", msg.Error, "connection locked")
+	}
+
+	// A refreshed token keeps the old login time and can't unlock
+	stale := requestUnlockTicket(t, ts.URL, secret, &auth.GitHubUser{Username: "alice", AuthTime: loggedIn})
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeUnlock, UnlockTicket: stale})
+	if msg := readUntil(t, alice, ofType(terminal.MsgTypeError)); msg.Error != "re-authentication required" {
+		t.Errorf("stale unlock error = %q, want %q", msg.Error, "re-authentication required")
+	}
+
+	// Another user's fresh login can't unlock alice's socket
+	mallory := requestUnlockTicket(t, ts.URL, secret, &auth.GitHubUser{Username: "mallory", AuthTime: time.Now().Add(time.Second)})
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeUnlock, UnlockTicket: mallory})
+	readUntil(t, alice, ofType(terminal.MsgTypeError))
+
+	fresh := requestUnlockTicket(t, ts.URL, secret, &auth.GitHubUser{Username: "alice", AuthTime: time.Now().Add(time.Second)})
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeUnlock, UnlockTicket: fresh})
+	readUntil(t, alice, ofType(terminal.MsgTypeUnlocked))
+
+	// Tickets are single-use
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeListTmuxSessions})
+	readUntil(t, alice, ofType(terminal.MsgTypeTmuxSessions))
+}
+
+func TestSessionMaxLifetime_ClosesPTY(t *testing.T) {
+	const secret = "test-secret-lifetime"
+	ts := newTimeoutTestServer(t, secret, 0, 200*time.Millisecond)
+
+	alice := dialAs(t, ts.URL, secret, "alice")
+	defer alice.Close()
+
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeCreate})
+	sessionID := readUntil(t, alice, ofType(terminal.MsgTypeSessionCreated)).SessionId
+
+	msg := readUntil(t, alice, func(m terminal.ServerMessage) bool {
+		return m.Type == terminal.MsgTypeExit && m.SessionId == sessionID
+	})
+	if msg.Error != "session lifetime exceeded" {
+		t.Errorf("exit error = %q, want %q", msg.Error, "session lifetime exceeded")
+	}
+
+	// The connection itself stays usable, and no second (plain) exit follows
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeInput, SessionId: sessionID, Data: "x"})
+	msg = readUntil(t, alice, func(m terminal.ServerMessage) bool {
+		return m.Type == terminal.MsgTypeError || m.Type == terminal.MsgTypeExit
+	})
+	if msg.Type != terminal.MsgTypeError || msg.Error != "session not found" {
+		t.Errorf("after expiry got %s %q, want error %q", msg.Type, msg.Error, "session not found")
+	}
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/vaughanknight/trex/internal/terminal"
+	"github.com/vaughanknight/trex/internal/workspace"
+)
+
+// handleHello negotiates the protocol version and capabilities and replies
+// with welcome. hello is only accepted as the first message. Returns false
+// if the client is incompatible and the connection has been closed.
+func (h *connectionHandler) handleHello(msg *terminal.ClientMessage, first bool) bool {
+	if !first {
+		h.replyError(msg, "", terminal.ErrCodeInvalidArgument, "hello must be the first message")
+		return true
+	}
+
+	version, err := terminal.NegotiateProtocol(msg.MinProtocolVersion, msg.ProtocolVersion)
+	if err != nil {
+		log.Printf("Rejecting client %q: %v", msg.Client, err)
+		h.replyError(msg, "", terminal.ErrCodeInvalidArgument, err.Error())
+		h.writeMu.Lock()
+		h.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseProtocolError, "unsupported protocol version"), time.Now().Add(time.Second))
+		h.writeMu.Unlock()
+		return false
+	}
+
+	var capabilities []string
+	for _, c := range terminal.ServerCapabilities {
+		if slices.Contains(msg.Capabilities, c) {
+			capabilities = append(capabilities, c)
+		}
+	}
+	h.protoMu.Lock()
+	h.protocol = version
+	h.capabilities = capabilities
+	h.protoMu.Unlock()
+
+	var serverVersion string
+	if h.server != nil {
+		serverVersion = h.server.version
+	}
+	log.Printf("Client %q speaks protocol %d with %v", msg.Client, version, capabilities)
+	h.reply(msg, terminal.ServerMessage{
+		Type:            terminal.MsgTypeWelcome,
+		ProtocolVersion: version,
+		Capabilities:    capabilities,
+		ServerVersion:   serverVersion,
+	})
+	return true
+}
+
+// negotiated reports whether the client sent hello.
+func (h *connectionHandler) negotiated() bool {
+	h.protoMu.RLock()
+	defer h.protoMu.RUnlock()
+	return h.protocol > 0
+}
+
+// hasCapability reports whether the connection may use capability. Clients
+// that never sent hello get the behaviour from before the handshake existed:
+// everything except binary frames.
+func (h *connectionHandler) hasCapability(capability string) bool {
+	h.protoMu.RLock()
+	defer h.protoMu.RUnlock()
+	if h.protocol == 0 {
+		return capability != terminal.CapBinaryFrames
+	}
+	return slices.Contains(h.capabilities, capability)
+}
+
+// BinaryOutput implements terminal.BinaryOutputConn.
+func (h *connectionHandler) BinaryOutput() bool {
+	return h.hasCapability(terminal.CapBinaryFrames)
+}
+
+// errorCode maps an error returned to a message handler to the
+// terminal.ErrCode* sent with it. tmux management errors reuse the status
+// mapping of the REST API; anything unrecognised is internal.
+func errorCode(err error) string {
+	switch {
+	case errors.Is(err, errSessionNotFound), errors.Is(err, workspace.ErrNotFound), errors.Is(err, terminal.ErrTmuxPaneNotFound):
+		return terminal.ErrCodeNotFound
+	case errors.Is(err, errPermissionDenied):
+		return terminal.ErrCodeForbidden
+	case errors.Is(err, errNoTargets), errors.Is(err, errTooManyTargets), errors.Is(err, terminal.ErrInvalidPermission),
+		errors.Is(err, workspace.ErrInvalid):
+		return terminal.ErrCodeInvalidArgument
+	case isPTYExhausted(err):
+		return terminal.ErrCodePtyExhausted
+	}
+	switch tmuxErrorStatus(err) {
+	case http.StatusBadRequest, http.StatusConflict:
+		return terminal.ErrCodeInvalidArgument
+	case http.StatusNotFound:
+		return terminal.ErrCodeNotFound
+	case http.StatusServiceUnavailable:
+		return terminal.ErrCodeTmuxUnavailable
+	}
+	return terminal.ErrCodeInternal
+}
+
+// isPTYExhausted reports whether err means no pseudo-terminal could be
+// allocated: /dev/ptmx is at kernel.pty.max, or the process or system is
+// out of file descriptors.
+func isPTYExhausted(err error) bool {
+	for _, errno := range []syscall.Errno{syscall.ENOSPC, syscall.EAGAIN, syscall.ENXIO, syscall.EMFILE, syscall.ENFILE} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/vaughanknight/trex/internal/terminal"
+	"github.com/vaughanknight/trex/internal/workspace"
+)
+
+// readBinaryOutput reads until a binary output frame containing marker
+// arrives and returns its session ID.
+func readBinaryOutput(t *testing.T, conn *websocket.Conn, marker string) string {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	defer conn.SetReadDeadline(time.Time{})
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read error while waiting for binary output: %v", err)
+		}
+		if messageType != websocket.BinaryMessage {
+			continue
+		}
+		n := int(data[0])
+		if strings.Contains(string(data[1+n:]), marker) {
+			return string(data[1 : 1+n])
+		}
+	}
+}
+
+// Test Doc:
+// - Why: The frontend, Electron app and backend ship separately; drift should fail loudly, not misbehave
+// - Contract: hello → welcome with the negotiated version, the capabilities both sides list and the server version; binary_frames switches this connection's output to binary frames; unknown types get an error once negotiated; hello after another message is refused
+// - Usage Notes: The legacy (no hello) path is covered by every other server test
+// - Worked Example: hello {1, [binary_frames, teleport]} → welcome {1, [binary_frames]}; `echo` output arrives as "\x02s1…"
+func TestProtocol_Handshake(t *testing.T) {
+	const secret = "test-secret-protocol"
+	_, ts := newAuthTestServer(t, secret)
+
+	alice := dialAs(t, ts.URL, secret, "alice")
+	defer alice.Close()
+	sendMsg(t, alice, terminal.ClientMessage{
+		Type:            terminal.MsgTypeHello,
+		ProtocolVersion: terminal.ProtocolVersion,
+		Capabilities:    []string{terminal.CapBinaryFrames, "teleport"},
+		Client:          "protocol-test/1.0",
+	})
+	welcome := readUntil(t, alice, ofType(terminal.MsgTypeWelcome))
+	if welcome.ProtocolVersion != terminal.ProtocolVersion || len(welcome.Capabilities) != 1 || welcome.Capabilities[0] != terminal.CapBinaryFrames || welcome.ServerVersion != "test-version" {
+		t.Fatalf("welcome = %+v", welcome)
+	}
+
+	id := startSession(t, alice)
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeInput, SessionId: id, Data: "echo bin$((6*7))\r"})
+	if got := readBinaryOutput(t, alice, "bin42"); got != id {
+		t.Errorf("binary frame session = %q, want %q", got, id)
+	}
+
+	sendMsg(t, alice, terminal.ClientMessage{Type: "teleport", SessionId: id})
+	if e := readUntil(t, alice, ofType(terminal.MsgTypeError)); e.Error != `unknown message type "teleport"` {
+		t.Errorf("unknown type error = %q", e.Error)
+	}
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeHello, ProtocolVersion: terminal.ProtocolVersion})
+	if e := readUntil(t, alice, ofType(terminal.MsgTypeError)); e.Error != "hello must be the first message" {
+		t.Errorf("late hello error = %q", e.Error)
+	}
+}
+
+// Test Doc:
+// - Why: A client from a newer, incompatible release must be told why it can't connect
+// - Contract: hello with no overlapping version gets an error naming both ranges, then a 1002 (protocol error) close
+func TestProtocol_RejectsIncompatibleClient(t *testing.T) {
+	const secret = "test-secret-protocol-reject"
+	_, ts := newAuthTestServer(t, secret)
+
+	conn := dialAs(t, ts.URL, secret, "alice")
+	defer conn.Close()
+	sendMsg(t, conn, terminal.ClientMessage{
+		Type:               terminal.MsgTypeHello,
+		ProtocolVersion:    terminal.ProtocolVersion + 5,
+		MinProtocolVersion: terminal.ProtocolVersion + 4,
+	})
+	if e := readUntil(t, conn, ofType(terminal.MsgTypeError)); !strings.Contains(e.Error, "unsupported protocol version") {
+		t.Errorf("error = %q, want unsupported protocol version", e.Error)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	_, _, err := conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseProtocolError) {
+		t.Errorf("read after rejection = %v, want close 1002", err)
+	}
+}
+
+// Test Doc:
+// - Why: Two creates in flight at once produce two session_created replies the client must tell apart, and clients need to branch on errors without parsing text
+// - Contract: Every reply echoes the requestId of the message it answers; errors carry an errorCode; close and detach succeed with an ack naming the message type
+// - Usage Notes: Auth enabled so bob's input to alice's session is a permission error rather than a missing session
+// - Worked Example: create{requestId:"a"} + create{requestId:"b"} → session_created for each with its own requestId; close{requestId:"c1"} → ack{requestId:"c1", data:"close"}; closing again → error{requestId:"c2", errorCode:"not_found"}
+func TestProtocol_RequestCorrelation(t *testing.T) {
+	const secret = "test-secret-correlation"
+	_, ts := newAuthTestServer(t, secret)
+
+	alice := dialAs(t, ts.URL, secret, "alice")
+	defer alice.Close()
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeCreate, RequestId: "a"})
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeCreate, RequestId: "b"})
+	created := map[string]string{}
+	for range 2 {
+		m := readUntil(t, alice, ofType(terminal.MsgTypeSessionCreated))
+		created[m.RequestId] = m.SessionId
+	}
+	if created["a"] == "" || created["b"] == "" || created["a"] == created["b"] {
+		t.Fatalf("session_created by requestId = %v, want distinct sessions for a and b", created)
+	}
+
+	bob := dialAs(t, ts.URL, secret, "bob")
+	defer bob.Close()
+	sendMsg(t, bob, terminal.ClientMessage{Type: terminal.MsgTypeInput, SessionId: created["b"], Data: "id\r", RequestId: "in1"})
+	if e := readUntil(t, bob, ofType(terminal.MsgTypeError)); e.ErrorCode != terminal.ErrCodeForbidden || e.RequestId != "in1" {
+		t.Errorf("input to another user's session = %+v, want forbidden for in1", e)
+	}
+
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeClose, SessionId: created["a"], RequestId: "c1"})
+	if ack := readUntil(t, alice, ofType(terminal.MsgTypeAck)); ack.RequestId != "c1" || ack.SessionId != created["a"] || ack.Data != terminal.MsgTypeClose {
+		t.Errorf("close ack = %+v", ack)
+	}
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeClose, SessionId: created["a"], RequestId: "c2"})
+	if e := readUntil(t, alice, ofType(terminal.MsgTypeError)); e.ErrorCode != terminal.ErrCodeNotFound || e.RequestId != "c2" {
+		t.Errorf("second close = %+v, want not_found for c2", e)
+	}
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeDetach, SessionId: created["b"], RequestId: "d1"})
+	if ack := readUntil(t, alice, ofType(terminal.MsgTypeAck)); ack.RequestId != "d1" || ack.Data != terminal.MsgTypeDetach {
+		t.Errorf("detach ack = %+v", ack)
+	}
+}
+
+// Test Doc:
+// - Why: Clients branch on errorCode, so each handler error must map to the same code every time
+// - Contract: Wrapped errors map by errors.Is; PTY allocation failures are pty_exhausted; tmux errors follow tmuxErrorStatus; anything else is internal
+func TestErrorCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{errSessionNotFound, terminal.ErrCodeNotFound},
+		{fmt.Errorf("get: %w", workspace.ErrNotFound), terminal.ErrCodeNotFound},
+		{errPermissionDenied, terminal.ErrCodeForbidden},
+		{errTooManyTargets, terminal.ErrCodeInvalidArgument},
+		{terminal.ErrTmuxSessionExists, terminal.ErrCodeInvalidArgument},
+		{terminal.ErrTmuxSessionNotFound, terminal.ErrCodeNotFound},
+		{errTmuxUnavailable, terminal.ErrCodeTmuxUnavailable},
+		{&os.PathError{Op: "open", Path: "/dev/ptmx", Err: syscall.ENOSPC}, terminal.ErrCodePtyExhausted},
+		{errors.New("exit status 1"), terminal.ErrCodeInternal},
+	}
+	for _, tt := range tests {
+		if got := errorCode(tt.err); got != tt.want {
+			t.Errorf("errorCode(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
@@ -6,11 +6,15 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/vaughanknight/trex/internal/audit"
 	"github.com/vaughanknight/trex/internal/auth"
 	"github.com/vaughanknight/trex/internal/config"
+	"github.com/vaughanknight/trex/internal/events"
 	"github.com/vaughanknight/trex/internal/plugins/copilot"
 	"github.com/vaughanknight/trex/internal/static"
 	"github.com/vaughanknight/trex/internal/terminal"
+	"github.com/vaughanknight/trex/internal/webhook"
+	"github.com/vaughanknight/trex/internal/workspace"
 )
 
 // Server holds the HTTP server configuration
@@ -25,8 +29,31 @@ type Server struct {
 	monitor *terminal.TmuxMonitor
 	// Plugin data collectors
 	collectors *terminal.CollectorRegistry
-	ctx        context.Context
-	cancel     context.CancelFunc
+	// Process tree detector shared by the poller and tmux lookups (one /proc snapshot per poll)
+	processes terminal.ProcessDetector
+	// Server-wide cwd, process and plugin collector polling for every session
+	poller *terminal.SessionPoller
+	// Audit log of auth, session lifecycle and admin actions (nil when disabled)
+	auditLog *audit.Logger
+	// Keystroke-level input audit policy (nil when no profile opts in)
+	inputAudit *terminal.InputAuditPolicy
+	// Per-profile notification rules (nil when the rules file is invalid)
+	notify *terminal.NotifyConfig
+	// Event stream for GET /api/events
+	eventStream *events.Broker
+	// Outgoing event webhooks (nil when not configured)
+	webhooks  *webhook.Dispatcher
+	hookState webhookState
+	// permessage-deflate counters for /ws
+	compression compressionCounters
+	// Auth endpoint rate limiter (nil when auth is disabled)
+	limiter *auth.RateLimiter
+	// Per-user workspaces (pane layouts)
+	workspaces *workspace.Store
+	// Single-use tickets for unlocking idle-locked connections
+	unlockTickets *unlockTicketStore
+	ctx           context.Context
+	cancel        context.CancelFunc
 }
 
 // New creates a new server instance
@@ -37,21 +64,105 @@ func New(version string, cfg *config.Config) *Server {
 		version:    version,
 		registry:   terminal.NewSessionRegistry(),
 		collectors: terminal.NewCollectorRegistry(),
+		processes:  terminal.NewProcessDetector(),
 		config:     cfg,
 		ctx:        ctx,
 		cancel:     cancel,
+
+		unlockTickets: newUnlockTicketStore(),
+		eventStream:   events.NewBroker(0),
+	}
+
+	// Open the audit log before routes so auth handlers can record to it
+	if cfg.AuditLogPath != "" {
+		auditLog, err := audit.NewLogger(cfg.AuditLogPath, int64(cfg.AuditMaxSizeMB)*1024*1024, 0)
+		if err != nil {
+			// Non-fatal: run without auditing
+			log.Printf("Audit log disabled: %v", err)
+		} else {
+			s.auditLog = auditLog
+			log.Printf("Audit log: %s", cfg.AuditLogPath)
+		}
+	}
+
+	workspaces, err := workspace.NewStore(cfg.WorkspacesPath)
+	if err != nil {
+		// Non-fatal: keep workspaces in memory rather than overwrite the file
+		log.Printf("Workspaces not persisted: %v", err)
+		workspaces, _ = workspace.NewStore("")
+	}
+	s.workspaces = workspaces
+
+	// Input audit is opt-in per profile
+	if len(cfg.InputAuditProfiles) > 0 {
+		rules, err := terminal.LoadRedactionRules(cfg.InputAuditRulesPath)
+		if err != nil {
+			// Never fall back to weaker rules than the operator configured
+			log.Printf("Input audit disabled: %v", err)
+		} else {
+			s.inputAudit = &terminal.InputAuditPolicy{
+				Dir:      cfg.InputAuditDir,
+				Profiles: cfg.InputAuditProfiles,
+				Rules:    rules,
+			}
+			log.Printf("Input audit enabled for profiles %v: %s", cfg.InputAuditProfiles, cfg.InputAuditDir)
+		}
+	}
+
+	notify, err := terminal.LoadNotifyConfig(cfg.NotifyRulesPath)
+	if err != nil {
+		// Non-fatal: notifications are a convenience
+		log.Printf("Notifications disabled: %v", err)
+	}
+	s.notify = notify
+
+	s.webhooks = loadWebhooks(cfg.WebhooksPath, cfg.WebhookDeadLetterPath)
+	if s.webhooks != nil {
+		s.webhooks.Start()
+		if s.notify != nil {
+			s.notify.Publish = s.publishNotification
+		}
 	}
+
+	// Throttle auth endpoints and WebSocket upgrades when exposed to the network
+	if cfg.AuthEnabled {
+		s.limiter = auth.NewRateLimiter(auth.RateLimitConfig{
+			PerMinute:        cfg.AuthRateLimit,
+			Burst:            cfg.AuthRateBurst,
+			LockoutThreshold: cfg.AuthLockoutThreshold,
+			LockoutWindow:    cfg.AuthLockoutWindow,
+			LockoutDuration:  cfg.AuthLockoutDuration,
+		})
+		s.limiter.SetAuditLog(s.auditLog)
+	}
+
 	s.routes()
 
 	// Register plugin data collectors
 	s.collectors.Register(copilot.NewCollector())
 
-	// Wrap mux with auth middleware
+	// Wrap mux with auth middleware, and rate limiting outside it so
+	// /ws upgrades are throttled before their token is checked. A rejected
+	// (401) upgrade is not a failure; only the auth handlers record those.
 	jwtService := auth.NewJWTService(cfg.JWTSecret)
 	s.handler = auth.Middleware(jwtService, cfg.AuthEnabled)(s.mux)
+	s.handler = auth.RateLimitMiddleware(s.limiter, "/auth/callback", "/auth/refresh", "/ws")(s.handler)
 
 	// Start tmux monitor
-	detector := terminal.NewRealTmuxDetector(5 * time.Second)
+	var detector terminal.TmuxDetector = terminal.NewRealTmuxDetector(5 * time.Second)
+	if cfg.TmuxControlMode {
+		detector = terminal.NewControlModeTmuxDetector(5 * time.Second)
+	}
+	if len(cfg.TmuxSockets) > 0 || len(cfg.TmuxRemoteHosts) > 0 {
+		multi := terminal.NewMultiHostTmuxDetector(detector)
+		for _, socket := range cfg.TmuxSockets {
+			multi.AddSocket(socket, terminal.NewSocketTmuxDetector(socket, 5*time.Second), cfg.TmuxPollInterval)
+		}
+		for _, host := range cfg.TmuxRemoteHosts {
+			multi.AddHost(host, terminal.NewRemoteTmuxDetector(host, cfg.TmuxSSHCommand, 5*time.Second), cfg.TmuxRemotePollInterval)
+		}
+		detector = multi
+	}
 	pollInterval := cfg.TmuxPollInterval
 	if pollInterval <= 0 {
 		pollInterval = 2 * time.Second
@@ -59,6 +170,15 @@ func New(version string, cfg *config.Config) *Server {
 	s.monitor = terminal.NewTmuxMonitor(detector, s.registry, pollInterval, s.handleTmuxChanges, s.handleSessionsChanged)
 	s.monitor.Start()
 
+	s.poller = terminal.NewSessionPoller(s.registry, terminal.NewCwdDetector(), s.processes, s.collectors, terminal.SessionPollerConfig{
+		Interval:         cfg.SessionPollInterval,
+		Jitter:           0.1,
+		MaxBackoff:       5 * time.Minute,
+		SessionProcesses: s.tmuxSessionProcesses,
+		OnPluginData:     s.publishPluginStatus,
+	})
+	s.poller.Start()
+
 	return s
 }
 
@@ -68,6 +188,11 @@ func (s *Server) Shutdown() {
 	if s.monitor != nil {
 		s.monitor.Stop()
 	}
+	if s.poller != nil {
+		s.poller.Stop()
+	}
+	s.webhooks.Stop()
+	s.auditLog.Close()
 	log.Printf("Server shutdown complete")
 }
 
@@ -92,6 +217,11 @@ func (s *Server) handleTmuxChanges(updates map[string]string) {
 			byConn[conn] = make(map[string]string)
 		}
 		byConn[conn][sessionID] = tmuxName
+		s.eventStream.Publish(terminal.MsgTypeTmuxStatus, sessionID, session.Owner, terminal.ServerMessage{
+			Type:        terminal.MsgTypeTmuxStatus,
+			SessionId:   sessionID,
+			TmuxUpdates: map[string]string{sessionID: tmuxName},
+		})
 	}
 
 	// Send one message per connection
@@ -126,6 +256,8 @@ func (s *Server) handleSessionsChanged(sessions []terminal.TmuxSessionInfo) {
 	}
 
 	log.Printf("broadcast tmux sessions (%d sessions) to %d clients", len(sessions), len(seen))
+	s.eventStream.Publish(terminal.MsgTypeTmuxSessions, "", "", terminal.ServerMessage{Type: terminal.MsgTypeTmuxSessions, TmuxSessions: sessions})
+	s.publishTmuxSessionChanges(sessions)
 }
 
 // ServeHTTP implements http.Handler
@@ -137,7 +269,15 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 func (s *Server) routes() {
 	s.mux.HandleFunc("/api/health", s.handleHealth())
 	s.mux.HandleFunc("/api/sessions", handleSessions(s.registry))
-	s.mux.HandleFunc("/api/sessions/", handleSessionDelete(s.registry))
+	s.mux.HandleFunc("/api/sessions/", handleSessionPath(s.registry, s.auditLog))
+	s.mux.HandleFunc("/api/audit", s.handleAudit())
+	s.mux.HandleFunc("/api/diagnostics", s.handleDiagnostics())
+	s.mux.HandleFunc("/api/events", s.handleEvents())
+	s.mux.HandleFunc("/api/unlock", s.handleUnlock())
+	s.mux.HandleFunc("/api/tmux/sessions", s.handleTmuxSessions())
+	s.mux.HandleFunc("/api/tmux/sessions/", s.handleTmuxSession())
+	s.mux.HandleFunc("/api/workspaces", s.handleWorkspaces())
+	s.mux.HandleFunc("/api/workspaces/", s.handleWorkspace())
 	s.mux.HandleFunc("/ws", s.handleTerminal())
 
 	// Auth routes
@@ -165,6 +305,8 @@ func (s *Server) setupAuthRoutes() {
 	stateStore := auth.NewStateStore(10 * time.Minute)
 	jwtService := auth.NewJWTService(s.config.JWTSecret)
 	authHandler := auth.NewAuthHandler(provider, stateStore, jwtService, s.config.AuthEnabled)
+	authHandler.SetAuditLog(s.auditLog)
+	authHandler.SetRateLimiter(s.limiter)
 
 	// Set up allowlist if auth is enabled
 	if s.config.AuthEnabled && s.config.AllowlistPath != "" {
@@ -173,6 +315,7 @@ func (s *Server) setupAuthRoutes() {
 			// Non-fatal: start with empty allowlist
 			allowlist = auth.NewAllowlistManager()
 		}
+		allowlist.SetAuditLog(s.auditLog)
 		authHandler.SetAllowlist(allowlist)
 
 		// Start file watcher in background
@@ -2,10 +2,12 @@ package server
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"strings"
 
+	"github.com/vaughanknight/trex/internal/audit"
 	"github.com/vaughanknight/trex/internal/auth"
 	"github.com/vaughanknight/trex/internal/terminal"
 )
@@ -18,17 +20,19 @@ func handleSessions(registry *terminal.SessionRegistry) http.HandlerFunc {
 			return
 		}
 
-		// Filter sessions by owner when auth is enabled
-		var owner string
+		// Filter sessions by owner (plus sessions shared with the user) when auth is enabled
+		var username string
 		if user := auth.UserFromContext(r.Context()); user != nil {
-			owner = user.Username
+			username = user.Username
 		}
-		sessions := registry.ListByOwner(owner)
+		sessions := registry.ListAccessible(username)
 
 		// Convert to SessionInfo slice for JSON response
 		infos := make([]terminal.SessionInfo, 0, len(sessions))
 		for _, s := range sessions {
-			infos = append(infos, s.Info())
+			info := s.Info()
+			info.Permission = s.PermissionFor(username)
+			infos = append(infos, info)
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -39,8 +43,58 @@ func handleSessions(registry *terminal.SessionRegistry) http.HandlerFunc {
 	}
 }
 
+// handleSessionPath routes /api/sessions/:id/commands to the command history
+// and everything else under /api/sessions/ to handleSessionDelete.
+func handleSessionPath(registry *terminal.SessionRegistry, auditLog *audit.Logger) http.HandlerFunc {
+	commands := handleSessionCommands(registry)
+	del := handleSessionDelete(registry, auditLog)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/commands") {
+			commands(w, r)
+			return
+		}
+		del(w, r)
+	}
+}
+
+// sessionCommands is the body of GET /api/sessions/:id/commands.
+type sessionCommands struct {
+	SessionID string                   `json:"sessionId"`
+	Name      string                   `json:"name"`
+	Commands  []terminal.CommandRecord `json:"commands"`
+}
+
+// handleSessionCommands handles GET /api/sessions/:id/commands: the
+// session's finished commands, oldest first, as reported by shell
+// integration. Anyone who can see the session's output may read them.
+// ?download=true serves the history as a JSON file attachment.
+func handleSessionCommands(registry *terminal.SessionRegistry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		sessionID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/sessions/"), "/commands")
+		session := registry.Get(sessionID)
+		if session == nil || session.PermissionFor(actorOf(r)) == terminal.SharePermissionNone {
+			http.Error(w, "session not found", http.StatusNotFound)
+			return
+		}
+
+		body := sessionCommands{SessionID: session.ID, Name: session.Name, Commands: session.Commands()}
+		if body.Commands == nil {
+			body.Commands = []terminal.CommandRecord{}
+		}
+		if r.URL.Query().Get("download") == "true" {
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "trex-"+session.ID+"-commands.json"))
+		}
+		writeJSON(w, http.StatusOK, body)
+	}
+}
+
 // handleSessionDelete handles DELETE /api/sessions/:id to close a session.
-func handleSessionDelete(registry *terminal.SessionRegistry) http.HandlerFunc {
+// auditLog may be nil.
+func handleSessionDelete(registry *terminal.SessionRegistry, auditLog *audit.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Extract session ID from path: /api/sessions/:id
 		path := strings.TrimPrefix(r.URL.Path, "/api/sessions/")
@@ -57,21 +111,35 @@ func handleSessionDelete(registry *terminal.SessionRegistry) http.HandlerFunc {
 			return
 		}
 
-		// Check ownership when auth is enabled
+		// Check ownership when auth is enabled. Users a session is shared
+		// with can see it exists but only the owner may close it.
+		var actor string
 		if user := auth.UserFromContext(r.Context()); user != nil {
-			if session.Owner != "" && session.Owner != user.Username {
+			actor = user.Username
+			switch session.PermissionFor(user.Username) {
+			case terminal.SharePermissionOwner:
+			case terminal.SharePermissionNone:
 				http.Error(w, "session not found", http.StatusNotFound)
 				return
+			default:
+				http.Error(w, "only the session owner can close it", http.StatusForbidden)
+				return
 			}
 		}
 
-		// Close session gracefully
-		log.Printf("Closing session %s (%s)", session.ID, session.Name)
-		session.CloseGracefully()
-
-		// Remove from registry
-		registry.Delete(sessionID)
-
+		closeSession(registry, auditLog, session, actor, r.RemoteAddr, "rest")
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
+
+// closeSession closes a session on behalf of a REST caller: viewers are
+// detached, the PTY is closed gracefully and the session is removed from the
+// registry. via records the endpoint in the audit log.
+func closeSession(registry *terminal.SessionRegistry, auditLog *audit.Logger, session *terminal.Session, actor, remote, via string) {
+	log.Printf("Closing session %s (%s)", session.ID, session.Name)
+	session.CloseAttached()
+	session.CloseGracefully()
+
+	registry.Delete(session.ID)
+	recordSessionEvent(auditLog, audit.EventSessionClose, actor, remote, session, map[string]string{"via": via})
+}
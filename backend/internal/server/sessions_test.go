@@ -142,7 +142,7 @@ func TestDeleteSession_Success(t *testing.T) {
 
 	registry.Add(session)
 
-	handler := handleSessionDelete(registry)
+	handler := handleSessionDelete(registry, nil)
 
 	// Simulate path parameter by using full path
 	req := httptest.NewRequest(http.MethodDelete, "/api/sessions/s1", nil)
@@ -168,7 +168,7 @@ func TestDeleteSession_Success(t *testing.T) {
 
 func TestDeleteSession_NotFound(t *testing.T) {
 	registry := terminal.NewSessionRegistry()
-	handler := handleSessionDelete(registry)
+	handler := handleSessionDelete(registry, nil)
 
 	req := httptest.NewRequest(http.MethodDelete, "/api/sessions/nonexistent", nil)
 	rec := httptest.NewRecorder()
@@ -182,7 +182,7 @@ func TestDeleteSession_NotFound(t *testing.T) {
 
 func TestDeleteSession_MissingID(t *testing.T) {
 	registry := terminal.NewSessionRegistry()
-	handler := handleSessionDelete(registry)
+	handler := handleSessionDelete(registry, nil)
 
 	req := httptest.NewRequest(http.MethodDelete, "/api/sessions/", nil)
 	rec := httptest.NewRecorder()
@@ -323,7 +323,7 @@ func TestDeleteSession_OwnershipCheck(t *testing.T) {
 
 	registry.Add(session)
 
-	handler := handleSessionDelete(registry)
+	handler := handleSessionDelete(registry, nil)
 
 	// Try to delete as bob
 	req := httptest.NewRequest(http.MethodDelete, "/api/sessions/s1", nil)
@@ -363,7 +363,7 @@ func TestDeleteSession_OwnerCanDelete(t *testing.T) {
 
 	registry.Add(session)
 
-	handler := handleSessionDelete(registry)
+	handler := handleSessionDelete(registry, nil)
 
 	// Delete as alice (the owner)
 	req := httptest.NewRequest(http.MethodDelete, "/api/sessions/s1", nil)
@@ -382,3 +382,57 @@ func TestDeleteSession_OwnerCanDelete(t *testing.T) {
 		t.Error("Session should be deleted by owner")
 	}
 }
+
+func TestSessionCommands(t *testing.T) {
+	// Test Doc:
+	// - Why: "What did the agent run and did it fail" without scrolling back through output
+	// - Contract: GET /api/sessions/:id/commands returns the shell-integration history with exit codes and output ranges; other users get 404; ?download=true adds an attachment header
+	// - Usage Notes: The fake PTY prints OSC 133 marks as a shell with the snippets sourced would
+
+	registry := terminal.NewSessionRegistry()
+	fakePTY := terminal.NewFakePTY()
+	fakeWS := terminal.NewFakeWebSocket()
+	session := terminal.NewSessionWithConn("s1", fakePTY, fakeWS)
+	session.Owner = "alice"
+	registry.Add(session)
+	go session.RunReadPTY()
+	defer session.CloseGracefully()
+
+	fakePTY.SimulateOutput("\x1b]133;C;cmdline_url=go%20test\x07FAIL\r\n\x1b]133;D;1\x07")
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) && len(session.Commands()) == 0 {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	handler := handleSessionPath(registry, nil)
+	get := func(user, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req = req.WithContext(auth.WithUser(req.Context(), &auth.GitHubUser{Username: user}))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("alice", "/api/sessions/s1/commands?download=true")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var body sessionCommands
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("bad body: %v", err)
+	}
+	if len(body.Commands) != 1 {
+		t.Fatalf("commands = %+v, want one", body.Commands)
+	}
+	cmd := body.Commands[0]
+	if cmd.Command != "go test" || cmd.ExitCode == nil || *cmd.ExitCode != 1 || cmd.OutputStart != 30 || cmd.OutputEnd != 36 {
+		t.Errorf("command = %+v, want go test exit 1 output [30, 36)", cmd)
+	}
+	if got := rec.Header().Get("Content-Disposition"); got != `attachment; filename="trex-s1-commands.json"` {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+
+	if rec := get("bob", "/api/sessions/s1/commands"); rec.Code != http.StatusNotFound {
+		t.Errorf("other user's status = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,227 @@
+package server
+
+import (
+	"log"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/vaughanknight/trex/internal/audit"
+	"github.com/vaughanknight/trex/internal/terminal"
+)
+
+// Share link lifetime bounds. Links default to one hour and may not outlive a day.
+const (
+	defaultShareLinkTTL = time.Hour
+	maxShareLinkTTL     = 24 * time.Hour
+)
+
+// username returns the authenticated username, or "" when auth is disabled.
+func (h *connectionHandler) username() string {
+	if h.authUser != nil {
+		return h.authUser.Username
+	}
+	return ""
+}
+
+// ownedSession returns a session created on this connection whose owner is the
+// current user. Only owners may share, unshare or close a session.
+func (h *connectionHandler) ownedSession(sessionID string) *terminal.Session {
+	h.mu.Lock()
+	session, ok := h.sessions[sessionID]
+	h.mu.Unlock()
+	if !ok || session.PermissionFor(h.username()) != terminal.SharePermissionOwner {
+		return nil
+	}
+	return session
+}
+
+// attachedSession returns a session this connection attached to via a share.
+func (h *connectionHandler) attachedSession(sessionID string) *terminal.Session {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.attached[sessionID]
+}
+
+// handleShare grants a user (ShareUser set) or a share link (ShareUser empty)
+// access to a session owned by this connection.
+func (h *connectionHandler) handleShare(msg *terminal.ClientMessage) {
+	session := h.ownedSession(msg.SessionId)
+	if session == nil {
+		h.replyError(msg, msg.SessionId, terminal.ErrCodeNotFound, "session not found")
+		return
+	}
+
+	perm := terminal.SharePermission(msg.Permission)
+	if !terminal.ValidSharePermission(perm) {
+		h.replyError(msg, msg.SessionId, terminal.ErrCodeInvalidArgument, "invalid share permission")
+		return
+	}
+
+	reply := terminal.ServerMessage{
+		SessionId:  session.ID,
+		Type:       terminal.MsgTypeShareCreated,
+		Permission: string(perm),
+	}
+
+	if msg.ShareUser != "" {
+		if msg.ShareUser == session.Owner {
+			h.replyError(msg, msg.SessionId, terminal.ErrCodeInvalidArgument, "cannot share a session with its owner")
+			return
+		}
+		if err := session.ShareWithUser(msg.ShareUser, perm); err != nil {
+			h.replyError(msg, msg.SessionId, errorCode(err), err.Error())
+			return
+		}
+		reply.Data = msg.ShareUser
+		log.Printf("Session %s shared with %s (%s)", session.ID, msg.ShareUser, perm)
+	} else {
+		ttl := defaultShareLinkTTL
+		if msg.ShareLinkTTL > 0 {
+			ttl = time.Duration(msg.ShareLinkTTL) * time.Second
+		}
+		if ttl > maxShareLinkTTL {
+			ttl = maxShareLinkTTL
+		}
+		link, err := session.CreateShareLink(perm, ttl)
+		if err != nil {
+			log.Printf("Share link creation error for session %s: %v", session.ID, err)
+			h.replyError(msg, msg.SessionId, terminal.ErrCodeInternal, "failed to create share link")
+			return
+		}
+		reply.ShareLink = &link
+		log.Printf("Session %s share link issued (%s, expires %s)", session.ID, perm, link.ExpiresAt.Format(time.RFC3339))
+	}
+
+	h.reply(msg, reply)
+}
+
+// handleUnshare revokes a user's grant (detaching their connections) or a share link.
+func (h *connectionHandler) handleUnshare(msg *terminal.ClientMessage) {
+	session := h.ownedSession(msg.SessionId)
+	if session == nil {
+		h.replyError(msg, msg.SessionId, terminal.ErrCodeNotFound, "session not found")
+		return
+	}
+
+	var detached []terminal.Conn
+	if msg.ShareToken != "" {
+		detached = append(detached, session.RevokeShareLink(msg.ShareToken)...)
+	}
+	if msg.ShareUser != "" {
+		session.Unshare(msg.ShareUser)
+		detached = append(detached, session.DetachUser(msg.ShareUser)...)
+	}
+	if len(detached) > 0 {
+		for _, c := range detached {
+			if viewer, ok := c.(*connectionHandler); ok {
+				viewer.forgetAttached(session.ID)
+				viewer.sendJSON(terminal.ServerMessage{SessionId: session.ID, Type: terminal.MsgTypeExit})
+			}
+		}
+		session.SendPresence()
+	}
+	log.Printf("Session %s unshared (user: %q)", session.ID, msg.ShareUser)
+	h.ack(msg)
+}
+
+// handleAttach attaches this connection to an existing session as a viewer or
+// collaborator. Access comes from a user share grant or a share link token.
+// Unknown sessions and missing permission both report "session not found" so
+// callers can't probe for session IDs.
+func (h *connectionHandler) handleAttach(msg *terminal.ClientMessage) {
+	if !h.hasCapability(terminal.CapAttach) {
+		h.replyError(msg, msg.SessionId, terminal.ErrCodeForbidden, "attach capability not negotiated")
+		return
+	}
+	session := h.registry.Get(msg.SessionId)
+	if session == nil || !session.IsRunning() {
+		h.replyError(msg, msg.SessionId, terminal.ErrCodeNotFound, "session not found")
+		return
+	}
+
+	h.mu.Lock()
+	_, owned := h.sessions[session.ID]
+	h.mu.Unlock()
+	if owned {
+		h.replyError(msg, msg.SessionId, terminal.ErrCodeInvalidArgument, "session already open on this connection")
+		return
+	}
+
+	perm := session.PermissionFor(h.username())
+	linkToken := ""
+	if perm == terminal.SharePermissionNone && msg.ShareToken != "" {
+		perm = session.ShareLinkPermission(msg.ShareToken)
+		linkToken = msg.ShareToken
+	}
+	if perm == terminal.SharePermissionNone {
+		h.replyError(msg, msg.SessionId, terminal.ErrCodeNotFound, "session not found")
+		return
+	}
+
+	watcher := h.username()
+	if watcher == "" {
+		watcher = "anonymous"
+	}
+	offset := session.AttachWithLink(h, terminal.Watcher{Username: watcher, Permission: perm}, linkToken)
+	h.mu.Lock()
+	h.attached[session.ID] = session
+	h.mu.Unlock()
+
+	log.Printf("Session %s attached by %s (%s)", session.ID, watcher, perm)
+	h.recordSession(audit.EventSessionAttach, session, map[string]string{"permission": string(perm)})
+
+	h.reply(msg, terminal.ServerMessage{
+		SessionId:       session.ID,
+		Type:            terminal.MsgTypeSessionAttached,
+		ShellType:       session.ShellType,
+		Data:            session.Name,
+		TmuxSessionName: session.TmuxSessionName,
+		Cwd:             session.Cwd(),
+		Permission:      string(perm),
+		OutputOffset:    offset,
+	})
+	h.sendSessionSnapshot(session)
+	session.SendPresence()
+}
+
+// sendSessionSnapshot sends an attaching connection the session's current
+// foreground process and plugin data. The poller only sends them when they
+// change, so a later joiner would otherwise wait for the next change.
+func (h *connectionHandler) sendSessionSnapshot(session *terminal.Session) {
+	if fg := session.Foreground(); fg != nil {
+		h.sendJSON(terminal.ServerMessage{SessionId: session.ID, Type: terminal.MsgTypeProcessUpdate, Foreground: fg})
+	}
+	data := session.PluginData()
+	for _, id := range slices.Sorted(maps.Keys(data)) {
+		h.sendJSON(terminal.ServerMessage{SessionId: session.ID, Type: terminal.MsgTypePluginData, PluginId: id, PluginData: data[id]})
+	}
+}
+
+// detachViewer removes this connection from a session it attached to via a share.
+// The session itself keeps running for its owner.
+func (h *connectionHandler) detachViewer(session *terminal.Session) {
+	h.forgetAttached(session.ID)
+	if session.Detach(h) {
+		log.Printf("Session %s detached by viewer %q", session.ID, h.username())
+		h.recordSession(audit.EventSessionDetach, session, nil)
+		session.SendPresence()
+	}
+}
+
+// forgetAttached drops a session from this connection's attached set.
+func (h *connectionHandler) forgetAttached(sessionID string) {
+	h.mu.Lock()
+	delete(h.attached, sessionID)
+	h.mu.Unlock()
+}
+
+// canWrite reports whether this connection may send input/resize to session.
+// Owners always can; attached connections need collaborator permission, and
+// those attached through a share link lose it when the link expires.
+func (h *connectionHandler) canWrite(session *terminal.Session) bool {
+	if h.attachedSession(session.ID) == session {
+		return session.AttachedPermission(h).CanWrite()
+	}
+	return session.PermissionFor(h.username()) == terminal.SharePermissionOwner
+}
@@ -0,0 +1,271 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/vaughanknight/trex/internal/auth"
+	"github.com/vaughanknight/trex/internal/config"
+	"github.com/vaughanknight/trex/internal/terminal"
+)
+
+// Test Doc:
+// - Why: Owners share live sessions with other users for pairing and supervision
+// - Contract: share → attach grants viewer/collaborator; viewers can't write; presence lists watchers
+// - Usage Notes: Auth enabled so session ownership is enforced; each user dials their own WebSocket
+// - Quality Contribution: End-to-end check of the share permission model over /ws
+// - Worked Example: alice shares s1 with bob as viewer → bob attaches → bob input rejected with "permission denied"
+
+// dialAs opens a /ws connection authenticated as username.
+func dialAs(t *testing.T, serverURL, secret, username string) *websocket.Conn {
+	t.Helper()
+	token, err := auth.NewJWTService(secret).GenerateAccessToken(&auth.GitHubUser{Username: username})
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	header := http.Header{}
+	header.Set("Cookie", "trex_access_token="+token)
+	wsURL := "ws" + strings.TrimPrefix(serverURL, "http") + "/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, header)
+	if err != nil {
+		t.Fatalf("WebSocket dial error: %v", err)
+	}
+	return conn
+}
+
+// sendMsg marshals and sends a client message.
+func sendMsg(t *testing.T, conn *websocket.Conn, msg terminal.ClientMessage) {
+	t.Helper()
+	data, _ := json.Marshal(msg)
+	if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		t.Fatalf("Failed to send %s: %v", msg.Type, err)
+	}
+}
+
+// readUntil reads messages until one matches, or fails after a timeout.
+func readUntil(t *testing.T, conn *websocket.Conn, match func(terminal.ServerMessage) bool) terminal.ServerMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	defer conn.SetReadDeadline(time.Time{})
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read error while waiting for message: %v", err)
+		}
+		var msg terminal.ServerMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			continue
+		}
+		if match(msg) {
+			return msg
+		}
+	}
+}
+
+func ofType(msgType string) func(terminal.ServerMessage) bool {
+	return func(m terminal.ServerMessage) bool { return m.Type == msgType }
+}
+
+func newAuthTestServer(t *testing.T, secret string) (*Server, *httptest.Server) {
+	t.Helper()
+	srv := New("test-version", &config.Config{
+		BindAddress: "127.0.0.1:0",
+		AuthEnabled: true,
+		JWTSecret:   secret,
+	})
+	ts := httptest.NewServer(srv)
+	t.Cleanup(func() {
+		ts.Close()
+		srv.Shutdown()
+	})
+	return srv, ts
+}
+
+func TestShare_ViewerAttachAndReadOnly(t *testing.T) {
+	const secret = "test-secret-share"
+	srv, ts := newAuthTestServer(t, secret)
+
+	alice := dialAs(t, ts.URL, secret, "alice")
+	defer alice.Close()
+	bob := dialAs(t, ts.URL, secret, "bob")
+	defer bob.Close()
+
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeCreate})
+	sessionID := readUntil(t, alice, ofType(terminal.MsgTypeSessionCreated)).SessionId
+
+	// Bob can't attach before a share exists
+	sendMsg(t, bob, terminal.ClientMessage{Type: terminal.MsgTypeAttach, SessionId: sessionID})
+	if msg := readUntil(t, bob, ofType(terminal.MsgTypeError)); msg.Error != "session not found" {
+		t.Errorf("attach without share error = %q, want %q", msg.Error, "session not found")
+	}
+
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeShare, SessionId: sessionID, ShareUser: "bob", Permission: "viewer"})
+	if msg := readUntil(t, alice, ofType(terminal.MsgTypeShareCreated)); msg.Data != "bob" {
+		t.Errorf("share_created data = %q, want bob", msg.Data)
+	}
+
+	sendMsg(t, bob, terminal.ClientMessage{Type: terminal.MsgTypeAttach, SessionId: sessionID})
+	attached := readUntil(t, bob, ofType(terminal.MsgTypeSessionAttached))
+	if attached.Permission != "viewer" {
+		t.Errorf("attached permission = %q, want viewer", attached.Permission)
+	}
+
+	presence := readUntil(t, alice, ofType(terminal.MsgTypePresence))
+	if len(presence.Watchers) != 2 || presence.Watchers[1].Username != "bob" {
+		t.Errorf("presence watchers = %+v, want alice and bob", presence.Watchers)
+	}
+
+	sendMsg(t, bob, terminal.ClientMessage{Type: terminal.MsgTypeInput, SessionId: sessionID, Data: "echo nope\r"})
+	if msg := readUntil(t, bob, ofType(terminal.MsgTypeError)); msg.Error != "permission denied" {
+		t.Errorf("viewer input error = %q, want %q", msg.Error, "permission denied")
+	}
+
+	// Viewer closing only detaches; the session keeps running for alice
+	sendMsg(t, bob, terminal.ClientMessage{Type: terminal.MsgTypeClose, SessionId: sessionID})
+	readUntil(t, alice, func(m terminal.ServerMessage) bool {
+		return m.Type == terminal.MsgTypePresence && len(m.Watchers) == 1
+	})
+	if s := srv.registry.Get(sessionID); s == nil || !s.IsRunning() {
+		t.Error("session should still be running after viewer detaches")
+	}
+}
+
+func TestShare_LinkGrantsCollaborator(t *testing.T) {
+	const secret = "test-secret-share-link"
+	srv, ts := newAuthTestServer(t, secret)
+
+	alice := dialAs(t, ts.URL, secret, "alice")
+	defer alice.Close()
+	carol := dialAs(t, ts.URL, secret, "carol")
+	defer carol.Close()
+
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeCreate})
+	sessionID := readUntil(t, alice, ofType(terminal.MsgTypeSessionCreated)).SessionId
+
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeShare, SessionId: sessionID, Permission: "collaborator", ShareLinkTTL: 60})
+	created := readUntil(t, alice, ofType(terminal.MsgTypeShareCreated))
+	if created.ShareLink == nil || created.ShareLink.Token == "" {
+		t.Fatal("share_created should include a share link")
+	}
+
+	sendMsg(t, carol, terminal.ClientMessage{Type: terminal.MsgTypeAttach, SessionId: sessionID, ShareToken: created.ShareLink.Token})
+	if msg := readUntil(t, carol, ofType(terminal.MsgTypeSessionAttached)); msg.Permission != "collaborator" {
+		t.Errorf("attached permission = %q, want collaborator", msg.Permission)
+	}
+
+	// Collaborator input reaches the PTY: no error, and the session is still alive
+	sendMsg(t, carol, terminal.ClientMessage{Type: terminal.MsgTypeResize, SessionId: sessionID, Cols: 100, Rows: 30})
+	sendMsg(t, carol, terminal.ClientMessage{Type: terminal.MsgTypeInput, SessionId: sessionID, Data: "echo collab-marker\r"})
+	readUntil(t, carol, func(m terminal.ServerMessage) bool {
+		if m.Type == terminal.MsgTypeError {
+			t.Fatalf("unexpected error for collaborator: %s", m.Error)
+		}
+		return m.Type == terminal.MsgTypeOutput && strings.Contains(m.Data, "collab-marker")
+	})
+
+	// Carol can't delete a session she doesn't own
+	token, _ := auth.NewJWTService(secret).GenerateAccessToken(&auth.GitHubUser{Username: "carol"})
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+"/api/sessions/"+sessionID, nil)
+	req.Header.Set("Cookie", "trex_access_token="+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("DELETE error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		// Link holders have no user grant, so the session stays hidden from REST
+		t.Errorf("DELETE status = %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+	if srv.registry.Get(sessionID) == nil {
+		t.Error("session should not be deleted by a collaborator")
+	}
+}
+
+// Test Doc:
+// - Why: Taking access away must reach connections that are already attached, not just future attaches
+// - Contract: re-sharing as viewer downgrades bob's live attachment; revoking a link detaches everyone who attached through it
+// - Worked Example: bob collaborator → viewer → bob input "permission denied"; carol attached by link → unshare token → carol gets exit
+func TestShare_RevokeAndDowngradeAffectAttached(t *testing.T) {
+	const secret = "test-secret-share-revoke"
+	_, ts := newAuthTestServer(t, secret)
+
+	alice := dialAs(t, ts.URL, secret, "alice")
+	defer alice.Close()
+	bob := dialAs(t, ts.URL, secret, "bob")
+	defer bob.Close()
+	carol := dialAs(t, ts.URL, secret, "carol")
+	defer carol.Close()
+
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeCreate})
+	sessionID := readUntil(t, alice, ofType(terminal.MsgTypeSessionCreated)).SessionId
+
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeShare, SessionId: sessionID, ShareUser: "bob", Permission: "collaborator"})
+	readUntil(t, alice, ofType(terminal.MsgTypeShareCreated))
+	sendMsg(t, bob, terminal.ClientMessage{Type: terminal.MsgTypeAttach, SessionId: sessionID})
+	readUntil(t, bob, ofType(terminal.MsgTypeSessionAttached))
+
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeShare, SessionId: sessionID, ShareUser: "bob", Permission: "viewer"})
+	readUntil(t, bob, func(m terminal.ServerMessage) bool {
+		return m.Type == terminal.MsgTypePresence && len(m.Watchers) == 2 && m.Watchers[1].Permission == terminal.SharePermissionViewer
+	})
+	sendMsg(t, bob, terminal.ClientMessage{Type: terminal.MsgTypeInput, SessionId: sessionID, Data: "echo nope\r"})
+	if msg := readUntil(t, bob, ofType(terminal.MsgTypeError)); msg.Error != "permission denied" {
+		t.Errorf("downgraded input error = %q, want %q", msg.Error, "permission denied")
+	}
+
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeShare, SessionId: sessionID, Permission: "collaborator"})
+	link := readUntil(t, alice, func(m terminal.ServerMessage) bool {
+		return m.Type == terminal.MsgTypeShareCreated && m.ShareLink != nil
+	}).ShareLink
+	sendMsg(t, carol, terminal.ClientMessage{Type: terminal.MsgTypeAttach, SessionId: sessionID, ShareToken: link.Token})
+	readUntil(t, carol, ofType(terminal.MsgTypeSessionAttached))
+
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeUnshare, SessionId: sessionID, ShareToken: link.Token, RequestId: "u1"})
+	if ack := readUntil(t, alice, ofType(terminal.MsgTypeAck)); ack.RequestId != "u1" || ack.Data != terminal.MsgTypeUnshare {
+		t.Errorf("unshare ack = %+v", ack)
+	}
+	if msg := readUntil(t, carol, ofType(terminal.MsgTypeExit)); msg.SessionId != sessionID {
+		t.Errorf("revoked link exit session = %q, want %q", msg.SessionId, sessionID)
+	}
+	sendMsg(t, carol, terminal.ClientMessage{Type: terminal.MsgTypeInput, SessionId: sessionID, Data: "echo nope\r"})
+	if msg := readUntil(t, carol, ofType(terminal.MsgTypeError)); msg.Error == "" {
+		t.Error("input after link revoke should be rejected")
+	}
+}
+
+// Test Doc:
+// - Why: The poller only sends plugin_data and process_update on change, so a late joiner needs the current values on attach
+// - Contract: attach is followed by the session's current foreground process and last plugin data
+// - Worked Example: s1 runs npm and copilot reported {"model":"x"} → bob attaches → bob gets process_update npm and plugin_data copilot
+func TestShare_AttachSendsCurrentState(t *testing.T) {
+	const secret = "test-secret-share-state"
+	srv, ts := newAuthTestServer(t, secret)
+
+	alice := dialAs(t, ts.URL, secret, "alice")
+	defer alice.Close()
+	bob := dialAs(t, ts.URL, secret, "bob")
+	defer bob.Close()
+
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeCreate})
+	sessionID := readUntil(t, alice, ofType(terminal.MsgTypeSessionCreated)).SessionId
+	session := srv.registry.Get(sessionID)
+	session.SetForeground(&terminal.ForegroundProcess{PID: 42, Name: "npm", Command: "npm test"})
+	session.SendPluginData("copilot", json.RawMessage(`{"model":"x"}`))
+
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeShare, SessionId: sessionID, ShareUser: "bob", Permission: "viewer"})
+	readUntil(t, alice, ofType(terminal.MsgTypeShareCreated))
+	sendMsg(t, bob, terminal.ClientMessage{Type: terminal.MsgTypeAttach, SessionId: sessionID})
+	readUntil(t, bob, ofType(terminal.MsgTypeSessionAttached))
+
+	if msg := readUntil(t, bob, ofType(terminal.MsgTypeProcessUpdate)); msg.Foreground == nil || msg.Foreground.Name != "npm" {
+		t.Errorf("process_update on attach = %+v, want npm", msg.Foreground)
+	}
+	if msg := readUntil(t, bob, ofType(terminal.MsgTypePluginData)); msg.PluginId != "copilot" || string(msg.PluginData) != `{"model":"x"}` {
+		t.Errorf("plugin_data on attach = %s %s, want copilot data", msg.PluginId, msg.PluginData)
+	}
+}
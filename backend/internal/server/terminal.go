@@ -10,6 +10,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -17,6 +18,7 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/vaughanknight/trex/internal/audit"
 	"github.com/vaughanknight/trex/internal/auth"
 	"github.com/vaughanknight/trex/internal/terminal"
 )
@@ -33,6 +35,22 @@ func validateTmuxSessionName(name string) bool {
 	return len(name) > 0 && len(name) <= 256 && validTmuxSessionName.MatchString(name)
 }
 
+// validTmuxPaneID matches tmux pane IDs ("%" followed by a number).
+var validTmuxPaneID = regexp.MustCompile(`^%[0-9]+$`)
+
+// validateTmuxPaneID returns true if id is a well-formed tmux pane ID.
+func validateTmuxPaneID(id string) bool {
+	return len(id) <= 16 && validTmuxPaneID.MatchString(id)
+}
+
+const (
+	// wsWriteTimeout bounds one WebSocket write to a client.
+	wsWriteTimeout = 10 * time.Second
+	// tmuxListPanesTimeout bounds the session poller's `tmux list-panes`
+	// fallback in tmuxPanePids.
+	tmuxListPanesTimeout = 5 * time.Second
+)
+
 // pendingShellStart tracks a session whose process hasn't started yet.
 // The process is deferred until the first resize arrives from the frontend,
 // so the PTY is sized correctly before the process outputs its first prompt.
@@ -41,6 +59,9 @@ type pendingShellStart struct {
 	shellPath       string
 	tmuxSessionName string // Non-empty for tmux-attach sessions
 	tmuxWindowIndex int    // tmux window index (0 = default)
+	tmuxHost        string   // SSH host of the tmux session (empty = local)
+	tmuxSocket      string   // tmux server socket (empty = default)
+	sshCommand      []string // ssh program and options for tmuxHost
 	initialCwd      string // Initial working directory (empty = default)
 	started         atomic.Bool
 }
@@ -62,17 +83,26 @@ type connectionHandler struct {
 	server        *Server                            // back-reference for monitor control
 	sessions      map[string]*terminal.Session       // sessions active on this connection
 	pendingStarts map[string]*pendingShellStart       // sessions waiting for first resize to start shell
-	mu            sync.Mutex                          // protects sessions and pendingStarts maps
+	attached      map[string]*terminal.Session       // sessions owned elsewhere, attached via a share
+	mu            sync.Mutex                          // protects sessions, pendingStarts and attached maps
 	writeMu       sync.Mutex                          // protects WebSocket writes
 	authUser      *auth.GitHubUser                   // authenticated user (nil when auth disabled)
-	cwdDetector       terminal.CwdDetector               // detects session working directories
-	processDetector   terminal.ProcessDetector           // detects child process names
-	collectorRegistry *terminal.CollectorRegistry        // registered data collectors
-	cwdCancel         context.CancelFunc                 // cancels cwd polling goroutine
+	remoteAddr    string                             // client address, for audit events
+	cancel            context.CancelFunc                 // stops the idle/lifetime watcher
+	lockMu            sync.Mutex                         // protects lastInput and lockedAt
+	lastInput         time.Time                          // last input message, for the idle lock
+	lockedAt          time.Time                          // when the idle lock engaged (zero = unlocked)
+	syncGroups        map[string][]string                // source session → sessions its input is mirrored to (guarded by mu)
+	protoMu           sync.RWMutex                       // protects protocol and capabilities
+	protocol          int                                // protocol version from hello (0 = client never sent hello)
+	capabilities      []string                           // capabilities negotiated in hello
+	compression       *compressionCounters               // set when permessage-deflate was negotiated
+	compressThreshold int                                // smallest message to compress
 }
 
 // newConnectionHandler creates a handler for a WebSocket connection.
-func newConnectionHandler(conn *websocket.Conn, registry *terminal.SessionRegistry, server *Server) *connectionHandler {
+// user is nil when auth is disabled.
+func newConnectionHandler(conn *websocket.Conn, registry *terminal.SessionRegistry, server *Server, user *auth.GitHubUser, remoteAddr string) *connectionHandler {
 	ctx, cancel := context.WithCancel(context.Background())
 	h := &connectionHandler{
 		conn:          conn,
@@ -80,12 +110,16 @@ func newConnectionHandler(conn *websocket.Conn, registry *terminal.SessionRegist
 		server:        server,
 		sessions:      make(map[string]*terminal.Session),
 		pendingStarts: make(map[string]*pendingShellStart),
-		cwdDetector:       terminal.NewCwdDetector(),
-		processDetector:   terminal.NewProcessDetector(),
-		collectorRegistry: server.collectors,
-		cwdCancel:     cancel,
+		attached:      make(map[string]*terminal.Session),
+		syncGroups:    make(map[string][]string),
+		authUser:      user,
+		remoteAddr:    remoteAddr,
+		cancel:        cancel,
+		lastInput:     time.Now(),
+	}
+	if cfg := server.config; cfg != nil && (cfg.IdleTimeout > 0 || cfg.SessionMaxLifetime > 0) {
+		go h.watchTimeouts(ctx, cfg.IdleTimeout, cfg.SessionMaxLifetime)
 	}
-	go h.pollCwd(ctx)
 	return h
 }
 
@@ -96,16 +130,37 @@ func (s *Server) handleTerminal() http.HandlerFunc {
 		// Will be nil when auth is disabled.
 		user := auth.UserFromContext(r.Context())
 
-		// Upgrade HTTP connection to WebSocket
-		conn, err := upgrader.Upgrade(w, r, nil)
+		// Per-user throttle (per-IP limits are applied by middleware)
+		if user != nil {
+			if ok, wait := s.limiter.Allow(auth.UserKey(user.Username)); !ok {
+				auth.TooManyRequests(w, wait)
+				return
+			}
+		}
+
+		// Upgrade HTTP connection to WebSocket, with permessage-deflate if
+		// enabled and offered by the client
+		up := upgrader
+		level, threshold := s.wsCompression()
+		compress := level > 0 && offersDeflate(r)
+		if compress {
+			up.EnableCompression = true
+			w = countingResponseWriter{ResponseWriter: w, written: &s.compression.wireBytes}
+		}
+		conn, err := up.Upgrade(w, r, nil)
 		if err != nil {
 			log.Printf("WebSocket upgrade error: %v", err)
 			return
 		}
 
-		handler := newConnectionHandler(conn, s.registry, s)
-		handler.authUser = user
+		handler := newConnectionHandler(conn, s.registry, s, user, r.RemoteAddr)
 		defer handler.cleanup()
+		if compress {
+			conn.SetCompressionLevel(level)
+			handler.compression = &s.compression
+			handler.compressThreshold = threshold
+			s.compression.connections.Add(1)
+		}
 
 		if user != nil {
 			log.Printf("WebSocket connection established (user: %s)", user.Username)
@@ -119,7 +174,7 @@ func (s *Server) handleTerminal() http.HandlerFunc {
 
 // run processes messages from the WebSocket connection.
 func (h *connectionHandler) run() {
-	for {
+	for first := true; ; first = false {
 		_, data, err := h.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
@@ -131,16 +186,28 @@ func (h *connectionHandler) run() {
 		var msg terminal.ClientMessage
 		if err := json.Unmarshal(data, &msg); err != nil {
 			log.Printf("Invalid message format: %v", err)
-			h.sendError("", "invalid message format")
+			h.replyError(nil, "", terminal.ErrCodeInvalidArgument, "invalid message format")
 			continue
 		}
 
+		if msg.Type == terminal.MsgTypeHello {
+			if !h.handleHello(&msg, first) {
+				return
+			}
+			continue
+		}
 		h.handleMessage(&msg)
 	}
 }
 
 // handleMessage processes a single client message.
 func (h *connectionHandler) handleMessage(msg *terminal.ClientMessage) {
+	// A locked connection only accepts unlock
+	if msg.Type != terminal.MsgTypeUnlock && h.isLocked() {
+		h.replyError(msg, msg.SessionId, terminal.ErrCodeForbidden, "connection locked")
+		return
+	}
+
 	switch msg.Type {
 	case terminal.MsgTypeCreate:
 		h.handleCreate(msg)
@@ -158,21 +225,58 @@ func (h *connectionHandler) handleMessage(msg *terminal.ClientMessage) {
 		h.handleTmuxConfig(msg)
 
 	case terminal.MsgTypeListTmuxSessions:
-		h.handleListTmuxSessions()
+		h.handleListTmuxSessions(msg)
 
 	case terminal.MsgTypeDetach:
 		h.handleDetach(msg)
 
+	case terminal.MsgTypeTmuxCreate:
+		h.handleTmuxCreate(msg)
+
+	case terminal.MsgTypeTmuxRename:
+		h.handleTmuxRename(msg)
+
+	case terminal.MsgTypeTmuxKill:
+		h.handleTmuxKill(msg)
+
+	case terminal.MsgTypeShare:
+		h.handleShare(msg)
+
+	case terminal.MsgTypeUnshare:
+		h.handleUnshare(msg)
+
+	case terminal.MsgTypeAttach:
+		h.handleAttach(msg)
+
+	case terminal.MsgTypeUnlock:
+		h.handleUnlock(msg)
+
+	case terminal.MsgTypeBroadcastInput:
+		h.handleBroadcastInput(msg)
+
+	case terminal.MsgTypeSyncInput:
+		h.handleSyncInput(msg)
+
 	default:
 		log.Printf("Unknown message type: %s", msg.Type)
+		if h.negotiated() {
+			h.replyError(msg, msg.SessionId, terminal.ErrCodeInvalidArgument, fmt.Sprintf("unknown message type %q", msg.Type))
+		}
 	}
 }
 
-// handleClose closes a specific terminal session.
+// handleClose closes a specific terminal session. For a session attached via
+// a share, only this connection's attachment is removed.
 func (h *connectionHandler) handleClose(msg *terminal.ClientMessage) {
+	if viewed := h.attachedSession(msg.SessionId); viewed != nil {
+		h.detachViewer(viewed)
+		h.ack(msg)
+		return
+	}
+
 	session := h.getSession(msg.SessionId)
-	if session == nil {
-		h.sendError(msg.SessionId, "session not found")
+	if session == nil || session.PermissionFor(h.username()) != terminal.SharePermissionOwner {
+		h.replyError(msg, msg.SessionId, terminal.ErrCodeNotFound, "session not found")
 		return
 	}
 
@@ -184,13 +288,16 @@ func (h *connectionHandler) handleClose(msg *terminal.ClientMessage) {
 	delete(h.pendingStarts, msg.SessionId)
 	h.mu.Unlock()
 
-	// Close session gracefully
+	// Close session gracefully, notifying any attached viewers
+	session.CloseAttached()
 	session.CloseGracefully()
 
 	// Remove from registry
 	h.registry.Delete(msg.SessionId)
+	h.recordSession(audit.EventSessionClose, session, nil)
 
 	log.Printf("Session %s closed", msg.SessionId)
+	h.ack(msg)
 }
 
 // handleDetach detaches a tmux-attached session by closing the PTY.
@@ -198,9 +305,15 @@ func (h *connectionHandler) handleClose(msg *terminal.ClientMessage) {
 // from the tmux session without killing it. The tmux session survives.
 // Functionally similar to handleClose, but semantically different for the frontend.
 func (h *connectionHandler) handleDetach(msg *terminal.ClientMessage) {
+	if viewed := h.attachedSession(msg.SessionId); viewed != nil {
+		h.detachViewer(viewed)
+		h.ack(msg)
+		return
+	}
+
 	session := h.getSession(msg.SessionId)
-	if session == nil {
-		h.sendError(msg.SessionId, "session not found")
+	if session == nil || session.PermissionFor(h.username()) != terminal.SharePermissionOwner {
+		h.replyError(msg, msg.SessionId, terminal.ErrCodeNotFound, "session not found")
 		return
 	}
 
@@ -211,10 +324,13 @@ func (h *connectionHandler) handleDetach(msg *terminal.ClientMessage) {
 	delete(h.pendingStarts, msg.SessionId)
 	h.mu.Unlock()
 
+	session.CloseAttached()
 	session.CloseGracefully()
 	h.registry.Delete(msg.SessionId)
+	h.recordSession(audit.EventSessionDetach, session, nil)
 
 	log.Printf("Session %s detached", session.ID)
+	h.ack(msg)
 }
 
 // handleCreate creates a new terminal session.
@@ -225,21 +341,38 @@ func (h *connectionHandler) handleDetach(msg *terminal.ClientMessage) {
 //
 // For tmux-attach sessions (TmuxSessionName is set): Validates the session
 // name, checks tmux availability, and creates a PTY running
-// `tmux attach -t <name>` with TMUX env vars stripped. These sessions use
-// deferred start like regular sessions so the tmux client gets the correct
-// terminal size.
+// `tmux attach -t <name>` with TMUX env vars stripped, or
+// `ssh -t -- <host> tmux attach -t <name>` when TmuxHost names a configured
+// remote host. TmuxSocket picks a configured local tmux server, passed as
+// `-L`/`-S`. These sessions use deferred start like regular sessions so
+// the tmux client gets the correct terminal size.
 func (h *connectionHandler) handleCreate(msg *terminal.ClientMessage) {
+	// A single pane is streamed rather than attached through a PTY
+	if msg.TmuxPaneId != "" {
+		h.handleCreatePane(msg)
+		return
+	}
+
 	// If tmux target specified, validate before creating PTY
 	if msg.TmuxSessionName != "" {
 		if !validateTmuxSessionName(msg.TmuxSessionName) {
-			h.sendError("", "invalid tmux session name")
+			h.replyError(msg, "", terminal.ErrCodeInvalidArgument, "invalid tmux session name")
+			return
+		}
+		if msg.TmuxHost != "" && !h.isTmuxRemoteHost(msg.TmuxHost) {
+			h.replyError(msg, "", terminal.ErrCodeInvalidArgument, "unknown tmux host")
+			return
+		}
+		// Extra sockets are local servers; remote hosts use their default one
+		if msg.TmuxSocket != "" && (msg.TmuxHost != "" || !h.isTmuxSocket(msg.TmuxSocket)) {
+			h.replyError(msg, "", terminal.ErrCodeInvalidArgument, "unknown tmux socket")
 			return
 		}
 		// Check tmux is available
 		if h.server != nil && h.server.monitor != nil {
 			detector := h.server.monitor.GetDetector()
 			if !detector.IsAvailable() {
-				h.sendError("", "tmux not available")
+				h.replyError(msg, "", terminal.ErrCodeTmuxUnavailable, "tmux not available")
 				return
 			}
 		}
@@ -252,7 +385,7 @@ func (h *connectionHandler) handleCreate(msg *terminal.ClientMessage) {
 	realPTY, err := terminal.NewUnstartedPTY()
 	if err != nil {
 		log.Printf("PTY creation error: %v", err)
-		h.sendError("", "failed to create terminal")
+		h.replyCreateError(msg, err)
 		return
 	}
 
@@ -260,11 +393,15 @@ func (h *connectionHandler) handleCreate(msg *terminal.ClientMessage) {
 	var shellPath string
 	var tmuxSessionName string
 	var tmuxWindowIndex int
+	var tmuxHost string
+	var tmuxSocket string
 
 	if msg.TmuxSessionName != "" {
 		// tmux-attach session
 		tmuxSessionName = msg.TmuxSessionName
 		tmuxWindowIndex = msg.TmuxWindowIndex
+		tmuxHost = msg.TmuxHost
+		tmuxSocket = msg.TmuxSocket
 		shellType = "tmux"
 		shellPath = "tmux" // Used as placeholder for pendingShellStart
 	} else {
@@ -283,9 +420,9 @@ func (h *connectionHandler) handleCreate(msg *terminal.ClientMessage) {
 	session.Status = terminal.SessionStatusActive
 	session.TtyPath = realPTY.TtyPath
 	session.TmuxSessionName = tmuxSessionName
-	if h.authUser != nil {
-		session.Owner = h.authUser.Username
-	}
+	session.TmuxHost = tmuxHost
+	session.TmuxSocket = tmuxSocket
+	h.initSession(session, msg.Profile)
 
 	// Track pending shell start
 	ps := &pendingShellStart{
@@ -293,8 +430,13 @@ func (h *connectionHandler) handleCreate(msg *terminal.ClientMessage) {
 		shellPath:       shellPath,
 		tmuxSessionName: tmuxSessionName,
 		tmuxWindowIndex: tmuxWindowIndex,
+		tmuxHost:        tmuxHost,
+		tmuxSocket:      tmuxSocket,
 		initialCwd:      msg.Cwd,
 	}
+	if tmuxHost != "" {
+		ps.sshCommand = h.server.config.TmuxSSHCommand
+	}
 
 	// Add to registry, local map, and pending starts
 	h.registry.Add(session)
@@ -303,16 +445,29 @@ func (h *connectionHandler) handleCreate(msg *terminal.ClientMessage) {
 	h.pendingStarts[sessionID] = ps
 	h.mu.Unlock()
 
-	// Start PTY read goroutine — blocks on Read() until process starts and writes output
-	go session.RunReadPTY()
-
 	log.Printf("Created session %s (%s) [shell deferred until first resize]", session.ID, session.Name)
+	h.recordSession(audit.EventSessionCreate, session, nil)
+	h.publishSessionCreated(session)
+	if tmuxSessionName != "" {
+		h.recordSession(audit.EventTmuxAttach, session, map[string]string{"window": strconv.Itoa(tmuxWindowIndex)})
+	}
 
 	// Detect initial cwd (home directory before shell starts)
 	initialCwd, _ := os.UserHomeDir()
 
 	// Send session created response (frontend can now render the terminal)
-	h.sendSessionCreated(sessionID, shellType, session.Name, tmuxSessionName, tmuxWindowIndex, initialCwd)
+	h.sendSessionCreated(msg.RequestId, session, tmuxWindowIndex, initialCwd)
+
+	// Start PTY read goroutine — blocks on Read() until process starts and writes output.
+	// A tmux attach sends the pane history from this goroutine first, so the
+	// history reaches the client ahead of any live output and the capture (an
+	// ssh round trip for remote hosts) never stalls this connection's read loop.
+	go func() {
+		if tmuxSessionName != "" {
+			h.sendTmuxHistory(session, tmuxWindowIndex)
+		}
+		session.RunReadPTY()
+	}()
 
 	// Fallback: start shell after 500ms if no resize received.
 	// The active/visible terminal sends resize within ~50ms of mounting.
@@ -334,6 +489,44 @@ func (h *connectionHandler) handleCreate(msg *terminal.ClientMessage) {
 	}()
 }
 
+// isTmuxSocket reports whether socket is one of TREX_TMUX_SOCKETS.
+func (h *connectionHandler) isTmuxSocket(socket string) bool {
+	return h.server != nil && h.server.config != nil && slices.Contains(h.server.config.TmuxSockets, socket)
+}
+
+// isTmuxRemoteHost reports whether host is one of TREX_TMUX_REMOTE_HOSTS.
+// Only configured hosts can be attached to, so clients can't make trex ssh
+// anywhere else.
+func (h *connectionHandler) isTmuxRemoteHost(host string) bool {
+	return h.server != nil && h.server.config != nil && slices.Contains(h.server.config.TmuxRemoteHosts, host)
+}
+
+// initSession sets the profile, owner, input recorder and notifier of a new
+// session.
+func (h *connectionHandler) initSession(session *terminal.Session, profile string) {
+	session.Profile = profile
+	if session.Profile == "" {
+		session.Profile = terminal.DefaultProfile
+	}
+	if h.authUser != nil {
+		session.Owner = h.authUser.Username
+	}
+	if h.server != nil && h.server.inputAudit.Enabled(session.Profile) {
+		recorder, err := h.server.inputAudit.NewRecorder(session)
+		if err != nil {
+			log.Printf("Input audit unavailable for session %s: %v", session.ID, err)
+		} else {
+			session.SetInputRecorder(recorder)
+		}
+	}
+	if h.server != nil {
+		if notifier := h.server.notify.NewNotifier(session); notifier != nil {
+			session.SetNotifier(notifier)
+		}
+		h.server.watchSession(session)
+	}
+}
+
 // startPendingSession starts the appropriate process for a pending session:
 // either a regular shell or a tmux attach command.
 func startPendingSession(ps *pendingShellStart, realPTY *terminal.RealPTY, sessionID string) error {
@@ -343,8 +536,12 @@ func startPendingSession(ps *pendingShellStart, realPTY *terminal.RealPTY, sessi
 		if ps.tmuxWindowIndex > 0 {
 			target = fmt.Sprintf("%s:%d", ps.tmuxSessionName, ps.tmuxWindowIndex)
 		}
-		args := []string{"attach", "-t", target}
+		args := append(terminal.TmuxSocketArgs(ps.tmuxSocket), "attach", "-t", target)
 		env := append(terminal.FilterTmuxEnv(os.Environ()), "TERM=xterm-256color")
+		if ps.tmuxHost != "" {
+			name, sshArgs := terminal.SSHTmuxCommand(ps.sshCommand, ps.tmuxHost, true, args...)
+			return realPTY.StartCommand(name, sshArgs, env)
+		}
 		return realPTY.StartCommand("tmux", args, env)
 	}
 	// Regular shell — optionally start in a specific cwd
@@ -354,15 +551,16 @@ func startPendingSession(ps *pendingShellStart, realPTY *terminal.RealPTY, sessi
 	return realPTY.StartShell(ps.shellPath)
 }
 
-// handleInput forwards input to the appropriate session.
+// handleInput forwards input to the appropriate session, and to the
+// session's sync group if input to it is mirrored.
+// Viewers attached via a read-only share are rejected.
 func (h *connectionHandler) handleInput(msg *terminal.ClientMessage) {
-	session := h.getSession(msg.SessionId)
-	if session == nil {
-		h.sendError(msg.SessionId, "session not found")
+	if err := h.writeInput(msg.SessionId, msg.Data); err != nil {
+		h.replyError(msg, msg.SessionId, errorCode(err), err.Error())
 		return
 	}
-
-	session.WriteInput(msg.Data)
+	h.touchInput()
+	h.mirrorInput(msg.SessionId, msg.Data)
 }
 
 // handleResize forwards resize to the appropriate session.
@@ -382,10 +580,14 @@ func (h *connectionHandler) handleResize(msg *terminal.ClientMessage) {
 			h.mu.Unlock()
 		}
 		if session == nil {
-			h.sendError(msg.SessionId, "session not found")
+			h.replyError(msg, msg.SessionId, terminal.ErrCodeNotFound, "session not found")
 			return
 		}
 	}
+	if !h.canWrite(session) {
+		h.replyError(msg, msg.SessionId, terminal.ErrCodeForbidden, "permission denied")
+		return
+	}
 
 	// Check if this session has a pending shell start
 	h.mu.Lock()
@@ -426,16 +628,16 @@ func (h *connectionHandler) handleTmuxConfig(msg *terminal.ClientMessage) {
 }
 
 // handleListTmuxSessions responds with the current tmux session list from the monitor cache.
-func (h *connectionHandler) handleListTmuxSessions() {
+func (h *connectionHandler) handleListTmuxSessions(msg *terminal.ClientMessage) {
 	if h.server == nil || h.server.monitor == nil {
-		h.sendJSON(terminal.ServerMessage{
+		h.reply(msg, terminal.ServerMessage{
 			Type:         terminal.MsgTypeTmuxSessions,
 			TmuxSessions: nil,
 		})
 		return
 	}
 	sessions := h.server.monitor.GetLastSessions()
-	h.sendJSON(terminal.ServerMessage{
+	h.reply(msg, terminal.ServerMessage{
 		Type:         terminal.MsgTypeTmuxSessions,
 		TmuxSessions: sessions,
 	})
@@ -455,6 +657,11 @@ func (h *connectionHandler) getSession(sessionID string) *terminal.Session {
 		return session
 	}
 
+	// Check sessions attached via a share
+	if session := h.attachedSession(sessionID); session != nil {
+		return session
+	}
+
 	// Also check registry (session might have been created by another connection)
 	return h.registry.Get(sessionID)
 }
@@ -466,120 +673,87 @@ func (h *connectionHandler) cleanup() {
 	for _, s := range h.sessions {
 		sessions = append(sessions, s)
 	}
+	attached := make([]*terminal.Session, 0, len(h.attached))
+	for _, s := range h.attached {
+		attached = append(attached, s)
+	}
 	h.sessions = make(map[string]*terminal.Session)
 	h.pendingStarts = make(map[string]*pendingShellStart)
+	h.attached = make(map[string]*terminal.Session)
 	h.mu.Unlock()
 
+	for _, session := range attached {
+		if session.Detach(h) {
+			h.recordSession(audit.EventSessionDetach, session, map[string]string{"via": "disconnect"})
+			session.SendPresence()
+		}
+	}
+
 	for _, session := range sessions {
 		log.Printf("Cleaning up session %s", session.ID)
+		session.CloseAttached()
 		session.CloseGracefully()
 		h.registry.Delete(session.ID)
+		h.recordSession(audit.EventSessionClose, session, map[string]string{"via": "disconnect"})
 	}
 
 	h.conn.Close()
-	if h.cwdCancel != nil {
-		h.cwdCancel()
+	if h.cancel != nil {
+		h.cancel()
 	}
 }
 
-// pollCwd periodically detects working directories for active sessions.
-func (h *connectionHandler) pollCwd(ctx context.Context) {
-	ticker := time.NewTicker(5 * time.Second)
-	defer ticker.Stop()
-	pluginDataCache := make(map[string]string) // "sessionId:pluginId" → JSON string
+// tmuxSessionProcesses finds process names running inside the tmux session a
+// trex session is attached to. The PTY's child is just the tmux client, so
+// the session poller adds these to its process tree.
+// Pane PIDs come from the monitor's cached window list, falling back to
+// `tmux list-panes -t <session>`; each pane's process tree is then walked.
+func (s *Server) tmuxSessionProcesses(session *terminal.Session) []string {
+	if session.TmuxSessionName == "" || session.TmuxHost != "" {
+		return nil
+	}
+	var allProcesses []string
+	for _, pid := range s.tmuxPanePids(session.TmuxSessionName, session.TmuxSocket) {
+		processes := s.processes.DetectProcessTree(pid)
+		allProcesses = append(allProcesses, processes...)
+	}
+	return allProcesses
+}
 
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			h.mu.Lock()
-			sessions := make([]*terminal.Session, 0, len(h.sessions))
-			for _, s := range h.sessions {
-				if s.IsRunning() {
-					sessions = append(sessions, s)
-				}
+// tmuxPanePids returns the PIDs of every pane in a local tmux session on socket.
+func (s *Server) tmuxPanePids(tmuxSession, socket string) []int {
+	var pids []int
+	if s.monitor != nil {
+		for _, info := range s.monitor.GetLastSessions() {
+			if info.Name != tmuxSession || info.Host != "" || info.Socket != socket {
+				continue
 			}
-			h.mu.Unlock()
-
-			for _, session := range sessions {
-				pid := session.GetPid()
-				if pid <= 0 {
-					continue
-				}
-
-				// Detect cwd
-				cwd := h.cwdDetector.DetectCwd(pid)
-				if cwd != "" && cwd != session.Cwd {
-					session.Cwd = cwd
-					msg := terminal.ServerMessage{
-						SessionId: session.ID,
-						Type:      terminal.MsgTypeCwdUpdate,
-						Cwd:       cwd,
-					}
-					h.sendJSON(msg)
-				}
-
-				// Detect process tree and invoke matching collectors
-				processes := h.processDetector.DetectProcessTree(pid)
-				// For tmux sessions, the PTY's child is the tmux client which
-				// has no children. We need to find processes inside the tmux session.
-				if session.TmuxSessionName != "" {
-					tmuxProcesses := h.detectTmuxSessionProcesses(session.TmuxSessionName)
-					processes = append(processes, tmuxProcesses...)
-				}
-				if len(processes) == 0 {
-					continue
-				}
-				collectors := h.collectorRegistry.FindMatching(processes)
-				for _, collector := range collectors {
-					data, err := collector.CollectForSession(pid, session.Cwd)
-					if err != nil {
-						log.Printf("Collector %s error for session %s: %v", collector.ID(), session.ID, err)
-						continue
-					}
-					if data == nil {
-						continue
-					}
-
-					// Only send if data changed (cache check)
-					cacheKey := session.ID + ":" + collector.ID()
-					dataStr := string(data)
-					if pluginDataCache[cacheKey] == dataStr {
-						continue
-					}
-					pluginDataCache[cacheKey] = dataStr
-
-					msg := terminal.ServerMessage{
-						SessionId:  session.ID,
-						Type:       terminal.MsgTypePluginData,
-						PluginId:   collector.ID(),
-						PluginData: data,
-					}
-					h.sendJSON(msg)
+			for _, w := range info.WindowList {
+				for _, p := range w.Panes {
+					pids = append(pids, p.Pid)
 				}
 			}
 		}
 	}
-}
+	if len(pids) > 0 {
+		return pids
+	}
 
-// detectTmuxSessionProcesses finds process names running inside a tmux session.
-// Uses `tmux list-panes -t <session>` to get pane PIDs, then walks each tree.
-func (h *connectionHandler) detectTmuxSessionProcesses(tmuxSession string) []string {
-	out, err := exec.Command("tmux", "list-panes", "-t", tmuxSession, "-F", "#{pane_pid}").Output()
+	ctx, cancel := context.WithTimeout(context.Background(), tmuxListPanesTimeout)
+	defer cancel()
+	args := append(terminal.TmuxSocketArgs(socket), "list-panes", "-t", tmuxSession, "-F", "#{pane_pid}")
+	out, err := exec.CommandContext(ctx, "tmux", args...).Output()
 	if err != nil {
 		return nil
 	}
-	var allProcesses []string
 	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
 		pid, err := strconv.Atoi(strings.TrimSpace(line))
 		if err != nil || pid <= 0 {
 			continue
 		}
-		processes := h.processDetector.DetectProcessTree(pid)
-		allProcesses = append(allProcesses, processes...)
+		pids = append(pids, pid)
 	}
-	return allProcesses
+	return pids
 }
 
 // WriteMessage implements the terminal.Conn interface for sending messages.
@@ -587,7 +761,26 @@ func (h *connectionHandler) detectTmuxSessionProcesses(tmuxSession string) []str
 func (h *connectionHandler) WriteMessage(messageType int, data []byte) error {
 	h.writeMu.Lock()
 	defer h.writeMu.Unlock()
-	return h.conn.WriteMessage(messageType, data)
+	return h.writeMessage(messageType, data)
+}
+
+// writeMessage writes one message, compressing it only if it reaches the
+// compression threshold. A write that misses wsWriteTimeout closes the
+// connection, so a client that stops reading can't hold up the sessions and
+// poller writing to it. Callers must hold writeMu.
+func (h *connectionHandler) writeMessage(messageType int, data []byte) error {
+	if h.compression != nil {
+		h.conn.EnableWriteCompression(len(data) >= h.compressThreshold)
+		h.compression.payloadBytes.Add(int64(len(data)))
+	}
+	h.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if err := h.conn.WriteMessage(messageType, data); err != nil {
+		// The connection is unusable after a failed write; closing it ends
+		// the read loop, which cleans up.
+		h.conn.Close()
+		return err
+	}
+	return nil
 }
 
 // ReadMessage is not used by connectionHandler (it reads directly in run()).
@@ -600,28 +793,61 @@ func (h *connectionHandler) Close() error {
 	return h.conn.Close()
 }
 
-// sendSessionCreated sends a session_created message with optional tmux metadata.
-func (h *connectionHandler) sendSessionCreated(sessionID, shellType, name, tmuxSessionName string, tmuxWindowIndex int, cwd string) {
+// sendSessionCreated sends a session_created message with the session's
+// tmux metadata, if any. requestID is the create message's requestId.
+func (h *connectionHandler) sendSessionCreated(requestID string, session *terminal.Session, tmuxWindowIndex int, cwd string) {
 	msg := terminal.ServerMessage{
-		SessionId:       sessionID,
-		ShellType:       shellType,
+		RequestId:       requestID,
+		SessionId:       session.ID,
+		ShellType:       session.ShellType,
 		Type:            terminal.MsgTypeSessionCreated,
-		Data:            name,
-		TmuxSessionName: tmuxSessionName,
+		Data:            session.Name,
+		TmuxSessionName: session.TmuxSessionName,
 		TmuxWindowIndex: tmuxWindowIndex,
+		TmuxPaneId:      session.TmuxPaneID,
+		TmuxHost:        session.TmuxHost,
+		TmuxSocket:      session.TmuxSocket,
 		Cwd:             cwd,
 	}
 	h.sendJSON(msg)
 }
 
-// sendError sends an error message.
-func (h *connectionHandler) sendError(sessionID, errMsg string) {
-	msg := terminal.ServerMessage{
+// reply sends a reply to msg, echoing its requestId. msg may be nil when
+// the request couldn't be parsed.
+func (h *connectionHandler) reply(msg *terminal.ClientMessage, reply terminal.ServerMessage) {
+	if msg != nil {
+		reply.RequestId = msg.RequestId
+	}
+	h.sendJSON(reply)
+}
+
+// replyError sends an error reply to msg with a terminal.ErrCode* code.
+func (h *connectionHandler) replyError(msg *terminal.ClientMessage, sessionID, code, errMsg string) {
+	h.reply(msg, terminal.ServerMessage{
 		SessionId: sessionID,
 		Type:      terminal.MsgTypeError,
 		Error:     errMsg,
+		ErrorCode: code,
+	})
+}
+
+// replyCreateError reports a PTY that couldn't be created, telling clients
+// apart when the host ran out of pseudo-terminals.
+func (h *connectionHandler) replyCreateError(msg *terminal.ClientMessage, err error) {
+	if isPTYExhausted(err) {
+		h.replyError(msg, "", terminal.ErrCodePtyExhausted, "no pseudo-terminals available")
+		return
 	}
-	h.sendJSON(msg)
+	h.replyError(msg, "", terminal.ErrCodeInternal, "failed to create terminal")
+}
+
+// ack confirms a close or detach that succeeded.
+func (h *connectionHandler) ack(msg *terminal.ClientMessage) {
+	h.reply(msg, terminal.ServerMessage{
+		SessionId: msg.SessionId,
+		Type:      terminal.MsgTypeAck,
+		Data:      msg.Type,
+	})
 }
 
 // sendJSON sends a JSON message over the WebSocket.
@@ -634,7 +860,7 @@ func (h *connectionHandler) sendJSON(msg terminal.ServerMessage) {
 	}
 	h.writeMu.Lock()
 	defer h.writeMu.Unlock()
-	if err := h.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+	if err := h.writeMessage(websocket.TextMessage, data); err != nil {
 		log.Printf("Failed to send message: %v", err)
 	}
 }
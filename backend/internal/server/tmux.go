@@ -0,0 +1,426 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/vaughanknight/trex/internal/audit"
+	"github.com/vaughanknight/trex/internal/auth"
+	"github.com/vaughanknight/trex/internal/terminal"
+)
+
+// Errors for tmux session management, alongside terminal.ErrTmuxSession*.
+var (
+	errTmuxUnavailable       = errors.New("tmux not available")
+	errInvalidTmuxName       = errors.New("invalid tmux session name")
+	errInvalidTmuxCwd        = errors.New("cwd must be an existing absolute directory")
+	errTmuxKillNotConfirmed  = errors.New("confirm required to kill a tmux session")
+	errTmuxManageDefaultOnly = errors.New("tmux session management only works on the local default server")
+)
+
+// validateNewTmuxSessionName checks a name trex is about to give a session.
+// On top of validateTmuxSessionName it rejects ':' and '.', which tmux
+// silently replaces with '_', so the session would not get the name asked
+// for, and a leading '-', which tmux would read as a flag.
+func validateNewTmuxSessionName(name string) bool {
+	return validateTmuxSessionName(name) && !strings.ContainsAny(name, ":.") && !strings.HasPrefix(name, "-")
+}
+
+// tmuxRequest is the JSON body for POST and PATCH /api/tmux/sessions.
+type tmuxRequest struct {
+	Name    string `json:"name"`
+	Cwd     string `json:"cwd,omitempty"`
+	Command string `json:"command,omitempty"`
+}
+
+// tmuxManager returns the monitor's detector as a session manager.
+func (s *Server) tmuxManager() (terminal.TmuxSessionManager, error) {
+	if s.monitor == nil {
+		return nil, errTmuxUnavailable
+	}
+	detector := s.monitor.GetDetector()
+	manager, ok := detector.(terminal.TmuxSessionManager)
+	if !ok || !detector.IsAvailable() {
+		return nil, errTmuxUnavailable
+	}
+	return manager, nil
+}
+
+// createTmuxSession creates a detached tmux session and refreshes the monitor
+// so the tmux_sessions broadcast goes out before the caller replies.
+func (s *Server) createTmuxSession(actor, remote, name, cwd, command string) error {
+	if !validateNewTmuxSessionName(name) {
+		return errInvalidTmuxName
+	}
+	if cwd != "" {
+		if info, err := os.Stat(cwd); !filepath.IsAbs(cwd) || err != nil || !info.IsDir() {
+			return errInvalidTmuxCwd
+		}
+	}
+	manager, err := s.tmuxManager()
+	if err != nil {
+		return err
+	}
+	if err := manager.NewSession(name, cwd, command); err != nil {
+		return err
+	}
+
+	log.Printf("Created tmux session %q", name)
+	detail := map[string]string{"name": name}
+	if command != "" {
+		detail["command"] = command
+	}
+	s.recordTmux(audit.EventTmuxCreate, actor, remote, detail)
+	s.monitor.Refresh()
+	return nil
+}
+
+// renameTmuxSession renames a tmux session. Attached trex sessions pick up
+// the new name through the usual tmux_status update.
+func (s *Server) renameTmuxSession(actor, remote, oldName, newName string) error {
+	if !validateTmuxSessionName(oldName) || !validateNewTmuxSessionName(newName) {
+		return errInvalidTmuxName
+	}
+	manager, err := s.tmuxManager()
+	if err != nil {
+		return err
+	}
+	if err := manager.RenameSession(oldName, newName); err != nil {
+		return err
+	}
+
+	log.Printf("Renamed tmux session %q to %q", oldName, newName)
+	s.recordTmux(audit.EventTmuxRename, actor, remote, map[string]string{"name": oldName, "newName": newName})
+	s.monitor.Refresh()
+	return nil
+}
+
+// killTmuxSession kills a tmux session, which ends every process in it, so
+// the caller has to confirm explicitly.
+func (s *Server) killTmuxSession(actor, remote, name string, confirm bool) error {
+	if !validateTmuxSessionName(name) {
+		return errInvalidTmuxName
+	}
+	if !confirm {
+		return errTmuxKillNotConfirmed
+	}
+	manager, err := s.tmuxManager()
+	if err != nil {
+		return err
+	}
+	if err := manager.KillSession(name); err != nil {
+		return err
+	}
+
+	log.Printf("Killed tmux session %q", name)
+	s.recordTmux(audit.EventTmuxKill, actor, remote, map[string]string{"name": name})
+	s.monitor.Refresh()
+	return nil
+}
+
+// recordTmux appends a tmux management event to the audit log.
+func (s *Server) recordTmux(eventType, actor, remote string, detail map[string]string) {
+	s.auditLog.Record(audit.Event{
+		Type:    eventType,
+		Actor:   actor,
+		Remote:  remote,
+		Outcome: audit.OutcomeSuccess,
+		Detail:  detail,
+	})
+}
+
+// findTmuxSession returns the monitor's cached info for a local session.
+func (s *Server) findTmuxSession(name string) terminal.TmuxSessionInfo {
+	for _, info := range s.monitor.GetLastSessions() {
+		if info.Name == name && info.Host == "" && info.Socket == "" {
+			return info
+		}
+	}
+	return terminal.TmuxSessionInfo{Name: name}
+}
+
+// tmuxErrorStatus maps a tmux management error to an HTTP status.
+func tmuxErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errInvalidTmuxName), errors.Is(err, errInvalidTmuxCwd), errors.Is(err, errTmuxKillNotConfirmed),
+		errors.Is(err, errTmuxManageDefaultOnly):
+		return http.StatusBadRequest
+	case errors.Is(err, terminal.ErrTmuxSessionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, terminal.ErrTmuxSessionExists):
+		return http.StatusConflict
+	case errors.Is(err, errTmuxUnavailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeTmuxError writes a tmux management error, hiding unexpected tmux
+// output behind a generic message.
+func writeTmuxError(w http.ResponseWriter, err error) {
+	status := tmuxErrorStatus(err)
+	if status == http.StatusInternalServerError {
+		log.Printf("tmux management error: %v", err)
+		http.Error(w, "tmux command failed", status)
+		return
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// handleTmuxSessions handles GET /api/tmux/sessions (the monitor's cached
+// list) and POST /api/tmux/sessions to create a session.
+func (s *Server) handleTmuxSessions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			sessions := s.monitor.GetLastSessions()
+			if sessions == nil {
+				sessions = []terminal.TmuxSessionInfo{}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(sessions)
+
+		case http.MethodPost:
+			var req tmuxRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "invalid request body", http.StatusBadRequest)
+				return
+			}
+			if err := s.createTmuxSession(actorOf(r), r.RemoteAddr, req.Name, req.Cwd, req.Command); err != nil {
+				writeTmuxError(w, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(s.findTmuxSession(req.Name))
+
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// handleTmuxSession handles PATCH /api/tmux/sessions/:name to rename a
+// session ({"name": "new"}) and DELETE /api/tmux/sessions/:name?confirm=true
+// to kill it. Names are path-escaped.
+func (s *Server) handleTmuxSession() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name, err := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), "/api/tmux/sessions/"))
+		if err != nil || name == "" {
+			http.Error(w, "tmux session name required", http.StatusBadRequest)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodPatch:
+			var req tmuxRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "invalid request body", http.StatusBadRequest)
+				return
+			}
+			if err := s.renameTmuxSession(actorOf(r), r.RemoteAddr, name, req.Name); err != nil {
+				writeTmuxError(w, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(s.findTmuxSession(req.Name))
+
+		case http.MethodDelete:
+			confirm := r.URL.Query().Get("confirm") == "true"
+			if err := s.killTmuxSession(actorOf(r), r.RemoteAddr, name, confirm); err != nil {
+				writeTmuxError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// actorOf returns the authenticated username, or "" when auth is disabled.
+func actorOf(r *http.Request) string {
+	if user := auth.UserFromContext(r.Context()); user != nil {
+		return user.Username
+	}
+	return ""
+}
+
+// handleTmuxCreate creates a tmux session from a tmux_create message.
+func (h *connectionHandler) handleTmuxCreate(msg *terminal.ClientMessage) {
+	if msg.TmuxHost != "" || msg.TmuxSocket != "" {
+		h.replyTmux(msg, errTmuxManageDefaultOnly)
+		return
+	}
+	h.replyTmux(msg, h.server.createTmuxSession(h.username(), h.remoteAddr, msg.TmuxSessionName, msg.Cwd, msg.Command))
+}
+
+// handleTmuxRename renames a tmux session from a tmux_rename message.
+func (h *connectionHandler) handleTmuxRename(msg *terminal.ClientMessage) {
+	if msg.TmuxHost != "" || msg.TmuxSocket != "" {
+		h.replyTmux(msg, errTmuxManageDefaultOnly)
+		return
+	}
+	h.replyTmux(msg, h.server.renameTmuxSession(h.username(), h.remoteAddr, msg.TmuxSessionName, msg.TmuxNewName))
+}
+
+// handleTmuxKill kills a tmux session from a tmux_kill message.
+func (h *connectionHandler) handleTmuxKill(msg *terminal.ClientMessage) {
+	if msg.TmuxHost != "" || msg.TmuxSocket != "" {
+		h.replyTmux(msg, errTmuxManageDefaultOnly)
+		return
+	}
+	h.replyTmux(msg, h.server.killTmuxSession(h.username(), h.remoteAddr, msg.TmuxSessionName, msg.Confirm))
+}
+
+// replyTmux answers a tmux management message: an error, or the refreshed
+// session list. The list is sent directly because the broadcast only reaches
+// connections that own a trex session.
+func (h *connectionHandler) replyTmux(msg *terminal.ClientMessage, err error) {
+	if err != nil {
+		code := errorCode(err)
+		if code == terminal.ErrCodeInternal {
+			log.Printf("tmux management error: %v", err)
+			err = errors.New("tmux command failed")
+		}
+		h.replyError(msg, "", code, err.Error())
+		return
+	}
+	h.handleListTmuxSessions(msg)
+}
+
+// tmuxSource returns the detector for one tmux server (see
+// MultiHostTmuxDetector.Source), or nil if it isn't configured.
+func (s *Server) tmuxSource(host, socket string) terminal.TmuxDetector {
+	detector := s.monitor.GetDetector()
+	if multi, ok := detector.(*terminal.MultiHostTmuxDetector); ok {
+		return multi.Source(host, socket)
+	}
+	if host == "" && socket == "" {
+		return detector
+	}
+	return nil
+}
+
+// sendTmuxHistory sends the attached pane's history (TREX_TMUX_HISTORY_LINES
+// lines of capture-pane) as output ahead of the live screen, so it ends up in
+// the browser's scrollback. handleCreate calls it on the session's PTY read
+// goroutine before reading starts, so attach output held in the PTY can't
+// overtake it. Failures only cost the history.
+func (h *connectionHandler) sendTmuxHistory(session *terminal.Session, windowIndex int) {
+	if h.server == nil || h.server.monitor == nil || h.server.config == nil || h.server.config.TmuxHistoryLines <= 0 {
+		return
+	}
+	if !h.hasCapability(terminal.CapScrollbackReplay) {
+		return
+	}
+	capturer, ok := h.server.tmuxSource(session.TmuxHost, session.TmuxSocket).(terminal.TmuxHistoryCapturer)
+	if !ok {
+		return
+	}
+	history, err := capturer.CaptureHistory(session.TmuxSessionName, windowIndex, h.server.config.TmuxHistoryLines)
+	if err != nil {
+		log.Printf("tmux history capture for session %s: %v", session.ID, err)
+		return
+	}
+	if len(history) == 0 {
+		return
+	}
+	h.sendJSON(terminal.ServerMessage{
+		SessionId: session.ID,
+		ShellType: session.ShellType,
+		Type:      terminal.MsgTypeOutput,
+		Data:      string(history),
+	})
+}
+
+// findTmuxPane looks a local pane up in the monitor's cached window list and
+// returns the session and window it belongs to.
+func (s *Server) findTmuxPane(paneID string) (sessionName string, windowIndex int, pane terminal.TmuxPane, ok bool) {
+	for _, info := range s.monitor.GetLastSessions() {
+		if info.Host != "" || info.Socket != "" {
+			continue // Pane IDs are only unique per server
+		}
+		for _, w := range info.WindowList {
+			for _, p := range w.Panes {
+				if p.ID == paneID {
+					return info.Name, w.Index, p, true
+				}
+			}
+		}
+	}
+	return "", 0, terminal.TmuxPane{}, false
+}
+
+// handleCreatePane creates a session that streams a single tmux pane
+// (create with tmuxPaneId). The pane is found in the monitor's window list,
+// so it must have been seen by a refresh. Closing the session detaches the
+// streaming client; the pane and its layout are left untouched.
+func (h *connectionHandler) handleCreatePane(msg *terminal.ClientMessage) {
+	if !validateTmuxPaneID(msg.TmuxPaneId) {
+		h.replyError(msg, "", terminal.ErrCodeInvalidArgument, "invalid tmux pane id")
+		return
+	}
+	if msg.TmuxHost != "" || msg.TmuxSocket != "" {
+		h.replyError(msg, "", terminal.ErrCodeInvalidArgument, "tmux panes can only be streamed from the local default server")
+		return
+	}
+	if h.server == nil || h.server.monitor == nil || !h.server.monitor.GetDetector().IsAvailable() {
+		h.replyError(msg, "", terminal.ErrCodeTmuxUnavailable, "tmux not available")
+		return
+	}
+	opener, ok := h.server.monitor.GetDetector().(terminal.TmuxPaneOpener)
+	if !ok {
+		h.replyError(msg, "", terminal.ErrCodeTmuxUnavailable, "tmux not available")
+		return
+	}
+	tmuxSessionName, windowIndex, pane, ok := h.server.findTmuxPane(msg.TmuxPaneId)
+	if !ok {
+		h.replyError(msg, "", terminal.ErrCodeNotFound, terminal.ErrTmuxPaneNotFound.Error())
+		return
+	}
+
+	pty, err := opener.OpenPane(tmuxSessionName, pane.ID)
+	if err != nil {
+		if errors.Is(err, terminal.ErrTmuxPaneNotFound) || errors.Is(err, terminal.ErrTmuxSessionNotFound) {
+			h.replyError(msg, "", terminal.ErrCodeNotFound, terminal.ErrTmuxPaneNotFound.Error())
+			return
+		}
+		log.Printf("tmux pane %s open error: %v", pane.ID, err)
+		h.replyCreateError(msg, err)
+		return
+	}
+
+	sessionID := h.registry.NextID()
+	session := terminal.NewSessionWithConn(sessionID, pty, h)
+	session.Name = "tmux-" + sessionID[1:]
+	session.ShellType = "tmux"
+	session.Status = terminal.SessionStatusActive
+	session.TmuxSessionName = tmuxSessionName
+	session.TmuxPaneID = pane.ID
+	session.SetCwd(pane.Cwd)
+	h.initSession(session, msg.Profile)
+
+	h.registry.Add(session)
+	h.mu.Lock()
+	h.sessions[sessionID] = session
+	h.mu.Unlock()
+
+	go session.RunReadPTY()
+
+	log.Printf("Created session %s streaming tmux pane %s (%s:%d)", session.ID, pane.ID, tmuxSessionName, windowIndex)
+	h.recordSession(audit.EventSessionCreate, session, nil)
+	h.publishSessionCreated(session)
+	h.recordSession(audit.EventTmuxAttach, session, map[string]string{"window": strconv.Itoa(windowIndex), "pane": pane.ID})
+
+	h.sendSessionCreated(msg.RequestId, session, windowIndex, pane.Cwd)
+}
@@ -0,0 +1,401 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vaughanknight/trex/internal/config"
+	"github.com/vaughanknight/trex/internal/terminal"
+)
+
+// useFakeTmux replaces srv's tmux monitor with one backed by a fake detector.
+func useFakeTmux(t *testing.T, srv *Server) *terminal.FakeTmuxDetector {
+	t.Helper()
+	srv.monitor.Stop()
+	fake := terminal.NewFakeTmuxDetector()
+	srv.monitor = terminal.NewTmuxMonitor(fake, srv.registry, time.Hour, srv.handleTmuxChanges, srv.handleSessionsChanged)
+	srv.monitor.Start()
+	return fake
+}
+
+// tmuxNames returns the session names in a tmux_sessions message.
+func tmuxNames(msg terminal.ServerMessage) []string {
+	names := make([]string, 0, len(msg.TmuxSessions))
+	for _, s := range msg.TmuxSessions {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+// Test Doc:
+// - Why: tmux sessions can be managed from trex, not just listed and attached
+// - Contract: tmux_create/tmux_rename/tmux_kill (and /api/tmux/sessions) validate names (no control characters, ':', '.' or leading '-'), require confirm to kill, and the change is in the tmux_sessions broadcast before the reply
+// - Usage Notes: useFakeTmux swaps the server's monitor for one over FakeTmuxDetector (1h interval, so only the explicit refresh after each change can broadcast)
+// - Quality Contribution: Proves changes are pushed immediately to other connections, not on the next poll
+// - Worked Example: tmux_create "work" → tmux_sessions [work] on both sockets → tmux_kill without confirm → error
+
+func TestTmuxManage_WebSocket(t *testing.T) {
+	const secret = "test-secret-tmux"
+	srv, ts := newAuthTestServer(t, secret)
+	useFakeTmux(t, srv)
+
+	alice := dialAs(t, ts.URL, secret, "alice")
+	defer alice.Close()
+	bob := dialAs(t, ts.URL, secret, "bob")
+	defer bob.Close()
+
+	// The broadcast reaches connections that own a trex session
+	sendMsg(t, bob, terminal.ClientMessage{Type: terminal.MsgTypeCreate})
+	readUntil(t, bob, ofType(terminal.MsgTypeSessionCreated))
+
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeTmuxCreate, TmuxSessionName: "work", Cwd: t.TempDir(), Command: "top"})
+	if names := tmuxNames(readUntil(t, alice, ofType(terminal.MsgTypeTmuxSessions))); len(names) != 1 || names[0] != "work" {
+		t.Errorf("alice tmux_sessions = %v, want [work]", names)
+	}
+	if names := tmuxNames(readUntil(t, bob, ofType(terminal.MsgTypeTmuxSessions))); len(names) != 1 || names[0] != "work" {
+		t.Errorf("bob broadcast = %v, want [work]", names)
+	}
+
+	errorCases := []struct {
+		msg  terminal.ClientMessage
+		want string
+	}{
+		{terminal.ClientMessage{Type: terminal.MsgTypeTmuxCreate, TmuxSessionName: "work"}, "tmux session already exists"},
+		{terminal.ClientMessage{Type: terminal.MsgTypeTmuxCreate, TmuxSessionName: "bad\x01name"}, "invalid tmux session name"},
+		{terminal.ClientMessage{Type: terminal.MsgTypeTmuxCreate, TmuxSessionName: "a:b"}, "invalid tmux session name"},
+		{terminal.ClientMessage{Type: terminal.MsgTypeTmuxCreate, TmuxSessionName: "-x"}, "invalid tmux session name"},
+		{terminal.ClientMessage{Type: terminal.MsgTypeTmuxRename, TmuxSessionName: "work", TmuxNewName: "-t=other"}, "invalid tmux session name"},
+		{terminal.ClientMessage{Type: terminal.MsgTypeTmuxCreate, TmuxSessionName: "x", Cwd: "relative/dir"}, "cwd must be an existing absolute directory"},
+		{terminal.ClientMessage{Type: terminal.MsgTypeTmuxRename, TmuxSessionName: "nope", TmuxNewName: "x"}, "tmux session not found"},
+		{terminal.ClientMessage{Type: terminal.MsgTypeTmuxKill, TmuxSessionName: "work"}, "confirm required to kill a tmux session"},
+	}
+	for _, tc := range errorCases {
+		sendMsg(t, alice, tc.msg)
+		if msg := readUntil(t, alice, ofType(terminal.MsgTypeError)); msg.Error != tc.want {
+			t.Errorf("%s %q error = %q, want %q", tc.msg.Type, tc.msg.TmuxSessionName, msg.Error, tc.want)
+		}
+	}
+
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeTmuxRename, TmuxSessionName: "work", TmuxNewName: "play"})
+	if names := tmuxNames(readUntil(t, alice, ofType(terminal.MsgTypeTmuxSessions))); len(names) != 1 || names[0] != "play" {
+		t.Errorf("after rename = %v, want [play]", names)
+	}
+
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeTmuxKill, TmuxSessionName: "play", Confirm: true})
+	if names := tmuxNames(readUntil(t, alice, ofType(terminal.MsgTypeTmuxSessions))); len(names) != 0 {
+		t.Errorf("after kill = %v, want none", names)
+	}
+}
+
+func TestTmuxManage_REST(t *testing.T) {
+	srv := New("test-version", &config.Config{BindAddress: "127.0.0.1:0"})
+	t.Cleanup(srv.Shutdown)
+	fake := useFakeTmux(t, srv)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodPost, "/api/tmux/sessions", `{"name":"work"}`, http.StatusCreated},
+		{http.MethodPost, "/api/tmux/sessions", `{"name":"work"}`, http.StatusConflict},
+		{http.MethodPost, "/api/tmux/sessions", `{"name":""}`, http.StatusBadRequest},
+		{http.MethodPatch, "/api/tmux/sessions/work", `{"name":"my%20work"}`, http.StatusOK},
+		{http.MethodPatch, "/api/tmux/sessions/work", `{"name":"x"}`, http.StatusNotFound},
+		{http.MethodDelete, "/api/tmux/sessions/my%2520work", "", http.StatusBadRequest},
+		{http.MethodDelete, "/api/tmux/sessions/my%2520work?confirm=true", "", http.StatusNoContent},
+		{http.MethodDelete, "/api/tmux/sessions/my%2520work?confirm=true", "", http.StatusNotFound},
+		{http.MethodPut, "/api/tmux/sessions", "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		if rec := do(tt.method, tt.path, tt.body); rec.Code != tt.want {
+			t.Errorf("%s %s %s = %d, want %d (%s)", tt.method, tt.path, tt.body, rec.Code, tt.want, strings.TrimSpace(rec.Body.String()))
+		}
+	}
+
+	fake.SetUnavailable()
+	if rec := do(http.MethodPost, "/api/tmux/sessions", `{"name":"work"}`); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("POST with tmux unavailable = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+}
+
+// Test Doc:
+// - Why: One pane of a multi-pane window can be opened as its own trex terminal
+// - Contract: create with tmuxPaneId opens the pane through the detector; session_created carries the pane, its session and window; closing leaves the pane in place
+// - Usage Notes: FakeTmuxDetector.OpenPane returns a FakePTY, inspected with PanePTY
+// - Quality Contribution: Proves input reaches the pane and that close only detaches
+// - Worked Example: work:1 has panes %7,%8 → create {tmuxPaneId:"%8"} → input "ls\r" lands on %8 → close → %8 still listed
+func TestTmuxPane_CreateAndClose(t *testing.T) {
+	const secret = "test-secret-pane"
+	srv, ts := newAuthTestServer(t, secret)
+	fake := useFakeTmux(t, srv)
+	fake.AddSession("work", 0, 0)
+	fake.AddWindow("work", terminal.TmuxWindow{Index: 1, Name: "agents", Panes: []terminal.TmuxPane{
+		{ID: "%7", Command: "bash", Cwd: "/srv"},
+		{ID: "%8", Command: "agent", Cwd: "/srv/agent", Pid: 4321},
+	}})
+	srv.monitor.Refresh()
+
+	alice := dialAs(t, ts.URL, secret, "alice")
+	defer alice.Close()
+
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeCreate, TmuxPaneId: "%8"})
+	created := readUntil(t, alice, ofType(terminal.MsgTypeSessionCreated))
+	if created.TmuxPaneId != "%8" || created.TmuxSessionName != "work" || created.TmuxWindowIndex != 1 || created.Cwd != "/srv/agent" {
+		t.Errorf("session_created = %+v, want pane %%8 of work:1 in /srv/agent", created)
+	}
+	if info := srv.registry.Get(created.SessionId).Info(); info.TmuxPaneID != "%8" {
+		t.Errorf("SessionInfo.TmuxPaneID = %q, want %%8", info.TmuxPaneID)
+	}
+
+	pane := fake.PanePTY("%8")
+	if pane == nil {
+		t.Fatal("pane %8 was not opened")
+	}
+	pane.SimulateOutput("agent> ")
+	if msg := readUntil(t, alice, ofType(terminal.MsgTypeOutput)); msg.Data != "agent> " {
+		t.Errorf("output = %q, want %q", msg.Data, "agent> ")
+	}
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeInput, SessionId: created.SessionId, Data: "ls\r"})
+
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeClose, SessionId: created.SessionId})
+	deadline := time.Now().Add(time.Second)
+	for !pane.IsClosed() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := pane.GetInput(); got != "ls\r" {
+		t.Errorf("pane input = %q, want %q", got, "ls\r")
+	}
+	if !pane.IsClosed() {
+		t.Error("closing the session did not close the pane stream")
+	}
+	if sessions, _ := fake.ListSessions(); len(sessions) != 1 || len(sessions[0].WindowList[0].Panes) != 2 {
+		t.Errorf("tmux sessions after close = %+v, want the pane untouched", sessions)
+	}
+
+	for id, want := range map[string]string{"7": "invalid tmux pane id", "%99": "tmux pane not found"} {
+		sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeCreate, TmuxPaneId: id})
+		if msg := readUntil(t, alice, ofType(terminal.MsgTypeError)); msg.Error != want {
+			t.Errorf("create pane %q error = %q, want %q", id, msg.Error, want)
+		}
+	}
+}
+
+// fakeTmuxScript logs the arguments of `tmux [-L name] attach`, one per line,
+// next to itself, in a single printf. Other commands (from monitors of other
+// test servers) are ignored.
+const fakeTmuxScript = `#!/bin/sh
+case " $* " in *" attach "*) ;; *) exit 1 ;; esac
+printf '%s\n' "$@" >> "$(dirname "$0")/tmux.log"
+`
+
+// waitForTmuxLog polls the fake tmux log in dir until it holds want (the
+// logged arguments joined by spaces) or two seconds pass, and returns what
+// it last read.
+func waitForTmuxLog(t *testing.T, dir, want string) string {
+	t.Helper()
+	var got string
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		logged, _ := os.ReadFile(filepath.Join(dir, "tmux.log"))
+		if got = strings.Join(strings.Fields(string(logged)), " "); got == want {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return got
+}
+
+// Test Doc:
+// - Why: Sessions on remote tmux hosts are attached through an SSH-backed PTY
+// - Contract: create with tmuxHost must name a configured host; the PTY runs ssh -t -- host tmux attach -t name; session_created and SessionInfo carry the host; management messages with a host are rejected
+// - Usage Notes: A fake ssh wrapper and fake tmux script on PATH stand in for the remote host; the first resize starts the command
+// - Quality Contribution: Proves clients can't point trex at arbitrary hosts and that the attach reaches the right session
+// - Worked Example: TREX_TMUX_REMOTE_HOSTS=devvm → create {tmuxSessionName:"build", tmuxHost:"devvm"} → remote tmux runs "attach -t build"
+func TestTmuxRemote_Attach(t *testing.T) {
+	dir := t.TempDir()
+	for name, script := range map[string]string{"ssh": terminal.FakeSSHScript, "tmux": fakeTmuxScript} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	const secret = "test-secret-remote"
+	srv, ts := newAuthTestServer(t, secret)
+	useFakeTmux(t, srv)
+	srv.config.TmuxRemoteHosts = []string{"devvm"}
+	srv.config.TmuxSSHCommand = []string{filepath.Join(dir, "ssh"), "-o", "BatchMode=yes"}
+
+	alice := dialAs(t, ts.URL, secret, "alice")
+	defer alice.Close()
+
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeCreate, TmuxSessionName: "build", TmuxHost: "devvm"})
+	created := readUntil(t, alice, ofType(terminal.MsgTypeSessionCreated))
+	if created.TmuxHost != "devvm" || created.TmuxSessionName != "build" {
+		t.Errorf("session_created = %+v, want build on devvm", created)
+	}
+	if info := srv.registry.Get(created.SessionId).Info(); info.TmuxHost != "devvm" {
+		t.Errorf("SessionInfo.TmuxHost = %q, want devvm", info.TmuxHost)
+	}
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeResize, SessionId: created.SessionId, Cols: 80, Rows: 24})
+
+	if got := waitForTmuxLog(t, dir, "attach -t build"); got != "attach -t build" {
+		t.Errorf("remote tmux args = %q, want attach -t build", got)
+	}
+
+	errorCases := []struct {
+		msg  terminal.ClientMessage
+		want string
+	}{
+		{terminal.ClientMessage{Type: terminal.MsgTypeCreate, TmuxSessionName: "build", TmuxHost: "elsewhere"}, "unknown tmux host"},
+		{terminal.ClientMessage{Type: terminal.MsgTypeTmuxKill, TmuxSessionName: "build", TmuxHost: "devvm", Confirm: true}, "tmux session management only works on the local default server"},
+	}
+	for _, tc := range errorCases {
+		sendMsg(t, alice, tc.msg)
+		if msg := readUntil(t, alice, ofType(terminal.MsgTypeError)); msg.Error != tc.want {
+			t.Errorf("%s on %q error = %q, want %q", tc.msg.Type, tc.msg.TmuxHost, msg.Error, tc.want)
+		}
+	}
+}
+
+// Test Doc:
+// - Why: Sessions on a separate local tmux server (e.g. `tmux -L agents`) are attached on that server
+// - Contract: create with tmuxSocket must name a configured socket (and no host); the PTY runs tmux -L/-S <socket> attach -t name; session_created carries the socket; management messages with a socket are rejected
+// - Usage Notes: A fake tmux script on PATH logs the attach arguments; the first resize starts the command
+// - Quality Contribution: Proves attach doesn't silently land on the default server's session of the same name
+// - Worked Example: TREX_TMUX_SOCKETS=agents → create {tmuxSessionName:"bot", tmuxSocket:"agents"} → tmux -L agents attach -t bot
+func TestTmuxSocket_Attach(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "tmux"), []byte(fakeTmuxScript), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	const secret = "test-secret-socket"
+	srv, ts := newAuthTestServer(t, secret)
+	useFakeTmux(t, srv)
+	srv.config.TmuxSockets = []string{"agents"}
+	srv.config.TmuxRemoteHosts = []string{"devvm"}
+
+	alice := dialAs(t, ts.URL, secret, "alice")
+	defer alice.Close()
+
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeCreate, TmuxSessionName: "bot", TmuxSocket: "agents"})
+	created := readUntil(t, alice, ofType(terminal.MsgTypeSessionCreated))
+	if created.TmuxSocket != "agents" || srv.registry.Get(created.SessionId).Info().TmuxSocket != "agents" {
+		t.Errorf("session_created = %+v, want socket agents", created)
+	}
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeResize, SessionId: created.SessionId, Cols: 80, Rows: 24})
+
+	if got := waitForTmuxLog(t, dir, "-L agents attach -t bot"); got != "-L agents attach -t bot" {
+		t.Errorf("tmux args = %q, want -L agents attach -t bot", got)
+	}
+
+	errorCases := []struct {
+		msg  terminal.ClientMessage
+		want string
+	}{
+		{terminal.ClientMessage{Type: terminal.MsgTypeCreate, TmuxSessionName: "bot", TmuxSocket: "humans"}, "unknown tmux socket"},
+		{terminal.ClientMessage{Type: terminal.MsgTypeCreate, TmuxSessionName: "bot", TmuxSocket: "agents", TmuxHost: "devvm"}, "unknown tmux socket"},
+		{terminal.ClientMessage{Type: terminal.MsgTypeTmuxCreate, TmuxSessionName: "bot2", TmuxSocket: "agents"}, "tmux session management only works on the local default server"},
+	}
+	for _, tc := range errorCases {
+		sendMsg(t, alice, tc.msg)
+		if msg := readUntil(t, alice, ofType(terminal.MsgTypeError)); msg.Error != tc.want {
+			t.Errorf("%s on %q error = %q, want %q", tc.msg.Type, tc.msg.TmuxSocket, msg.Error, tc.want)
+		}
+	}
+}
+
+// Test Doc:
+// - Why: Attaching to a tmux session puts the pane's earlier output in the browser scrollback
+// - Contract: After session_created, an output message carries the last TREX_TMUX_HISTORY_LINES lines of history before any live output; 0 lines sends none
+// - Usage Notes: FakeTmuxDetector.SetHistory provides the history; the attach command itself starts later, on the first resize
+// - Quality Contribution: Proves the history arrives first and respects the configured depth
+// - Worked Example: history one..three, depth 2 → output "two\r\nthree\x1b[0m\r\n"
+func TestTmuxHistory_OnAttach(t *testing.T) {
+	const secret = "test-secret-history"
+	srv, ts := newAuthTestServer(t, secret)
+	fake := useFakeTmux(t, srv)
+	fake.AddSession("work", 1, 0)
+	fake.SetHistory("work", "one\ntwo\nthree")
+	srv.config.TmuxHistoryLines = 2
+
+	alice := dialAs(t, ts.URL, secret, "alice")
+	defer alice.Close()
+
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeCreate, TmuxSessionName: "work"})
+	created := readUntil(t, alice, ofType(terminal.MsgTypeSessionCreated))
+	msg := readUntil(t, alice, ofType(terminal.MsgTypeOutput))
+	if msg.SessionId != created.SessionId || msg.Data != "two\r\nthree\x1b[0m\r\n" {
+		t.Errorf("first output = %+v, want the last 2 history lines", msg)
+	}
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeClose, SessionId: created.SessionId})
+
+	// Disabled: the next message after session_created is the tmux list reply
+	srv.config.TmuxHistoryLines = 0
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeCreate, TmuxSessionName: "work"})
+	created = readUntil(t, alice, ofType(terminal.MsgTypeSessionCreated))
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeListTmuxSessions})
+	if msg := readUntil(t, alice, func(m terminal.ServerMessage) bool {
+		return m.Type == terminal.MsgTypeTmuxSessions || m.Type == terminal.MsgTypeOutput
+	}); msg.Type != terminal.MsgTypeTmuxSessions {
+		t.Errorf("with history disabled got %+v before the tmux_sessions reply", msg)
+	}
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeClose, SessionId: created.SessionId})
+}
+
+// slowHistoryTmux delays CaptureHistory like an ssh round trip would.
+type slowHistoryTmux struct {
+	*terminal.FakeTmuxDetector
+	delay time.Duration
+}
+
+func (s slowHistoryTmux) CaptureHistory(sessionName string, windowIndex, lines int) ([]byte, error) {
+	time.Sleep(s.delay)
+	return s.FakeTmuxDetector.CaptureHistory(sessionName, windowIndex, lines)
+}
+
+// Test Doc:
+// - Why: A remote history capture is an ssh round trip; it must not hold up every other session on the connection
+// - Contract: The capture runs off the connection's read loop, and the history is still the session's first output
+// - Worked Example: capture takes 300ms → list_tmux_sessions sent right after create is answered first, then the history arrives
+func TestTmuxHistory_SlowCaptureDoesNotBlock(t *testing.T) {
+	const secret = "test-secret-history-slow"
+	srv, ts := newAuthTestServer(t, secret)
+	srv.monitor.Stop()
+	fake := terminal.NewFakeTmuxDetector()
+	fake.AddSession("work", 1, 0)
+	fake.SetHistory("work", "old line")
+	srv.monitor = terminal.NewTmuxMonitor(slowHistoryTmux{fake, 300 * time.Millisecond}, srv.registry, time.Hour, srv.handleTmuxChanges, srv.handleSessionsChanged)
+	srv.monitor.Start()
+	srv.config.TmuxHistoryLines = 10
+
+	alice := dialAs(t, ts.URL, secret, "alice")
+	defer alice.Close()
+
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeCreate, TmuxSessionName: "work"})
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeListTmuxSessions})
+	created := readUntil(t, alice, ofType(terminal.MsgTypeSessionCreated))
+	if msg := readUntil(t, alice, func(m terminal.ServerMessage) bool {
+		return m.Type == terminal.MsgTypeTmuxSessions || m.Type == terminal.MsgTypeOutput
+	}); msg.Type != terminal.MsgTypeTmuxSessions {
+		t.Errorf("got %+v before the tmux_sessions reply; the capture blocked the read loop", msg)
+	}
+	if msg := readUntil(t, alice, ofType(terminal.MsgTypeOutput)); msg.SessionId != created.SessionId || !strings.Contains(msg.Data, "old line") {
+		t.Errorf("first output = %+v, want the history", msg)
+	}
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeClose, SessionId: created.SessionId})
+}
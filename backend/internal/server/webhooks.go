@@ -0,0 +1,147 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+
+	"github.com/vaughanknight/trex/internal/plugins/copilot"
+	"github.com/vaughanknight/trex/internal/terminal"
+	"github.com/vaughanknight/trex/internal/webhook"
+)
+
+// webhookState is what the server remembers between polls to turn snapshots
+// (tmux session lists, plugin data) into webhook events.
+type webhookState struct {
+	mu           sync.Mutex
+	tmuxSessions map[string]terminal.TmuxSessionInfo // tmuxSessionKey → session
+	pluginStatus map[string]copilot.Status           // session ID → last copilot status
+}
+
+// loadWebhooks creates the webhook dispatcher when TREX_WEBHOOKS_PATH is set.
+// Returns nil (webhooks disabled) when unset or invalid.
+func loadWebhooks(path, deadLetterPath string) *webhook.Dispatcher {
+	if path == "" {
+		return nil
+	}
+	cfg, err := webhook.LoadConfig(path)
+	if err != nil {
+		// Non-fatal: run without webhooks
+		log.Printf("Webhooks disabled: %v", err)
+		return nil
+	}
+	log.Printf("Webhooks: %d endpoints from %s", len(cfg.Endpoints), path)
+	return webhook.NewDispatcher(cfg.Endpoints, webhook.Options{DeadLetterPath: deadLetterPath})
+}
+
+// sessionEventData is the data of session.* webhook events.
+func sessionEventData(session *terminal.Session) map[string]any {
+	data := map[string]any{
+		"sessionId": session.ID,
+		"name":      session.Name,
+		"profile":   session.Profile,
+		"shellType": session.ShellType,
+	}
+	if session.Owner != "" {
+		data["owner"] = session.Owner
+	}
+	if session.TmuxSessionName != "" {
+		data["tmuxSession"] = session.TmuxSessionName
+	}
+	if session.TmuxHost != "" {
+		data["tmuxHost"] = session.TmuxHost
+	}
+	if session.TmuxSocket != "" {
+		data["tmuxSocket"] = session.TmuxSocket
+	}
+	return data
+}
+
+// tmuxSessionKey identifies a tmux session across hosts and sockets.
+func tmuxSessionKey(info terminal.TmuxSessionInfo) string {
+	return info.Host + "\x00" + info.Socket + "\x00" + info.Name
+}
+
+// publishTmuxSessionChanges compares the new tmux session list with the
+// previous one and sends tmux.session_appeared / tmux.session_vanished.
+// Sessions already running when trex starts are reported as appeared.
+func (s *Server) publishTmuxSessionChanges(sessions []terminal.TmuxSessionInfo) {
+	if s.webhooks == nil {
+		return
+	}
+	current := make(map[string]terminal.TmuxSessionInfo, len(sessions))
+	for _, info := range sessions {
+		current[tmuxSessionKey(info)] = info
+	}
+
+	s.hookState.mu.Lock()
+	previous := s.hookState.tmuxSessions
+	s.hookState.tmuxSessions = current
+	s.hookState.mu.Unlock()
+
+	for key, info := range current {
+		if _, ok := previous[key]; !ok {
+			s.webhooks.Publish(webhook.EventTmuxSessionAppeared, tmuxEventData(info))
+		}
+	}
+	for key, info := range previous {
+		if _, ok := current[key]; !ok {
+			s.webhooks.Publish(webhook.EventTmuxSessionVanished, tmuxEventData(info))
+		}
+	}
+}
+
+// tmuxEventData is the data of tmux.* webhook events.
+func tmuxEventData(info terminal.TmuxSessionInfo) map[string]any {
+	data := map[string]any{"name": info.Name, "windows": info.Windows, "attached": info.Attached}
+	if info.Host != "" {
+		data["host"] = info.Host
+	}
+	if info.Socket != "" {
+		data["socket"] = info.Socket
+	}
+	return data
+}
+
+// publishPluginStatus sends plugin.status when a session's copilot phase or
+// status changes. Called by the session poller with each new collector result.
+func (s *Server) publishPluginStatus(session *terminal.Session, pluginID string, data json.RawMessage) {
+	if s.webhooks == nil || pluginID != copilot.CollectorID {
+		return
+	}
+	status, ok := copilot.StatusOf(data)
+	if !ok {
+		return
+	}
+
+	s.hookState.mu.Lock()
+	previous, known := s.hookState.pluginStatus[session.ID]
+	if known && previous == status {
+		s.hookState.mu.Unlock()
+		return
+	}
+	if s.hookState.pluginStatus == nil {
+		s.hookState.pluginStatus = make(map[string]copilot.Status)
+	}
+	s.hookState.pluginStatus[session.ID] = status
+	s.hookState.mu.Unlock()
+
+	event := map[string]any{
+		"sessionId": session.ID,
+		"name":      session.Name,
+		"pluginId":  pluginID,
+		"to":        status,
+	}
+	if session.Owner != "" {
+		event["owner"] = session.Owner
+	}
+	if known {
+		event["from"] = previous
+	}
+	s.webhooks.Publish(webhook.EventPluginStatus, event)
+}
+
+// publishNotification forwards notifier notifications to webhooks.
+func (s *Server) publishNotification(n terminal.Notification) {
+	s.webhooks.Publish(webhook.EventNotification, n)
+}
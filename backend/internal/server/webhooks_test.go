@@ -0,0 +1,161 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vaughanknight/trex/internal/config"
+	"github.com/vaughanknight/trex/internal/plugins/copilot"
+	"github.com/vaughanknight/trex/internal/terminal"
+	"github.com/vaughanknight/trex/internal/webhook"
+)
+
+// hookReceiver records the webhook events it receives. With a secret, it
+// rejects any whose signature doesn't verify.
+type hookReceiver struct {
+	*httptest.Server
+	mu     sync.Mutex
+	events []webhook.Event
+}
+
+func newHookReceiver(t *testing.T, secret string) *hookReceiver {
+	t.Helper()
+	r := &hookReceiver{}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		if secret != "" && !webhook.Verify(secret, body, req.Header.Get(webhook.HeaderSignature)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var event webhook.Event
+		json.Unmarshal(body, &event)
+		r.mu.Lock()
+		r.events = append(r.events, event)
+		r.mu.Unlock()
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+// waitForEvent returns the first received event of eventType, waiting up to
+// three seconds.
+func (r *hookReceiver) waitForEvent(t *testing.T, eventType string) map[string]any {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		r.mu.Lock()
+		for _, e := range r.events {
+			if e.Type == eventType {
+				r.mu.Unlock()
+				data, _ := e.Data.(map[string]any)
+				return data
+			}
+		}
+		r.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("no %s webhook received", eventType)
+	return nil
+}
+
+// Test Doc:
+// - Why: CI and chat integrations react to sessions starting and finishing without polling the API
+// - Contract: With TREX_WEBHOOKS_PATH set, creating a session sends a signed session.created (with owner and profile) and its shell exiting sends session.exited with the real exit code
+// - Usage Notes: Real PTY; the endpoint subscribes to "session." so tmux events on the host don't interfere
+// - Worked Example: alice creates a session and runs `exit 3` → session.created {owner: alice}, session.exited {exitCode: 3}
+func TestWebhooks_SessionLifecycle(t *testing.T) {
+	const secret = "test-secret-webhooks"
+	hook := newHookReceiver(t, "hook-secret")
+	dir := t.TempDir()
+	hooksPath := filepath.Join(dir, "webhooks.json")
+	os.WriteFile(hooksPath, []byte(`{"endpoints": [{"url": "`+hook.URL+`", "secret": "hook-secret", "events": ["session."]}]}`), 0o600)
+
+	srv := New("test-version", &config.Config{
+		BindAddress:           "127.0.0.1:0",
+		AuthEnabled:           true,
+		JWTSecret:             secret,
+		WebhooksPath:          hooksPath,
+		WebhookDeadLetterPath: filepath.Join(dir, "dead.jsonl"),
+	})
+	ts := httptest.NewServer(srv)
+	t.Cleanup(func() {
+		ts.Close()
+		srv.Shutdown()
+	})
+
+	alice := dialAs(t, ts.URL, secret, "alice")
+	defer alice.Close()
+	id := startSession(t, alice)
+
+	created := hook.waitForEvent(t, webhook.EventSessionCreated)
+	if created["sessionId"] != id || created["owner"] != "alice" || created["profile"] != terminal.DefaultProfile {
+		t.Errorf("session.created data = %v", created)
+	}
+
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeInput, SessionId: id, Data: "exit 3\r"})
+	if exit := readUntil(t, alice, ofType(terminal.MsgTypeExit)); exit.Code != 3 {
+		t.Errorf("exit message code = %d, want 3", exit.Code)
+	}
+	exited := hook.waitForEvent(t, webhook.EventSessionExited)
+	if exited["sessionId"] != id || exited["exitCode"] != float64(3) {
+		t.Errorf("session.exited data = %v", exited)
+	}
+}
+
+// Test Doc:
+// - Why: The tmux monitor and plugin collectors report snapshots; webhooks need transitions
+// - Contract: A tmux session missing from the previous list is appeared, one missing from the new list is vanished (keyed by host, socket and name); copilot data sends plugin.status only when phase/status changes, with the previous value as "from"
+// - Worked Example: [work] → [work@devbox, play] → appeared work@devbox and play, vanished work
+func TestWebhooks_Transitions(t *testing.T) {
+	hook := newHookReceiver(t, "")
+	srv := &Server{webhooks: webhook.NewDispatcher([]webhook.Endpoint{{URL: hook.URL}}, webhook.Options{})}
+	srv.webhooks.Start()
+	defer srv.webhooks.Stop()
+
+	srv.publishTmuxSessionChanges([]terminal.TmuxSessionInfo{{Name: "work"}})
+	srv.publishTmuxSessionChanges([]terminal.TmuxSessionInfo{{Name: "work", Host: "devbox"}, {Name: "play"}})
+
+	session := &terminal.Session{ID: "s1", Name: "copilot-1"}
+	for _, phase := range []string{"plan", "plan", "implement"} {
+		srv.publishPluginStatus(session, copilot.CollectorID, json.RawMessage(`{"tasks":[],"context":{"workflowPhase":"`+phase+`","status":"running"}}`))
+	}
+	srv.publishPluginStatus(session, "other-plugin", json.RawMessage(`{}`))
+
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) && srv.webhooks.Stats().Delivered < 6 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond) // Let any unexpected extra deliveries arrive
+
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	counts := map[string]int{}
+	var lastStatus map[string]any
+	for _, e := range hook.events {
+		counts[e.Type]++
+		data := e.Data.(map[string]any)
+		switch e.Type {
+		case webhook.EventTmuxSessionVanished:
+			if data["name"] != "work" || data["host"] != nil {
+				t.Errorf("vanished = %v, want local work", data)
+			}
+		case webhook.EventPluginStatus:
+			lastStatus = data
+		}
+	}
+	if counts[webhook.EventTmuxSessionAppeared] != 3 || counts[webhook.EventTmuxSessionVanished] != 1 || counts[webhook.EventPluginStatus] != 2 {
+		t.Fatalf("event counts = %v, want 3 appeared, 1 vanished, 2 plugin.status", counts)
+	}
+	from, _ := lastStatus["from"].(map[string]any)
+	to, _ := lastStatus["to"].(map[string]any)
+	if from["phase"] != "plan" || to["phase"] != "implement" || lastStatus["sessionId"] != "s1" {
+		t.Errorf("plugin.status = %v, want plan → implement", lastStatus)
+	}
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/vaughanknight/trex/internal/terminal"
+	"github.com/vaughanknight/trex/internal/workspace"
+)
+
+// reorderRequest is the body of PUT /api/workspaces.
+type reorderRequest struct {
+	Order []string `json:"order"`
+}
+
+// workspaceErrorStatus maps workspace store errors to HTTP status codes.
+func workspaceErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, workspace.ErrInvalid):
+		return http.StatusBadRequest
+	case errors.Is(err, workspace.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, workspace.ErrExists), errors.Is(err, workspace.ErrTooMany):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeWorkspaceError writes a workspace error, hiding file errors behind a
+// generic message.
+func writeWorkspaceError(w http.ResponseWriter, err error) {
+	status := workspaceErrorStatus(err)
+	if status == http.StatusInternalServerError {
+		log.Printf("workspace store error: %v", err)
+		http.Error(w, "failed to save workspaces", status)
+		return
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// writeJSON writes v as a JSON response with the given status.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// handleWorkspaces handles GET /api/workspaces (the caller's workspaces in
+// order), POST /api/workspaces to create one and PUT /api/workspaces
+// ({"order": [ids]}) to reorder them.
+func (s *Server) handleWorkspaces() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := actorOf(r)
+
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, s.workspaces.List(user))
+
+		case http.MethodPost:
+			var ws workspace.Workspace
+			if err := json.NewDecoder(r.Body).Decode(&ws); err != nil {
+				http.Error(w, "invalid request body", http.StatusBadRequest)
+				return
+			}
+			s.stampWorkspaceSessions(&ws, nil)
+			created, err := s.workspaces.Create(user, ws)
+			if err != nil {
+				writeWorkspaceError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusCreated, created)
+
+		case http.MethodPut:
+			var req reorderRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "invalid request body", http.StatusBadRequest)
+				return
+			}
+			list, err := s.workspaces.Reorder(user, req.Order)
+			if err != nil {
+				writeWorkspaceError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, list)
+
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// handleWorkspace handles GET, PUT (replace name, layout and focus) and
+// DELETE on /api/workspaces/:id. DELETE ?closeSessions=true also closes the
+// sessions in the workspace's terminal panes that the caller owns.
+func (s *Server) handleWorkspace() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := actorOf(r)
+		id := strings.TrimPrefix(r.URL.Path, "/api/workspaces/")
+		if id == "" {
+			http.Error(w, "workspace ID required", http.StatusBadRequest)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			ws, err := s.workspaces.Get(user, id)
+			if err != nil {
+				writeWorkspaceError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, ws)
+
+		case http.MethodPut:
+			var ws workspace.Workspace
+			if err := json.NewDecoder(r.Body).Decode(&ws); err != nil {
+				http.Error(w, "invalid request body", http.StatusBadRequest)
+				return
+			}
+			var prev *workspace.Workspace
+			if saved, err := s.workspaces.Get(user, id); err == nil {
+				prev = &saved
+			}
+			s.stampWorkspaceSessions(&ws, prev)
+			updated, err := s.workspaces.Update(user, id, ws)
+			if err != nil {
+				writeWorkspaceError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, updated)
+
+		case http.MethodDelete:
+			ws, err := s.workspaces.Delete(user, id)
+			if err != nil {
+				writeWorkspaceError(w, err)
+				return
+			}
+			if r.URL.Query().Get("closeSessions") == "true" {
+				s.closeWorkspaceSessions(ws, user, r.RemoteAddr)
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// stampWorkspaceSessions records the creation time of each terminal pane's
+// session in ws, so the pane can be told apart from a later session that
+// reuses the ID after a restart. prev is the workspace being replaced, if any.
+func (s *Server) stampWorkspaceSessions(ws *workspace.Workspace, prev *workspace.Workspace) {
+	ws.StampSessions(prev, func(id string) (time.Time, bool) {
+		session := s.registry.Get(id)
+		if session == nil {
+			return time.Time{}, false
+		}
+		return session.CreatedAt, true
+	})
+}
+
+// workspaceSessions returns the live sessions in ws's terminal panes. A pane
+// whose session ID now belongs to a different session (IDs are reused after
+// a restart), or that was saved without a creation time, is skipped.
+func (s *Server) workspaceSessions(ws workspace.Workspace) []*terminal.Session {
+	var sessions []*terminal.Session
+	for _, ref := range ws.Sessions() {
+		session := s.registry.Get(ref.ID)
+		if session == nil || ref.CreatedAt.IsZero() || !session.CreatedAt.Equal(ref.CreatedAt) {
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions
+}
+
+// closeWorkspaceSessions closes the sessions in a deleted workspace. Sessions
+// that are already gone or were replaced after a restart, or that user
+// doesn't own (a shared session placed in the workspace), are left alone.
+func (s *Server) closeWorkspaceSessions(ws workspace.Workspace, user, remote string) {
+	for _, session := range s.workspaceSessions(ws) {
+		if s.config.AuthEnabled && session.PermissionFor(user) != terminal.SharePermissionOwner {
+			continue
+		}
+		closeSession(s.registry, s.auditLog, session, user, remote, "workspace")
+	}
+}
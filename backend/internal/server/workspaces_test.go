@@ -0,0 +1,178 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/vaughanknight/trex/internal/auth"
+	"github.com/vaughanknight/trex/internal/terminal"
+	"github.com/vaughanknight/trex/internal/workspace"
+)
+
+// workspaceRequest sends a /api/workspaces request as username and decodes
+// a JSON response into out (when non-nil). Returns the status code.
+func workspaceRequest(t *testing.T, serverURL, secret, username, method, path string, body, out any) int {
+	t.Helper()
+	token, err := auth.NewJWTService(secret).GenerateAccessToken(&auth.GitHubUser{Username: username})
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	var reader *bytes.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req, _ := http.NewRequest(method, serverURL+path, reader)
+	req.Header.Set("Cookie", "trex_access_token="+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s error: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("decode %s %s: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// Test Doc:
+// - Why: Layouts follow the user between browsers, and closing a workspace can close its sessions together
+// - Contract: /api/workspaces CRUD is scoped to the caller; DELETE ?closeSessions=true closes only sessions the caller owns
+// - Usage Notes: Auth enabled so workspaces and sessions have owners; the store is in memory (no WorkspacesPath)
+// - Quality Contribution: End-to-end check of the REST surface and its link to the session registry
+// - Worked Example: alice saves [s1 | bob's s2] → bob sees no workspaces → alice deletes with closeSessions → s1 closed, s2 still running
+func TestWorkspaces_REST(t *testing.T) {
+	const secret = "test-secret-workspaces"
+	srv, ts := newAuthTestServer(t, secret)
+
+	alice := dialAs(t, ts.URL, secret, "alice")
+	defer alice.Close()
+	bob := dialAs(t, ts.URL, secret, "bob")
+	defer bob.Close()
+
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeCreate})
+	aliceSession := readUntil(t, alice, ofType(terminal.MsgTypeSessionCreated)).SessionId
+	sendMsg(t, bob, terminal.ClientMessage{Type: terminal.MsgTypeCreate})
+	bobSession := readUntil(t, bob, ofType(terminal.MsgTypeSessionCreated)).SessionId
+
+	ws := workspace.Workspace{
+		ID:   "dev",
+		Name: "dev",
+		Tree: &workspace.Layout{
+			Type: workspace.TypeSplit, Direction: "h", Ratio: 0.5,
+			First:  &workspace.Layout{Type: workspace.TypeTerminal, PaneID: "p1", SessionID: aliceSession},
+			Second: &workspace.Layout{Type: workspace.TypeTerminal, PaneID: "p2", SessionID: bobSession},
+		},
+		FocusedPaneID: "p1",
+	}
+	var created workspace.Workspace
+	if status := workspaceRequest(t, ts.URL, secret, "alice", http.MethodPost, "/api/workspaces", ws, &created); status != http.StatusCreated {
+		t.Fatalf("POST status = %d, want %d", status, http.StatusCreated)
+	}
+	if created.ID != "dev" || created.CreatedAt.IsZero() {
+		t.Errorf("created = %+v, want id dev with a creation time", created)
+	}
+	if status := workspaceRequest(t, ts.URL, secret, "alice", http.MethodPost, "/api/workspaces", ws, nil); status != http.StatusConflict {
+		t.Errorf("duplicate POST status = %d, want %d", status, http.StatusConflict)
+	}
+	if status := workspaceRequest(t, ts.URL, secret, "alice", http.MethodPost, "/api/workspaces", workspace.Workspace{Name: "empty"}, nil); status != http.StatusBadRequest {
+		t.Errorf("POST without tree status = %d, want %d", status, http.StatusBadRequest)
+	}
+
+	ws.Name = "renamed"
+	ws.UserRenamed = true
+	var updated workspace.Workspace
+	if status := workspaceRequest(t, ts.URL, secret, "alice", http.MethodPut, "/api/workspaces/dev", ws, &updated); status != http.StatusOK {
+		t.Fatalf("PUT status = %d, want %d", status, http.StatusOK)
+	}
+	if updated.Name != "renamed" || !updated.UserRenamed {
+		t.Errorf("updated = %+v, want renamed by user", updated)
+	}
+
+	var list []workspace.Workspace
+	workspaceRequest(t, ts.URL, secret, "alice", http.MethodGet, "/api/workspaces", nil, &list)
+	if len(list) != 1 || list[0].Name != "renamed" {
+		t.Errorf("alice's workspaces = %+v, want the renamed one", list)
+	}
+	workspaceRequest(t, ts.URL, secret, "bob", http.MethodGet, "/api/workspaces", nil, &list)
+	if len(list) != 0 {
+		t.Errorf("bob's workspaces = %+v, want none", list)
+	}
+	if status := workspaceRequest(t, ts.URL, secret, "bob", http.MethodDelete, "/api/workspaces/dev?closeSessions=true", nil, nil); status != http.StatusNotFound {
+		t.Errorf("bob DELETE status = %d, want %d", status, http.StatusNotFound)
+	}
+
+	if status := workspaceRequest(t, ts.URL, secret, "alice", http.MethodDelete, "/api/workspaces/dev?closeSessions=true", nil, nil); status != http.StatusNoContent {
+		t.Fatalf("DELETE status = %d, want %d", status, http.StatusNoContent)
+	}
+	if srv.registry.Get(aliceSession) != nil {
+		t.Error("alice's session should be closed with the workspace")
+	}
+	if s := srv.registry.Get(bobSession); s == nil || !s.IsRunning() {
+		t.Error("bob's session should not be closed by alice's workspace")
+	}
+	if status := workspaceRequest(t, ts.URL, secret, "alice", http.MethodGet, "/api/workspaces/dev", nil, nil); status != http.StatusNotFound {
+		t.Errorf("GET after delete status = %d, want %d", status, http.StatusNotFound)
+	}
+}
+
+// Test Doc:
+// - Why: Session IDs restart from s1 with the server, so a workspace saved before a restart names sessions that no longer exist
+// - Contract: Saving stamps each pane with its session's creation time; broadcast_input to the workspace and DELETE ?closeSessions=true skip panes whose ID now belongs to a newer session
+// - Usage Notes: The restart is simulated by storing the workspace directly with an earlier creation time for a live session's ID
+// - Quality Contribution: Stops an old layout from typing into, or closing, an unrelated new session
+// - Worked Example: workspace "old" saved with s1@yesterday; live s1 created today → broadcast "no target sessions" → delete leaves s1 running
+func TestWorkspaces_SessionsFromBeforeRestart(t *testing.T) {
+	const secret = "test-secret-workspace-restart"
+	srv, ts := newAuthTestServer(t, secret)
+
+	alice := dialAs(t, ts.URL, secret, "alice")
+	defer alice.Close()
+	id := startSession(t, alice)
+	session := srv.registry.Get(id)
+
+	pane := &workspace.Layout{Type: workspace.TypeTerminal, PaneID: "p1", SessionID: id}
+	var saved workspace.Workspace
+	if status := workspaceRequest(t, ts.URL, secret, "alice", http.MethodPost, "/api/workspaces", workspace.Workspace{ID: "new", Tree: pane}, &saved); status != http.StatusCreated {
+		t.Fatalf("POST status = %d, want %d", status, http.StatusCreated)
+	}
+	if !saved.Tree.SessionCreatedAt.Equal(session.CreatedAt) {
+		t.Errorf("saved sessionCreatedAt = %v, want %v", saved.Tree.SessionCreatedAt, session.CreatedAt)
+	}
+
+	stale := *pane
+	stale.SessionCreatedAt = session.CreatedAt.Add(-24 * time.Hour)
+	if _, err := srv.workspaces.Create("alice", workspace.Workspace{ID: "old", Tree: &stale}); err != nil {
+		t.Fatal(err)
+	}
+
+	sendMsg(t, alice, terminal.ClientMessage{Type: terminal.MsgTypeBroadcastInput, WorkspaceId: "old", Data: "x"})
+	if msg := readUntil(t, alice, ofType(terminal.MsgTypeError)); msg.Error != errNoTargets.Error() {
+		t.Errorf("broadcast to stale workspace error = %q, want %q", msg.Error, errNoTargets.Error())
+	}
+	if status := workspaceRequest(t, ts.URL, secret, "alice", http.MethodDelete, "/api/workspaces/old?closeSessions=true", nil, nil); status != http.StatusNoContent {
+		t.Fatalf("DELETE status = %d, want %d", status, http.StatusNoContent)
+	}
+	if s := srv.registry.Get(id); s == nil || !s.IsRunning() {
+		t.Error("a session that reused the ID should not be closed with the old workspace")
+	}
+
+	// Re-saving the old layout keeps its stale stamp rather than adopting the live session
+	if _, err := srv.workspaces.Create("alice", workspace.Workspace{ID: "old", Tree: &stale}); err != nil {
+		t.Fatal(err)
+	}
+	resave := workspace.Workspace{ID: "old", Tree: &workspace.Layout{Type: workspace.TypeTerminal, PaneID: "p1", SessionID: id}}
+	if status := workspaceRequest(t, ts.URL, secret, "alice", http.MethodPut, "/api/workspaces/old", resave, &saved); status != http.StatusOK {
+		t.Fatalf("PUT status = %d, want %d", status, http.StatusOK)
+	}
+	if !saved.Tree.SessionCreatedAt.Equal(stale.SessionCreatedAt) {
+		t.Errorf("re-saved sessionCreatedAt = %v, want the stale %v", saved.Tree.SessionCreatedAt, stale.SessionCreatedAt)
+	}
+}
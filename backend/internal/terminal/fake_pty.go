@@ -128,6 +128,13 @@ func (f *FakePTY) SimulateOutput(data string) {
 	f.OutputBuffer.WriteString(data)
 }
 
+// IsClosed reports whether Close was called. Safe to call while the PTY is in use.
+func (f *FakePTY) IsClosed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.Closed
+}
+
 // GetInput returns all data written to the PTY.
 func (f *FakePTY) GetInput() string {
 	f.mu.Lock()
@@ -0,0 +1,11 @@
+package terminal
+
+// FakeSSHScript is a test double for ssh as a shell script: it drops options
+// up to "--" and the host, then runs the remote command line through sh, like
+// sshd's shell would. Write it to a file named "ssh" on PATH (or point
+// TREX_TMUX_SSH_COMMAND at it) next to a fake tmux script.
+const FakeSSHScript = `#!/bin/sh
+while [ "$#" -gt 0 ] && [ "$1" != "--" ]; do shift; done
+shift 2
+exec sh -c "$*"
+`
@@ -0,0 +1,76 @@
+package terminal
+
+import (
+	"log"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// ForegroundProcess is the process in the foreground of a session's
+// terminal: the shell while it waits at a prompt, otherwise the job it is
+// running (e.g. "npm test").
+type ForegroundProcess struct {
+	PID     int    `json:"pid"`
+	Name    string `json:"name"`              // Process name, e.g. "npm"
+	Command string `json:"command,omitempty"` // Full command line, e.g. "npm test"
+}
+
+// NewForegroundProcess describes info as a foreground process. Command
+// falls back to the name when the command line is unknown.
+func NewForegroundProcess(info ProcessInfo) *ForegroundProcess {
+	command := strings.Join(info.Cmdline, " ")
+	if command == "" {
+		command = info.Comm
+	}
+	return &ForegroundProcess{PID: info.PID, Name: info.Comm, Command: command}
+}
+
+// ForegroundPID returns the terminal's foreground process group (tcgetpgrp
+// on the primary side), or 0 if the process hasn't started or the PTY is
+// closed. The group leader's PID equals the group ID.
+func (r *RealPTY) ForegroundPID() int {
+	if r.started() == nil {
+		return 0
+	}
+	raw, err := r.ptmx.SyscallConn()
+	if err != nil {
+		return 0
+	}
+	var pgrp int32
+	var errno syscall.Errno
+	if err := raw.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, uintptr(syscall.TIOCGPGRP), uintptr(unsafe.Pointer(&pgrp)))
+	}); err != nil || errno != 0 {
+		return 0
+	}
+	return int(pgrp)
+}
+
+// ForegroundPID returns the foreground process group of the session's
+// terminal, or 0 when the PTY can't report one (tmux panes, fakes).
+func (s *Session) ForegroundPID() int {
+	if p, ok := s.pty.(interface{ ForegroundPID() int }); ok {
+		return p.ForegroundPID()
+	}
+	return 0
+}
+
+// Foreground returns the last detected foreground process, or nil.
+func (s *Session) Foreground() *ForegroundProcess {
+	return s.foreground.Load()
+}
+
+// SetForeground records the foreground process and sends a process_update
+// to the owner and every attached connection.
+func (s *Session) SetForeground(fg *ForegroundProcess) {
+	s.foreground.Store(fg)
+	msg := ServerMessage{
+		SessionId:  s.ID,
+		Type:       MsgTypeProcessUpdate,
+		Foreground: fg,
+	}
+	if err := s.sendJSON(msg); err != nil {
+		log.Printf("Failed to send process_update for session %s: %v", s.ID, err)
+	}
+}
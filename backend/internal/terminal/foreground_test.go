@@ -0,0 +1,56 @@
+package terminal
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRealPTY_ForegroundPID(t *testing.T) {
+	// Test Doc:
+	// - Why: The foreground process comes from tcgetpgrp on the PTY, not from a process-tree guess
+	// - Contract: Before start → 0; a command started in the PTY leads its own foreground group → ForegroundPID == GetPid; after Close → 0
+
+	p, err := NewUnstartedPTY()
+	if err != nil {
+		t.Fatalf("NewUnstartedPTY: %v", err)
+	}
+	if got := p.ForegroundPID(); got != 0 {
+		t.Errorf("ForegroundPID before start = %d, want 0", got)
+	}
+	if err := p.StartCommand("sleep", []string{"5"}, os.Environ()); err != nil {
+		t.Fatalf("StartCommand: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for p.ForegroundPID() != p.GetPid() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := p.ForegroundPID(); got != p.GetPid() {
+		t.Errorf("ForegroundPID = %d, want sleep's pid %d", got, p.GetPid())
+	}
+
+	p.Close()
+	if got := p.ForegroundPID(); got != 0 {
+		t.Errorf("ForegroundPID after close = %d, want 0", got)
+	}
+}
+
+func TestProcfsProcessDetector_Process(t *testing.T) {
+	// Test Doc:
+	// - Why: A just-started foreground job must be found even if the process snapshot predates it
+	// - Contract: Process reads /proc/<pid> directly, with its command line; unknown PIDs → false
+
+	root := t.TempDir()
+	d := NewProcfsProcessDetector(root, time.Minute)
+	d.ProcessTree(1) // Take an (empty) snapshot first
+
+	writeProc(t, root, 50, 42, "npm", "npm test", 10)
+	info, ok := d.Process(50)
+	if !ok || info.PPID != 42 || NewForegroundProcess(info).Command != "npm test" {
+		t.Errorf("Process(50) = %+v, %v; want npm test", info, ok)
+	}
+	if _, ok := d.Process(51); ok {
+		t.Error("Process(51) should not be found")
+	}
+}
@@ -0,0 +1,363 @@
+package terminal
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultProfile is the profile name used when a create message doesn't specify one.
+const DefaultProfile = "default"
+
+// redactedPassword replaces a whole line typed at a password prompt.
+const redactedPassword = "[REDACTED:password]"
+
+// RedactionPattern replaces every match of Regex in a submitted line with
+// "[REDACTED:<Name>]".
+type RedactionPattern struct {
+	Name  string `json:"name"`
+	Regex string `json:"regex"`
+	re    *regexp.Regexp
+}
+
+// RedactionRules is the input audit redaction ruleset. Rules are applied
+// before anything is written to disk.
+type RedactionRules struct {
+	// PasswordPrompts match the tail of terminal output. A line typed while
+	// the output ends in a password prompt is redacted entirely.
+	PasswordPrompts []string `json:"passwordPrompts"`
+	// Patterns redact matching substrings (tokens, keys) within a line.
+	Patterns []RedactionPattern `json:"patterns"`
+
+	promptREs []*regexp.Regexp
+}
+
+// DefaultRedactionRules returns the built-in ruleset: common password and
+// passphrase prompts, plus GitHub, AWS, bearer and generic secret assignments.
+func DefaultRedactionRules() *RedactionRules {
+	rules := &RedactionRules{
+		PasswordPrompts: []string{
+			`(?i)(password|passphrase|passcode|pin)( for [^:]*)?:\s*$`,
+			`(?i)\[sudo\] password for [^:]*:\s*$`,
+			`(?i)enter (pin|token|secret)[^:]*:\s*$`,
+		},
+		Patterns: []RedactionPattern{
+			{Name: "github-token", Regex: `\b(gh[pousr]_[A-Za-z0-9]{30,}|github_pat_[A-Za-z0-9_]{30,})\b`},
+			{Name: "aws-access-key", Regex: `\b(AKIA|ASIA)[0-9A-Z]{16}\b`},
+			{Name: "bearer", Regex: `(?i)\bbearer\s+[A-Za-z0-9._~+/=-]{16,}`},
+			{Name: "secret-assignment", Regex: `(?i)\b[A-Z0-9_]*(SECRET|TOKEN|PASSWORD|PASSWD|API_KEY)[A-Z0-9_]*=\S+`},
+		},
+	}
+	for _, p := range rules.PasswordPrompts {
+		rules.promptREs = append(rules.promptREs, regexp.MustCompile(p))
+	}
+	for i := range rules.Patterns {
+		rules.Patterns[i].re = regexp.MustCompile(rules.Patterns[i].Regex)
+	}
+	return rules
+}
+
+// LoadRedactionRules reads a JSON ruleset from path. An empty path returns
+// DefaultRedactionRules.
+func LoadRedactionRules(path string) (*RedactionRules, error) {
+	if path == "" {
+		return DefaultRedactionRules(), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules RedactionRules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("parse redaction rules %s: %w", path, err)
+	}
+	if err := rules.compile(); err != nil {
+		return nil, err
+	}
+	return &rules, nil
+}
+
+// compile compiles the prompt and pattern regexes.
+func (r *RedactionRules) compile() error {
+	r.promptREs = r.promptREs[:0]
+	for _, p := range r.PasswordPrompts {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return fmt.Errorf("invalid password prompt regex %q: %w", p, err)
+		}
+		r.promptREs = append(r.promptREs, re)
+	}
+	for i := range r.Patterns {
+		re, err := regexp.Compile(r.Patterns[i].Regex)
+		if err != nil {
+			return fmt.Errorf("invalid redaction pattern %q: %w", r.Patterns[i].Name, err)
+		}
+		r.Patterns[i].re = re
+	}
+	return nil
+}
+
+// IsPasswordPrompt returns true if the (ANSI-stripped) output tail ends in a password prompt.
+func (r *RedactionRules) IsPasswordPrompt(outputTail string) bool {
+	for _, re := range r.promptREs {
+		if re.MatchString(outputTail) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact applies every pattern to line. Returns the redacted line and whether
+// anything was replaced.
+func (r *RedactionRules) Redact(line string) (string, bool) {
+	redacted := false
+	for _, p := range r.Patterns {
+		if p.re == nil || !p.re.MatchString(line) {
+			continue
+		}
+		line = p.re.ReplaceAllString(line, "[REDACTED:"+p.Name+"]")
+		redacted = true
+	}
+	return line, redacted
+}
+
+// InputAuditPolicy decides which sessions get an input audit and how
+// their lines are redacted and stored.
+type InputAuditPolicy struct {
+	Dir      string          // Root directory; files are Dir/<user>/<created>-<sessionID>.jsonl
+	Profiles []string        // Profiles with input audit enabled ("*" = all)
+	Rules    *RedactionRules // Redaction ruleset (nil = DefaultRedactionRules)
+}
+
+// Enabled reports whether sessions using profile should be audited.
+func (p *InputAuditPolicy) Enabled(profile string) bool {
+	if p == nil {
+		return false
+	}
+	for _, name := range p.Profiles {
+		if name == "*" || name == profile {
+			return true
+		}
+	}
+	return false
+}
+
+// NewRecorder opens the audit file for session. The session's Owner, ID,
+// Profile and CreatedAt must already be set.
+func (p *InputAuditPolicy) NewRecorder(session *Session) (*InputRecorder, error) {
+	rules := p.Rules
+	if rules == nil {
+		rules = DefaultRedactionRules()
+	}
+	owner := session.Owner
+	if owner == "" {
+		owner = "local"
+	}
+	dir := filepath.Join(p.Dir, sanitizePathComponent(owner))
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("%s-%s.jsonl", session.CreatedAt.UTC().Format("20060102T150405Z"), sanitizePathComponent(session.ID))
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &InputRecorder{
+		sessionID: session.ID,
+		profile:   session.Profile,
+		rules:     rules,
+		file:      f,
+		lines:     make(map[string]*pendingLine),
+		now:       time.Now,
+	}, nil
+}
+
+// sanitizePathComponent keeps a username or session ID from escaping the audit dir.
+func sanitizePathComponent(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r < 0x20 {
+			return '_'
+		}
+		return r
+	}, s)
+	if s == "" || s == "." || s == ".." {
+		return "_"
+	}
+	return s
+}
+
+// InputRecord is one submitted line in the input audit file.
+type InputRecord struct {
+	Time      time.Time `json:"time"`
+	SessionID string    `json:"sessionId"`
+	User      string    `json:"user,omitempty"`
+	Profile   string    `json:"profile"`
+	Line      string    `json:"line"`
+	Redacted  bool      `json:"redacted,omitempty"`
+}
+
+// pendingLine is a line being typed by one user.
+type pendingLine struct {
+	buf         []rune
+	atPassword  bool // output ended in a password prompt when typing began
+	escapeState int  // 0 = normal, 1 = after ESC, 2 = in CSI, 3 = after ESC O
+}
+
+// outputTailSize bounds how much recent output is kept for prompt detection.
+const outputTailSize = 256
+
+// InputRecorder reconstructs submitted lines from raw keystrokes and writes
+// them, redacted, to the session's input audit file. Lines are tracked per
+// user so concurrent collaborators don't interleave.
+type InputRecorder struct {
+	mu         sync.Mutex
+	sessionID  string
+	profile    string
+	rules      *RedactionRules
+	file       *os.File
+	lines      map[string]*pendingLine
+	outputTail []byte
+	now        func() time.Time
+}
+
+// ObserveOutput keeps the tail of terminal output for password prompt detection.
+func (r *InputRecorder) ObserveOutput(data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.outputTail = append(r.outputTail, data...)
+	if len(r.outputTail) > outputTailSize {
+		r.outputTail = r.outputTail[len(r.outputTail)-outputTailSize:]
+	}
+}
+
+// Feed processes keystrokes typed by user. Printable runes are appended to the
+// user's pending line; backspace, Ctrl-U and Ctrl-W edit it; Ctrl-C discards
+// it; Enter submits it. Escape sequences (arrows, function keys) are skipped —
+// history recall and cursor movement are not reconstructed.
+func (r *InputRecorder) Feed(user, data string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	line := r.lines[user]
+	if line == nil {
+		line = &pendingLine{}
+		r.lines[user] = line
+	}
+
+	for _, c := range data {
+		switch line.escapeState {
+		case 1:
+			switch c {
+			case '[':
+				line.escapeState = 2
+			case 'O':
+				line.escapeState = 3
+			default:
+				line.escapeState = 0
+			}
+			continue
+		case 2:
+			if c >= 0x40 && c <= 0x7e {
+				line.escapeState = 0
+			}
+			continue
+		case 3:
+			line.escapeState = 0
+			continue
+		}
+
+		switch {
+		case c == 0x1b:
+			line.escapeState = 1
+		case c == '\r' || c == '\n':
+			if len(line.buf) > 0 || line.atPassword {
+				r.submit(user, line)
+			}
+			r.lines[user] = &pendingLine{}
+			line = r.lines[user]
+		case c == 0x7f || c == '\b':
+			if len(line.buf) > 0 {
+				line.buf = line.buf[:len(line.buf)-1]
+			}
+		case c == 0x15: // Ctrl-U
+			line.buf = line.buf[:0]
+		case c == 0x17: // Ctrl-W
+			line.buf = deleteWord(line.buf)
+		case c == 0x03: // Ctrl-C
+			r.lines[user] = &pendingLine{}
+			line = r.lines[user]
+		case c < 0x20:
+			// Other control characters (tab completion, Ctrl-D) don't add text
+		default:
+			if len(line.buf) == 0 {
+				line.atPassword = r.rules.IsPasswordPrompt(stripANSI(string(r.outputTail)))
+			}
+			line.buf = append(line.buf, c)
+		}
+	}
+}
+
+// deleteWord removes the last word (and trailing spaces) like readline's Ctrl-W.
+func deleteWord(buf []rune) []rune {
+	i := len(buf)
+	for i > 0 && buf[i-1] == ' ' {
+		i--
+	}
+	for i > 0 && buf[i-1] != ' ' {
+		i--
+	}
+	return buf[:i]
+}
+
+// submit redacts and writes a completed line. Caller must hold mu.
+func (r *InputRecorder) submit(user string, line *pendingLine) {
+	rec := InputRecord{
+		Time:      r.now().UTC(),
+		SessionID: r.sessionID,
+		User:      user,
+		Profile:   r.profile,
+	}
+	if line.atPassword {
+		rec.Line = redactedPassword
+		rec.Redacted = true
+	} else {
+		rec.Line, rec.Redacted = r.rules.Redact(string(line.buf))
+	}
+
+	if r.file == nil {
+		return
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	if _, err := r.file.Write(append(data, '\n')); err != nil {
+		log.Printf("Input audit write error for session %s: %v", r.sessionID, err)
+	}
+}
+
+// Close closes the audit file. Unsubmitted lines are discarded.
+func (r *InputRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// ansiPattern matches CSI and OSC escape sequences for prompt detection.
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(\x07|\x1b\\)|\x1b[@-Z\\-_]`)
+
+// stripANSI removes terminal escape sequences from s.
+func stripANSI(s string) string {
+	return ansiPattern.ReplaceAllString(s, "")
+}
@@ -14,7 +14,38 @@ type ClientMessage struct {
 	// tmux-attach session creation fields
 	TmuxSessionName string `json:"tmuxSessionName,omitempty"` // Target tmux session for attach
 	TmuxWindowIndex int    `json:"tmuxWindowIndex,omitempty"` // Target tmux window (0 = default)
+	TmuxPaneId      string `json:"tmuxPaneId,omitempty"`      // Target tmux pane (e.g. "%3"); streams just that pane
+	TmuxHost        string `json:"tmuxHost,omitempty"`        // Remote host of the target tmux session (empty = local)
+	TmuxSocket      string `json:"tmuxSocket,omitempty"`      // tmux server socket of the target session (empty = default)
 	Cwd             string `json:"cwd,omitempty"`             // Initial working directory for new session
+	Profile         string `json:"profile,omitempty"`         // Profile name for new session (default "default")
+
+	// tmux session management fields (tmux_create, tmux_rename, tmux_kill)
+	TmuxNewName string `json:"tmuxNewName,omitempty"` // New name (tmux_rename)
+	Command     string `json:"command,omitempty"`     // Command to run in the new session (tmux_create)
+	Confirm     bool   `json:"confirm,omitempty"`     // Must be true to kill (tmux_kill)
+
+	// Session sharing fields (share, unshare, attach)
+	ShareUser       string `json:"shareUser,omitempty"`       // Username to share with (share/unshare)
+	Permission      string `json:"permission,omitempty"`      // "viewer" | "collaborator"
+	ShareLinkTTL    int    `json:"shareLinkTtl,omitempty"`    // Share link lifetime in seconds (share without shareUser)
+	ShareToken      string `json:"shareToken,omitempty"`      // Share link token (attach via link)
+
+	// Idle lock (unlock)
+	UnlockTicket string `json:"unlockTicket,omitempty"` // Ticket from POST /api/unlock after re-authenticating
+
+	// Broadcast and synchronized input (broadcast_input, sync_input)
+	SessionIds  []string `json:"sessionIds,omitempty"`  // Target sessions
+	WorkspaceId string   `json:"workspaceId,omitempty"` // Target the sessions in one of the user's workspaces
+
+	// Protocol handshake (hello)
+	ProtocolVersion    int      `json:"protocolVersion,omitempty"`    // Newest protocol version the client speaks
+	MinProtocolVersion int      `json:"minProtocolVersion,omitempty"` // Oldest protocol version the client speaks (default protocolVersion)
+	Capabilities       []string `json:"capabilities,omitempty"`       // Capabilities the client supports
+	Client             string   `json:"client,omitempty"`             // Client name and version, for logs (e.g. "trex-web/1.4.0")
+
+	// Request correlation
+	RequestId string `json:"requestId,omitempty"` // Client-chosen ID echoed on every reply to this message
 }
 
 // ServerMessage represents messages sent from server to browser.
@@ -31,11 +62,43 @@ type ServerMessage struct {
 	// tmux-attach metadata (included in session_created response)
 	TmuxSessionName string `json:"tmuxSessionName,omitempty"` // tmux session name for this session
 	TmuxWindowIndex int    `json:"tmuxWindowIndex,omitempty"` // tmux window index for this session
+	TmuxPaneId      string `json:"tmuxPaneId,omitempty"`      // tmux pane ID for single-pane sessions
+	TmuxHost        string `json:"tmuxHost,omitempty"`        // Remote host of the tmux session (empty = local)
+	TmuxSocket      string `json:"tmuxSocket,omitempty"`      // tmux server socket of the session (empty = default)
 	Cwd             string `json:"cwd,omitempty"`             // Current working directory of the session
 
 	// Plugin data (included in plugin_data messages)
 	PluginId   string          `json:"pluginId,omitempty"`   // Plugin identifier
 	PluginData json.RawMessage `json:"pluginData,omitempty"` // Plugin-specific JSON payload
+
+	// Session sharing (share_created, session_attached, presence)
+	Permission string     `json:"permission,omitempty"` // Caller's permission on the session
+	ShareLink  *ShareLink `json:"shareLink,omitempty"`  // Issued link (share_created)
+	Watchers   []Watcher  `json:"watchers,omitempty"`   // Everyone watching the session (presence)
+	// OutputOffset is the PTY output offset the caller's output stream starts
+	// at (session_attached), for mapping CommandRecord output ranges
+	OutputOffset int64 `json:"outputOffset,omitempty"`
+
+	// Synchronized input (sync_status)
+	SessionIds []string `json:"sessionIds,omitempty"` // Sessions input is mirrored to (empty = sync off)
+
+	// Foreground process (process_update)
+	Foreground *ForegroundProcess `json:"foreground,omitempty"` // What is running in the terminal now
+
+	// Shell integration (command_finished)
+	Command *CommandRecord `json:"command,omitempty"` // The command that finished
+
+	// Notifier (notification)
+	Notification *Notification `json:"notification,omitempty"` // Why the user should look at the session
+
+	// Protocol handshake (welcome)
+	ProtocolVersion int      `json:"protocolVersion,omitempty"` // Negotiated protocol version
+	Capabilities    []string `json:"capabilities,omitempty"`    // Capabilities enabled on this connection
+	ServerVersion   string   `json:"serverVersion,omitempty"`   // trex server version
+
+	// Request correlation (replies, error, ack)
+	RequestId string `json:"requestId,omitempty"` // requestId of the client message this replies to
+	ErrorCode string `json:"errorCode,omitempty"` // Machine-readable error code (ErrCode*) for "error" type
 }
 
 // Message type constants
@@ -59,4 +122,48 @@ const (
 	MsgTypeDetach           = "detach"             // Client requests tmux detach (PTY closed, tmux session survives)
 	MsgTypeCwdUpdate        = "cwd_update"         // Server sends updated cwd for a session
 	MsgTypePluginData       = "plugin_data"        // Server sends plugin-specific data for a session
+	MsgTypeProcessUpdate    = "process_update"     // Server sends the session's new foreground process
+	MsgTypeCommandFinished  = "command_finished"   // Server sends a command reported by shell integration (OSC 133)
+	MsgTypeNotification     = "notification"       // Server sends a notifier event (prompt match, long command, quiet)
+
+	// tmux session management message types (success replies with tmux_sessions)
+	MsgTypeTmuxCreate = "tmux_create" // Client creates a detached tmux session (tmuxSessionName, cwd, command)
+	MsgTypeTmuxRename = "tmux_rename" // Client renames a tmux session (tmuxSessionName → tmuxNewName)
+	MsgTypeTmuxKill   = "tmux_kill"   // Client kills a tmux session (requires confirm)
+
+	// Session sharing message types
+	MsgTypeShare           = "share"            // Owner grants a user or link access to a session
+	MsgTypeUnshare         = "unshare"          // Owner revokes a user's or link's access
+	MsgTypeShareCreated    = "share_created"    // Server confirms a share (includes link when issued)
+	MsgTypeAttach          = "attach"           // Client attaches to an existing shared session
+	MsgTypeSessionAttached = "session_attached" // Server confirms attach (includes caller permission)
+	MsgTypePresence        = "presence"         // Server broadcasts who is watching a session
+
+	// Idle lock message types
+	MsgTypeLocked   = "locked"   // Server locked the connection (Data: "idle"); only unlock is accepted
+	MsgTypeUnlock   = "unlock"   // Client presents an unlock ticket after re-authenticating
+	MsgTypeUnlocked = "unlocked" // Server confirms the connection is unlocked
+
+	// Broadcast and synchronized input message types
+	MsgTypeBroadcastInput = "broadcast_input" // Client writes Data once to each target session
+	MsgTypeSyncInput      = "sync_input"      // Client mirrors input to sessionId onto a group (no targets = off)
+	MsgTypeSyncStatus     = "sync_status"     // Server confirms the group input to sessionId is mirrored to
+
+	// Protocol handshake message types
+	MsgTypeHello   = "hello"   // Client announces its protocol versions and capabilities (first message)
+	MsgTypeWelcome = "welcome" // Server replies with the negotiated version and capabilities
+
+	// Request correlation message types
+	MsgTypeAck = "ack" // Server confirms close/detach/unshare (Data: the acknowledged message type)
+)
+
+// Error codes sent in ServerMessage.ErrorCode. Error text is for people and
+// may change; clients should branch on the code.
+const (
+	ErrCodeNotFound        = "not_found"        // Session, tmux session or pane doesn't exist (or isn't visible to the caller)
+	ErrCodeForbidden       = "forbidden"        // Caller lacks permission, or the connection is locked
+	ErrCodeInvalidArgument = "invalid_argument" // Malformed message or invalid field
+	ErrCodeTmuxUnavailable = "tmux_unavailable" // tmux isn't installed or reachable
+	ErrCodePtyExhausted    = "pty_exhausted"    // No pseudo-terminal (or file descriptor) could be allocated
+	ErrCodeInternal        = "internal"         // Unexpected server-side failure
 )
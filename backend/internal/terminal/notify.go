@@ -0,0 +1,338 @@
+package terminal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// notifyTailSize bounds how much recent (ANSI-stripped) output is kept
+	// for pattern matching, so a prompt split across reads still matches.
+	notifyTailSize = 512
+	// notifyCooldown stops one rule firing repeatedly for a session, e.g.
+	// when a TUI redraws the same prompt.
+	notifyCooldown = 30 * time.Second
+	// notifyDeliveryTimeout bounds one webhook POST or desktop command.
+	notifyDeliveryTimeout = 10 * time.Second
+)
+
+// Notification kinds.
+const (
+	NotifyKindMatch   = "match"   // Output matched a pattern
+	NotifyKindCommand = "command" // A long command finished
+	NotifyKindQuiet   = "quiet"   // The session went quiet after a burst of output
+)
+
+// Notification is an event worth interrupting the user for, e.g. an agent
+// waiting at "Do you want to proceed?".
+type Notification struct {
+	SessionID   string         `json:"sessionId"`
+	SessionName string         `json:"sessionName,omitempty"`
+	Owner       string         `json:"owner,omitempty"`
+	Profile     string         `json:"profile,omitempty"`
+	Kind        string         `json:"kind"`              // "match" | "command" | "quiet"
+	Rule        string         `json:"rule,omitempty"`    // Pattern name (match)
+	Message     string         `json:"message"`           // Human-readable summary
+	Command     *CommandRecord `json:"command,omitempty"` // The finished command (command)
+	Time        time.Time      `json:"time"`
+}
+
+// NotifyPattern fires when recent output matches Regex.
+type NotifyPattern struct {
+	Name  string `json:"name"`
+	Regex string `json:"regex"`
+	re    *regexp.Regexp
+}
+
+// NotifyRules are one profile's notification rules and destinations.
+type NotifyRules struct {
+	Patterns []NotifyPattern `json:"patterns"`
+	// LongCommandSeconds notifies when a command (from shell integration)
+	// ran at least this long. 0 disables.
+	LongCommandSeconds int `json:"longCommandSeconds"`
+	// QuietSeconds notifies when a session that printed at least BurstBytes
+	// has been silent this long. 0 disables.
+	QuietSeconds int `json:"quietSeconds"`
+	BurstBytes   int `json:"burstBytes"`
+	// Webhook receives each notification as a JSON POST. Empty disables.
+	Webhook string `json:"webhook"`
+	// DesktopCommand is run with the title and message appended as the last
+	// two arguments, e.g. ["notify-send", "--app-name=trex"]. Empty disables.
+	DesktopCommand []string `json:"desktopCommand"`
+}
+
+// NotifyConfig maps profile names to their rules. "*" applies to profiles
+// without their own entry.
+type NotifyConfig struct {
+	Profiles map[string]*NotifyRules `json:"profiles"`
+
+	// Publish, if set, also receives every notification (e.g. for the
+	// server's event webhooks).
+	Publish func(Notification) `json:"-"`
+}
+
+// DefaultNotifyConfig returns the built-in rules: common confirmation
+// prompts and commands longer than a minute, sent to the socket only.
+func DefaultNotifyConfig() *NotifyConfig {
+	rules := &NotifyRules{
+		Patterns: []NotifyPattern{
+			{Name: "yes-no", Regex: `(?i)[(\[]y/n[)\]]\s*:?\s*$`},
+			{Name: "proceed", Regex: `(?i)do you want to (proceed|continue)`},
+			{Name: "press-enter", Regex: `(?i)press (enter|return) to continue`},
+		},
+		LongCommandSeconds: 60,
+	}
+	for i := range rules.Patterns {
+		rules.Patterns[i].re = regexp.MustCompile(rules.Patterns[i].Regex)
+	}
+	return &NotifyConfig{Profiles: map[string]*NotifyRules{"*": rules}}
+}
+
+// LoadNotifyConfig reads JSON notification rules from path. An empty path
+// returns DefaultNotifyConfig.
+func LoadNotifyConfig(path string) (*NotifyConfig, error) {
+	if path == "" {
+		return DefaultNotifyConfig(), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg NotifyConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse notification rules %s: %w", path, err)
+	}
+	if err := cfg.compile(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// compile compiles every profile's patterns.
+func (c *NotifyConfig) compile() error {
+	for profile, rules := range c.Profiles {
+		if rules == nil {
+			continue
+		}
+		for i := range rules.Patterns {
+			re, err := regexp.Compile(rules.Patterns[i].Regex)
+			if err != nil {
+				return fmt.Errorf("invalid notification pattern %q for profile %q: %w", rules.Patterns[i].Name, profile, err)
+			}
+			rules.Patterns[i].re = re
+		}
+	}
+	return nil
+}
+
+// ForProfile returns the rules for profile, falling back to "*". Returns
+// nil when neither exists.
+func (c *NotifyConfig) ForProfile(profile string) *NotifyRules {
+	if c == nil {
+		return nil
+	}
+	if rules, ok := c.Profiles[profile]; ok {
+		return rules
+	}
+	return c.Profiles["*"]
+}
+
+// NewNotifier creates the notifier for session, whose ID, Name, Owner and
+// Profile must already be set. Returns nil if its profile has no rules.
+func (c *NotifyConfig) NewNotifier(session *Session) *SessionNotifier {
+	rules := c.ForProfile(session.Profile)
+	if rules == nil {
+		return nil
+	}
+	return &SessionNotifier{
+		session:  session,
+		rules:    rules,
+		lastFire: make(map[string]time.Time),
+		now:      time.Now,
+		deliver:  deliverNotification,
+		publish:  c.Publish,
+	}
+}
+
+// SessionNotifier watches one session's output and finished commands and
+// sends a notification message to the session's connections, plus the
+// profile's webhook and desktop command, when a rule fires.
+type SessionNotifier struct {
+	session *Session
+	rules   *NotifyRules
+
+	mu       sync.Mutex
+	tail     string
+	lastFire map[string]time.Time // rule → last fired, for notifyCooldown
+	burst    int                  // bytes printed since the session was last quiet
+	quiet    *time.Timer
+	closed   bool
+
+	now     func() time.Time
+	deliver func(rules *NotifyRules, n Notification) // webhook and desktop command
+	publish func(Notification)                       // NotifyConfig.Publish
+}
+
+// ObserveOutput checks a chunk of output against the patterns and restarts
+// the quiet timer.
+func (n *SessionNotifier) ObserveOutput(data []byte) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		return
+	}
+
+	if len(n.rules.Patterns) > 0 {
+		n.tail += stripANSI(string(data))
+		for _, p := range n.rules.Patterns {
+			loc := p.re.FindStringIndex(n.tail)
+			if loc == nil {
+				continue
+			}
+			match := strings.TrimSpace(n.tail[loc[0]:loc[1]])
+			n.tail = n.tail[loc[1]:] // Don't match the same text twice
+			n.fire(Notification{Kind: NotifyKindMatch, Rule: p.Name, Message: fmt.Sprintf("%s: %s", n.session.Name, match)})
+		}
+		if len(n.tail) > notifyTailSize {
+			n.tail = strings.ToValidUTF8(n.tail[len(n.tail)-notifyTailSize:], "")
+		}
+	}
+
+	if n.rules.QuietSeconds > 0 {
+		n.burst += len(data)
+		quietAfter := time.Duration(n.rules.QuietSeconds) * time.Second
+		if n.quiet == nil {
+			n.quiet = time.AfterFunc(quietAfter, n.wentQuiet)
+		} else {
+			n.quiet.Reset(quietAfter)
+		}
+	}
+}
+
+// wentQuiet runs when no output arrived for QuietSeconds.
+func (n *SessionNotifier) wentQuiet() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	burst := n.burst
+	n.burst = 0
+	if n.closed || burst < n.rules.BurstBytes {
+		return
+	}
+	n.fire(Notification{Kind: NotifyKindQuiet, Message: fmt.Sprintf("%s went quiet after %d bytes of output", n.session.Name, burst)})
+}
+
+// CommandFinished notifies if rec ran for at least LongCommandSeconds.
+func (n *SessionNotifier) CommandFinished(rec CommandRecord) {
+	if n.rules.LongCommandSeconds <= 0 || rec.DurationMs < int64(n.rules.LongCommandSeconds)*1000 {
+		return
+	}
+	status := "finished"
+	if rec.ExitCode != nil && *rec.ExitCode != 0 {
+		status = fmt.Sprintf("failed (exit %d)", *rec.ExitCode)
+	}
+	duration := (time.Duration(rec.DurationMs) * time.Millisecond).Round(time.Second)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		return
+	}
+	n.fire(Notification{Kind: NotifyKindCommand, Message: fmt.Sprintf("%s: `%s` %s after %s", n.session.Name, rec.Command, status, duration), Command: &rec})
+}
+
+// fire fills in the session fields and sends the notification unless its
+// rule is cooling down. Caller must hold mu.
+func (n *SessionNotifier) fire(notification Notification) {
+	now := n.now()
+	key := notification.Kind + "/" + notification.Rule
+	if notification.Kind != NotifyKindCommand {
+		if last, ok := n.lastFire[key]; ok && now.Sub(last) < notifyCooldown {
+			return
+		}
+		n.lastFire[key] = now
+	}
+
+	notification.SessionID = n.session.ID
+	notification.SessionName = n.session.Name
+	notification.Owner = n.session.Owner
+	notification.Profile = n.session.Profile
+	notification.Time = now.UTC()
+	n.session.SendNotification(notification)
+	if n.rules.Webhook != "" || len(n.rules.DesktopCommand) > 0 {
+		go n.deliver(n.rules, notification)
+	}
+	if n.publish != nil {
+		n.publish(notification)
+	}
+}
+
+// Close stops the quiet timer. Later output is ignored.
+func (n *SessionNotifier) Close() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.closed = true
+	if n.quiet != nil {
+		n.quiet.Stop()
+	}
+}
+
+// deliverNotification posts the notification to the webhook and runs the
+// desktop command. Failures are logged; notifications aren't retried.
+func deliverNotification(rules *NotifyRules, notification Notification) {
+	ctx, cancel := context.WithTimeout(context.Background(), notifyDeliveryTimeout)
+	defer cancel()
+
+	if rules.Webhook != "" {
+		body, _ := json.Marshal(notification)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, rules.Webhook, bytes.NewReader(body))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+			var resp *http.Response
+			if resp, err = http.DefaultClient.Do(req); err == nil {
+				resp.Body.Close()
+				if resp.StatusCode >= 300 {
+					err = fmt.Errorf("status %s", resp.Status)
+				}
+			}
+		}
+		if err != nil {
+			log.Printf("Notification webhook for session %s failed: %v", notification.SessionID, err)
+		}
+	}
+
+	if len(rules.DesktopCommand) > 0 {
+		args := append(rules.DesktopCommand[1:len(rules.DesktopCommand):len(rules.DesktopCommand)], "trex: "+notification.SessionName, notification.Message)
+		if err := exec.CommandContext(ctx, rules.DesktopCommand[0], args...).Run(); err != nil {
+			log.Printf("Notification command for session %s failed: %v", notification.SessionID, err)
+		}
+	}
+}
+
+// SendNotification sends a notification message to the owner and every
+// attached connection.
+func (s *Session) SendNotification(notification Notification) {
+	msg := ServerMessage{
+		SessionId:    s.ID,
+		Type:         MsgTypeNotification,
+		Notification: &notification,
+	}
+	if err := s.sendJSON(msg); err != nil {
+		log.Printf("Failed to send notification for session %s: %v", s.ID, err)
+	}
+}
+
+// SetNotifier enables notifications for this session. Must be called
+// before RunReadPTY starts.
+func (s *Session) SetNotifier(n *SessionNotifier) {
+	s.notifier = n
+}
@@ -0,0 +1,178 @@
+package terminal
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newNotifierFixture returns a notifier for an "agents" session on a fake
+// clock. Deliveries are counted instead of made.
+func newNotifierFixture(t *testing.T, rules *NotifyRules) (*SessionNotifier, *FakeWebSocket, func() int, *time.Time) {
+	t.Helper()
+	cfg := &NotifyConfig{Profiles: map[string]*NotifyRules{"agents": rules}}
+	if err := cfg.compile(); err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	owner := NewFakeWebSocket()
+	session := NewSessionWithConn("s1", NewFakePTY(), owner)
+	session.Name = "claude-1"
+	session.Profile = "agents"
+
+	n := cfg.NewNotifier(session)
+	if n == nil {
+		t.Fatal("NewNotifier returned nil for a profile with rules")
+	}
+	now := time.Unix(1000, 0)
+	n.now = func() time.Time { return now }
+	var mu sync.Mutex
+	delivered := 0
+	n.deliver = func(*NotifyRules, Notification) {
+		mu.Lock()
+		defer mu.Unlock()
+		delivered++
+	}
+	t.Cleanup(n.Close)
+	return n, owner, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return delivered
+	}, &now
+}
+
+func TestNotifier_PatternMatch(t *testing.T) {
+	// Test Doc:
+	// - Why: Agents stall at "approve this?" prompts while nobody is watching the terminal
+	// - Contract: Output matching a pattern sends one notification (kind "match", rule name) even when the prompt is split across reads and wrapped in colour codes; the same rule is quiet for notifyCooldown
+	// - Worked Example: "Do you want to \e[1mproceed\e[0m?" in two reads → one notification "claude-1: Do you want to proceed"
+
+	n, owner, _, now := newNotifierFixture(t, &NotifyRules{
+		Patterns: []NotifyPattern{{Name: "proceed", Regex: `(?i)do you want to proceed`}},
+	})
+
+	n.ObserveOutput([]byte("Edit file? Do you want to "))
+	n.ObserveOutput([]byte("\x1b[1mproceed\x1b[0m?\r\n"))
+	msgs := messagesOfType(t, owner, MsgTypeNotification)
+	if len(msgs) != 1 || msgs[0].Notification == nil {
+		t.Fatalf("notifications = %+v, want one", msgs)
+	}
+	got := msgs[0].Notification
+	if got.Kind != NotifyKindMatch || got.Rule != "proceed" || got.Message != "claude-1: Do you want to proceed" || got.Profile != "agents" {
+		t.Errorf("notification = %+v", got)
+	}
+
+	n.ObserveOutput([]byte("Do you want to proceed?"))
+	if got := len(messagesOfType(t, owner, MsgTypeNotification)); got != 1 {
+		t.Errorf("notifications during cooldown = %d, want 1", got)
+	}
+	*now = now.Add(notifyCooldown)
+	n.ObserveOutput([]byte("Do you want to proceed?"))
+	if got := len(messagesOfType(t, owner, MsgTypeNotification)); got != 2 {
+		t.Errorf("notifications after cooldown = %d, want 2", got)
+	}
+}
+
+func TestNotifier_LongCommandAndQuiet(t *testing.T) {
+	// Test Doc:
+	// - Why: Long builds and agents that stop printing are the other reasons to look back at a terminal
+	// - Contract: A command at least LongCommandSeconds long notifies (with exit status); shorter ones don't; going quiet notifies only after BurstBytes of output; webhook/desktop destinations get every notification
+	// - Usage Notes: wentQuiet is called directly instead of waiting for the timer
+
+	exit := 1
+	n, owner, delivered, _ := newNotifierFixture(t, &NotifyRules{
+		LongCommandSeconds: 30,
+		QuietSeconds:       10,
+		BurstBytes:         8,
+		Webhook:            "http://hooks.invalid/trex",
+	})
+
+	n.CommandFinished(CommandRecord{Command: "ls", DurationMs: 200})
+	n.CommandFinished(CommandRecord{Command: "make", DurationMs: 95_000, ExitCode: &exit})
+	msgs := messagesOfType(t, owner, MsgTypeNotification)
+	if len(msgs) != 1 || msgs[0].Notification.Kind != NotifyKindCommand || msgs[0].Notification.Message != "claude-1: `make` failed (exit 1) after 1m35s" {
+		t.Fatalf("notifications = %+v, want one for make", msgs)
+	}
+
+	n.ObserveOutput([]byte("ok"))
+	n.wentQuiet()
+	if got := len(messagesOfType(t, owner, MsgTypeNotification)); got != 1 {
+		t.Errorf("quiet after 2 bytes notified; want BurstBytes first")
+	}
+	n.ObserveOutput([]byte("compiling..."))
+	n.wentQuiet()
+	msgs = messagesOfType(t, owner, MsgTypeNotification)
+	if len(msgs) != 2 || msgs[1].Notification.Kind != NotifyKindQuiet {
+		t.Errorf("notifications = %+v, want quiet second", msgs)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) && delivered() < 2 {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := delivered(); got != 2 {
+		t.Errorf("delivered %d notifications, want 2", got)
+	}
+}
+
+func TestDeliverNotification(t *testing.T) {
+	// Test Doc:
+	// - Why: Notifications reach people away from the browser tab
+	// - Contract: The webhook receives the notification as a JSON POST; the desktop command runs with title and message as its last two arguments
+
+	var received Notification
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &received)
+	}))
+	defer hook.Close()
+	out := filepath.Join(t.TempDir(), "desktop")
+
+	deliverNotification(&NotifyRules{
+		Webhook:        hook.URL,
+		DesktopCommand: []string{"sh", "-c", `printf '%s|%s' "$0" "$1" > ` + out},
+	}, Notification{SessionID: "s1", SessionName: "claude-1", Kind: NotifyKindMatch, Message: "claude-1: (y/n)"})
+
+	if received.SessionID != "s1" || received.Message != "claude-1: (y/n)" {
+		t.Errorf("webhook received %+v", received)
+	}
+	if data, _ := os.ReadFile(out); string(data) != "trex: claude-1|claude-1: (y/n)" {
+		t.Errorf("desktop command args = %q", data)
+	}
+}
+
+func TestLoadNotifyConfig(t *testing.T) {
+	// Test Doc:
+	// - Why: Agent profiles need different prompts and destinations than ordinary shells
+	// - Contract: Rules are looked up by profile, then "*"; a profile without either gets no notifier; invalid regexes are rejected; empty path → built-in rules
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notify.json")
+	os.WriteFile(path, []byte(`{"profiles": {"agents": {"patterns": [{"name": "approve", "regex": "approve\\?"}]}}}`), 0o600)
+	cfg, err := LoadNotifyConfig(path)
+	if err != nil {
+		t.Fatalf("LoadNotifyConfig: %v", err)
+	}
+	if rules := cfg.ForProfile("agents"); rules == nil || rules.Patterns[0].re == nil {
+		t.Errorf("agents rules = %+v, want compiled pattern", rules)
+	}
+	if cfg.ForProfile("default") != nil {
+		t.Errorf("default profile got rules without a \"*\" entry")
+	}
+
+	os.WriteFile(path, []byte(`{"profiles": {"*": {"patterns": [{"name": "bad", "regex": "("}]}}}`), 0o600)
+	if _, err := LoadNotifyConfig(path); err == nil || !strings.Contains(err.Error(), "bad") {
+		t.Errorf("invalid regex error = %v", err)
+	}
+
+	builtin, _ := LoadNotifyConfig("")
+	if rules := builtin.ForProfile("anything"); rules == nil || !rules.Patterns[0].re.MatchString("Overwrite? (y/n) ") {
+		t.Errorf("built-in rules don't match a (y/n) prompt")
+	}
+}
@@ -1,10 +1,12 @@
 package terminal
 
 import (
-	"fmt"
+	"os"
 	"os/exec"
+	"runtime"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // ProcessDetector detects the process tree of a running PID.
@@ -12,36 +14,85 @@ type ProcessDetector interface {
 	// DetectProcessTree returns all process names in the tree rooted at pid.
 	// Walks the full child process tree (not just immediate child).
 	DetectProcessTree(pid int) []string
+
+	// ProcessTree returns the processes in the tree rooted at pid, root
+	// first, then each child's subtree in PID order. Returns nil if pid
+	// isn't running.
+	ProcessTree(pid int) []ProcessInfo
+
+	// Process describes a single process as it is now, with its command
+	// line. Returns false if pid isn't running.
+	Process(pid int) (ProcessInfo, bool)
 }
 
-// NewProcessDetector creates a platform-appropriate ProcessDetector.
+// ProcessInfo describes one process in a tree. Cmdline and StartTime are
+// only known on Linux (/proc).
+type ProcessInfo struct {
+	PID       int       `json:"pid"`
+	PPID      int       `json:"ppid"`
+	Comm      string    `json:"comm"`
+	Cmdline   []string  `json:"cmdline,omitempty"`
+	StartTime time.Time `json:"startTime,omitempty"`
+}
+
+// maxProcessTreeDepth bounds tree walks (guards against PID reuse cycles).
+const maxProcessTreeDepth = 10
+
+// NewProcessDetector creates a platform-appropriate ProcessDetector. On
+// Linux it reads /proc and caches one snapshot of every process for
+// processSnapshotTTL, so share it between connections. Elsewhere (macOS)
+// it falls back to ps and pgrep.
 func NewProcessDetector() ProcessDetector {
+	if runtime.GOOS == "linux" {
+		if _, err := os.Stat("/proc/self/stat"); err == nil {
+			return NewProcfsProcessDetector("/proc", processSnapshotTTL)
+		}
+	}
 	return &osProcessDetector{}
 }
 
+// processNames returns the Comm of each process.
+func processNames(tree []ProcessInfo) []string {
+	if tree == nil {
+		return nil
+	}
+	names := make([]string, 0, len(tree))
+	for _, p := range tree {
+		if p.Comm != "" {
+			names = append(names, p.Comm)
+		}
+	}
+	return names
+}
+
 // osProcessDetector uses ps/pgrep to walk the process tree.
 type osProcessDetector struct{}
 
 func (d *osProcessDetector) DetectProcessTree(pid int) []string {
+	return processNames(d.ProcessTree(pid))
+}
+
+func (d *osProcessDetector) ProcessTree(pid int) []ProcessInfo {
 	if pid <= 0 {
 		return nil
 	}
-	var names []string
-	d.walkTree(pid, &names, 0)
-	return names
+	var tree []ProcessInfo
+	d.walkTree(pid, 0, &tree, 0)
+	return tree
 }
 
-// walkTree recursively collects process names via pgrep -P <pid>.
-func (d *osProcessDetector) walkTree(pid int, names *[]string, depth int) {
-	if depth > 10 {
+// walkTree recursively collects processes via pgrep -P <pid>.
+func (d *osProcessDetector) walkTree(pid, ppid int, tree *[]ProcessInfo, depth int) {
+	if depth > maxProcessTreeDepth {
 		return // Safety: prevent infinite recursion
 	}
 
 	// Get this process's name
 	name := d.getProcessName(pid)
-	if name != "" {
-		*names = append(*names, name)
+	if name == "" && depth == 0 {
+		return // Not running
 	}
+	*tree = append(*tree, ProcessInfo{PID: pid, PPID: ppid, Comm: name})
 
 	// Get child PIDs
 	out, err := exec.Command("pgrep", "-P", strconv.Itoa(pid)).Output()
@@ -54,13 +105,31 @@ func (d *osProcessDetector) walkTree(pid int, names *[]string, depth int) {
 		if err != nil || childPid <= 0 {
 			continue
 		}
-		d.walkTree(childPid, names, depth+1)
+		d.walkTree(childPid, pid, tree, depth+1)
 	}
 }
 
+func (d *osProcessDetector) Process(pid int) (ProcessInfo, bool) {
+	if pid <= 0 {
+		return ProcessInfo{}, false
+	}
+	name := d.getProcessName(pid)
+	if name == "" {
+		return ProcessInfo{}, false
+	}
+	info := ProcessInfo{PID: pid, Comm: name}
+	if out, err := exec.Command("ps", "-o", "ppid=", "-p", strconv.Itoa(pid)).Output(); err == nil {
+		info.PPID, _ = strconv.Atoi(strings.TrimSpace(string(out)))
+	}
+	if out, err := exec.Command("ps", "-o", "command=", "-p", strconv.Itoa(pid)).Output(); err == nil {
+		info.Cmdline = strings.Fields(string(out))
+	}
+	return info, true
+}
+
 // getProcessName returns the command name for a PID.
 func (d *osProcessDetector) getProcessName(pid int) string {
-	out, err := exec.Command("ps", "-o", "comm=", "-p", fmt.Sprintf("%d", pid)).Output()
+	out, err := exec.Command("ps", "-o", "comm=", "-p", strconv.Itoa(pid)).Output()
 	if err != nil {
 		return ""
 	}
@@ -74,16 +143,46 @@ func (d *osProcessDetector) getProcessName(pid int) string {
 
 // FakeProcessDetector returns configurable process trees for testing (ADR-0004).
 type FakeProcessDetector struct {
-	Trees map[int][]string // pid → process names
+	Trees map[int][]string      // pid → process names
+	Infos map[int][]ProcessInfo // pid → full tree (ProcessTree falls back to Trees)
 }
 
 func NewFakeProcessDetector() *FakeProcessDetector {
-	return &FakeProcessDetector{Trees: make(map[int][]string)}
+	return &FakeProcessDetector{Trees: make(map[int][]string), Infos: make(map[int][]ProcessInfo)}
 }
 
 func (f *FakeProcessDetector) DetectProcessTree(pid int) []string {
 	if tree, ok := f.Trees[pid]; ok {
 		return tree
 	}
+	if tree, ok := f.Infos[pid]; ok {
+		return processNames(tree)
+	}
 	return nil
 }
+
+func (f *FakeProcessDetector) ProcessTree(pid int) []ProcessInfo {
+	if tree, ok := f.Infos[pid]; ok {
+		return tree
+	}
+	names, ok := f.Trees[pid]
+	if !ok {
+		return nil
+	}
+	tree := make([]ProcessInfo, 0, len(names))
+	for _, name := range names {
+		tree = append(tree, ProcessInfo{Comm: name})
+	}
+	return tree
+}
+
+func (f *FakeProcessDetector) Process(pid int) (ProcessInfo, bool) {
+	for _, tree := range f.Infos {
+		for _, p := range tree {
+			if p.PID == pid {
+				return p, true
+			}
+		}
+	}
+	return ProcessInfo{}, false
+}
@@ -0,0 +1,218 @@
+package terminal
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// processSnapshotTTL is how long one /proc snapshot is reused. Connections
+// poll every few seconds, so this makes one read of /proc per cycle serve
+// every session on every connection.
+const processSnapshotTTL = 2 * time.Second
+
+// clockTicks is USER_HZ, the unit of /proc/<pid>/stat start times. It is 100
+// on every mainstream Linux architecture and can't be read without cgo.
+const clockTicks = 100
+
+// processSnapshot is every process at one instant, indexed by parent.
+type processSnapshot struct {
+	procs    map[int]ProcessInfo
+	children map[int][]int // ppid → child PIDs, sorted
+	takenAt  time.Time
+}
+
+// ProcfsProcessDetector reads process trees from a Linux /proc filesystem.
+// Each snapshot reads /proc/*/stat once and is shared by every lookup until
+// it is ttl old; command lines are only read for processes in a returned
+// tree. Safe for concurrent use.
+type ProcfsProcessDetector struct {
+	root string
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu       sync.Mutex
+	snapshot *processSnapshot
+	bootTime time.Time
+}
+
+// NewProcfsProcessDetector reads processes from root (normally "/proc"),
+// reusing each snapshot for ttl.
+func NewProcfsProcessDetector(root string, ttl time.Duration) *ProcfsProcessDetector {
+	return &ProcfsProcessDetector{root: root, ttl: ttl, now: time.Now}
+}
+
+func (d *ProcfsProcessDetector) DetectProcessTree(pid int) []string {
+	return processNames(d.ProcessTree(pid))
+}
+
+func (d *ProcfsProcessDetector) ProcessTree(pid int) []ProcessInfo {
+	if pid <= 0 {
+		return nil
+	}
+	snap := d.current()
+	if _, ok := snap.procs[pid]; !ok {
+		return nil
+	}
+
+	var tree []ProcessInfo
+	var walk func(pid, depth int)
+	walk = func(pid, depth int) {
+		if depth > maxProcessTreeDepth {
+			return
+		}
+		info := snap.procs[pid]
+		info.Cmdline = d.readCmdline(pid)
+		tree = append(tree, info)
+		for _, child := range snap.children[pid] {
+			walk(child, depth+1)
+		}
+	}
+	walk(pid, 0)
+	return tree
+}
+
+// Process reads one process directly, bypassing the snapshot, so a command
+// started since the last snapshot is still found.
+func (d *ProcfsProcessDetector) Process(pid int) (ProcessInfo, bool) {
+	if pid <= 0 {
+		return ProcessInfo{}, false
+	}
+	d.mu.Lock()
+	if d.bootTime.IsZero() {
+		d.bootTime = readBootTime(d.root)
+	}
+	bootTime := d.bootTime
+	d.mu.Unlock()
+
+	data, err := os.ReadFile(filepath.Join(d.root, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return ProcessInfo{}, false
+	}
+	info, ok := parseProcStat(data, bootTime)
+	if !ok || info.PID != pid {
+		return ProcessInfo{}, false
+	}
+	info.Cmdline = d.readCmdline(pid)
+	return info, true
+}
+
+// current returns the cached snapshot, taking a new one if it is stale.
+func (d *ProcfsProcessDetector) current() *processSnapshot {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	if d.snapshot != nil && now.Sub(d.snapshot.takenAt) < d.ttl {
+		return d.snapshot
+	}
+	if d.bootTime.IsZero() {
+		d.bootTime = readBootTime(d.root)
+	}
+	d.snapshot = d.takeSnapshot(now)
+	return d.snapshot
+}
+
+// takeSnapshot reads every /proc/<pid>/stat. Processes that exit mid-scan
+// are skipped.
+func (d *ProcfsProcessDetector) takeSnapshot(now time.Time) *processSnapshot {
+	snap := &processSnapshot{
+		procs:    make(map[int]ProcessInfo),
+		children: make(map[int][]int),
+		takenAt:  now,
+	}
+	entries, err := os.ReadDir(d.root)
+	if err != nil {
+		return snap
+	}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid <= 0 {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(d.root, entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		info, ok := parseProcStat(data, d.bootTime)
+		if !ok || info.PID != pid {
+			continue
+		}
+		snap.procs[pid] = info
+		snap.children[info.PPID] = append(snap.children[info.PPID], pid)
+	}
+	for _, kids := range snap.children {
+		slices.Sort(kids)
+	}
+	return snap
+}
+
+// readCmdline returns a process's arguments, or nil for kernel threads and
+// exited processes.
+func (d *ProcfsProcessDetector) readCmdline(pid int) []string {
+	data, err := os.ReadFile(filepath.Join(d.root, strconv.Itoa(pid), "cmdline"))
+	if err != nil || len(data) == 0 {
+		return nil
+	}
+	return strings.Split(string(bytes.TrimRight(data, "\x00")), "\x00")
+}
+
+// parseProcStat parses /proc/<pid>/stat: "pid (comm) state ppid ..." with
+// the start time (in clock ticks since boot) as field 22. comm may contain
+// spaces and parentheses, so it ends at the last ')'.
+func parseProcStat(data []byte, bootTime time.Time) (ProcessInfo, bool) {
+	s := string(data)
+	open := strings.IndexByte(s, '(')
+	end := strings.LastIndexByte(s, ')')
+	if open < 0 || end < open {
+		return ProcessInfo{}, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(s[:open]))
+	if err != nil {
+		return ProcessInfo{}, false
+	}
+	// Fields after comm start at field 3 (state)
+	fields := strings.Fields(s[end+1:])
+	if len(fields) < 20 {
+		return ProcessInfo{}, false
+	}
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return ProcessInfo{}, false
+	}
+
+	info := ProcessInfo{PID: pid, PPID: ppid, Comm: s[open+1 : end]}
+	if ticks, err := strconv.ParseInt(fields[19], 10, 64); err == nil && !bootTime.IsZero() {
+		info.StartTime = bootTime.Add(time.Duration(ticks) * (time.Second / clockTicks))
+	}
+	return info, true
+}
+
+// readBootTime reads the btime line of /proc/stat. Returns the zero time if
+// it can't be read.
+func readBootTime(root string) time.Time {
+	data, err := os.ReadFile(filepath.Join(root, "stat"))
+	if err != nil {
+		return time.Time{}
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if rest, ok := strings.CutPrefix(line, "btime "); ok {
+			if secs, err := strconv.ParseInt(strings.TrimSpace(rest), 10, 64); err == nil {
+				return time.Unix(secs, 0)
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// Verify interface compliance at compile time.
+var (
+	_ ProcessDetector = (*ProcfsProcessDetector)(nil)
+	_ ProcessDetector = (*osProcessDetector)(nil)
+	_ ProcessDetector = (*FakeProcessDetector)(nil)
+)
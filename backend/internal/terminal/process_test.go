@@ -1,8 +1,15 @@
 package terminal
 
 import (
+	"fmt"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestFakeProcessDetector(t *testing.T) {
@@ -46,6 +53,20 @@ func TestOsProcessDetector_CurrentProcess(t *testing.T) {
 	}
 }
 
+func TestPsProcessDetector_CurrentProcess(t *testing.T) {
+	// Test Doc:
+	// - Why: macOS has no /proc, so the ps/pgrep walk must keep working
+	// - Contract: ProcessTree(self) starts with this process, with its PID
+
+	if _, err := exec.LookPath("ps"); err != nil {
+		t.Skip("ps not available")
+	}
+	tree := (&osProcessDetector{}).ProcessTree(os.Getpid())
+	if len(tree) == 0 || tree[0].PID != os.Getpid() || tree[0].Comm == "" {
+		t.Errorf("ProcessTree(self) = %+v, want this process first", tree)
+	}
+}
+
 func TestOsProcessDetector_InvalidPid(t *testing.T) {
 	detector := NewProcessDetector()
 	tree := detector.DetectProcessTree(-1)
@@ -58,3 +79,81 @@ func TestOsProcessDetector_InvalidPid(t *testing.T) {
 		t.Errorf("expected empty for non-existent pid, got %v", tree)
 	}
 }
+
+// writeProc adds a process to a fake /proc tree.
+func writeProc(t *testing.T, root string, pid, ppid int, comm, cmdline string, startTicks int) {
+	t.Helper()
+	dir := filepath.Join(root, strconv.Itoa(pid))
+	os.MkdirAll(dir, 0755)
+	stat := fmt.Sprintf("%d (%s) S %d %d %d 0 -1 4194560 100 0 0 0 0 0 0 0 20 0 1 0 %d 1000 100 0\n", pid, comm, ppid, pid, pid, startTicks)
+	if err := os.WriteFile(filepath.Join(dir, "stat"), []byte(stat), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "cmdline"), []byte(strings.ReplaceAll(cmdline, " ", "\x00")+"\x00"), 0644)
+}
+
+func TestProcfsProcessDetector_Tree(t *testing.T) {
+	// Test Doc:
+	// - Why: One read of /proc per cycle replaces a pgrep and a ps per process
+	// - Contract: ProcessTree returns root first then children depth-first in PID order, with ppid, comm, cmdline and start time; unknown PIDs → nil
+	// - Usage Notes: Uses a fake /proc directory (ADR-0004); start times are btime + ticks/100
+	// - Worked Example: zsh(10) → node(30) → copilot(31), vim(20) → [zsh vim node copilot]
+
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "stat"), []byte("cpu 1 2 3\nbtime 1700000000\n"), 0644)
+	writeProc(t, root, 1, 0, "init", "/sbin/init", 1)
+	writeProc(t, root, 10, 1, "zsh", "-zsh", 500)
+	writeProc(t, root, 30, 10, "node", "node agent.js", 700)
+	writeProc(t, root, 31, 30, "copilot (x)", "copilot --yes", 800)
+	writeProc(t, root, 20, 10, "vim", "vim README.md", 600)
+
+	d := NewProcfsProcessDetector(root, time.Minute)
+	tree := d.ProcessTree(10)
+	var pids []int
+	for _, p := range tree {
+		pids = append(pids, p.PID)
+	}
+	if !slices.Equal(pids, []int{10, 20, 30, 31}) {
+		t.Fatalf("tree pids = %v, want [10 20 30 31]", pids)
+	}
+	copilot := tree[3]
+	if copilot.PPID != 30 || copilot.Comm != "copilot (x)" || !slices.Equal(copilot.Cmdline, []string{"copilot", "--yes"}) {
+		t.Errorf("copilot = %+v, want ppid 30, comm with parens, cmdline [copilot --yes]", copilot)
+	}
+	if want := time.Unix(1700000008, 0); !copilot.StartTime.Equal(want) {
+		t.Errorf("copilot start = %v, want %v", copilot.StartTime, want)
+	}
+	if names := d.DetectProcessTree(30); !slices.Equal(names, []string{"node", "copilot (x)"}) {
+		t.Errorf("DetectProcessTree(30) = %v", names)
+	}
+	if d.ProcessTree(99) != nil || d.ProcessTree(-1) != nil {
+		t.Error("unknown and negative PIDs should return nil")
+	}
+}
+
+func TestProcfsProcessDetector_SharedSnapshot(t *testing.T) {
+	// Test Doc:
+	// - Why: Every connection polls; they must share one /proc scan per cycle
+	// - Contract: A snapshot is reused until it is ttl old, then /proc is read again
+
+	root := t.TempDir()
+	writeProc(t, root, 10, 1, "zsh", "zsh", 1)
+
+	now := time.Unix(1000, 0)
+	d := NewProcfsProcessDetector(root, 2*time.Second)
+	d.now = func() time.Time { return now }
+
+	if got := d.DetectProcessTree(10); len(got) != 1 {
+		t.Fatalf("initial tree = %v, want [zsh]", got)
+	}
+	writeProc(t, root, 11, 10, "make", "make", 2)
+
+	now = now.Add(time.Second)
+	if got := d.DetectProcessTree(10); len(got) != 1 {
+		t.Errorf("tree within ttl = %v, want cached [zsh]", got)
+	}
+	now = now.Add(2 * time.Second)
+	if got := d.DetectProcessTree(10); !slices.Equal(got, []string{"zsh", "make"}) {
+		t.Errorf("tree after ttl = %v, want [zsh make]", got)
+	}
+}
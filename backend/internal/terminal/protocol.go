@@ -0,0 +1,56 @@
+package terminal
+
+import "fmt"
+
+// WebSocket protocol versions. A client announces the range it speaks in
+// hello; the connection uses the highest version both sides support.
+const (
+	ProtocolVersion    = 1 // Newest version this server speaks
+	MinProtocolVersion = 1 // Oldest version this server still speaks
+)
+
+// Capabilities negotiated in hello/welcome. A connection uses a capability
+// only if both the client and the server list it.
+const (
+	// CapBinaryFrames sends output as binary WebSocket frames (see
+	// BinaryOutputFrame) instead of JSON "output" messages.
+	CapBinaryFrames = "binary_frames"
+	// CapScrollbackReplay sends a tmux pane's history as output when a
+	// session attaches to it.
+	CapScrollbackReplay = "scrollback_replay"
+	// CapAttach allows attaching to sessions shared by other users.
+	CapAttach = "attach"
+)
+
+// ServerCapabilities are the capabilities this server offers.
+var ServerCapabilities = []string{CapBinaryFrames, CapScrollbackReplay, CapAttach}
+
+// NegotiateProtocol picks the protocol version for a client that speaks
+// clientMin..clientMax. clientMin 0 means clientMax only. Returns an error
+// describing both ranges when they don't overlap.
+func NegotiateProtocol(clientMin, clientMax int) (int, error) {
+	if clientMin <= 0 || clientMin > clientMax {
+		clientMin = clientMax
+	}
+	version := min(clientMax, ProtocolVersion)
+	if clientMax <= 0 || version < max(clientMin, MinProtocolVersion) {
+		return 0, fmt.Errorf("unsupported protocol version %d (server supports %d-%d)", clientMax, MinProtocolVersion, ProtocolVersion)
+	}
+	return version, nil
+}
+
+// BinaryOutputConn is implemented by connections that may receive output as
+// binary frames. Session checks it per connection on every write.
+type BinaryOutputConn interface {
+	BinaryOutput() bool
+}
+
+// BinaryOutputFrame encodes output for a binary frame: one byte holding the
+// length of the session ID, the session ID, then the raw output bytes.
+// Unlike JSON output, bytes that aren't valid UTF-8 reach the client as-is.
+func BinaryOutputFrame(sessionID string, data []byte) []byte {
+	frame := make([]byte, 0, 1+len(sessionID)+len(data))
+	frame = append(frame, byte(len(sessionID)))
+	frame = append(frame, sessionID...)
+	return append(frame, data...)
+}
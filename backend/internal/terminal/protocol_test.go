@@ -0,0 +1,72 @@
+package terminal
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestNegotiateProtocol(t *testing.T) {
+	// Test Doc:
+	// - Why: The web and Electron clients ship separately from the backend and can drift
+	// - Contract: The highest version inside both the client's and the server's range is chosen; no overlap (or no version) is an error naming both ranges
+
+	tests := []struct {
+		name          string
+		clientMin     int
+		clientMax     int
+		want          int
+		wantErrSubstr string
+	}{
+		{"same version", 0, ProtocolVersion, ProtocolVersion, ""},
+		{"newer client that still speaks ours", MinProtocolVersion, ProtocolVersion + 3, ProtocolVersion, ""},
+		{"newer client only", ProtocolVersion + 1, ProtocolVersion + 2, 0, "server supports"},
+		{"older than the server supports", 0, MinProtocolVersion - 1, 0, "unsupported protocol version"},
+	}
+	for _, tt := range tests {
+		got, err := NegotiateProtocol(tt.clientMin, tt.clientMax)
+		if tt.wantErrSubstr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErrSubstr) {
+				t.Errorf("%s: error = %v, want %q", tt.name, err, tt.wantErrSubstr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s: NegotiateProtocol(%d, %d) = %d, %v; want %d", tt.name, tt.clientMin, tt.clientMax, got, err, tt.want)
+		}
+	}
+}
+
+// binaryConn is a FakeWebSocket that negotiated binary frames.
+type binaryConn struct{ *FakeWebSocket }
+
+func (binaryConn) BinaryOutput() bool { return true }
+
+func TestSession_OutputAdaptsPerConnection(t *testing.T) {
+	// Test Doc:
+	// - Why: One session can be watched by an old JSON client and a new binary-frame client at once
+	// - Contract: Output goes as a binary frame (length-prefixed session ID + raw bytes) to connections whose BinaryOutput() is true and as JSON output to the rest; invalid UTF-8 survives the binary path
+	// - Worked Example: "hi\xff" on s7 → owner gets {"type":"output","data":"hi�"}, binary watcher gets "\x02s7hi\xff"
+
+	owner := NewFakeWebSocket()
+	watcher := binaryConn{NewFakeWebSocket()}
+	session := NewSessionWithConn("s7", NewFakePTY(), owner)
+	session.Attach(watcher, Watcher{Username: "bob"})
+
+	if err := session.sendOutput([]byte("hi\xff")); err != nil {
+		t.Fatalf("sendOutput: %v", err)
+	}
+
+	written := owner.GetWrittenMessages()
+	var msg ServerMessage
+	if len(written) != 1 || written[0].MessageType != websocket.TextMessage || json.Unmarshal(written[0].Data, &msg) != nil || msg.Type != MsgTypeOutput || msg.Data != "hi�" {
+		t.Errorf("owner got %+v, want one JSON output", written)
+	}
+	written = watcher.GetWrittenMessages()
+	if len(written) != 1 || written[0].MessageType != websocket.BinaryMessage || !bytes.Equal(written[0].Data, []byte("\x02s7hi\xff")) {
+		t.Errorf("binary watcher got %+v, want one binary frame", written)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"sync"
 	"syscall"
 
 	"github.com/creack/pty"
@@ -27,8 +28,14 @@ func FilterTmuxEnv(env []string) []string {
 // RealPTY wraps creack/pty for actual terminal functionality.
 type RealPTY struct {
 	ptmx *os.File
-	tty  *os.File   // secondary PTY fd; non-nil until StartShell() or Close()
-	cmd  *exec.Cmd
+
+	// mu guards tty and cmd: the process is started on the connection
+	// goroutine while the read loop and the session poller read them.
+	mu  sync.Mutex
+	tty *os.File  // secondary PTY fd; non-nil until StartShell() or Close()
+	cmd *exec.Cmd // nil until the process has started
+
+	waitOnce sync.Once // reaps cmd exactly once (Close and ExitCode)
 
 	// TtyPath is the device path of the secondary PTY (e.g., "/dev/ttys010" on macOS,
 	// "/dev/pts/5" on Linux). Used by tmux monitor to match trex sessions to tmux clients.
@@ -149,6 +156,8 @@ func (r *RealPTY) StartShell(shell string) error {
 // StartShellInDir starts a login shell in the specified working directory.
 // If dir is empty, starts in the default directory (user's home).
 func (r *RealPTY) StartShellInDir(shell string, dir string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	if r.tty == nil {
 		return fmt.Errorf("PTY already started or closed")
 	}
@@ -186,6 +195,8 @@ func (r *RealPTY) StartShellInDir(shell string, dir string) error {
 // Like StartShell, the PTY should be Resize()d to the correct dimensions first.
 // Must be called at most once (mutually exclusive with StartShell).
 func (r *RealPTY) StartCommand(name string, args []string, env []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	if r.tty == nil {
 		return fmt.Errorf("PTY already started or closed")
 	}
@@ -238,15 +249,18 @@ func (r *RealPTY) Close() error {
 	}
 
 	// Close tty if shell was never started (StartShell sets tty to nil)
+	r.mu.Lock()
 	if r.tty != nil {
 		_ = r.tty.Close()
 		r.tty = nil
 	}
+	cmd := r.cmd
+	r.mu.Unlock()
 
 	// Kill and wait for process (if shell was started)
-	if r.cmd != nil && r.cmd.Process != nil {
-		_ = r.cmd.Process.Kill()
-		_ = r.cmd.Wait()
+	if cmd != nil {
+		_ = cmd.Process.Kill()
+		r.wait(cmd)
 	}
 
 	return nil
@@ -255,10 +269,34 @@ func (r *RealPTY) Close() error {
 // Verify RealPTY implements PTY interface
 var _ PTY = (*RealPTY)(nil)
 
+// started returns the started process, or nil if none has started.
+func (r *RealPTY) started() *exec.Cmd {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cmd
+}
+
+// wait reaps cmd. Safe to call more than once and from several goroutines;
+// cmd.ProcessState may be read once it returns.
+func (r *RealPTY) wait(cmd *exec.Cmd) {
+	r.waitOnce.Do(func() { _ = cmd.Wait() })
+}
+
+// ExitCode waits for the process to exit and returns its exit status: -1 if
+// it was killed by a signal (e.g. by Close) or never started.
+func (r *RealPTY) ExitCode() int {
+	cmd := r.started()
+	if cmd == nil {
+		return -1
+	}
+	r.wait(cmd)
+	return cmd.ProcessState.ExitCode()
+}
+
 // GetPid returns the PID of the running process, or 0 if not started.
 func (r *RealPTY) GetPid() int {
-	if r.cmd != nil && r.cmd.Process != nil {
-		return r.cmd.Process.Pid
+	if cmd := r.started(); cmd != nil {
+		return cmd.Process.Pid
 	}
 	return 0
 }
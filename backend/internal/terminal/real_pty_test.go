@@ -0,0 +1,42 @@
+package terminal
+
+import (
+	"os"
+	"testing"
+)
+
+func TestRealPTY_StartWhileObserved(t *testing.T) {
+	// Test Doc:
+	// - Why: The shell starts on the connection goroutine (first resize) while the read loop and the session poller already watch the PTY
+	// - Contract: GetPid/ExitCode may run concurrently with StartCommand; ExitCode waits for the started process and returns its status; a PTY closed before starting reports -1
+	// - Usage Notes: Meaningful under go test -race
+
+	p, err := NewUnstartedPTY()
+	if err != nil {
+		t.Fatalf("NewUnstartedPTY: %v", err)
+	}
+	defer p.Close()
+
+	polled := make(chan struct{})
+	go func() {
+		defer close(polled)
+		for p.GetPid() == 0 {
+		}
+	}()
+	if err := p.StartCommand("/bin/sh", []string{"-c", "exit 3"}, os.Environ()); err != nil {
+		t.Fatalf("StartCommand: %v", err)
+	}
+	<-polled
+	if got := p.ExitCode(); got != 3 {
+		t.Errorf("ExitCode = %d, want 3", got)
+	}
+
+	unstarted, err := NewUnstartedPTY()
+	if err != nil {
+		t.Fatalf("NewUnstartedPTY: %v", err)
+	}
+	unstarted.Close()
+	if got := unstarted.ExitCode(); got != -1 {
+		t.Errorf("ExitCode of a PTY that never started = %d, want -1", got)
+	}
+}
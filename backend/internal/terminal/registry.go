@@ -101,6 +101,21 @@ func (r *SessionRegistry) ListByOwner(owner string) []*Session {
 	return sessions
 }
 
+// ListAccessible returns sessions the given user owns or has been shared.
+// If username is empty, returns all sessions (same as ListByOwner).
+func (r *SessionRegistry) ListAccessible(username string) []*Session {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sessions := make([]*Session, 0)
+	for _, s := range r.sessions {
+		if username == "" || s.PermissionFor(username) != SharePermissionNone {
+			sessions = append(sessions, s)
+		}
+	}
+	return sessions
+}
+
 // ListByTmuxSession returns all sessions attached to the given tmux session name.
 // Returns an empty slice (not nil) if no sessions match.
 // Used by Plan 013 (Session Metadata API) for tmux-targeted updates.
@@ -132,7 +147,14 @@ type SessionInfo struct {
 	Status           SessionStatus `json:"status"`
 	CreatedAt        time.Time     `json:"createdAt"`
 	Owner            string        `json:"owner,omitempty"`
+	Profile          string        `json:"profile,omitempty"`
 	TmuxSessionName  string        `json:"tmuxSessionName,omitempty"`
+	TmuxPaneID       string        `json:"tmuxPaneId,omitempty"`
+	TmuxHost         string        `json:"tmuxHost,omitempty"`
+	TmuxSocket       string        `json:"tmuxSocket,omitempty"`
+	Foreground       *ForegroundProcess `json:"foreground,omitempty"` // Process in the foreground of the terminal
+	Title            string        `json:"title,omitempty"` // Window title set by the session's programs (OSC 0/2)
+	Permission       SharePermission `json:"permission,omitempty"` // Caller's access level (set by the API handler)
 }
 
 // Info returns the session metadata suitable for API responses.
@@ -144,6 +166,12 @@ func (s *Session) Info() SessionInfo {
 		Status:          s.Status,
 		CreatedAt:       s.CreatedAt,
 		Owner:           s.Owner,
+		Profile:         s.Profile,
 		TmuxSessionName: s.TmuxSessionName,
+		TmuxPaneID:      s.TmuxPaneID,
+		TmuxHost:        s.TmuxHost,
+		TmuxSocket:      s.TmuxSocket,
+		Foreground:      s.Foreground(),
+		Title:           s.Title(),
 	}
 }
@@ -34,11 +34,14 @@ type Session struct {
 	Status    SessionStatus // Lifecycle status
 	CreatedAt time.Time     // When session was created
 	Owner     string        // GitHub username of session creator (empty when auth disabled)
+	Profile   string        // Profile name selected at create time (e.g., "default", "agents")
 
 	// tmux tracking fields
 	TtyPath          string // TTY device path (e.g., "/dev/ttys010") for tmux client matching
 	TmuxSessionName  string // tmux session this terminal is attached to (empty = not in tmux)
-	Cwd              string // Last known working directory
+	TmuxPaneID       string // tmux pane streamed by this terminal (empty = whole session or not tmux)
+	TmuxHost         string // SSH host of the attached tmux session (empty = local)
+	TmuxSocket       string // tmux server socket of the attached session (empty = default)
 
 	pty  PTY
 	conn Conn
@@ -51,8 +54,53 @@ type Session struct {
 	// writeMu protects concurrent WebSocket writes
 	writeMu sync.Mutex
 
+	// outputOffset counts the PTY output bytes sent so far (the offset
+	// space of CommandRecord.OutputStart/End). Guarded by writeMu.
+	outputOffset int64
+
 	// state tracks the session lifecycle atomically
 	state atomic.Int32
+
+	// exitSent ensures clients get one exit message per session
+	exitSent atomic.Bool
+
+	// sharing holds share grants and secondary (viewer/collaborator) connections.
+	// Initialized lazily via sharingState().
+	sharing     *sessionSharing
+	sharingOnce sync.Once
+
+	// inputRecorder reconstructs and stores submitted input lines when the
+	// session's profile has input audit enabled. Nil when disabled.
+	// Set once via SetInputRecorder before RunReadPTY starts.
+	inputRecorder *InputRecorder
+
+	// notifier fires notifications for matching output, long commands and
+	// quiet periods. Nil when the profile has no rules.
+	// Set once via SetNotifier before RunReadPTY starts.
+	notifier *SessionNotifier
+
+	// onExit is called once with the exit status when the process exits.
+	// Set once via OnExit before RunReadPTY starts.
+	onExit func(code int)
+
+	// onEvent is called with each cwd_update and plugin_data message, for the
+	// server's event stream. Set once via OnEvent before RunReadPTY starts.
+	onEvent func(msg ServerMessage)
+
+	// foreground is the last detected foreground process (set by the session poller).
+	foreground atomic.Pointer[ForegroundProcess]
+
+	// pluginData is the last plugin_data sent for each plugin ID, so
+	// connections attaching later can be brought up to date.
+	pluginDataMu sync.Mutex
+	pluginData   map[string]json.RawMessage
+
+	// cwd is the last known working directory, set by the session poller and
+	// by OSC 7 from the PTY reader. See Cwd and SetCwd.
+	cwd atomic.Pointer[string]
+
+	// shell parses shell integration sequences (OSC 7/133/0/2) from output.
+	shell *ShellIntegration
 }
 
 // NewSession creates a new terminal session bridging the given PTY and WebSocket.
@@ -64,6 +112,7 @@ func NewSession(pty PTY, conn Conn) *Session {
 		ctx:       ctx,
 		cancel:    cancel,
 		CreatedAt: time.Now(),
+		shell:     NewShellIntegration(),
 	}
 	s.initState()
 	return s
@@ -80,6 +129,7 @@ func NewSessionWithConn(id string, pty PTY, conn Conn) *Session {
 		ctx:       ctx,
 		cancel:    cancel,
 		CreatedAt: time.Now(),
+		shell:     NewShellIntegration(),
 	}
 	s.initState()
 	return s
@@ -156,22 +206,62 @@ func (s *Session) CloseGracefully() {
 
 	// Close resources
 	s.pty.Close()
+	if s.inputRecorder != nil {
+		s.inputRecorder.Close()
+	}
+	if s.notifier != nil {
+		s.notifier.Close()
+	}
 	// Note: Don't close conn here as it may be shared (multi-session)
 
 	// Transition to fully Closed
 	s.transitionTo(SessionStateClosed)
 }
 
-// WriteInput writes data to the PTY (terminal input).
+// CloseWithReason closes the session like CloseGracefully, replacing the
+// read loop's plain exit message with one whose Error is reason. If the
+// process already exited and its exit was sent, no second exit is sent.
+func (s *Session) CloseWithReason(reason string) {
+	if !s.exitSent.CompareAndSwap(false, true) {
+		s.CloseGracefully()
+		return
+	}
+	s.CloseGracefully()
+	msg := ServerMessage{
+		SessionId: s.ID,
+		Type:      MsgTypeExit,
+		Error:     reason,
+	}
+	if err := s.sendJSON(msg); err != nil {
+		log.Printf("Failed to send exit message for session %s: %v", s.ID, err)
+	}
+}
+
+// WriteInput writes data to the PTY (terminal input), attributed to the owner.
 func (s *Session) WriteInput(data string) {
+	s.WriteInputFrom(s.Owner, data)
+}
+
+// WriteInputFrom writes data to the PTY on behalf of user. When input audit
+// is enabled the keystrokes are also fed to the recorder under that user.
+func (s *Session) WriteInputFrom(user, data string) {
 	if !s.IsRunning() {
 		return
 	}
+	if s.inputRecorder != nil {
+		s.inputRecorder.Feed(user, data)
+	}
 	if _, err := s.pty.Write([]byte(data)); err != nil {
 		log.Printf("PTY write error for session %s: %v", s.ID, err)
 	}
 }
 
+// SetInputRecorder enables input audit for this session. Must be called
+// before RunReadPTY starts.
+func (s *Session) SetInputRecorder(r *InputRecorder) {
+	s.inputRecorder = r
+}
+
 // Resize changes the terminal dimensions.
 func (s *Session) Resize(cols, rows uint16) {
 	if !s.IsRunning() {
@@ -199,27 +289,57 @@ func (s *Session) RunReadPTY() {
 				log.Printf("PTY read error for session %s: %v", s.ID, err)
 			}
 			// Send exit message
-			s.sendExitMessageWithSession(0)
+			code := s.exitCode()
+			s.sendExitMessageWithSession(code)
+			if s.onExit != nil {
+				s.onExit(code)
+			}
 			return
 		}
 
 		if n > 0 {
-			msg := ServerMessage{
-				SessionId: s.ID,
-				ShellType: s.ShellType,
-				Type:      MsgTypeOutput,
-				Data:      string(buf[:n]),
+			if s.inputRecorder != nil {
+				s.inputRecorder.ObserveOutput(buf[:n])
 			}
-			if err := s.sendJSON(msg); err != nil {
+			if err := s.sendOutput(buf[:n]); err != nil {
 				log.Printf("WebSocket write error for session %s: %v", s.ID, err)
 				return
 			}
+			s.observeShell(buf[:n])
+			if s.notifier != nil {
+				s.notifier.ObserveOutput(buf[:n])
+			}
 		}
 	}
 }
 
-// sendExitMessageWithSession sends an exit message with session ID.
+// exitCode returns the process's exit status when the PTY can report one
+// (RealPTY), otherwise 0.
+func (s *Session) exitCode() int {
+	if p, ok := s.pty.(interface{ ExitCode() int }); ok {
+		return p.ExitCode()
+	}
+	return 0
+}
+
+// OnEvent registers fn to be called with each cwd_update and plugin_data
+// message the session sends. Must be called before RunReadPTY starts.
+func (s *Session) OnEvent(fn func(msg ServerMessage)) {
+	s.onEvent = fn
+}
+
+// OnExit registers fn to be called with the exit status when the session's
+// process exits or is closed. Must be called before RunReadPTY starts.
+func (s *Session) OnExit(fn func(code int)) {
+	s.onExit = fn
+}
+
+// sendExitMessageWithSession sends an exit message with session ID, unless
+// one was already sent (see CloseWithReason).
 func (s *Session) sendExitMessageWithSession(code int) {
+	if !s.exitSent.CompareAndSwap(false, true) {
+		return
+	}
 	msg := ServerMessage{
 		SessionId: s.ID,
 		Type:      MsgTypeExit,
@@ -312,7 +432,9 @@ func (s *Session) readWebSocket() {
 	}
 }
 
-// sendJSON sends a JSON-encoded message to the WebSocket.
+// sendJSON sends a JSON-encoded message to the WebSocket and fans it out to
+// any attached viewer/collaborator connections. Only a failure on the owner
+// connection is returned; attached connection errors are logged.
 func (s *Session) sendJSON(msg ServerMessage) error {
 	data, err := json.Marshal(msg)
 	if err != nil {
@@ -322,9 +444,71 @@ func (s *Session) sendJSON(msg ServerMessage) error {
 	s.writeMu.Lock()
 	defer s.writeMu.Unlock()
 
+	for _, c := range s.attachedConns() {
+		if err := c.WriteMessage(websocket.TextMessage, data); err != nil {
+			log.Printf("Attached connection write error for session %s: %v", s.ID, err)
+		}
+	}
+
 	return s.conn.WriteMessage(websocket.TextMessage, data)
 }
 
+// sendOwnerJSON sends a message to the owner connection only. Used for
+// messages that describe the owner's connection (its tmux attachments, the
+// tmux session list) rather than the session, which attached viewers must
+// not receive.
+func (s *Session) sendOwnerJSON(msg ServerMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return s.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// sendOutput sends PTY output to the owner and every attached connection: as
+// a binary frame to connections that negotiated binary_frames, otherwise as
+// a JSON output message. Each encoding is built at most once.
+func (s *Session) sendOutput(data []byte) error {
+	var text, binary []byte
+	write := func(c Conn) error {
+		if b, ok := c.(BinaryOutputConn); ok && b.BinaryOutput() {
+			if binary == nil {
+				binary = BinaryOutputFrame(s.ID, data)
+			}
+			return c.WriteMessage(websocket.BinaryMessage, binary)
+		}
+		if text == nil {
+			var err error
+			text, err = json.Marshal(ServerMessage{
+				SessionId: s.ID,
+				ShellType: s.ShellType,
+				Type:      MsgTypeOutput,
+				Data:      string(data),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return c.WriteMessage(websocket.TextMessage, text)
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	for _, c := range s.attachedConns() {
+		if err := write(c); err != nil {
+			log.Printf("Attached connection write error for session %s: %v", s.ID, err)
+		}
+	}
+
+	err := write(s.conn)
+	s.outputOffset += int64(len(data))
+	return err
+}
+
 // sendError sends an error message to the client.
 func (s *Session) sendError(errMsg string) {
 	msg := ServerMessage{
@@ -343,35 +527,110 @@ func (s *Session) GetConn() Conn {
 }
 
 // GetPid returns the PID of the running process, or 0 if unavailable.
+// For a tmux pane this is the process the pane was started with.
 func (s *Session) GetPid() int {
-	if rpty, ok := s.pty.(*RealPTY); ok {
-		return rpty.GetPid()
+	if p, ok := s.pty.(interface{ GetPid() int }); ok {
+		return p.GetPid()
 	}
 	return 0
 }
 
-// SendTmuxStatus sends a tmux_status message with the given updates map.
+// SendTmuxStatus sends a tmux_status message with the given updates map to
+// the owner connection.
 func (s *Session) SendTmuxStatus(updates map[string]string) {
 	msg := ServerMessage{
 		Type:        MsgTypeTmuxStatus,
 		TmuxUpdates: updates,
 	}
-	if err := s.sendJSON(msg); err != nil {
+	if err := s.sendOwnerJSON(msg); err != nil {
 		log.Printf("Failed to send tmux_status for session %s: %v", s.ID, err)
 	}
 }
 
-// SendTmuxSessions sends a tmux_sessions message with the full session list.
+// SendTmuxSessions sends a tmux_sessions message with the full session list
+// to the owner connection.
 func (s *Session) SendTmuxSessions(sessions []TmuxSessionInfo) {
 	msg := ServerMessage{
 		Type:         MsgTypeTmuxSessions,
 		TmuxSessions: sessions,
 	}
-	if err := s.sendJSON(msg); err != nil {
+	if err := s.sendOwnerJSON(msg); err != nil {
 		log.Printf("Failed to send tmux_sessions for session %s: %v", s.ID, err)
 	}
 }
 
+// Cwd returns the last known working directory, or "" if unknown.
+func (s *Session) Cwd() string {
+	if cwd := s.cwd.Load(); cwd != nil {
+		return *cwd
+	}
+	return ""
+}
+
+// SetCwd records the working directory without notifying anyone. Used to
+// seed a session's cwd before it is announced.
+func (s *Session) SetCwd(cwd string) {
+	s.cwd.Store(&cwd)
+}
+
+// updateCwd records a detected working directory and sends cwd_update if
+// it changed. Safe to call from the poller and the PTY reader at once.
+func (s *Session) updateCwd(cwd string) {
+	if old := s.cwd.Swap(&cwd); old != nil && *old == cwd {
+		return
+	}
+	s.SendCwdUpdate(cwd)
+}
+
+// SendCwdUpdate sends a cwd_update message with the session's new working directory.
+func (s *Session) SendCwdUpdate(cwd string) {
+	msg := ServerMessage{
+		SessionId: s.ID,
+		Type:      MsgTypeCwdUpdate,
+		Cwd:       cwd,
+	}
+	if s.onEvent != nil {
+		s.onEvent(msg)
+	}
+	if err := s.sendJSON(msg); err != nil {
+		log.Printf("Failed to send cwd_update for session %s: %v", s.ID, err)
+	}
+}
+
+// SendPluginData sends a plugin_data message with a collector's latest data.
+func (s *Session) SendPluginData(pluginID string, data json.RawMessage) {
+	s.pluginDataMu.Lock()
+	if s.pluginData == nil {
+		s.pluginData = make(map[string]json.RawMessage)
+	}
+	s.pluginData[pluginID] = data
+	s.pluginDataMu.Unlock()
+
+	msg := ServerMessage{
+		SessionId:  s.ID,
+		Type:       MsgTypePluginData,
+		PluginId:   pluginID,
+		PluginData: data,
+	}
+	if s.onEvent != nil {
+		s.onEvent(msg)
+	}
+	if err := s.sendJSON(msg); err != nil {
+		log.Printf("Failed to send plugin_data for session %s: %v", s.ID, err)
+	}
+}
+
+// PluginData returns a copy of the last plugin data sent for each plugin ID.
+func (s *Session) PluginData() map[string]json.RawMessage {
+	s.pluginDataMu.Lock()
+	defer s.pluginDataMu.Unlock()
+	result := make(map[string]json.RawMessage, len(s.pluginData))
+	for id, data := range s.pluginData {
+		result[id] = data
+	}
+	return result
+}
+
 // sendExitMessage sends an exit message to the client.
 func (s *Session) sendExitMessage(code int) {
 	msg := ServerMessage{
@@ -0,0 +1,335 @@
+package terminal
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// sessionPollerTick is how often the poller checks for due work. Sessions
+// and collectors each run on their own schedule; the tick only bounds how
+// late they can be.
+const sessionPollerTick = time.Second
+
+// defaultCollectorTimeout bounds one collector run when
+// SessionPollerConfig.CollectorTimeout is zero.
+const defaultCollectorTimeout = 10 * time.Second
+
+// SessionPollerConfig configures a SessionPoller.
+type SessionPollerConfig struct {
+	// Interval is how often each session's cwd and process tree are checked,
+	// and the default for collectors whose Interval() is zero.
+	Interval time.Duration
+	// Jitter randomises each delay by up to this fraction either way
+	// (0.1 = ±10%), so sessions created together don't poll in lockstep.
+	Jitter float64
+	// MaxBackoff caps the retry delay of a collector that keeps failing.
+	MaxBackoff time.Duration
+	// CollectorTimeout bounds one collector run (default 10s). A run that
+	// takes longer counts as a failure; the collector isn't run again for
+	// that session until the slow run returns.
+	CollectorTimeout time.Duration
+	// SessionProcesses optionally returns extra process names for a session,
+	// such as the processes inside an attached tmux session's panes.
+	SessionProcesses func(session *Session) []string
+	// OnPluginData, if set, is called with each changed collector result
+	// after it is sent to the session's connections.
+	OnPluginData func(session *Session, pluginID string, data json.RawMessage)
+}
+
+// collectorPollState is one collector's schedule for one session.
+type collectorPollState struct {
+	next     time.Time
+	failures int
+	last     string      // last JSON sent, to skip unchanged data
+	running  atomic.Bool // a timed-out run hasn't returned yet
+}
+
+// collectResult is what one collector run returned.
+type collectResult struct {
+	data json.RawMessage
+	err  error
+}
+
+// sessionPollState is one session's schedule and last detected processes.
+// Fields other than busy belong to the goroutine polling the session while
+// busy is set.
+type sessionPollState struct {
+	busy          bool // guarded by SessionPoller.mu
+	next          time.Time
+	processes     []string
+	foregroundPID int
+	collectors    map[string]*collectorPollState
+}
+
+// SessionPoller polls every running session once, server-wide: it detects
+// working directory changes and process trees on Interval, tracks the
+// foreground process, runs matching plugin collectors on their own
+// Interval(), and sends cwd_update, process_update and plugin_data messages
+// through the session to its owner and every attached connection. Failing
+// collectors back off exponentially. Sessions are polled concurrently, so a
+// slow collector or connection only holds up its own session.
+type SessionPoller struct {
+	registry   *SessionRegistry
+	cwd        CwdDetector
+	processes  ProcessDetector
+	collectors *CollectorRegistry
+	cfg        SessionPollerConfig
+
+	now    func() time.Time
+	random func() float64
+
+	mu     sync.Mutex // protects states and each state's busy flag
+	states map[string]*sessionPollState
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewSessionPoller creates a poller. Call Start to begin polling.
+func NewSessionPoller(registry *SessionRegistry, cwd CwdDetector, processes ProcessDetector, collectors *CollectorRegistry, cfg SessionPollerConfig) *SessionPoller {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 5 * time.Second
+	}
+	if cfg.MaxBackoff < cfg.Interval {
+		cfg.MaxBackoff = cfg.Interval
+	}
+	if cfg.CollectorTimeout <= 0 {
+		cfg.CollectorTimeout = defaultCollectorTimeout
+	}
+	return &SessionPoller{
+		registry:   registry,
+		cwd:        cwd,
+		processes:  processes,
+		collectors: collectors,
+		cfg:        cfg,
+		now:        time.Now,
+		random:     rand.Float64,
+		states:     make(map[string]*sessionPollState),
+		stopCh:     make(chan struct{}),
+	}
+}
+
+// Start begins polling in a goroutine.
+func (p *SessionPoller) Start() {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(sessionPollerTick)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stopCh:
+				return
+			case <-ticker.C:
+				p.poll() // Don't wait: a slow session is skipped until it finishes
+			}
+		}
+	}()
+	log.Printf("session poller started (interval: %s)", p.cfg.Interval)
+}
+
+// Stop ends polling and waits for in-progress session polls to finish.
+func (p *SessionPoller) Stop() {
+	close(p.stopCh)
+	p.wg.Wait()
+}
+
+// Poll runs whatever is due for every running session, then forgets
+// sessions that are gone, and waits for the work it started. Start runs the
+// same polls every tick without waiting.
+func (p *SessionPoller) Poll() {
+	p.poll().Wait()
+}
+
+// poll reads the schedule under mu and starts a goroutine per session for
+// the polling itself (/proc reads, collectors, sends), outside mu. A session
+// whose previous poll is still running is skipped. The returned WaitGroup
+// covers the goroutines started.
+func (p *SessionPoller) poll() *sync.WaitGroup {
+	type job struct {
+		session *Session
+		pid     int
+		state   *sessionPollState
+	}
+
+	p.mu.Lock()
+	now := p.now()
+	live := make(map[string]bool)
+	var jobs []job
+	for _, session := range p.registry.List() {
+		if !session.IsRunning() {
+			continue
+		}
+		pid := session.GetPid()
+		if pid <= 0 {
+			continue // Process not started yet
+		}
+		live[session.ID] = true
+
+		state := p.states[session.ID]
+		if state == nil {
+			state = &sessionPollState{collectors: make(map[string]*collectorPollState)}
+			p.states[session.ID] = state
+		}
+		if state.busy {
+			continue
+		}
+		state.busy = true
+		jobs = append(jobs, job{session, pid, state})
+	}
+	for id := range p.states {
+		if !live[id] {
+			delete(p.states, id)
+		}
+	}
+	p.mu.Unlock()
+
+	wg := &sync.WaitGroup{}
+	for _, j := range jobs {
+		wg.Add(1)
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			defer wg.Done()
+			p.pollOne(j.session, j.pid, j.state, now)
+			p.mu.Lock()
+			j.state.busy = false
+			p.mu.Unlock()
+		}()
+	}
+	return wg
+}
+
+// pollOne runs whatever is due for one session.
+func (p *SessionPoller) pollOne(session *Session, pid int, state *sessionPollState, now time.Time) {
+	due := !now.Before(state.next)
+	p.pollForeground(session, state, due)
+	if due {
+		p.pollSession(session, pid, state, now)
+	}
+	p.runCollectors(session, pid, state, now)
+}
+
+// pollSession detects the session's cwd and process tree. The cwd isn't
+// polled once the shell reports it with OSC 7.
+func (p *SessionPoller) pollSession(session *Session, pid int, state *sessionPollState, now time.Time) {
+	if !session.shell.ReportsCwd() {
+		if cwd := p.cwd.DetectCwd(pid); cwd != "" {
+			session.updateCwd(cwd)
+		}
+	}
+
+	processes := p.processes.DetectProcessTree(pid)
+	if p.cfg.SessionProcesses != nil {
+		processes = append(processes, p.cfg.SessionProcesses(session)...)
+	}
+	state.processes = processes
+	state.next = now.Add(p.jittered(p.cfg.Interval))
+}
+
+// pollForeground reports the session's foreground process when the
+// terminal's foreground process group changes. Checking the group is one
+// ioctl, so it runs every tick; the process is also re-read when the session
+// is due, since a job may exec another program without changing group.
+func (p *SessionPoller) pollForeground(session *Session, state *sessionPollState, due bool) {
+	pgid := session.ForegroundPID()
+	if pgid <= 0 || (pgid == state.foregroundPID && !due) {
+		return
+	}
+	state.foregroundPID = pgid
+
+	info, ok := p.processes.Process(pgid)
+	if !ok {
+		return // Exited between the ioctl and the lookup
+	}
+	fg := NewForegroundProcess(info)
+	if current := session.Foreground(); current != nil && *current == *fg {
+		return
+	}
+	session.SetForeground(fg)
+}
+
+// runCollectors runs each due collector that matches the session's processes.
+func (p *SessionPoller) runCollectors(session *Session, pid int, state *sessionPollState, now time.Time) {
+	if len(state.processes) == 0 {
+		return
+	}
+	for _, collector := range p.collectors.FindMatching(state.processes) {
+		cs := state.collectors[collector.ID()]
+		if cs == nil {
+			cs = &collectorPollState{}
+			state.collectors[collector.ID()] = cs
+		}
+		if now.Before(cs.next) || cs.running.Load() {
+			continue
+		}
+
+		interval := collector.Interval()
+		if interval <= 0 {
+			interval = p.cfg.Interval
+		}
+		data, err := p.collect(collector, cs, pid, session.Cwd())
+		if err != nil {
+			cs.failures++
+			delay := p.backoff(interval, cs.failures)
+			cs.next = now.Add(p.jittered(delay))
+			log.Printf("Collector %s error for session %s (retry in %s): %v", collector.ID(), session.ID, delay, err)
+			continue
+		}
+		cs.failures = 0
+		cs.next = now.Add(p.jittered(interval))
+
+		if data == nil || string(data) == cs.last {
+			continue
+		}
+		cs.last = string(data)
+		session.SendPluginData(collector.ID(), json.RawMessage(data))
+		if p.cfg.OnPluginData != nil {
+			p.cfg.OnPluginData(session, collector.ID(), data)
+		}
+	}
+}
+
+// collect runs collector for one session, giving up after CollectorTimeout.
+// A run that times out keeps cs.running set until it returns, so runs never
+// pile up behind a hung collector.
+func (p *SessionPoller) collect(collector DataCollector, cs *collectorPollState, pid int, cwd string) (json.RawMessage, error) {
+	cs.running.Store(true)
+	done := make(chan collectResult, 1)
+	go func() {
+		defer cs.running.Store(false)
+		data, err := collector.CollectForSession(pid, cwd)
+		done <- collectResult{data, err}
+	}()
+
+	timer := time.NewTimer(p.cfg.CollectorTimeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.data, r.err
+	case <-timer.C:
+		return nil, fmt.Errorf("timed out after %s", p.cfg.CollectorTimeout)
+	}
+}
+
+// backoff doubles interval for each consecutive failure, up to MaxBackoff.
+func (p *SessionPoller) backoff(interval time.Duration, failures int) time.Duration {
+	delay := interval
+	for i := 0; i < failures && delay < p.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, p.cfg.MaxBackoff)
+}
+
+// jittered randomises d by up to Jitter either way.
+func (p *SessionPoller) jittered(d time.Duration) time.Duration {
+	if p.cfg.Jitter <= 0 {
+		return d
+	}
+	return d + time.Duration((p.random()*2-1)*p.cfg.Jitter*float64(d))
+}
@@ -0,0 +1,242 @@
+package terminal
+
+import (
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// pidPTY is a FakePTY that reports a process ID, as RealPTY does.
+type pidPTY struct {
+	*FakePTY
+	pid int
+}
+
+func (p *pidPTY) GetPid() int { return p.pid }
+
+// messagesOfType decodes the messages written to ws and keeps those of msgType.
+func messagesOfType(t *testing.T, ws *FakeWebSocket, msgType string) []ServerMessage {
+	t.Helper()
+	var result []ServerMessage
+	for _, m := range ws.GetWrittenMessages() {
+		var msg ServerMessage
+		if err := json.Unmarshal(m.Data, &msg); err != nil {
+			t.Fatalf("bad message %q: %v", m.Data, err)
+		}
+		if msg.Type == msgType {
+			result = append(result, msg)
+		}
+	}
+	return result
+}
+
+// newPollerFixture registers one running session with pid 42 and returns a
+// poller on a fake clock with jitter pinned to zero.
+func newPollerFixture(t *testing.T) (*SessionPoller, *Session, *FakeWebSocket, *FakeCwdDetector, *FakeProcessDetector, *FakeDataCollector, *time.Time) {
+	t.Helper()
+	registry := NewSessionRegistry()
+	owner := NewFakeWebSocket()
+	session := NewSessionWithConn("s1", &pidPTY{FakePTY: NewFakePTY(), pid: 42}, owner)
+	registry.Add(session)
+
+	cwd := NewFakeCwdDetector()
+	processes := NewFakeProcessDetector()
+	collector := NewFakeDataCollector("todos", []string{"copilot"})
+	collector.PollInterval = 3 * time.Second
+	collectors := NewCollectorRegistry()
+	collectors.Register(collector)
+
+	now := time.Unix(1000, 0)
+	p := NewSessionPoller(registry, cwd, processes, collectors, SessionPollerConfig{
+		Interval:   5 * time.Second,
+		Jitter:     0.1,
+		MaxBackoff: 20 * time.Second,
+	})
+	p.now = func() time.Time { return now }
+	p.random = func() float64 { return 0.5 }
+	return p, session, owner, cwd, processes, collector, &now
+}
+
+func TestSessionPoller_CwdAndFanOut(t *testing.T) {
+	// Test Doc:
+	// - Why: One server-wide poll per session replaces a poller per connection, so every watcher gets the same updates once
+	// - Contract: cwd changes send cwd_update to the owner and attached connections; unchanged cwd sends nothing; the cwd is rechecked on Interval
+	// - Usage Notes: Fake clock; Poll is called directly instead of Start
+
+	p, session, owner, cwd, _, _, now := newPollerFixture(t)
+	viewer := NewFakeWebSocket()
+	session.Attach(viewer, Watcher{Username: "bob", Permission: SharePermissionViewer})
+
+	cwd.Cwds[42] = "/src"
+	p.Poll()
+	for name, ws := range map[string]*FakeWebSocket{"owner": owner, "viewer": viewer} {
+		if msgs := messagesOfType(t, ws, MsgTypeCwdUpdate); len(msgs) != 1 || msgs[0].Cwd != "/src" || msgs[0].SessionId != "s1" {
+			t.Errorf("%s cwd_update = %+v, want one for /src", name, msgs)
+		}
+	}
+
+	cwd.Cwds[42] = "/src/app"
+	*now = now.Add(time.Second)
+	p.Poll()
+	if msgs := messagesOfType(t, owner, MsgTypeCwdUpdate); len(msgs) != 1 {
+		t.Errorf("cwd_update before interval = %d messages, want 1", len(msgs))
+	}
+	*now = now.Add(5 * time.Second)
+	p.Poll()
+	if msgs := messagesOfType(t, owner, MsgTypeCwdUpdate); len(msgs) != 2 || msgs[1].Cwd != "/src/app" {
+		t.Errorf("cwd_update after interval = %+v, want /src/app second", msgs)
+	}
+}
+
+func TestSessionPoller_CollectorIntervalAndBackoff(t *testing.T) {
+	// Test Doc:
+	// - Why: DataCollector.Interval() was ignored and failing collectors were retried every cycle
+	// - Contract: A matching collector runs on its own Interval(); unchanged data isn't resent; errors back off exponentially up to MaxBackoff and reset on success
+	// - Worked Example: Interval 3s; a failure → retried 6s later (not 3s); success → every 3s again
+
+	p, _, owner, _, processes, collector, now := newPollerFixture(t)
+	processes.Trees[42] = []string{"zsh", "copilot"}
+	collector.Data = json.RawMessage(`{"todos":1}`)
+
+	advance := func(d time.Duration) {
+		*now = now.Add(d)
+		p.Poll()
+	}
+	pluginData := func() int { return len(messagesOfType(t, owner, MsgTypePluginData)) }
+
+	p.Poll()
+	if pluginData() != 1 {
+		t.Fatalf("plugin_data after first poll = %d, want 1", pluginData())
+	}
+	advance(3 * time.Second)
+	if pluginData() != 1 {
+		t.Errorf("unchanged data was resent")
+	}
+	collector.Data = json.RawMessage(`{"todos":2}`)
+	advance(time.Second)
+	if pluginData() != 1 {
+		t.Errorf("collector ran before its interval")
+	}
+	advance(2 * time.Second)
+	if pluginData() != 2 {
+		t.Errorf("plugin_data after interval = %d, want 2", pluginData())
+	}
+
+	collector.Err = errors.New("db locked")
+	advance(3 * time.Second) // fails, next try in 6s
+	collector.Err = nil
+	collector.Data = json.RawMessage(`{"todos":3}`)
+	advance(5 * time.Second)
+	if pluginData() != 2 {
+		t.Errorf("collector retried before its backoff")
+	}
+	advance(time.Second)
+	if pluginData() != 3 {
+		t.Errorf("plugin_data after backoff = %d, want 3", pluginData())
+	}
+
+	if got := p.backoff(3*time.Second, 10); got != 20*time.Second {
+		t.Errorf("backoff after 10 failures = %s, want MaxBackoff 20s", got)
+	}
+	if got := p.jittered(10 * time.Second); got != 10*time.Second {
+		t.Errorf("jittered with random 0.5 = %s, want 10s", got)
+	}
+	p.random = func() float64 { return 1 }
+	if got := p.jittered(10 * time.Second); got != 11*time.Second {
+		t.Errorf("jittered with random 1 = %s, want 11s", got)
+	}
+}
+
+// foregroundPTY is a pidPTY that also reports a foreground process group.
+type foregroundPTY struct {
+	pidPTY
+	fg int
+}
+
+func (p *foregroundPTY) ForegroundPID() int { return p.fg }
+
+func TestSessionPoller_Foreground(t *testing.T) {
+	// Test Doc:
+	// - Why: The sidebar and titles show what is running now (e.g. "npm test") without shell integration
+	// - Contract: A change of foreground process group sends process_update and sets SessionInfo.Foreground; an unchanged one sends nothing
+	// - Worked Example: fg 42 (zsh) → process_update zsh; fg 50 (npm test) → process_update "npm test"
+
+	registry := NewSessionRegistry()
+	owner := NewFakeWebSocket()
+	pty := &foregroundPTY{pidPTY: pidPTY{FakePTY: NewFakePTY(), pid: 42}, fg: 42}
+	session := NewSessionWithConn("s1", pty, owner)
+	registry.Add(session)
+
+	processes := NewFakeProcessDetector()
+	processes.Infos[42] = []ProcessInfo{
+		{PID: 42, Comm: "zsh", Cmdline: []string{"-zsh"}},
+		{PID: 50, PPID: 42, Comm: "npm", Cmdline: []string{"npm", "test"}},
+	}
+	p := NewSessionPoller(registry, NewFakeCwdDetector(), processes, NewCollectorRegistry(), SessionPollerConfig{Interval: 5 * time.Second})
+
+	p.Poll()
+	p.Poll()
+	updates := messagesOfType(t, owner, MsgTypeProcessUpdate)
+	if len(updates) != 1 || updates[0].Foreground == nil || updates[0].Foreground.Name != "zsh" {
+		t.Fatalf("process_update after idle polls = %+v, want one for zsh", updates)
+	}
+
+	pty.fg = 50
+	p.Poll()
+	updates = messagesOfType(t, owner, MsgTypeProcessUpdate)
+	if len(updates) != 2 || updates[1].Foreground.Command != "npm test" || updates[1].Foreground.PID != 50 {
+		t.Fatalf("process_update after job start = %+v, want npm test", updates)
+	}
+	if fg := session.Info().Foreground; fg == nil || fg.Name != "npm" {
+		t.Errorf("SessionInfo.Foreground = %+v, want npm", fg)
+	}
+}
+
+// hangingCollector blocks in CollectForSession until release is closed.
+type hangingCollector struct {
+	*FakeDataCollector
+	release chan struct{}
+	calls   atomic.Int32
+}
+
+func (h *hangingCollector) CollectForSession(pid int, cwd string) (json.RawMessage, error) {
+	h.calls.Add(1)
+	<-h.release
+	return h.Collect()
+}
+
+func TestSessionPoller_HungCollectorOnlyDelaysItsSession(t *testing.T) {
+	// Test Doc:
+	// - Why: Collectors, /proc reads and sends used to run one session after another under the poller lock, so one hung collector stalled every session
+	// - Contract: Sessions are polled concurrently; a collector run past CollectorTimeout counts as a failure and isn't started again until it returns
+	// - Usage Notes: The hanging collector only matches s1's process tree; s2 must still get its cwd_update
+	// - Worked Example: s1 runs "copilot" with a hung collector, s2 is in /other → Poll returns after the timeout with s2's cwd_update sent
+
+	p, _, _, cwd, processes, collector, now := newPollerFixture(t)
+	p.cfg.CollectorTimeout = 50 * time.Millisecond
+	hung := &hangingCollector{FakeDataCollector: collector, release: make(chan struct{})}
+	p.collectors = NewCollectorRegistry()
+	p.collectors.Register(hung)
+	processes.Trees[42] = []string{"zsh", "copilot"}
+
+	other := NewFakeWebSocket()
+	p.registry.Add(NewSessionWithConn("s2", &pidPTY{FakePTY: NewFakePTY(), pid: 43}, other))
+	cwd.Cwds[43] = "/other"
+
+	p.Poll()
+	if msgs := messagesOfType(t, other, MsgTypeCwdUpdate); len(msgs) != 1 || msgs[0].Cwd != "/other" {
+		t.Errorf("s2 cwd_update = %+v, want /other", msgs)
+	}
+	if cs := p.states["s1"].collectors["todos"]; cs.failures != 1 {
+		t.Errorf("failures after timeout = %d, want 1", cs.failures)
+	}
+
+	*now = now.Add(time.Minute)
+	p.Poll()
+	if n := hung.calls.Load(); n != 1 {
+		t.Errorf("collector runs while hung = %d, want 1", n)
+	}
+	close(hung.release)
+}
@@ -0,0 +1,342 @@
+package terminal
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// SharePermission is the level of access a share grants on a session.
+type SharePermission string
+
+const (
+	// SharePermissionNone means the user has no access to the session.
+	SharePermissionNone SharePermission = ""
+	// SharePermissionViewer allows watching output only.
+	SharePermissionViewer SharePermission = "viewer"
+	// SharePermissionCollaborator allows watching output and sending input/resize.
+	SharePermissionCollaborator SharePermission = "collaborator"
+	// SharePermissionOwner is the session creator. Never granted via a share.
+	SharePermissionOwner SharePermission = "owner"
+)
+
+// ErrInvalidPermission is returned when a share is created with a permission
+// other than viewer or collaborator.
+var ErrInvalidPermission = errors.New("invalid share permission")
+
+// ValidSharePermission returns true if p can be granted through a share.
+func ValidSharePermission(p SharePermission) bool {
+	return p == SharePermissionViewer || p == SharePermissionCollaborator
+}
+
+// CanWrite returns true if the permission allows sending input to the session.
+func (p SharePermission) CanWrite() bool {
+	return p == SharePermissionOwner || p == SharePermissionCollaborator
+}
+
+// ShareLink is a time-limited bearer share for a session.
+type ShareLink struct {
+	Token      string          `json:"token"`
+	Permission SharePermission `json:"permission"`
+	ExpiresAt  time.Time       `json:"expiresAt"`
+}
+
+// Watcher describes one connection attached to a session, for presence.
+type Watcher struct {
+	Username   string          `json:"username"`
+	Permission SharePermission `json:"permission"`
+}
+
+// attachment is a secondary connection receiving a session's output.
+// linkToken is set when access came from a share link rather than a user grant.
+type attachment struct {
+	watcher   Watcher
+	linkToken string
+}
+
+// sessionSharing holds the share grants, share links and attached connections
+// for a session. Lazily initialized so Session literals in tests stay valid.
+type sessionSharing struct {
+	mu       sync.RWMutex
+	users    map[string]SharePermission // username → granted permission
+	links    map[string]ShareLink       // token → link
+	attached map[Conn]*attachment       // secondary connections (not the owner conn)
+}
+
+// sharingState returns the session's sharing state, creating it on first use.
+func (s *Session) sharingState() *sessionSharing {
+	s.sharingOnce.Do(func() {
+		s.sharing = &sessionSharing{
+			users:    make(map[string]SharePermission),
+			links:    make(map[string]ShareLink),
+			attached: make(map[Conn]*attachment),
+		}
+	})
+	return s.sharing
+}
+
+// ShareWithUser grants the named user access to this session.
+// Re-sharing with the same user replaces the previous permission, including on
+// connections the user already attached through the grant; watchers are told
+// through a presence update when that happens.
+func (s *Session) ShareWithUser(username string, perm SharePermission) error {
+	if !ValidSharePermission(perm) {
+		return ErrInvalidPermission
+	}
+	sh := s.sharingState()
+	sh.mu.Lock()
+	sh.users[username] = perm
+	changed := false
+	for _, a := range sh.attached {
+		if a.watcher.Username == username && a.linkToken == "" && a.watcher.Permission != perm {
+			a.watcher.Permission = perm
+			changed = true
+		}
+	}
+	sh.mu.Unlock()
+
+	if changed {
+		s.SendPresence()
+	}
+	return nil
+}
+
+// Unshare revokes a user's share grant. Connections already attached by that
+// user are not detached; callers should call DetachUser for that.
+func (s *Session) Unshare(username string) {
+	sh := s.sharingState()
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	delete(sh.users, username)
+}
+
+// CreateShareLink issues a random bearer token granting perm until ttl elapses.
+func (s *Session) CreateShareLink(perm SharePermission, ttl time.Duration) (ShareLink, error) {
+	if !ValidSharePermission(perm) {
+		return ShareLink{}, ErrInvalidPermission
+	}
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return ShareLink{}, err
+	}
+	link := ShareLink{
+		Token:      hex.EncodeToString(b),
+		Permission: perm,
+		ExpiresAt:  time.Now().Add(ttl),
+	}
+	sh := s.sharingState()
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	sh.links[link.Token] = link
+	return link, nil
+}
+
+// RevokeShareLink invalidates a share link token and detaches every connection
+// that attached through it. Returns the connections that were detached.
+func (s *Session) RevokeShareLink(token string) []Conn {
+	sh := s.sharingState()
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	delete(sh.links, token)
+	var detached []Conn
+	for conn, a := range sh.attached {
+		if a.linkToken == token {
+			delete(sh.attached, conn)
+			detached = append(detached, conn)
+		}
+	}
+	return detached
+}
+
+// ShareLinkPermission returns the permission granted by token, or
+// SharePermissionNone if the token is unknown or expired. Expired links
+// are pruned as a side effect.
+func (s *Session) ShareLinkPermission(token string) SharePermission {
+	sh := s.sharingState()
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	return sh.linkPermissionLocked(token)
+}
+
+// linkPermissionLocked is ShareLinkPermission with sh.mu already held for writing.
+func (sh *sessionSharing) linkPermissionLocked(token string) SharePermission {
+	link, ok := sh.links[token]
+	if !ok {
+		return SharePermissionNone
+	}
+	if time.Now().After(link.ExpiresAt) {
+		delete(sh.links, token)
+		return SharePermissionNone
+	}
+	return link.Permission
+}
+
+// PermissionFor returns the access level the given user has on this session.
+// An empty owner (auth disabled) grants everyone owner access, matching
+// ListByOwner's backward-compatible behavior.
+func (s *Session) PermissionFor(username string) SharePermission {
+	if s.Owner == "" || s.Owner == username {
+		return SharePermissionOwner
+	}
+	sh := s.sharingState()
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	return sh.users[username]
+}
+
+// SharedWith returns a copy of the username → permission share grants.
+func (s *Session) SharedWith() map[string]SharePermission {
+	sh := s.sharingState()
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	result := make(map[string]SharePermission, len(sh.users))
+	for u, p := range sh.users {
+		result[u] = p
+	}
+	return result
+}
+
+// Attach adds a secondary connection that receives this session's output.
+// Attaching the same connection twice updates its watcher metadata. Returns
+// the output offset the connection's stream starts at (see AttachWithLink).
+func (s *Session) Attach(conn Conn, w Watcher) int64 {
+	return s.AttachWithLink(conn, w, "")
+}
+
+// AttachWithLink is Attach for a connection whose access came from the share
+// link token. The connection loses write access once the link expires and is
+// detached when the link is revoked. Returns the output offset the
+// connection's stream starts at: the first output it receives is the byte
+// at that offset of the session's PTY output, which is what
+// CommandRecord.OutputStart/End count.
+func (s *Session) AttachWithLink(conn Conn, w Watcher, token string) int64 {
+	// Holding writeMu keeps output from being sent between reading the
+	// offset and adding the connection.
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	sh := s.sharingState()
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	sh.attached[conn] = &attachment{watcher: w, linkToken: token}
+	return s.outputOffset
+}
+
+// Detach removes a secondary connection. Returns false if it was not attached.
+func (s *Session) Detach(conn Conn) bool {
+	sh := s.sharingState()
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if _, ok := sh.attached[conn]; !ok {
+		return false
+	}
+	delete(sh.attached, conn)
+	return true
+}
+
+// DetachUser removes every secondary connection belonging to username and
+// returns the connections that were detached.
+func (s *Session) DetachUser(username string) []Conn {
+	sh := s.sharingState()
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	var detached []Conn
+	for conn, a := range sh.attached {
+		if a.watcher.Username == username {
+			delete(sh.attached, conn)
+			detached = append(detached, conn)
+		}
+	}
+	return detached
+}
+
+// AttachedPermission returns the permission of a secondary connection, or
+// SharePermissionNone if the connection is not attached or attached through a
+// share link that has since expired.
+func (s *Session) AttachedPermission(conn Conn) SharePermission {
+	sh := s.sharingState()
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	a, ok := sh.attached[conn]
+	if !ok {
+		return SharePermissionNone
+	}
+	if a.linkToken != "" && sh.linkPermissionLocked(a.linkToken) == SharePermissionNone {
+		return SharePermissionNone
+	}
+	return a.watcher.Permission
+}
+
+// Watchers returns the owner plus every attached watcher, sorted by username.
+func (s *Session) Watchers() []Watcher {
+	sh := s.sharingState()
+	sh.mu.RLock()
+	watchers := make([]Watcher, 0, len(sh.attached)+1)
+	for _, a := range sh.attached {
+		watchers = append(watchers, a.watcher)
+	}
+	sh.mu.RUnlock()
+	sort.Slice(watchers, func(i, j int) bool {
+		return watchers[i].Username < watchers[j].Username
+	})
+	return append([]Watcher{{Username: s.Owner, Permission: SharePermissionOwner}}, watchers...)
+}
+
+// attachedConns returns a snapshot of secondary connections.
+func (s *Session) attachedConns() []Conn {
+	sh := s.sharingState()
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	if len(sh.attached) == 0 {
+		return nil
+	}
+	conns := make([]Conn, 0, len(sh.attached))
+	for c := range sh.attached {
+		conns = append(conns, c)
+	}
+	return conns
+}
+
+// SendPresence broadcasts the current watcher list to every connection on the session.
+func (s *Session) SendPresence() {
+	msg := ServerMessage{
+		SessionId: s.ID,
+		Type:      MsgTypePresence,
+		Watchers:  s.Watchers(),
+	}
+	if err := s.sendJSON(msg); err != nil {
+		log.Printf("Failed to send presence for session %s: %v", s.ID, err)
+	}
+}
+
+// CloseAttached sends an exit message to every attached connection and
+// detaches them. Called when the owner closes the session.
+func (s *Session) CloseAttached() {
+	sh := s.sharingState()
+	sh.mu.Lock()
+	conns := make([]Conn, 0, len(sh.attached))
+	for c := range sh.attached {
+		conns = append(conns, c)
+	}
+	sh.attached = make(map[Conn]*attachment)
+	sh.mu.Unlock()
+
+	if len(conns) == 0 {
+		return
+	}
+	data, err := json.Marshal(ServerMessage{SessionId: s.ID, Type: MsgTypeExit})
+	if err != nil {
+		return
+	}
+	for _, c := range conns {
+		if err := c.WriteMessage(websocket.TextMessage, data); err != nil {
+			log.Printf("Failed to send exit to attached connection for session %s: %v", s.ID, err)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package terminal
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// Test Doc:
+// - Why: Sessions can be shared read-only or read-write with other users (pairing, supervising agents)
+// - Contract: Owner always has owner access; share grants and links give viewer/collaborator; output fans out to attached conns
+// - Usage Notes: Session literals without a constructor must still work (sharing state is lazy)
+// - Quality Contribution: Guards the permission model the WebSocket handler relies on
+// - Worked Example: ShareWithUser("bob", viewer) → PermissionFor("bob") = viewer, CanWrite() = false
+
+func TestSessionShare_PermissionFor(t *testing.T) {
+	s := &Session{ID: "s1", Owner: "alice"}
+
+	if got := s.PermissionFor("alice"); got != SharePermissionOwner {
+		t.Errorf("PermissionFor(owner) = %q, want %q", got, SharePermissionOwner)
+	}
+	if got := s.PermissionFor("bob"); got != SharePermissionNone {
+		t.Errorf("PermissionFor(stranger) = %q, want none", got)
+	}
+
+	if err := s.ShareWithUser("bob", SharePermissionViewer); err != nil {
+		t.Fatalf("ShareWithUser error: %v", err)
+	}
+	if got := s.PermissionFor("bob"); got != SharePermissionViewer {
+		t.Errorf("PermissionFor(bob) = %q, want %q", got, SharePermissionViewer)
+	}
+	if s.PermissionFor("bob").CanWrite() {
+		t.Error("viewer should not be able to write")
+	}
+
+	s.Unshare("bob")
+	if got := s.PermissionFor("bob"); got != SharePermissionNone {
+		t.Errorf("PermissionFor(bob) after unshare = %q, want none", got)
+	}
+}
+
+func TestSessionShare_NoOwnerGrantsEveryone(t *testing.T) {
+	s := &Session{ID: "s1"}
+	if got := s.PermissionFor("anyone"); got != SharePermissionOwner {
+		t.Errorf("PermissionFor with auth disabled = %q, want owner", got)
+	}
+}
+
+func TestSessionShare_RejectsInvalidPermission(t *testing.T) {
+	s := &Session{ID: "s1", Owner: "alice"}
+	if err := s.ShareWithUser("bob", SharePermissionOwner); err != ErrInvalidPermission {
+		t.Errorf("ShareWithUser(owner) error = %v, want ErrInvalidPermission", err)
+	}
+	if _, err := s.CreateShareLink("admin", time.Hour); err != ErrInvalidPermission {
+		t.Errorf("CreateShareLink(admin) error = %v, want ErrInvalidPermission", err)
+	}
+}
+
+func TestSessionShare_LinkExpiry(t *testing.T) {
+	s := &Session{ID: "s1", Owner: "alice"}
+
+	link, err := s.CreateShareLink(SharePermissionCollaborator, time.Hour)
+	if err != nil {
+		t.Fatalf("CreateShareLink error: %v", err)
+	}
+	if len(link.Token) != 48 {
+		t.Errorf("token length = %d, want 48", len(link.Token))
+	}
+	if got := s.ShareLinkPermission(link.Token); got != SharePermissionCollaborator {
+		t.Errorf("ShareLinkPermission = %q, want collaborator", got)
+	}
+	if got := s.ShareLinkPermission("bogus"); got != SharePermissionNone {
+		t.Errorf("ShareLinkPermission(bogus) = %q, want none", got)
+	}
+
+	expired, _ := s.CreateShareLink(SharePermissionViewer, -time.Second)
+	if got := s.ShareLinkPermission(expired.Token); got != SharePermissionNone {
+		t.Errorf("expired link permission = %q, want none", got)
+	}
+
+	s.RevokeShareLink(link.Token)
+	if got := s.ShareLinkPermission(link.Token); got != SharePermissionNone {
+		t.Errorf("revoked link permission = %q, want none", got)
+	}
+}
+
+func TestSessionShare_OutputFansOutToAttached(t *testing.T) {
+	fakePTY := NewFakePTY()
+	owner := NewFakeWebSocket()
+	viewer := NewFakeWebSocket()
+
+	s := NewSessionWithConn("s1", fakePTY, owner)
+	s.Owner = "alice"
+	s.Attach(viewer, Watcher{Username: "bob", Permission: SharePermissionViewer})
+
+	go s.RunReadPTY()
+	defer s.CloseGracefully()
+	fakePTY.SimulateOutput("hello")
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) && len(viewer.GetWrittenMessages()) == 0 {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	for name, ws := range map[string]*FakeWebSocket{"owner": owner, "viewer": viewer} {
+		msgs := ws.GetWrittenMessages()
+		if len(msgs) == 0 {
+			t.Fatalf("%s received no messages", name)
+		}
+		var msg ServerMessage
+		json.Unmarshal(msgs[0].Data, &msg)
+		if msg.Type != MsgTypeOutput || msg.Data != "hello" {
+			t.Errorf("%s got %+v, want output \"hello\"", name, msg)
+		}
+	}
+}
+
+func TestSessionShare_AttachReturnsStreamOffset(t *testing.T) {
+	// Test Doc:
+	// - Why: CommandRecord output ranges count every PTY byte since the session started, but a late attacher's stream starts later
+	// - Contract: Attach returns how many output bytes were sent before it; the attached connection receives exactly the output from there
+	// - Worked Example: "hello" sent → Attach returns 5 → viewer's first output is "world"
+
+	owner := NewFakeWebSocket()
+	viewer := NewFakeWebSocket()
+	s := NewSessionWithConn("s1", NewFakePTY(), owner)
+
+	s.sendOutput([]byte("hello"))
+	if offset := s.Attach(viewer, Watcher{Username: "bob", Permission: SharePermissionViewer}); offset != 5 {
+		t.Errorf("Attach offset = %d, want 5", offset)
+	}
+	s.sendOutput([]byte("world"))
+
+	msgs := viewer.GetWrittenMessages()
+	if len(msgs) != 1 {
+		t.Fatalf("viewer got %d messages, want 1", len(msgs))
+	}
+	var msg ServerMessage
+	json.Unmarshal(msgs[0].Data, &msg)
+	if msg.Data != "world" {
+		t.Errorf("viewer output = %q, want %q", msg.Data, "world")
+	}
+}
+
+func TestSessionShare_WatchersAndDetach(t *testing.T) {
+	owner := NewFakeWebSocket()
+	bob := NewFakeWebSocket()
+	carol := NewFakeWebSocket()
+
+	s := NewSessionWithConn("s1", NewFakePTY(), owner)
+	s.Owner = "alice"
+	s.Attach(carol, Watcher{Username: "carol", Permission: SharePermissionCollaborator})
+	s.Attach(bob, Watcher{Username: "bob", Permission: SharePermissionViewer})
+
+	watchers := s.Watchers()
+	want := []string{"alice", "bob", "carol"}
+	if len(watchers) != len(want) {
+		t.Fatalf("Watchers() len = %d, want %d", len(watchers), len(want))
+	}
+	for i, w := range watchers {
+		if w.Username != want[i] {
+			t.Errorf("Watchers()[%d] = %q, want %q", i, w.Username, want[i])
+		}
+	}
+
+	if got := s.AttachedPermission(carol); got != SharePermissionCollaborator {
+		t.Errorf("AttachedPermission(carol) = %q, want collaborator", got)
+	}
+
+	detached := s.DetachUser("bob")
+	if len(detached) != 1 || detached[0] != Conn(bob) {
+		t.Errorf("DetachUser(bob) = %v, want [bob conn]", detached)
+	}
+	if s.Detach(bob) {
+		t.Error("Detach(bob) after DetachUser should return false")
+	}
+
+	s.CloseAttached()
+	msgs := carol.GetWrittenMessages()
+	if len(msgs) != 1 {
+		t.Fatalf("carol messages = %d, want 1 exit", len(msgs))
+	}
+	var msg ServerMessage
+	json.Unmarshal(msgs[0].Data, &msg)
+	if msg.Type != MsgTypeExit {
+		t.Errorf("carol got %q, want exit", msg.Type)
+	}
+	if s.AttachedPermission(carol) != SharePermissionNone {
+		t.Error("carol should be detached after CloseAttached")
+	}
+}
+
+func TestSessionShare_RevokeAndExpireLinkAttachments(t *testing.T) {
+	s := NewSessionWithConn("s1", NewFakePTY(), NewFakeWebSocket())
+	s.Owner = "alice"
+	carol := NewFakeWebSocket()
+	dave := NewFakeWebSocket()
+
+	link, _ := s.CreateShareLink(SharePermissionCollaborator, time.Hour)
+	s.AttachWithLink(carol, Watcher{Username: "carol", Permission: SharePermissionCollaborator}, link.Token)
+	expired, _ := s.CreateShareLink(SharePermissionCollaborator, -time.Second)
+	s.AttachWithLink(dave, Watcher{Username: "dave", Permission: SharePermissionCollaborator}, expired.Token)
+
+	if got := s.AttachedPermission(dave); got != SharePermissionNone {
+		t.Errorf("AttachedPermission through expired link = %q, want none", got)
+	}
+	if got := s.AttachedPermission(carol); got != SharePermissionCollaborator {
+		t.Errorf("AttachedPermission through live link = %q, want collaborator", got)
+	}
+
+	detached := s.RevokeShareLink(link.Token)
+	if len(detached) != 1 || detached[0] != Conn(carol) {
+		t.Errorf("RevokeShareLink detached %v, want [carol conn]", detached)
+	}
+	if got := s.AttachedPermission(carol); got != SharePermissionNone {
+		t.Errorf("AttachedPermission after revoke = %q, want none", got)
+	}
+}
+
+func TestSessionShare_RegrantUpdatesAttachments(t *testing.T) {
+	owner := NewFakeWebSocket()
+	bob := NewFakeWebSocket()
+	s := NewSessionWithConn("s1", NewFakePTY(), owner)
+	s.Owner = "alice"
+
+	s.ShareWithUser("bob", SharePermissionCollaborator)
+	s.Attach(bob, Watcher{Username: "bob", Permission: SharePermissionCollaborator})
+	s.ShareWithUser("bob", SharePermissionViewer)
+
+	if got := s.AttachedPermission(bob); got != SharePermissionViewer {
+		t.Errorf("AttachedPermission after downgrade = %q, want viewer", got)
+	}
+	msgs := owner.GetWrittenMessages()
+	if len(msgs) != 1 {
+		t.Fatalf("owner messages = %d, want 1 presence", len(msgs))
+	}
+	var msg ServerMessage
+	json.Unmarshal(msgs[0].Data, &msg)
+	if msg.Type != MsgTypePresence || len(msg.Watchers) != 2 || msg.Watchers[1].Permission != SharePermissionViewer {
+		t.Errorf("owner got %+v, want presence with bob as viewer", msg)
+	}
+}
+
+func TestSessionShare_ConnectionMessagesSkipAttached(t *testing.T) {
+	owner := NewFakeWebSocket()
+	viewer := NewFakeWebSocket()
+	s := NewSessionWithConn("s1", NewFakePTY(), owner)
+	s.Owner = "alice"
+	s.Attach(viewer, Watcher{Username: "bob", Permission: SharePermissionViewer})
+
+	s.SendTmuxStatus(map[string]string{"s1": "work", "s7": "private"})
+	s.SendTmuxSessions([]TmuxSessionInfo{{Name: "work"}})
+
+	if got := len(owner.GetWrittenMessages()); got != 2 {
+		t.Errorf("owner messages = %d, want tmux_status and tmux_sessions", got)
+	}
+	if got := viewer.GetWrittenMessages(); len(got) != 0 {
+		t.Errorf("viewer got %d owner-only messages", len(got))
+	}
+}
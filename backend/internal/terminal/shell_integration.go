@@ -0,0 +1,337 @@
+package terminal
+
+import (
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// maxOSCLength bounds one OSC payload; longer sequences are dropped.
+	maxOSCLength = 4096
+	// maxCommandLength bounds the command text kept for one command.
+	maxCommandLength = 4096
+	// maxCommandHistory bounds each session's command history; the oldest
+	// commands are dropped first.
+	maxCommandHistory = 500
+)
+
+// CommandRecord is one command run in a session whose shell emits OSC 133
+// marks (see scripts/shell-integration).
+type CommandRecord struct {
+	Command    string    `json:"command"`            // Command line, e.g. "npm test"
+	Cwd        string    `json:"cwd,omitempty"`      // Working directory the command ran in
+	StartedAt  time.Time `json:"startedAt"`          // OSC 133;C
+	FinishedAt time.Time `json:"finishedAt"`         // OSC 133;D
+	DurationMs int64     `json:"durationMs"`         // FinishedAt - StartedAt
+	ExitCode   *int      `json:"exitCode,omitempty"` // nil when the shell didn't report one
+
+	// OutputStart and OutputEnd are the command's output as a byte range
+	// [start, end) of the session's PTY output: every byte read from the PTY
+	// since the session started. tmux history sent on attach is not counted,
+	// and a connection that attached later starts at the outputOffset in its
+	// session_attached, so clients subtract that and add any history they
+	// were sent to find the range in their scrollback.
+	OutputStart int64 `json:"outputStart"`
+	OutputEnd   int64 `json:"outputEnd"`
+}
+
+// ShellUpdate is what one chunk of output changed.
+type ShellUpdate struct {
+	Cwd      string          // New working directory (OSC 7), "" = unchanged
+	Finished []CommandRecord // Commands that finished (OSC 133;D)
+}
+
+// OSC parser states.
+const (
+	oscGround       = iota // Plain output
+	oscEscape              // After ESC
+	oscString              // Inside OSC, collecting the payload
+	oscStringEscape        // ESC inside OSC, expecting '\' (ST)
+	oscCSI                 // Inside a CSI sequence, skipped
+)
+
+// Shell phases between OSC 133 marks.
+const (
+	shellIdle    = iota // No prompt seen, or the command finished
+	shellPrompt         // A: the prompt is being drawn
+	shellInput          // B: the user is typing the command
+	shellRunning        // C: the command is running
+)
+
+// ShellIntegration parses shell integration sequences from a session's
+// output stream: OSC 7 (working directory), OSC 133 A/B/C/D (prompt start,
+// command start, command executed, command finished with exit code) and
+// OSC 0/2 (window title). Sequences may be split across reads and end in
+// BEL or ST. The output itself is not modified. Safe for concurrent use.
+type ShellIntegration struct {
+	mu sync.Mutex
+
+	state int    // OSC parser state
+	osc   []byte // Payload of the OSC being parsed
+	drop  bool   // The current OSC exceeded maxOSCLength
+
+	offset   int64 // Bytes observed so far
+	seqStart int64 // Offset of the ESC that began the current sequence
+	escAt    int64 // Offset of an ESC inside the current OSC
+
+	phase   int
+	input   []byte         // Echoed command text between B and C
+	current *CommandRecord // Running command (after C)
+	history []CommandRecord
+
+	cwd   string
+	title string
+
+	now func() time.Time
+}
+
+// NewShellIntegration creates a parser with no shell state. The getters
+// also work on a nil *ShellIntegration, for sessions built without one.
+func NewShellIntegration() *ShellIntegration {
+	return &ShellIntegration{now: time.Now}
+}
+
+// Observe parses one chunk of output and returns what it changed.
+func (si *ShellIntegration) Observe(data []byte) ShellUpdate {
+	si.mu.Lock()
+	defer si.mu.Unlock()
+
+	var update ShellUpdate
+	for _, b := range data {
+		si.offset++
+		switch si.state {
+		case oscEscape:
+			si.escape(b)
+		case oscString:
+			switch b {
+			case 0x07:
+				si.state = oscGround
+				si.dispatch(&update)
+			case 0x1b:
+				si.state = oscStringEscape
+				si.escAt = si.offset - 1
+			default:
+				if len(si.osc) >= maxOSCLength {
+					si.drop = true
+				} else {
+					si.osc = append(si.osc, b)
+				}
+			}
+		case oscStringEscape:
+			if b == '\\' {
+				si.state = oscGround
+				si.dispatch(&update)
+			} else {
+				// Unterminated OSC cut short by another escape sequence
+				si.seqStart = si.escAt
+				si.escape(b)
+			}
+		case oscCSI:
+			if b >= 0x40 && b <= 0x7e {
+				si.state = oscGround
+			}
+		default:
+			si.ground(b)
+		}
+	}
+	return update
+}
+
+// escape handles the byte after ESC. Caller must hold mu.
+func (si *ShellIntegration) escape(b byte) {
+	switch b {
+	case ']':
+		si.state = oscString
+		si.osc = si.osc[:0]
+		si.drop = false
+	case '[':
+		si.state = oscCSI
+	default:
+		si.state = oscGround
+	}
+}
+
+// ground handles one byte of plain output, capturing the echoed command
+// line while the user types at the prompt. Caller must hold mu.
+func (si *ShellIntegration) ground(b byte) {
+	if b == 0x1b {
+		si.state = oscEscape
+		si.seqStart = si.offset - 1
+		return
+	}
+	if si.phase != shellInput {
+		return
+	}
+	switch {
+	case b == '\b' || b == 0x7f:
+		if len(si.input) > 0 {
+			_, size := utf8.DecodeLastRune(si.input)
+			si.input = si.input[:len(si.input)-size]
+		}
+	case b < 0x20:
+		// Line breaks and other controls aren't part of the command text
+	case len(si.input) < maxCommandLength:
+		si.input = append(si.input, b)
+	}
+}
+
+// dispatch applies a complete OSC payload. Caller must hold mu.
+func (si *ShellIntegration) dispatch(update *ShellUpdate) {
+	if si.drop {
+		return
+	}
+	code, arg, _ := strings.Cut(string(si.osc), ";")
+	switch code {
+	case "0", "2":
+		si.title = arg
+	case "7":
+		if cwd := parseOSC7(arg); cwd != "" && cwd != si.cwd {
+			si.cwd = cwd
+			update.Cwd = cwd
+		}
+	case "133":
+		si.mark(arg, update)
+	}
+}
+
+// mark applies an OSC 133 mark. Caller must hold mu.
+func (si *ShellIntegration) mark(arg string, update *ShellUpdate) {
+	kind, params, _ := strings.Cut(arg, ";")
+	switch kind {
+	case "A":
+		si.phase = shellPrompt
+	case "B":
+		si.phase = shellInput
+		si.input = si.input[:0]
+	case "C":
+		command := commandLineParam(params)
+		if command == "" {
+			command = strings.TrimSpace(string(si.input))
+		}
+		si.phase = shellRunning
+		si.current = &CommandRecord{Command: command, Cwd: si.cwd, StartedAt: si.now(), OutputStart: si.offset}
+	case "D":
+		si.phase = shellIdle
+		if si.current == nil {
+			return // Prompt redrawn (e.g. Ctrl-C at the prompt) without a command
+		}
+		rec := *si.current
+		si.current = nil
+		rec.FinishedAt = si.now()
+		rec.DurationMs = rec.FinishedAt.Sub(rec.StartedAt).Milliseconds()
+		rec.OutputEnd = si.seqStart
+		code, _, _ := strings.Cut(params, ";")
+		if exit, err := strconv.Atoi(code); err == nil {
+			rec.ExitCode = &exit
+		}
+		si.history = append(si.history, rec)
+		if len(si.history) > maxCommandHistory {
+			si.history = si.history[len(si.history)-maxCommandHistory:]
+		}
+		update.Finished = append(update.Finished, rec)
+	}
+}
+
+// commandLineParam returns the command line from OSC 133;C parameters:
+// "cmdline_url=<percent-encoded>" (what our snippets send) or "cmdline=<raw>".
+func commandLineParam(params string) string {
+	for _, param := range strings.Split(params, ";") {
+		if v, ok := strings.CutPrefix(param, "cmdline_url="); ok {
+			if s, err := url.PathUnescape(v); err == nil {
+				return limitCommand(s)
+			}
+		}
+		if v, ok := strings.CutPrefix(param, "cmdline="); ok {
+			return limitCommand(v)
+		}
+	}
+	return ""
+}
+
+// limitCommand truncates s to maxCommandLength bytes.
+func limitCommand(s string) string {
+	if len(s) > maxCommandLength {
+		s = strings.ToValidUTF8(s[:maxCommandLength], "")
+	}
+	return s
+}
+
+// parseOSC7 returns the path of an OSC 7 "file://host/path" URL, or "" if
+// it isn't one. The host is ignored: a shell on a remote host reports its own
+// directory, which is still what the user sees.
+func parseOSC7(arg string) string {
+	u, err := url.Parse(arg)
+	if err != nil || u.Scheme != "file" || !strings.HasPrefix(u.Path, "/") {
+		return ""
+	}
+	return u.Path
+}
+
+// ReportsCwd reports whether the shell has sent an OSC 7 working directory.
+// Once it has, it is more accurate than polling /proc (e.g. over ssh).
+func (si *ShellIntegration) ReportsCwd() bool {
+	if si == nil {
+		return false
+	}
+	si.mu.Lock()
+	defer si.mu.Unlock()
+	return si.cwd != ""
+}
+
+// Title returns the last window title set with OSC 0/2.
+func (si *ShellIntegration) Title() string {
+	if si == nil {
+		return ""
+	}
+	si.mu.Lock()
+	defer si.mu.Unlock()
+	return si.title
+}
+
+// Commands returns the finished commands, oldest first.
+func (si *ShellIntegration) Commands() []CommandRecord {
+	if si == nil {
+		return nil
+	}
+	si.mu.Lock()
+	defer si.mu.Unlock()
+	return append([]CommandRecord(nil), si.history...)
+}
+
+// observeShell parses output for shell integration sequences and sends
+// cwd_update and command_finished for what changed.
+func (s *Session) observeShell(data []byte) {
+	update := s.shell.Observe(data)
+	if update.Cwd != "" {
+		s.updateCwd(update.Cwd)
+	}
+	for i := range update.Finished {
+		msg := ServerMessage{
+			SessionId: s.ID,
+			Type:      MsgTypeCommandFinished,
+			Command:   &update.Finished[i],
+		}
+		if err := s.sendJSON(msg); err != nil {
+			log.Printf("Failed to send command_finished for session %s: %v", s.ID, err)
+		}
+		if s.notifier != nil {
+			s.notifier.CommandFinished(update.Finished[i])
+		}
+	}
+}
+
+// Title returns the window title last set by the session's programs.
+func (s *Session) Title() string {
+	return s.shell.Title()
+}
+
+// Commands returns the session's finished commands, oldest first. Empty
+// unless the shell emits OSC 133 marks.
+func (s *Session) Commands() []CommandRecord {
+	return s.shell.Commands()
+}
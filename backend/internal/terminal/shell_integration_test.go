@@ -0,0 +1,158 @@
+package terminal
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// feedInChunks observes data split into chunks of size n, so every sequence
+// is cut at some point.
+func feedInChunks(si *ShellIntegration, data string, n int) ShellUpdate {
+	var all ShellUpdate
+	for len(data) > 0 {
+		k := min(n, len(data))
+		update := si.Observe([]byte(data[:k]))
+		data = data[k:]
+		if update.Cwd != "" {
+			all.Cwd = update.Cwd
+		}
+		all.Finished = append(all.Finished, update.Finished...)
+	}
+	return all
+}
+
+func TestShellIntegration_CommandLifecycle(t *testing.T) {
+	// Test Doc:
+	// - Why: OSC 133 marks give command boundaries, durations and exit codes that polling /proc can't
+	// - Contract: C starts a command (cmdline_url or the echoed input after B); D finishes it with its exit code and duration; OSC 7 sets the cwd the next command runs in; OSC 0/2 set the title
+	// - Usage Notes: Fed 3 bytes at a time so every sequence is split across reads; BEL and ST terminators both work
+	// - Worked Example: cd /src → "make test" runs 2s, exits 2 → {command: "make test", cwd: "/src", durationMs: 2000, exitCode: 2}
+
+	si := NewShellIntegration()
+	now := time.Unix(1000, 0)
+	si.now = func() time.Time { return now }
+
+	update := feedInChunks(si, "\x1b]7;file://host/src%20code\x07\x1b]0;build\x1b\\\x1b]133;A\x07$ \x1b]133;B\x07", 3)
+	if update.Cwd != "/src code" {
+		t.Errorf("cwd = %q, want %q", update.Cwd, "/src code")
+	}
+	if si.Title() != "build" {
+		t.Errorf("title = %q, want %q", si.Title(), "build")
+	}
+
+	feedInChunks(si, "make tset\b\b\bes\x1b[1Dt\r\n\x1b]133;C\x07", 3)
+	now = now.Add(2 * time.Second)
+	update = feedInChunks(si, "FAIL\r\n\x1b]133;D;2\x1b\\\x1b]133;A\x07$ ", 3)
+	if len(update.Finished) != 1 {
+		t.Fatalf("finished = %+v, want one command", update.Finished)
+	}
+	rec := update.Finished[0]
+	if rec.Command != "make test" || rec.Cwd != "/src code" || rec.DurationMs != 2000 || rec.ExitCode == nil || *rec.ExitCode != 2 {
+		t.Errorf("record = %+v (exit %v), want make test in /src code, 2000ms, exit 2", rec, rec.ExitCode)
+	}
+
+	// cmdline_url wins over the echo; D without a C (Ctrl-C at the prompt) records nothing
+	feedInChunks(si, "\x1b]133;B\x07ls\x1b]133;C;cmdline_url=ls%20-la\x07\x1b]133;D\x07\x1b]133;D;130\x07", 5)
+	commands := si.Commands()
+	if len(commands) != 2 || commands[1].Command != "ls -la" || commands[1].ExitCode != nil {
+		t.Errorf("commands = %+v, want make test then ls -la with no exit code", commands)
+	}
+}
+
+func TestShellIntegration_OutputRange(t *testing.T) {
+	// Test Doc:
+	// - Why: The command history points at each command's output in the scrollback instead of copying it
+	// - Contract: OutputStart is the offset just after the C mark; OutputEnd is the offset of the ESC that begins the D mark; offsets count every byte observed
+	// - Worked Example: "$ " + C (8 bytes) + "hello\r\n" + D → [10, 17)
+
+	si := NewShellIntegration()
+	update := feedInChunks(si, "$ \x1b]133;C\x07hello\r\n\x1b]133;D;0\x1b\\", 4)
+	if len(update.Finished) != 1 {
+		t.Fatalf("finished = %+v, want one command", update.Finished)
+	}
+	if rec := update.Finished[0]; rec.OutputStart != 10 || rec.OutputEnd != 17 {
+		t.Errorf("output range = [%d, %d), want [10, 17)", rec.OutputStart, rec.OutputEnd)
+	}
+}
+
+func TestShellIntegration_Limits(t *testing.T) {
+	// Test Doc:
+	// - Why: Output is untrusted; a runaway OSC or a long-lived shell must not grow memory without bound
+	// - Contract: An OSC longer than maxOSCLength is dropped; history keeps the last maxCommandHistory commands; non-file OSC 7 URLs are ignored
+
+	si := NewShellIntegration()
+	if update := si.Observe([]byte("\x1b]7;file:///" + strings.Repeat("a", maxOSCLength) + "\x07")); update.Cwd != "" {
+		t.Errorf("oversized OSC 7 set cwd %q", update.Cwd[:16])
+	}
+	if update := si.Observe([]byte("\x1b]7;http://host/x\x07")); update.Cwd != "" || si.ReportsCwd() {
+		t.Errorf("http OSC 7 set cwd %q", update.Cwd)
+	}
+
+	for range maxCommandHistory + 10 {
+		si.Observe([]byte("\x1b]133;C;cmdline=true\x07\x1b]133;D;0\x07"))
+	}
+	if got := len(si.Commands()); got != maxCommandHistory {
+		t.Errorf("history length = %d, want %d", got, maxCommandHistory)
+	}
+}
+
+func TestSession_ShellIntegrationMessages(t *testing.T) {
+	// Test Doc:
+	// - Why: Clients get instant cwd changes and per-command events instead of waiting for the poller
+	// - Contract: OSC 7 in output sends cwd_update and sets Cwd; OSC 133;D sends command_finished; the output itself is forwarded unchanged
+
+	fakePTY := NewFakePTY()
+	owner := NewFakeWebSocket()
+	s := NewSessionWithConn("s1", fakePTY, owner)
+	go s.RunReadPTY()
+	defer s.CloseGracefully()
+
+	output := "\x1b]7;file://h/tmp\x07\x1b]133;C;cmdline=ls\x07x\r\n\x1b]133;D;0\x07"
+	fakePTY.SimulateOutput(output)
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) && len(messagesOfType(t, owner, MsgTypeCommandFinished)) == 0 {
+		time.Sleep(5 * time.Millisecond)
+	}
+	finished := messagesOfType(t, owner, MsgTypeCommandFinished)
+	if len(finished) != 1 || finished[0].Command == nil || finished[0].Command.Command != "ls" || finished[0].Command.Cwd != "/tmp" {
+		t.Fatalf("command_finished = %+v, want ls in /tmp", finished)
+	}
+	if cwd := messagesOfType(t, owner, MsgTypeCwdUpdate); len(cwd) != 1 || cwd[0].Cwd != "/tmp" || s.Cwd() != "/tmp" {
+		t.Errorf("cwd_update = %+v, session cwd %q, want /tmp", cwd, s.Cwd())
+	}
+	if out := messagesOfType(t, owner, MsgTypeOutput); len(out) != 1 || out[0].Data != output {
+		t.Errorf("output = %+v, want the raw output once", out)
+	}
+}
+
+func TestSession_CwdFromTwoWriters(t *testing.T) {
+	// Test Doc:
+	// - Why: OSC 7 (PTY reader goroutine) and the session poller both update the cwd
+	// - Contract: Concurrent updates are safe; a cwd_update is sent only when the value changes, and Cwd() returns the last value stored
+	// - Usage Notes: Meaningful under go test -race
+
+	owner := NewFakeWebSocket()
+	s := NewSessionWithConn("s1", NewFakePTY(), owner)
+	s.SetCwd("/home")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 50 {
+			s.observeShell([]byte("\x1b]7;file://h/src\x07"))
+		}
+	}()
+	for range 50 {
+		s.updateCwd("/src")
+	}
+	<-done
+
+	if got := s.Cwd(); got != "/src" {
+		t.Errorf("Cwd() = %q, want /src", got)
+	}
+	if updates := messagesOfType(t, owner, MsgTypeCwdUpdate); len(updates) != 1 {
+		t.Errorf("got %d cwd_update messages, want 1 for the one change", len(updates))
+	}
+}
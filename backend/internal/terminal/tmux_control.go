@@ -0,0 +1,225 @@
+package terminal
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TmuxWatcher is implemented by detectors that can push change notifications
+// instead of waiting to be polled. TmuxMonitor refreshes immediately on each
+// notification and keeps polling as a fallback while the watch is down.
+type TmuxWatcher interface {
+	// Watch starts watching until ctx is cancelled. The returned channel
+	// receives a value (coalesced) whenever tmux reports a session, window or
+	// client change, and is closed when ctx is done.
+	Watch(ctx context.Context) <-chan struct{}
+
+	// Connected reports whether notifications are currently being received.
+	Connected() bool
+}
+
+// controlModeEvents are the control-mode notifications that can change
+// list-clients, list-sessions or list-panes output (besides %session-changed,
+// which is handled separately). Everything else (%output, %begin, ...) is
+// ignored. A pane's current command or path changing has no notification;
+// the monitor's safety refresh picks that up.
+var controlModeEvents = map[string]bool{
+	"%sessions-changed":       true,
+	"%session-renamed":        true,
+	"%session-window-changed": true,
+	"%client-session-changed": true,
+	"%client-detached":        true,
+	"%window-add":             true,
+	"%window-close":           true,
+	"%window-renamed":         true,
+	"%unlinked-window-add":    true,
+	"%unlinked-window-close":  true,
+	"%layout-change":          true,
+	"%window-pane-changed":    true,
+}
+
+// Control-mode reconnect backoff. While disconnected the monitor polls.
+const (
+	controlModeMinBackoff = time.Second
+	controlModeMaxBackoff = 10 * time.Second
+)
+
+// ControlModeTmuxDetector is a RealTmuxDetector that also keeps a `tmux -C`
+// control-mode client attached (read-only, no output, ignoring size) and turns
+// its notifications into change events. Listing still shells out; only the
+// "when to list" decision moves from a timer to tmux itself.
+type ControlModeTmuxDetector struct {
+	*RealTmuxDetector
+
+	connected atomic.Bool
+
+	// attachedTo is the session our own control client is attached to, so
+	// ListSessions can hide it from that session's attached count.
+	mu         sync.Mutex
+	attachedTo string
+
+	// start launches the control client. Replaced in tests.
+	start func(ctx context.Context) (io.ReadCloser, io.WriteCloser, func() error, error)
+}
+
+// NewControlModeTmuxDetector creates a control-mode detector with the given
+// command timeout for list operations.
+func NewControlModeTmuxDetector(timeout time.Duration) *ControlModeTmuxDetector {
+	d := &ControlModeTmuxDetector{RealTmuxDetector: NewRealTmuxDetector(timeout)}
+	d.start = startControlClient
+	return d
+}
+
+// startControlClient runs `tmux -C attach-session -r`. The environment is
+// inherited like the list commands', so it watches the server being listed
+// (control clients aren't refused inside tmux the way `tmux attach` is).
+// Control mode exits when stdin closes, so stdin is returned to the caller.
+func startControlClient(ctx context.Context) (io.ReadCloser, io.WriteCloser, func() error, error) {
+	cmd := exec.CommandContext(ctx, "tmux", "-C", "attach-session", "-r")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, nil, err
+	}
+	return stdout, stdin, cmd.Wait, nil
+}
+
+// Connected reports whether the control-mode client is attached.
+func (d *ControlModeTmuxDetector) Connected() bool {
+	return d.connected.Load()
+}
+
+// ListSessions lists sessions, excluding our control client from the
+// attached count of the session it sits on.
+func (d *ControlModeTmuxDetector) ListSessions() ([]TmuxSessionInfo, error) {
+	sessions, err := d.RealTmuxDetector.ListSessions()
+	if err != nil || !d.Connected() {
+		return sessions, err
+	}
+	d.mu.Lock()
+	own := d.attachedTo
+	d.mu.Unlock()
+	for i := range sessions {
+		if sessions[i].Name == own && sessions[i].Attached > 0 {
+			sessions[i].Attached--
+		}
+	}
+	return sessions, nil
+}
+
+// Watch keeps a control-mode client running until ctx is cancelled,
+// reconnecting with backoff (e.g. when no tmux server is running yet, or
+// the session it was attached to is killed).
+func (d *ControlModeTmuxDetector) Watch(ctx context.Context) <-chan struct{} {
+	events := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case events <- struct{}{}:
+		default:
+			// A refresh is already pending; it will pick this change up
+		}
+	}
+
+	go func() {
+		defer close(events)
+		backoff := controlModeMinBackoff
+		for {
+			if d.runControlClient(ctx, notify) {
+				backoff = controlModeMinBackoff
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, controlModeMaxBackoff)
+		}
+	}()
+	return events
+}
+
+// runControlClient runs one control-mode client until it exits. Returns true
+// if it got as far as attaching (so the reconnect backoff resets).
+func (d *ControlModeTmuxDetector) runControlClient(ctx context.Context, notify func()) bool {
+	stdout, stdin, wait, err := d.start(ctx)
+	if err != nil {
+		return false
+	}
+
+	// Don't stream pane output, resize windows or accept input
+	io.WriteString(stdin, "refresh-client -f no-output,ignore-size,read-only\n")
+
+	attached := d.readControlStream(stdout, notify)
+
+	// Closing stdin ends a control client that is still running
+	d.connected.Store(false)
+	stdin.Close()
+	stdout.Close()
+	wait()
+	if attached {
+		log.Printf("tmux control mode disconnected, falling back to polling")
+		notify()
+	}
+	return attached
+}
+
+// readControlStream consumes control-mode output until EOF or %exit,
+// calling notify for relevant notifications. Returns true if the client
+// attached (a %session-changed for itself was seen).
+func (d *ControlModeTmuxDetector) readControlStream(r io.Reader, notify func()) bool {
+	attached := false
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		event, args := parseControlLine(scanner.Text())
+		switch {
+		case event == "%exit":
+			return attached
+		case event == "%session-changed":
+			// Our own client's session: "%session-changed $id name"
+			if _, name, ok := strings.Cut(args, " "); ok {
+				d.mu.Lock()
+				d.attachedTo = name
+				d.mu.Unlock()
+			}
+			if !attached {
+				attached = true
+				d.connected.Store(true)
+				log.Printf("tmux control mode connected")
+			}
+			notify()
+		case controlModeEvents[event]:
+			notify()
+		}
+	}
+	return attached
+}
+
+// parseControlLine splits a control-mode line into its notification name and
+// arguments. Lines that aren't notifications (command output) return "".
+func parseControlLine(line string) (event, args string) {
+	if !strings.HasPrefix(line, "%") {
+		return "", ""
+	}
+	event, args, _ = strings.Cut(line, " ")
+	return event, args
+}
+
+// Verify interface compliance at compile time.
+var (
+	_ TmuxDetector = (*ControlModeTmuxDetector)(nil)
+	_ TmuxWatcher  = (*ControlModeTmuxDetector)(nil)
+)
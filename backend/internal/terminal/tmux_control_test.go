@@ -0,0 +1,97 @@
+package terminal
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+// Test Doc:
+// - Why: Control mode replaces the 2s poll with tmux's own change notifications
+// - Contract: Relevant % notifications trigger notify; %output/command output are ignored;
+//   %session-changed marks the client connected and records its session; %exit ends the stream
+// - Usage Notes: The control client is replaced via the start hook — no tmux binary needed
+// - Quality Contribution: Catches parser regressions and connection-state bugs
+// - Worked Example: "%session-changed $1 work" → Connected, attachedTo="work"; "%window-add @3" → notify
+
+func TestParseControlLine(t *testing.T) {
+	tests := []struct {
+		line, event, args string
+	}{
+		{"%sessions-changed", "%sessions-changed", ""},
+		{"%session-changed $1 work", "%session-changed", "$1 work"},
+		{"%window-add @3", "%window-add", "@3"},
+		{"some command output", "", ""},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		event, args := parseControlLine(tt.line)
+		if event != tt.event || args != tt.args {
+			t.Errorf("parseControlLine(%q) = (%q, %q), want (%q, %q)", tt.line, event, args, tt.event, tt.args)
+		}
+	}
+}
+
+// nopWriteCloser captures what the detector writes to the control client's stdin.
+type nopWriteCloser struct{ bytes.Buffer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestControlMode_NotificationsAndState(t *testing.T) {
+	stream := strings.Join([]string{
+		"%begin 1700000000 1 0",
+		"%end 1700000000 1 0",
+		"%session-changed $1 work",
+		"%output %1 hello",
+		"%layout-change @1 abcd",
+		"%window-add @3",
+		"%sessions-changed",
+		"%client-session-changed /dev/pts/4 $2 debug",
+		"%exit",
+		"%window-add @9", // after exit: ignored
+	}, "\n")
+
+	stdin := &nopWriteCloser{}
+	d := NewControlModeTmuxDetector(0)
+	d.start = func(ctx context.Context) (io.ReadCloser, io.WriteCloser, func() error, error) {
+		return io.NopCloser(strings.NewReader(stream)), stdin, func() error { return nil }, nil
+	}
+
+	var notified int
+	attached := d.runControlClient(context.Background(), func() { notified++ })
+
+	if !attached {
+		t.Error("runControlClient() = false, want true after session-changed")
+	}
+	// session-changed, layout-change, window-add, sessions-changed, client-session-changed, plus one on disconnect
+	if notified != 6 {
+		t.Errorf("notified = %d, want 6", notified)
+	}
+	if d.attachedTo != "work" {
+		t.Errorf("attachedTo = %q, want %q", d.attachedTo, "work")
+	}
+	if d.Connected() {
+		t.Error("Connected() = true after the client exited")
+	}
+	if !strings.Contains(stdin.String(), "refresh-client -f no-output,ignore-size,read-only") {
+		t.Errorf("stdin = %q, want refresh-client flags", stdin.String())
+	}
+}
+
+func TestControlMode_NeverAttached(t *testing.T) {
+	// e.g. "no sessions" when the tmux server isn't running
+	d := NewControlModeTmuxDetector(0)
+	d.start = func(ctx context.Context) (io.ReadCloser, io.WriteCloser, func() error, error) {
+		return io.NopCloser(strings.NewReader("")), &nopWriteCloser{}, func() error { return nil }, nil
+	}
+
+	var notified int
+	if d.runControlClient(context.Background(), func() { notified++ }) {
+		t.Error("runControlClient() = true, want false without session-changed")
+	}
+	if notified != 0 {
+		t.Errorf("notified = %d, want 0", notified)
+	}
+}
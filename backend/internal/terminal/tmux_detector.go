@@ -2,18 +2,24 @@ package terminal
 
 import (
 	"context"
+	"log"
 	"os/exec"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // TmuxSessionInfo holds metadata about a single tmux session, as returned
-// by `tmux list-sessions`.
+// by `tmux list-sessions`, with its windows and panes from `tmux list-panes -a`.
 type TmuxSessionInfo struct {
-	Name     string `json:"name"`
-	Windows  int    `json:"windows"`
-	Attached int    `json:"attached"`
+	Name       string       `json:"name"`
+	Windows    int          `json:"windows"`
+	Attached   int          `json:"attached"`
+	WindowList []TmuxWindow `json:"windowList,omitempty"` // Windows and their panes, by index
+	Host       string       `json:"host,omitempty"`       // SSH host the session lives on (empty = local)
+	Socket     string       `json:"socket,omitempty"`     // tmux server socket (-L name or -S path; empty = default)
 }
 
 // TmuxDetector detects tmux sessions and clients on the system.
@@ -24,7 +30,8 @@ type TmuxDetector interface {
 	// tmux clients. Returns an empty map when no clients are connected.
 	ListClients() (map[string]string, error)
 
-	// ListSessions returns metadata for all tmux sessions on the system.
+	// ListSessions returns metadata for all tmux sessions on the system,
+	// including windows and panes where available.
 	// Returns an empty slice (not error) when no tmux server is running.
 	ListSessions() ([]TmuxSessionInfo, error)
 
@@ -37,6 +44,15 @@ type TmuxDetector interface {
 type RealTmuxDetector struct {
 	// Timeout for each tmux command invocation.
 	Timeout time.Duration
+
+	// Host is an SSH destination to run tmux on (empty = local). Commands
+	// then go through SSHCommand, e.g. ["ssh", "-o", "BatchMode=yes"].
+	Host       string
+	SSHCommand []string
+
+	// Socket selects a tmux server other than the default: a name is passed
+	// as `-L name`, a path as `-S path`.
+	Socket string
 }
 
 // NewRealTmuxDetector creates a detector with the given command timeout.
@@ -44,19 +60,61 @@ func NewRealTmuxDetector(timeout time.Duration) *RealTmuxDetector {
 	return &RealTmuxDetector{Timeout: timeout}
 }
 
-// IsAvailable returns true if tmux is found on PATH.
+// NewRemoteTmuxDetector creates a detector that runs tmux on host over SSH.
+func NewRemoteTmuxDetector(host string, sshCommand []string, timeout time.Duration) *RealTmuxDetector {
+	return &RealTmuxDetector{Timeout: timeout, Host: host, SSHCommand: sshCommand}
+}
+
+// NewSocketTmuxDetector creates a detector for the local tmux server on socket.
+func NewSocketTmuxDetector(socket string, timeout time.Duration) *RealTmuxDetector {
+	return &RealTmuxDetector{Timeout: timeout, Socket: socket}
+}
+
+// TmuxSocketArgs returns the tmux flags selecting socket: `-S path` when it
+// contains a "/", `-L name` otherwise, and none for the default server.
+func TmuxSocketArgs(socket string) []string {
+	switch {
+	case socket == "":
+		return nil
+	case strings.Contains(socket, "/"):
+		return []string{"-S", socket}
+	default:
+		return []string{"-L", socket}
+	}
+}
+
+// IsAvailable returns true if tmux (or, for a remote host, ssh) is found on PATH.
 func (d *RealTmuxDetector) IsAvailable() bool {
-	_, err := exec.LookPath("tmux")
+	name, _ := d.commandLine()
+	_, err := exec.LookPath(name)
 	return err == nil
 }
 
+// command builds a tmux command, run over SSH when Host is set.
+func (d *RealTmuxDetector) command(ctx context.Context, args ...string) *exec.Cmd {
+	name, args := d.commandLine(args...)
+	return exec.CommandContext(ctx, name, args...)
+}
+
+// commandLine returns the program and arguments that run tmux with args on
+// Socket's server, locally or on Host through SSHCommand.
+func (d *RealTmuxDetector) commandLine(args ...string) (string, []string) {
+	if d.Socket != "" {
+		args = append(TmuxSocketArgs(d.Socket), args...)
+	}
+	if d.Host == "" {
+		return "tmux", args
+	}
+	return SSHTmuxCommand(d.SSHCommand, d.Host, false, args...)
+}
+
 // ListClients runs `tmux list-clients` and parses the output into a
 // ttyPath → sessionName map.
 func (d *RealTmuxDetector) ListClients() (map[string]string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), d.Timeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "tmux", "list-clients", "-F", "#{client_tty}\t#{session_name}")
+	cmd := d.command(ctx, "list-clients", "-F", "#{client_tty}\t#{session_name}")
 	output, err := cmd.Output()
 	if err != nil {
 		// tmux returns exit code 1 when no server is running — treat as empty
@@ -69,13 +127,15 @@ func (d *RealTmuxDetector) ListClients() (map[string]string, error) {
 	return parseTmuxClients(string(output)), nil
 }
 
-// ListSessions runs `tmux list-sessions` and returns metadata for all sessions.
-// Returns an empty slice (not error) when the tmux server is not running.
+// ListSessions runs `tmux list-sessions` and `tmux list-panes -a` and returns
+// metadata for all sessions. Returns an empty slice (not error) when the tmux
+// server is not running. If listing panes fails, sessions are returned
+// without their window list.
 func (d *RealTmuxDetector) ListSessions() ([]TmuxSessionInfo, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), d.Timeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "tmux", "list-sessions", "-F", "#{session_name}\t#{session_windows}\t#{session_attached}")
+	cmd := d.command(ctx, "list-sessions", "-F", "#{session_name}\t#{session_windows}\t#{session_attached}")
 	output, err := cmd.Output()
 	if err != nil {
 		// tmux returns exit code 1 when no server is running — treat as empty
@@ -85,7 +145,32 @@ func (d *RealTmuxDetector) ListSessions() ([]TmuxSessionInfo, error) {
 		return nil, err
 	}
 
-	return parseTmuxSessions(string(output)), nil
+	sessions := parseTmuxSessions(string(output))
+	if len(sessions) == 0 {
+		return sessions, nil
+	}
+
+	windows, err := d.listWindows()
+	if err != nil {
+		log.Printf("tmux list-panes error: %v", err)
+		return sessions, nil
+	}
+	for i := range sessions {
+		sessions[i].WindowList = windows[sessions[i].Name]
+	}
+	return sessions, nil
+}
+
+// listWindows runs `tmux list-panes -a` and groups panes by session and window.
+func (d *RealTmuxDetector) listWindows() (map[string][]TmuxWindow, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.Timeout)
+	defer cancel()
+
+	output, err := d.command(ctx, "list-panes", "-a", "-F", listPanesFormat).Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseTmuxPanes(string(output)), nil
 }
 
 // parseTmuxClients parses `tmux list-clients -F '#{client_tty}\t#{session_name}'`
@@ -135,12 +220,21 @@ func parseTmuxSessions(output string) []TmuxSessionInfo {
 	return result
 }
 
-// FakeTmuxDetector is a test double for TmuxDetector.
+// FakeTmuxDetector is a test double for TmuxDetector. It also implements
+// TmuxWatcher: Notify pushes a change event while SetConnected(true) is set.
 type FakeTmuxDetector struct {
+	mu        sync.Mutex // protects clients and sessions
 	clients   map[string]string
 	sessions  []TmuxSessionInfo
 	available bool
 	err       error
+
+	connected atomic.Bool
+	watchMu   sync.Mutex
+	events    chan struct{}
+
+	panes   map[string]*FakePTY // pane ID → PTY returned by OpenPane
+	history map[string]string   // session name → history returned by CaptureHistory
 }
 
 // NewFakeTmuxDetector creates an available fake with no clients.
@@ -158,6 +252,8 @@ func (f *FakeTmuxDetector) IsAvailable() bool {
 
 // ListClients returns the configured client map or error.
 func (f *FakeTmuxDetector) ListClients() (map[string]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	if f.err != nil {
 		return nil, f.err
 	}
@@ -171,11 +267,15 @@ func (f *FakeTmuxDetector) ListClients() (map[string]string, error) {
 
 // AddClient simulates a tmux client connecting on the given TTY path.
 func (f *FakeTmuxDetector) AddClient(ttyPath, sessionName string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	f.clients[ttyPath] = sessionName
 }
 
 // RemoveClient simulates a tmux client disconnecting.
 func (f *FakeTmuxDetector) RemoveClient(ttyPath string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	delete(f.clients, ttyPath)
 }
 
@@ -184,14 +284,18 @@ func (f *FakeTmuxDetector) SetUnavailable() {
 	f.available = false
 }
 
-// SetError configures ListClients and ListSessions to return an error.
+// SetError configures listing and session management calls to return an error.
 func (f *FakeTmuxDetector) SetError(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	f.err = err
 }
 
 // ListSessions returns the configured sessions or error.
 // Returns a copy to prevent test mutation.
 func (f *FakeTmuxDetector) ListSessions() ([]TmuxSessionInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	if f.err != nil {
 		return nil, f.err
 	}
@@ -199,12 +303,16 @@ func (f *FakeTmuxDetector) ListSessions() ([]TmuxSessionInfo, error) {
 		return nil, nil
 	}
 	result := make([]TmuxSessionInfo, len(f.sessions))
-	copy(result, f.sessions)
+	for i, s := range f.sessions {
+		result[i] = s.clone()
+	}
 	return result, nil
 }
 
 // AddSession adds a tmux session to the fake.
 func (f *FakeTmuxDetector) AddSession(name string, windows, attached int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	f.sessions = append(f.sessions, TmuxSessionInfo{
 		Name:     name,
 		Windows:  windows,
@@ -214,16 +322,200 @@ func (f *FakeTmuxDetector) AddSession(name string, windows, attached int) {
 
 // RemoveSession removes a tmux session by name from the fake.
 func (f *FakeTmuxDetector) RemoveSession(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if i := f.sessionIndex(name); i >= 0 {
+		f.sessions = append(f.sessions[:i], f.sessions[i+1:]...)
+	}
+}
+
+// sessionIndex returns the index of the named session, or -1. Caller must hold mu.
+func (f *FakeTmuxDetector) sessionIndex(name string) int {
 	for i, s := range f.sessions {
 		if s.Name == name {
-			f.sessions = append(f.sessions[:i], f.sessions[i+1:]...)
-			return
+			return i
 		}
 	}
+	return -1
+}
+
+// AddWindow appends a window to a session added with AddSession and sets
+// its window count to match. No-op if the session doesn't exist.
+func (f *FakeTmuxDetector) AddWindow(sessionName string, window TmuxWindow) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if i := f.sessionIndex(sessionName); i >= 0 {
+		f.sessions[i].WindowList = append(f.sessions[i].WindowList, window)
+		f.sessions[i].Windows = len(f.sessions[i].WindowList)
+	}
+}
+
+// NewSession adds a detached one-window session, like `tmux new-session -d`.
+// cwd and command are accepted but not simulated.
+func (f *FakeTmuxDetector) NewSession(name, cwd, command string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	if f.sessionIndex(name) >= 0 {
+		return ErrTmuxSessionExists
+	}
+	f.sessions = append(f.sessions, TmuxSessionInfo{Name: name, Windows: 1})
+	return nil
+}
+
+// RenameSession renames a session and moves its clients with it.
+func (f *FakeTmuxDetector) RenameSession(oldName, newName string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	i := f.sessionIndex(oldName)
+	if i < 0 {
+		return ErrTmuxSessionNotFound
+	}
+	if oldName != newName && f.sessionIndex(newName) >= 0 {
+		return ErrTmuxSessionExists
+	}
+	f.sessions[i].Name = newName
+	for tty, s := range f.clients {
+		if s == oldName {
+			f.clients[tty] = newName
+		}
+	}
+	return nil
+}
+
+// KillSession removes a session and detaches its clients.
+func (f *FakeTmuxDetector) KillSession(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	i := f.sessionIndex(name)
+	if i < 0 {
+		return ErrTmuxSessionNotFound
+	}
+	f.sessions = append(f.sessions[:i], f.sessions[i+1:]...)
+	for tty, s := range f.clients {
+		if s == name {
+			delete(f.clients, tty)
+		}
+	}
+	return nil
+}
+
+// OpenPane returns a FakePTY for a pane added with AddWindow. The PTY is
+// kept so tests can inspect it with PanePTY.
+func (f *FakeTmuxDetector) OpenPane(sessionName, paneID string) (PTY, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	i := f.sessionIndex(sessionName)
+	if i < 0 {
+		return nil, ErrTmuxSessionNotFound
+	}
+	for _, w := range f.sessions[i].WindowList {
+		for _, pane := range w.Panes {
+			if pane.ID == paneID {
+				if f.panes == nil {
+					f.panes = make(map[string]*FakePTY)
+				}
+				pty := NewFakePTY()
+				f.panes[paneID] = pty
+				return pty, nil
+			}
+		}
+	}
+	return nil, ErrTmuxPaneNotFound
+}
+
+// SetHistory sets the pane history CaptureHistory returns for a session
+// (newline-separated lines, oldest first).
+func (f *FakeTmuxDetector) SetHistory(sessionName, history string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.history == nil {
+		f.history = make(map[string]string)
+	}
+	f.history[sessionName] = history
+}
+
+// CaptureHistory returns the last lines lines set with SetHistory, formatted
+// like RealTmuxDetector's. The window index is ignored.
+func (f *FakeTmuxDetector) CaptureHistory(sessionName string, windowIndex, lines int) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	if f.sessionIndex(sessionName) < 0 {
+		return nil, ErrTmuxSessionNotFound
+	}
+	if lines <= 0 {
+		return nil, nil
+	}
+	return formatHistory(lastLines(f.history[sessionName], lines)), nil
+}
+
+// PanePTY returns the PTY most recently opened for a pane, or nil.
+func (f *FakeTmuxDetector) PanePTY(paneID string) *FakePTY {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.panes[paneID]
+}
+
+// Watch returns the channel Notify sends on. It is closed when ctx is done.
+func (f *FakeTmuxDetector) Watch(ctx context.Context) <-chan struct{} {
+	f.watchMu.Lock()
+	events := make(chan struct{}, 1)
+	f.events = events
+	f.watchMu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		f.watchMu.Lock()
+		if f.events == events {
+			f.events = nil
+		}
+		close(events)
+		f.watchMu.Unlock()
+	}()
+	return events
+}
+
+// Connected returns the state set by SetConnected.
+func (f *FakeTmuxDetector) Connected() bool {
+	return f.connected.Load()
+}
+
+// SetConnected simulates the watch (control-mode client) connecting or dropping.
+func (f *FakeTmuxDetector) SetConnected(connected bool) {
+	f.connected.Store(connected)
+}
+
+// Notify simulates tmux pushing a change notification. Coalesces like the
+// real detector: a pending event absorbs further notifications.
+func (f *FakeTmuxDetector) Notify() {
+	f.watchMu.Lock()
+	defer f.watchMu.Unlock()
+	if f.events == nil {
+		return
+	}
+	select {
+	case f.events <- struct{}{}:
+	default:
+	}
 }
 
 // Verify interface compliance at compile time.
 var (
 	_ TmuxDetector = (*RealTmuxDetector)(nil)
 	_ TmuxDetector = (*FakeTmuxDetector)(nil)
+	_ TmuxWatcher  = (*FakeTmuxDetector)(nil)
 )
@@ -3,7 +3,11 @@
 package terminal
 
 import (
+	"fmt"
+	"os"
 	"os/exec"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -77,3 +81,175 @@ func TestRealTmuxDetector_ListClients_WithSession(t *testing.T) {
 	// (requires a TTY), so we validate the parsing path works.
 	t.Logf("ListClients returned %d clients with session '%s' running", len(clients), sessionName)
 }
+
+func TestRealTmuxDetector_ManageSessions(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not installed, skipping integration test")
+	}
+
+	detector := NewRealTmuxDetector(5 * time.Second)
+	if err := detector.NewSession("trex-manage", t.TempDir(), "sleep 60"); err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+	defer exec.Command("tmux", "kill-session", "-t", "=trex-manage-renamed").Run()
+	defer exec.Command("tmux", "kill-session", "-t", "=trex-manage").Run()
+
+	if err := detector.NewSession("trex-manage", "", ""); err != ErrTmuxSessionExists {
+		t.Errorf("duplicate NewSession = %v, want ErrTmuxSessionExists", err)
+	}
+	// Exact targets: a prefix must not match
+	if err := detector.KillSession("trex-man"); err != ErrTmuxSessionNotFound {
+		t.Errorf("KillSession(prefix) = %v, want ErrTmuxSessionNotFound", err)
+	}
+	if err := detector.RenameSession("trex-manage", "trex-manage-renamed"); err != nil {
+		t.Fatalf("RenameSession error: %v", err)
+	}
+	if err := detector.KillSession("trex-manage-renamed"); err != nil {
+		t.Fatalf("KillSession error: %v", err)
+	}
+}
+
+func TestRealTmuxDetector_ListSessions_Panes(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not installed, skipping integration test")
+	}
+
+	detector := NewRealTmuxDetector(5 * time.Second)
+	dir := t.TempDir()
+	if err := detector.NewSession("trex-panes", dir, "sleep 60"); err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+	defer detector.KillSession("trex-panes")
+
+	sessions, err := detector.ListSessions()
+	if err != nil {
+		t.Fatalf("ListSessions error: %v", err)
+	}
+	for _, s := range sessions {
+		if s.Name != "trex-panes" {
+			continue
+		}
+		if len(s.WindowList) != 1 || len(s.WindowList[0].Panes) != 1 {
+			t.Fatalf("windows = %+v, want 1 window with 1 pane", s.WindowList)
+		}
+		pane := s.WindowList[0].Panes[0]
+		if pane.Cwd != dir || pane.Pid <= 0 || pane.Width <= 0 || pane.ID == "" {
+			t.Errorf("pane = %+v, want cwd %s and a pid, size and ID", pane, dir)
+		}
+		return
+	}
+	t.Fatal("trex-panes not found in ListSessions")
+}
+
+func TestRealTmuxDetector_OpenPane(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not installed, skipping integration test")
+	}
+
+	detector := NewRealTmuxDetector(5 * time.Second)
+	if err := detector.NewSession("trex-pane", "", "cat"); err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+	defer detector.KillSession("trex-pane")
+
+	sessions, _ := detector.ListSessions()
+	var paneID string
+	for _, s := range sessions {
+		if s.Name == "trex-pane" && len(s.WindowList) > 0 {
+			paneID = s.WindowList[0].Panes[0].ID
+		}
+	}
+	if paneID == "" {
+		t.Fatal("trex-pane has no panes")
+	}
+
+	pty, err := detector.OpenPane("trex-pane", paneID)
+	if err != nil {
+		t.Fatalf("OpenPane error: %v", err)
+	}
+	pty.Write([]byte("hello-pane\r"))
+
+	var seen strings.Builder
+	buf := make([]byte, 4096)
+	deadline := time.Now().Add(3 * time.Second)
+	for !strings.Contains(seen.String(), "hello-pane") && time.Now().Before(deadline) {
+		n, err := pty.Read(buf)
+		seen.Write(buf[:n])
+		if err != nil {
+			break
+		}
+	}
+	if !strings.Contains(seen.String(), "hello-pane") {
+		t.Errorf("output = %q, want the echoed input", seen.String())
+	}
+
+	pty.Close()
+	time.Sleep(100 * time.Millisecond)
+	if _, ok := detector.findPane(paneID); !ok {
+		t.Error("closing the pane PTY killed the pane")
+	}
+	if _, err := detector.OpenPane("trex-pane", "%999999"); err != ErrTmuxPaneNotFound {
+		t.Errorf("OpenPane(unknown) = %v, want ErrTmuxPaneNotFound", err)
+	}
+}
+
+func TestRealTmuxDetector_Socket(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not installed, skipping integration test")
+	}
+
+	socket := fmt.Sprintf("trex-test-%d", os.Getpid())
+	detector := NewSocketTmuxDetector(socket, 5*time.Second)
+	if err := detector.NewSession("agents", "", "sleep 60"); err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+	defer exec.Command("tmux", "-L", socket, "kill-server").Run()
+
+	multi := NewMultiHostTmuxDetector(NewRealTmuxDetector(5 * time.Second))
+	multi.AddSocket(socket, detector)
+	sessions, err := multi.ListSessions()
+	if err != nil {
+		t.Fatalf("ListSessions error: %v", err)
+	}
+	for _, s := range sessions {
+		if s.Socket == socket {
+			if s.Name != "agents" || len(s.WindowList) != 1 {
+				t.Errorf("socket session = %+v, want agents with one window", s)
+			}
+			return
+		}
+	}
+	t.Fatalf("no session tagged with socket %s in %+v", socket, sessions)
+}
+
+func TestRealTmuxDetector_CaptureHistory(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not installed, skipping integration test")
+	}
+
+	detector := NewRealTmuxDetector(5 * time.Second)
+	if err := detector.NewSession("trex-history", "", "seq 1 200; sleep 60"); err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+	defer detector.KillSession("trex-history")
+	time.Sleep(300 * time.Millisecond)
+
+	history, err := detector.CaptureHistory("trex-history", 0, 50)
+	if err != nil {
+		t.Fatalf("CaptureHistory error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(history), "\x1b[0m\r\n"), "\r\n")
+	if len(lines) != 50 {
+		t.Fatalf("history = %d lines, want 50", len(lines))
+	}
+	// Lines are consecutive numbers ending just above the visible screen
+	first, _ := strconv.Atoi(lines[0])
+	last, _ := strconv.Atoi(lines[49])
+	if first <= 0 || last != first+49 || last >= 200 {
+		t.Errorf("history runs %q..%q, want 50 consecutive lines above the screen", lines[0], lines[49])
+	}
+
+	if _, err := detector.CaptureHistory("trex-history-missing", 0, 50); err != ErrTmuxSessionNotFound {
+		t.Errorf("CaptureHistory(missing) = %v, want ErrTmuxSessionNotFound", err)
+	}
+}
@@ -0,0 +1,68 @@
+package terminal
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+)
+
+// TmuxHistoryCapturer is implemented by detectors that can read a pane's
+// history, so attaching can show what scrolled off before trex connected.
+type TmuxHistoryCapturer interface {
+	// CaptureHistory returns up to lines lines of history (the lines above
+	// the visible screen) of the active pane in a session's window, with
+	// colours, ready to write to a terminal. windowIndex 0 means the
+	// session's current window. Returns nil when there is no history.
+	CaptureHistory(sessionName string, windowIndex, lines int) ([]byte, error)
+}
+
+// CaptureHistory runs `tmux capture-pane -p -e -S -<lines> -E -1` on the
+// active pane of the target window.
+func (d *RealTmuxDetector) CaptureHistory(sessionName string, windowIndex, lines int) ([]byte, error) {
+	if lines <= 0 {
+		return nil, nil
+	}
+	target := exactTarget(sessionName) + ":"
+	if windowIndex > 0 {
+		target += strconv.Itoa(windowIndex)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.Timeout)
+	defer cancel()
+
+	output, err := d.command(ctx, "capture-pane", "-p", "-e", "-S", "-"+strconv.Itoa(lines), "-E", "-1", "-t", target).CombinedOutput()
+	if err != nil {
+		return nil, tmuxCommandError("capture-pane", string(output), err)
+	}
+	return formatHistory(string(output)), nil
+}
+
+// formatHistory turns capture-pane output into terminal output: CRLF line
+// endings, attributes reset at the end so colours don't leak into the live
+// screen. Returns nil when there are no lines.
+func formatHistory(output string) []byte {
+	output = strings.TrimRight(output, "\n")
+	if output == "" {
+		return nil
+	}
+	var b bytes.Buffer
+	b.WriteString(strings.ReplaceAll(output, "\n", "\r\n"))
+	b.WriteString("\x1b[0m\r\n")
+	return b.Bytes()
+}
+
+// lastLines returns at most n trailing lines of s.
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Verify interface compliance at compile time.
+var (
+	_ TmuxHistoryCapturer = (*RealTmuxDetector)(nil)
+	_ TmuxHistoryCapturer = (*FakeTmuxDetector)(nil)
+)
@@ -0,0 +1,54 @@
+package terminal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test Doc:
+// - Why: Attaching to a tmux session shows what the pane printed before, not just the current screen
+// - Contract: CaptureHistory runs capture-pane -p -e -S -<lines> -E -1 on the target window's active pane and returns CRLF output ending in an attribute reset; empty history and lines <= 0 return nil; FakeTmuxDetector keeps the last lines set with SetHistory
+// - Usage Notes: A fake tmux script on PATH (see writeFakeSSH) logs arguments and prints canned capture output
+// - Quality Contribution: Catches targets that could hit the wrong session (prefix match) and colours leaking into the live screen
+// - Worked Example: ("work", 2, 500) → capture-pane ... -S -500 -E -1 -t =work:2 → "make: ok\r\n\x1b[31mFAIL\x1b[0m\x1b[0m\r\n"
+func TestCaptureHistory(t *testing.T) {
+	if got := formatHistory("\n"); got != nil {
+		t.Errorf("formatHistory(empty) = %q, want nil", got)
+	}
+	if got := string(formatHistory("a\nb\n")); got != "a\r\nb\x1b[0m\r\n" {
+		t.Errorf("formatHistory = %q", got)
+	}
+
+	dir := writeFakeSSH(t)
+	d := NewRealTmuxDetector(5 * time.Second)
+	history, err := d.CaptureHistory("work", 2, 500)
+	if err != nil {
+		t.Fatalf("CaptureHistory error: %v", err)
+	}
+	if want := "make: ok\r\n\x1b[31mFAIL\x1b[0m\x1b[0m\r\n"; string(history) != want {
+		t.Errorf("CaptureHistory = %q, want %q", history, want)
+	}
+	logged, _ := os.ReadFile(filepath.Join(dir, "tmux.log"))
+	want := []string{"capture-pane", "-p", "-e", "-S", "-500", "-E", "-1", "-t", "=work:2"}
+	if got := strings.Split(strings.TrimSpace(string(logged)), "\n"); !slices.Equal(got, want) {
+		t.Errorf("tmux args = %q, want %q", got, want)
+	}
+	if history, err := d.CaptureHistory("work", 0, 0); history != nil || err != nil {
+		t.Errorf("CaptureHistory(lines=0) = %q, %v; want nil", history, err)
+	}
+
+	f := NewFakeTmuxDetector()
+	if _, err := f.CaptureHistory("work", 0, 10); !errors.Is(err, ErrTmuxSessionNotFound) {
+		t.Errorf("fake CaptureHistory(missing) = %v, want ErrTmuxSessionNotFound", err)
+	}
+	f.AddSession("work", 1, 0)
+	f.SetHistory("work", "one\ntwo\nthree\n")
+	if history, _ := f.CaptureHistory("work", 0, 2); string(history) != "two\r\nthree\x1b[0m\r\n" {
+		t.Errorf("fake CaptureHistory = %q, want the last 2 lines", history)
+	}
+}
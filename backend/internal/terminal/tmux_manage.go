@@ -0,0 +1,97 @@
+package terminal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Errors returned by TmuxSessionManager and TmuxPaneOpener implementations.
+var (
+	ErrTmuxSessionExists   = errors.New("tmux session already exists")
+	ErrTmuxSessionNotFound = errors.New("tmux session not found")
+	ErrTmuxPaneNotFound    = errors.New("tmux pane not found")
+)
+
+// TmuxSessionManager is implemented by detectors that can also change tmux
+// state. Names are used as exact targets, never as prefixes or patterns.
+type TmuxSessionManager interface {
+	// NewSession creates a detached session. cwd and command are optional;
+	// command runs through tmux's default shell, like `tmux new-session cmd`.
+	NewSession(name, cwd, command string) error
+
+	// RenameSession renames an existing session.
+	RenameSession(oldName, newName string) error
+
+	// KillSession kills a session and every process in its panes.
+	KillSession(name string) error
+}
+
+// NewSession runs `tmux new-session -d -s name [-c cwd] [-- command]`. The
+// "--" stops a command starting with "-" being read as a flag.
+func (d *RealTmuxDetector) NewSession(name, cwd, command string) error {
+	args := []string{"new-session", "-d", "-s", name}
+	if cwd != "" {
+		args = append(args, "-c", cwd)
+	}
+	if command != "" {
+		args = append(args, "--", command)
+	}
+	return d.run(args...)
+}
+
+// RenameSession runs `tmux rename-session -t =old -- new`.
+func (d *RealTmuxDetector) RenameSession(oldName, newName string) error {
+	return d.run("rename-session", "-t", exactTarget(oldName), "--", newName)
+}
+
+// KillSession runs `tmux kill-session -t =name`.
+func (d *RealTmuxDetector) KillSession(name string) error {
+	return d.run("kill-session", "-t", exactTarget(name))
+}
+
+// run executes a tmux command that changes state. The environment is
+// inherited like ListSessions', so the change lands on the server being
+// listed (new-session -d doesn't refuse to run inside tmux).
+func (d *RealTmuxDetector) run(args ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), d.Timeout)
+	defer cancel()
+
+	output, err := d.command(ctx, args...).CombinedOutput()
+	if err != nil {
+		return tmuxCommandError(args[0], string(output), err)
+	}
+	return nil
+}
+
+// exactTarget prefixes a session name with "=" so tmux matches it exactly
+// instead of falling back to prefix or fnmatch matching (which could make
+// `kill-session -t dev` kill "dev-old").
+func exactTarget(name string) string {
+	return "=" + name
+}
+
+// tmuxCommandError maps tmux's stderr to the manager's sentinel errors.
+func tmuxCommandError(op, output string, err error) error {
+	msg := strings.TrimSpace(output)
+	switch {
+	case strings.Contains(msg, "duplicate session"):
+		return ErrTmuxSessionExists
+	case strings.Contains(msg, "can't find session"),
+		strings.Contains(msg, "no server running"),
+		strings.Contains(msg, "error connecting to"):
+		return ErrTmuxSessionNotFound
+	case msg != "":
+		return fmt.Errorf("tmux %s: %s", op, msg)
+	default:
+		return fmt.Errorf("tmux %s: %w", op, err)
+	}
+}
+
+// Verify interface compliance at compile time.
+var (
+	_ TmuxSessionManager = (*RealTmuxDetector)(nil)
+	_ TmuxSessionManager = (*ControlModeTmuxDetector)(nil)
+	_ TmuxSessionManager = (*FakeTmuxDetector)(nil)
+)
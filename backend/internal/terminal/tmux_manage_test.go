@@ -0,0 +1,120 @@
+package terminal
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test Doc:
+// - Why: REST and WebSocket callers map manager errors to 404/409, so tmux's stderr must map to the sentinels
+// - Contract: "duplicate session" → ErrTmuxSessionExists; "can't find session"/no server → ErrTmuxSessionNotFound; other output is wrapped
+// - Usage Notes: tmux writes these messages to stderr; RealTmuxDetector passes CombinedOutput
+// - Quality Contribution: Catches tmux wording changes that would surface as 500s
+// - Worked Example: "duplicate session: work" → ErrTmuxSessionExists
+func TestTmuxCommandError(t *testing.T) {
+	exitErr := &exec.ExitError{}
+	tests := []struct {
+		name   string
+		output string
+		want   error
+	}{
+		{"duplicate", "duplicate session: work\n", ErrTmuxSessionExists},
+		{"missing session", "can't find session: work\n", ErrTmuxSessionNotFound},
+		{"no server", "no server running on /tmp/tmux-1000/default\n", ErrTmuxSessionNotFound},
+		{"no socket", "error connecting to /tmp/tmux-1000/default (No such file or directory)\n", ErrTmuxSessionNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tmuxCommandError("kill-session", tt.output, exitErr); !errors.Is(err, tt.want) {
+				t.Errorf("tmuxCommandError(%q) = %v, want %v", tt.output, err, tt.want)
+			}
+		})
+	}
+
+	err := tmuxCommandError("new-session", "bad thing\n", exitErr)
+	if err == nil || err.Error() != "tmux new-session: bad thing" {
+		t.Errorf("other output = %v, want %q", err, "tmux new-session: bad thing")
+	}
+	if exactTarget("dev") != "=dev" {
+		t.Errorf("exactTarget(dev) = %q, want %q", exactTarget("dev"), "=dev")
+	}
+}
+
+// Test Doc:
+// - Why: Server tests drive session management through FakeTmuxDetector
+// - Contract: NewSession adds a 1-window session; duplicates, unknown targets and SetError fail like tmux; RenameSession moves clients to the new name; KillSession detaches them
+// - Usage Notes: cwd and command are accepted but not simulated
+// - Quality Contribution: Keeps the fake's semantics close to tmux
+// - Worked Example: NewSession("work") → RenameSession("work","play") → KillSession("play") → no sessions
+func TestFakeTmuxDetector_Manage(t *testing.T) {
+	f := NewFakeTmuxDetector()
+
+	if err := f.NewSession("work", "/tmp", "top"); err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+	if err := f.NewSession("work", "", ""); !errors.Is(err, ErrTmuxSessionExists) {
+		t.Errorf("duplicate NewSession = %v, want ErrTmuxSessionExists", err)
+	}
+	sessions, _ := f.ListSessions()
+	if len(sessions) != 1 || !sessions[0].equal(TmuxSessionInfo{Name: "work", Windows: 1}) {
+		t.Fatalf("sessions = %+v, want [{work 1 0}]", sessions)
+	}
+
+	f.AddClient("/dev/pts/3", "work")
+	if err := f.RenameSession("work", "play"); err != nil {
+		t.Fatalf("RenameSession error: %v", err)
+	}
+	if clients, _ := f.ListClients(); clients["/dev/pts/3"] != "play" {
+		t.Errorf("client session after rename = %q, want %q", clients["/dev/pts/3"], "play")
+	}
+	if err := f.RenameSession("work", "other"); !errors.Is(err, ErrTmuxSessionNotFound) {
+		t.Errorf("rename of old name = %v, want ErrTmuxSessionNotFound", err)
+	}
+
+	if err := f.KillSession("play"); err != nil {
+		t.Fatalf("KillSession error: %v", err)
+	}
+	if clients, _ := f.ListClients(); len(clients) != 0 {
+		t.Errorf("clients after kill = %v, want none", clients)
+	}
+	if err := f.KillSession("play"); !errors.Is(err, ErrTmuxSessionNotFound) {
+		t.Errorf("second KillSession = %v, want ErrTmuxSessionNotFound", err)
+	}
+
+	f.SetError(errTest)
+	if err := f.NewSession("x", "", ""); err != errTest {
+		t.Errorf("NewSession with error set = %v, want errTest", err)
+	}
+}
+
+// Test Doc:
+// - Why: tmux parses its own flags, so a command or new name starting with "-" could otherwise be read as one
+// - Contract: NewSession passes the command, and RenameSession the new name, after "--"
+// - Usage Notes: writeFakeSSH puts a fake tmux on PATH that logs its arguments to tmux.log
+// - Quality Contribution: Catches argument-order regressions that would reopen flag injection
+// - Worked Example: NewSession("work", "", "-x") → tmux new-session -d -s work -- -x
+func TestRealTmuxDetector_ManageArgs(t *testing.T) {
+	dir := writeFakeSSH(t)
+	d := NewRealTmuxDetector(5 * time.Second)
+
+	if err := d.NewSession("work", "/tmp", "-x"); err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+	if err := d.RenameSession("work", "-t=other"); err != nil {
+		t.Fatalf("RenameSession error: %v", err)
+	}
+
+	logged, err := os.ReadFile(filepath.Join(dir, "tmux.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "new-session -d -s work -c /tmp -- -x rename-session -t =work -- -t=other"
+	if got := strings.Join(strings.Fields(string(logged)), " "); got != want {
+		t.Errorf("tmux args = %q, want %q", got, want)
+	}
+}
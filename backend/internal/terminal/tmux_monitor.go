@@ -4,11 +4,14 @@ import (
 	"context"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // TmuxMonitor periodically polls tmux to detect which trex sessions are
 // attached to which tmux sessions and which tmux sessions exist on the system.
+// Detectors implementing TmuxWatcher (control mode) push changes instead,
+// with polling as the fallback.
 //
 // Two independent polling paths run on the same ticker:
 // - poll(): client attachment tracking (Plan 014) — calls onChange
@@ -32,7 +35,10 @@ type TmuxMonitor struct {
 
 	// intervalCh receives new polling intervals from UpdateInterval.
 	intervalCh chan time.Duration
-	wg         sync.WaitGroup
+	// refreshCh receives Refresh requests; the loop closes each channel when done.
+	refreshCh chan chan struct{}
+	started   atomic.Bool
+	wg        sync.WaitGroup
 }
 
 // NewTmuxMonitor creates a monitor. Call Start() to begin polling.
@@ -49,6 +55,7 @@ func NewTmuxMonitor(detector TmuxDetector, registry *SessionRegistry, interval t
 		ctx:               ctx,
 		cancel:            cancel,
 		intervalCh:        make(chan time.Duration, 1),
+		refreshCh:         make(chan chan struct{}),
 	}
 }
 
@@ -59,8 +66,15 @@ func (m *TmuxMonitor) Start() {
 		return
 	}
 
+	// Subscribe before the loop starts so no early notification is missed
+	var events <-chan struct{}
+	if watcher, ok := m.detector.(TmuxWatcher); ok {
+		events = watcher.Watch(m.ctx)
+	}
+
+	m.started.Store(true)
 	m.wg.Add(1)
-	go m.run()
+	go m.run(events)
 	log.Printf("tmux monitor started (interval: %s)", m.interval)
 }
 
@@ -80,13 +94,37 @@ func (m *TmuxMonitor) UpdateInterval(d time.Duration) {
 	}
 }
 
-// run is the polling loop.
-func (m *TmuxMonitor) run() {
+// Refresh runs an immediate refresh on the monitor goroutine and waits for
+// it, so a change trex just made (e.g. creating a tmux session) is broadcast
+// before the caller replies. No-op if the monitor isn't running.
+func (m *TmuxMonitor) Refresh() {
+	if !m.started.Load() {
+		return
+	}
+	done := make(chan struct{})
+	select {
+	case m.refreshCh <- done:
+	case <-m.ctx.Done():
+		return
+	}
+	select {
+	case <-done:
+	case <-m.ctx.Done():
+	}
+}
+
+// run is the polling loop. When the detector is a TmuxWatcher, its
+// notifications trigger an immediate refresh and the ticker only polls while
+// the watch is disconnected (plus a slow safety refresh while it is up).
+func (m *TmuxMonitor) run(events <-chan struct{}) {
 	defer m.wg.Done()
 
 	ticker := time.NewTicker(m.interval)
 	defer ticker.Stop()
 
+	watcher, _ := m.detector.(TmuxWatcher)
+	var lastRefresh time.Time
+
 	for {
 		select {
 		case <-m.ctx.Done():
@@ -97,24 +135,51 @@ func (m *TmuxMonitor) run() {
 			ticker.Reset(m.interval)
 			log.Printf("tmux monitor interval updated to %s", m.interval)
 
-		case <-ticker.C:
-			changes := m.poll()
-			if changes != nil && len(changes) > 0 && m.onChange != nil {
-				m.onChange(changes)
+		case done := <-m.refreshCh:
+			m.refresh()
+			lastRefresh = time.Now()
+			close(done)
+
+		case _, ok := <-events:
+			if !ok {
+				events = nil // Watch ended; polling carries on alone
+				continue
 			}
+			m.refresh()
+			lastRefresh = time.Now()
 
-			m.pollSessions()
+		case <-ticker.C:
+			if watcher != nil && watcher.Connected() && time.Since(lastRefresh) < watchSafetyInterval {
+				continue
+			}
+			m.refresh()
+			lastRefresh = time.Now()
 		}
 	}
 }
 
+// watchSafetyInterval is how often the monitor still polls while a
+// TmuxWatcher is connected, in case a notification was missed.
+const watchSafetyInterval = 30 * time.Second
+
+// refresh runs both polling paths once and fires the callbacks on change.
+func (m *TmuxMonitor) refresh() {
+	changes := m.poll()
+	if changes != nil && len(changes) > 0 && m.onChange != nil {
+		m.onChange(changes)
+	}
+
+	m.pollSessions()
+}
+
 // poll executes one polling cycle: snapshot → exec → apply. Returns changed sessions.
 func (m *TmuxMonitor) poll() map[string]string {
 	// 1. Snapshot: gather session TTY paths under read lock
 	sessions := m.registry.List()
 	sessionByTty := make(map[string]*Session, len(sessions))
 	for _, s := range sessions {
-		if s.TtyPath != "" {
+		// Remote attaches run ssh on the TTY; local clients never match them
+		if s.TtyPath != "" && s.TmuxHost == "" {
 			sessionByTty[s.TtyPath] = s
 		}
 	}
@@ -174,7 +239,7 @@ func (m *TmuxMonitor) pollSessions() {
 
 	if m.onSessionsChanged != nil {
 		// Pass a copy to prevent callback from mutating cached state
-		m.onSessionsChanged(append([]TmuxSessionInfo(nil), sessions...))
+		m.onSessionsChanged(cloneSessions(sessions))
 	}
 }
 
@@ -186,18 +251,31 @@ func (m *TmuxMonitor) GetLastSessions() []TmuxSessionInfo {
 	if len(m.lastSessions) == 0 {
 		return nil
 	}
-	result := make([]TmuxSessionInfo, len(m.lastSessions))
-	copy(result, m.lastSessions)
+	return cloneSessions(m.lastSessions)
+}
+
+// GetDetector returns the detector used by this monitor.
+// Used by the request handler to check tmux availability before attaching.
+func (m *TmuxMonitor) GetDetector() TmuxDetector {
+	return m.detector
+}
+
+// cloneSessions deep-copies a session list, including windows and panes.
+func cloneSessions(sessions []TmuxSessionInfo) []TmuxSessionInfo {
+	result := make([]TmuxSessionInfo, len(sessions))
+	for i, s := range sessions {
+		result[i] = s.clone()
+	}
 	return result
 }
 
-// sessionsEqual compares two TmuxSessionInfo slices for equality.
+// sessionsEqual compares two TmuxSessionInfo slices for equality, including windows and panes.
 func sessionsEqual(a, b []TmuxSessionInfo) bool {
 	if len(a) != len(b) {
 		return false
 	}
 	for i := range a {
-		if a[i] != b[i] {
+		if !a[i].equal(b[i]) {
 			return false
 		}
 	}
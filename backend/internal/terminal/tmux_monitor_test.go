@@ -334,3 +334,122 @@ func TestTmuxMonitor_SessionListInitial(t *testing.T) {
 		t.Fatalf("expected 2 sessions on initial discovery, got %d", len(received))
 	}
 }
+
+// Test Doc:
+// - Why: Control mode pushes tmux changes without waiting for the next poll
+// - Contract: TmuxWatcher notifications refresh immediately; while connected the ticker
+//   doesn't poll (beyond a 30s safety refresh); while disconnected polling continues
+// - Usage Notes: FakeTmuxDetector implements TmuxWatcher via SetConnected/Notify
+// - Quality Contribution: Guards the push path and the polling fallback
+// - Worked Example: interval 1h, Notify() after AddSession("work") → onSessionsChanged called at once
+
+func TestTmuxMonitor_WatchPushesImmediately(t *testing.T) {
+	registry := NewSessionRegistry()
+	detector := NewFakeTmuxDetector()
+	detector.SetConnected(true)
+
+	received := make(chan []TmuxSessionInfo, 4)
+	monitor := NewTmuxMonitor(detector, registry, time.Hour, nil, func(sessions []TmuxSessionInfo) {
+		received <- sessions
+	})
+	monitor.Start()
+	defer monitor.Stop()
+
+	detector.AddSession("work", 1, 0)
+	detector.Notify()
+
+	select {
+	case sessions := <-received:
+		if len(sessions) != 1 || sessions[0].Name != "work" {
+			t.Errorf("sessions = %v, want [work]", sessions)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("onSessionsChanged not called after Notify")
+	}
+}
+
+func TestTmuxMonitor_WatchConnectedSkipsPolling(t *testing.T) {
+	registry := NewSessionRegistry()
+	detector := NewFakeTmuxDetector()
+	detector.SetConnected(true)
+	detector.AddSession("work", 1, 0)
+
+	received := make(chan []TmuxSessionInfo, 4)
+	monitor := NewTmuxMonitor(detector, registry, 20*time.Millisecond, nil, func(sessions []TmuxSessionInfo) {
+		received <- sessions
+	})
+	monitor.Start()
+	defer monitor.Stop()
+
+	// First tick refreshes (nothing seen yet)
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Fatal("initial refresh did not happen")
+	}
+
+	// Further ticks are skipped while the watch is connected
+	detector.AddSession("debug", 1, 0)
+	select {
+	case sessions := <-received:
+		t.Fatalf("polled while watch connected: %v", sessions)
+	case <-time.After(150 * time.Millisecond):
+	}
+
+	detector.Notify()
+	select {
+	case sessions := <-received:
+		if len(sessions) != 2 {
+			t.Errorf("sessions = %v, want 2", sessions)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("onSessionsChanged not called after Notify")
+	}
+}
+
+func TestTmuxMonitor_WatchDisconnectedFallsBackToPolling(t *testing.T) {
+	registry := NewSessionRegistry()
+	detector := NewFakeTmuxDetector()
+	detector.AddSession("work", 1, 0)
+	// Not connected: control mode is down
+
+	received := make(chan []TmuxSessionInfo, 4)
+	monitor := NewTmuxMonitor(detector, registry, 20*time.Millisecond, nil, func(sessions []TmuxSessionInfo) {
+		received <- sessions
+	})
+	monitor.Start()
+	defer monitor.Stop()
+
+	select {
+	case sessions := <-received:
+		if len(sessions) != 1 {
+			t.Errorf("sessions = %v, want 1", sessions)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("polling fallback did not run")
+	}
+}
+
+// Test Doc:
+// - Why: Sessions created or killed from trex must show up before the request is answered
+// - Contract: Refresh runs a refresh on the monitor goroutine and returns once it is done; no-op when not started
+// - Usage Notes: Interval is 1h so only Refresh can trigger the callback
+// - Quality Contribution: Guards the synchronous hand-off used by tmux management
+// - Worked Example: AddSession("work") → Refresh() → GetLastSessions() = [work]
+func TestTmuxMonitor_Refresh(t *testing.T) {
+	registry := NewSessionRegistry()
+	detector := NewFakeTmuxDetector()
+	monitor := NewTmuxMonitor(detector, registry, time.Hour, nil, nil)
+
+	// Not started: returns immediately
+	monitor.Refresh()
+
+	monitor.Start()
+	defer monitor.Stop()
+
+	detector.AddSession("work", 1, 0)
+	monitor.Refresh()
+	if sessions := monitor.GetLastSessions(); len(sessions) != 1 || sessions[0].Name != "work" {
+		t.Errorf("sessions after Refresh = %v, want [work]", sessions)
+	}
+}
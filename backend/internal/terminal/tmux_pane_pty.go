@@ -0,0 +1,277 @@
+package terminal
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// TmuxPaneOpener is implemented by detectors that can stream a single pane
+// into its own trex terminal.
+type TmuxPaneOpener interface {
+	// OpenPane returns a PTY showing one pane of a session. Closing it
+	// detaches without touching the pane or its layout.
+	OpenPane(sessionName, paneID string) (PTY, error)
+}
+
+// paneGoneEvents are control-mode notifications after which the pane may
+// have been closed, so its existence is re-checked.
+var paneGoneEvents = map[string]bool{
+	"%layout-change":         true,
+	"%window-close":          true,
+	"%unlinked-window-close": true,
+}
+
+// paneKeysChunk bounds how many bytes go into one send-keys command.
+const paneKeysChunk = 128
+
+// TmuxPanePTY implements PTY for a single tmux pane through a control-mode
+// client attached to the pane's session: %output for the pane becomes Read
+// data and Write becomes `send-keys -H`. On start it draws the pane's current
+// screen from capture-pane. Resize is a no-op: the pane keeps the size its
+// tmux layout gives it, so output is formatted for that size.
+type TmuxPanePTY struct {
+	paneID string
+	pid    int
+
+	stdin   io.WriteCloser
+	stdout  io.ReadCloser
+	wait    func() error
+	writeMu sync.Mutex
+
+	out       *io.PipeReader
+	outW      *io.PipeWriter
+	closeOnce sync.Once
+
+	// paneExists re-checks the pane after layout changes. Replaced in tests.
+	paneExists func() bool
+}
+
+// OpenPane attaches a control-mode client to sessionName and streams paneID.
+func (d *RealTmuxDetector) OpenPane(sessionName, paneID string) (PTY, error) {
+	pid, ok := d.findPane(paneID)
+	if !ok {
+		return nil, ErrTmuxPaneNotFound
+	}
+
+	cmd := d.command(context.Background(), "-C", "attach-session", "-t", exactTarget(sessionName))
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	exists := func() bool {
+		_, ok := d.findPane(paneID)
+		return ok
+	}
+	return newTmuxPanePTY(paneID, pid, stdout, stdin, cmd.Wait, exists), nil
+}
+
+// findPane looks a pane up with `tmux list-panes -a` and returns its PID.
+// (display-message -t falls back to the current pane for unknown IDs, so it
+// can't be used as an existence check.)
+func (d *RealTmuxDetector) findPane(paneID string) (int, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.Timeout)
+	defer cancel()
+
+	output, err := d.command(ctx, "list-panes", "-a", "-F", "#{pane_id}\t#{pane_pid}").Output()
+	if err != nil {
+		return 0, false
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		id, pid, ok := strings.Cut(strings.TrimSpace(line), "\t")
+		if ok && id == paneID {
+			n, _ := strconv.Atoi(pid)
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// newTmuxPanePTY wires a started control-mode client to a pane PTY.
+func newTmuxPanePTY(paneID string, pid int, stdout io.ReadCloser, stdin io.WriteCloser, wait func() error, paneExists func() bool) *TmuxPanePTY {
+	out, outW := io.Pipe()
+	p := &TmuxPanePTY{
+		paneID:     paneID,
+		pid:        pid,
+		stdin:      stdin,
+		stdout:     stdout,
+		wait:       wait,
+		out:        out,
+		outW:       outW,
+		paneExists: paneExists,
+	}
+
+	// 1: don't let this client resize windows
+	// 2, 3: current screen and cursor, run back to back so no output lands between them
+	p.command("refresh-client -f ignore-size")
+	p.command(fmt.Sprintf("capture-pane -p -e -t %s ; display-message -p -t %s '#{cursor_x} #{cursor_y}'", paneID, paneID))
+
+	go p.readLoop()
+	return p
+}
+
+// command writes one control-mode command line.
+func (p *TmuxPanePTY) command(line string) error {
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+	_, err := io.WriteString(p.stdin, line+"\n")
+	return err
+}
+
+// readLoop turns the control-mode stream into terminal output until the
+// client exits or the pane goes away.
+func (p *TmuxPanePTY) readLoop() {
+	defer p.outW.Close()
+
+	var (
+		block   int // our command blocks seen so far (flags=1)
+		inBlock bool
+		lines   []string // current block's output
+		screen  []string // capture-pane output
+		ready   bool     // initial screen drawn; stream %output from here on
+	)
+
+	scanner := bufio.NewScanner(p.stdout)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if inBlock {
+			event, args := parseControlLine(line)
+			if event != "%end" && event != "%error" {
+				lines = append(lines, line)
+				continue
+			}
+			inBlock = false
+			if !strings.HasSuffix(args, " 1") {
+				continue // Not one of our commands
+			}
+			block++
+			switch {
+			case block == 2 && event == "%error":
+				return // capture-pane failed: the pane is gone
+			case block == 2:
+				screen = lines
+			case block == 3:
+				if _, err := p.outW.Write(paneScreen(screen, lines)); err != nil {
+					return
+				}
+				ready = true
+			}
+			continue
+		}
+
+		event, args := parseControlLine(line)
+		switch {
+		case event == "%begin":
+			inBlock = true
+			lines = nil
+		case event == "%exit":
+			return
+		case event == "%output":
+			id, data, _ := strings.Cut(args, " ")
+			if ready && id == p.paneID {
+				if _, err := p.outW.Write(decodeControlOutput(data)); err != nil {
+					return
+				}
+			}
+		case paneGoneEvents[event]:
+			if !p.paneExists() {
+				log.Printf("tmux pane %s closed", p.paneID)
+				return
+			}
+		}
+	}
+}
+
+// paneScreen renders capture-pane output and the "x y" cursor position as a
+// clear-screen redraw.
+func paneScreen(screen, cursor []string) []byte {
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+	b.WriteString(strings.Join(screen, "\r\n"))
+	if len(cursor) == 1 {
+		var x, y int
+		if _, err := fmt.Sscanf(cursor[0], "%d %d", &x, &y); err == nil {
+			fmt.Fprintf(&b, "\x1b[%d;%dH", y+1, x+1)
+		}
+	}
+	return []byte(b.String())
+}
+
+// decodeControlOutput undoes control mode's %output escaping, where
+// characters below space and backslash are sent as \ooo octal.
+func decodeControlOutput(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				out = append(out, byte(n))
+				i += 3
+				continue
+			}
+		}
+		out = append(out, s[i])
+	}
+	return out
+}
+
+// Read returns the pane's output.
+func (p *TmuxPanePTY) Read(b []byte) (int, error) {
+	return p.out.Read(b)
+}
+
+// Write sends input to the pane as raw bytes with `send-keys -H`.
+func (p *TmuxPanePTY) Write(b []byte) (int, error) {
+	for start := 0; start < len(b); start += paneKeysChunk {
+		chunk := b[start:min(start+paneKeysChunk, len(b))]
+		hex := make([]string, len(chunk))
+		for i, c := range chunk {
+			hex[i] = fmt.Sprintf("%02x", c)
+		}
+		if err := p.command("send-keys -t " + p.paneID + " -H " + strings.Join(hex, " ")); err != nil {
+			return start, err
+		}
+	}
+	return len(b), nil
+}
+
+// Resize is a no-op: resizing would change the pane's tmux layout.
+func (p *TmuxPanePTY) Resize(cols, rows uint16) error {
+	return nil
+}
+
+// Close detaches the control client. The pane and its process keep running.
+func (p *TmuxPanePTY) Close() error {
+	p.closeOnce.Do(func() {
+		p.stdin.Close()
+		p.stdout.Close()
+		p.out.Close()
+		go p.wait()
+	})
+	return nil
+}
+
+// GetPid returns the PID the pane was started with.
+func (p *TmuxPanePTY) GetPid() int {
+	return p.pid
+}
+
+// Verify interface compliance at compile time.
+var (
+	_ PTY            = (*TmuxPanePTY)(nil)
+	_ TmuxPaneOpener = (*RealTmuxDetector)(nil)
+	_ TmuxPaneOpener = (*FakeTmuxDetector)(nil)
+)
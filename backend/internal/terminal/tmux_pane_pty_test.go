@@ -0,0 +1,162 @@
+package terminal
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Test Doc:
+// - Why: A single tmux pane is streamed through control mode instead of a real PTY
+// - Contract: The pane's screen is drawn from capture-pane first; only that pane's %output follows (decoded); input becomes send-keys -H; the stream ends on %exit or when the pane is gone; Close never kills the pane
+// - Usage Notes: newTmuxPanePTY is fed a scripted control-mode stream; paneExists is stubbed
+// - Quality Contribution: Guards block counting, octal decoding and the pane-gone check
+// - Worked Example: capture "$ " + cursor "2 0" → "\x1b[H\x1b[2J$ \x1b[1;3H"; "%output %3 hi\015\012" → "hi\r\n"
+
+// syncBuffer is a WriteCloser safe for the PTY's writer and the test to share.
+type syncBuffer struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	closed bool
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	return nil
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestDecodeControlOutput(t *testing.T) {
+	tests := map[string]string{
+		`plain`:              "plain",
+		`a\015\012b`:         "a\r\nb",
+		`\033[1mbold\033[0m`: "\x1b[1mbold\x1b[0m",
+		`back\134slash`:      `back\slash`,
+		`trailing\01`:        `trailing\01`,
+		`not\9octal`:         `not\9octal`,
+	}
+	for in, want := range tests {
+		if got := string(decodeControlOutput(in)); got != want {
+			t.Errorf("decodeControlOutput(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestTmuxPanePTY_Stream(t *testing.T) {
+	stdoutR, stdoutW := io.Pipe()
+	stdin := &syncBuffer{}
+	exists := true
+	var existsMu sync.Mutex
+	p := newTmuxPanePTY("%3", 4242, stdoutR, stdin, func() error { return nil }, func() bool {
+		existsMu.Lock()
+		defer existsMu.Unlock()
+		return exists
+	})
+	defer p.Close()
+
+	output := make(chan string, 16)
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := p.Read(buf)
+			if n > 0 {
+				output <- string(buf[:n])
+			}
+			if err != nil {
+				close(output)
+				return
+			}
+		}
+	}()
+	next := func() string {
+		t.Helper()
+		select {
+		case s, ok := <-output:
+			if !ok {
+				t.Fatal("stream ended early")
+			}
+			return s
+		case <-time.After(time.Second):
+			t.Fatal("no output")
+		}
+		return ""
+	}
+
+	io.WriteString(stdoutW, strings.Join([]string{
+		"%begin 1 1 0", "%end 1 1 0", // attach (not ours)
+		"%session-changed $1 work",
+		"%begin 1 2 1", "%end 1 2 1", // refresh-client
+		"%output %3 before-capture",                 // covered by the capture: dropped
+		"%begin 1 3 1", "$ ls", "a b", "%end 1 3 1", // capture-pane
+		"%begin 1 4 1", "3 1", "%end 1 4 1", // cursor
+		"%output %4 other pane",
+		"%output %3 hi\\015\\012",
+		"",
+	}, "\n"))
+
+	if got, want := next(), "\x1b[H\x1b[2J$ ls\r\na b\x1b[2;4H"; got != want {
+		t.Errorf("screen = %q, want %q", got, want)
+	}
+	if got := next(); got != "hi\r\n" {
+		t.Errorf("output = %q, want %q", got, "hi\r\n")
+	}
+
+	if n, err := p.Write([]byte("ls\r")); err != nil || n != 3 {
+		t.Fatalf("Write = %d, %v", n, err)
+	}
+	if p.GetPid() != 4242 || p.Resize(100, 40) != nil {
+		t.Error("GetPid/Resize mismatch")
+	}
+
+	// A layout change with the pane still present keeps streaming
+	io.WriteString(stdoutW, "%layout-change @1 abcd\n%output %3 still\n")
+	if got := next(); got != "still" {
+		t.Errorf("output after layout change = %q, want %q", got, "still")
+	}
+
+	existsMu.Lock()
+	exists = false
+	existsMu.Unlock()
+	io.WriteString(stdoutW, "%layout-change @1 abcd\n%output %3 never\n")
+	select {
+	case s, ok := <-output:
+		if ok {
+			t.Errorf("got %q after the pane closed, want end of stream", s)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("stream did not end when the pane closed")
+	}
+
+	want := []string{
+		"refresh-client -f ignore-size",
+		"capture-pane -p -e -t %3 ; display-message -p -t %3 '#{cursor_x} #{cursor_y}'",
+		"send-keys -t %3 -H 6c 73 0d",
+	}
+	if got := strings.Split(strings.TrimSpace(stdin.String()), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("commands = %q, want %q", got, want)
+	}
+
+	p.Close()
+	stdin.mu.Lock()
+	closed := stdin.closed
+	stdin.mu.Unlock()
+	if !closed {
+		t.Error("Close did not close the control client's stdin")
+	}
+}
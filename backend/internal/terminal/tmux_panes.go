@@ -0,0 +1,109 @@
+package terminal
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// TmuxWindow describes one window of a tmux session.
+type TmuxWindow struct {
+	Index  int        `json:"index"`
+	Name   string     `json:"name"`
+	Active bool       `json:"active"`
+	Layout string     `json:"layout"` // tmux layout string, e.g. "b25d,80x24,0,0,1"
+	Panes  []TmuxPane `json:"panes"`
+}
+
+// TmuxPane describes one pane of a tmux window.
+type TmuxPane struct {
+	ID      string `json:"id"` // Server-unique pane ID, e.g. "%3" (usable as a -t target)
+	Index   int    `json:"index"`
+	Active  bool   `json:"active"`
+	Command string `json:"command"` // pane_current_command: foreground process name
+	Cwd     string `json:"cwd"`     // pane_current_path
+	Pid     int    `json:"pid"`     // PID of the process the pane was started with
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+}
+
+// listPanesFormat is the `tmux list-panes -a -F` format parsed by parseTmuxPanes.
+// pane_current_path comes last so a path containing a tab still parses.
+const listPanesFormat = "#{session_name}\t#{window_index}\t#{window_name}\t#{window_active}\t#{window_layout}\t" +
+	"#{pane_id}\t#{pane_index}\t#{pane_active}\t#{pane_current_command}\t#{pane_pid}\t#{pane_width}\t#{pane_height}\t#{pane_current_path}"
+
+// listPanesFields is the number of tab-separated fields in listPanesFormat.
+const listPanesFields = 13
+
+// parseTmuxPanes parses `tmux list-panes -a -F listPanesFormat` output into
+// session name → windows (in output order, which tmux sorts by index).
+// Malformed lines are skipped.
+func parseTmuxPanes(output string) map[string][]TmuxWindow {
+	result := make(map[string][]TmuxWindow)
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+		f := strings.SplitN(line, "\t", listPanesFields)
+		if len(f) != listPanesFields || f[0] == "" {
+			continue
+		}
+		windowIndex, err1 := strconv.Atoi(f[1])
+		paneIndex, err2 := strconv.Atoi(f[6])
+		pid, err3 := strconv.Atoi(f[9])
+		width, err4 := strconv.Atoi(f[10])
+		height, err5 := strconv.Atoi(f[11])
+		if err1 != nil || err2 != nil || err3 != nil || err4 != nil || err5 != nil {
+			continue
+		}
+
+		windows := result[f[0]]
+		if n := len(windows); n == 0 || windows[n-1].Index != windowIndex {
+			windows = append(windows, TmuxWindow{
+				Index:  windowIndex,
+				Name:   f[2],
+				Active: f[3] == "1",
+				Layout: f[4],
+			})
+		}
+		w := &windows[len(windows)-1]
+		w.Panes = append(w.Panes, TmuxPane{
+			ID:      f[5],
+			Index:   paneIndex,
+			Active:  f[7] == "1",
+			Command: f[8],
+			Pid:     pid,
+			Width:   width,
+			Height:  height,
+			Cwd:     f[12],
+		})
+		result[f[0]] = windows
+	}
+	return result
+}
+
+// equal reports whether two session infos match, including windows and panes.
+func (s TmuxSessionInfo) equal(o TmuxSessionInfo) bool {
+	return s.Name == o.Name && s.Host == o.Host && s.Socket == o.Socket && s.Windows == o.Windows && s.Attached == o.Attached &&
+		slices.EqualFunc(s.WindowList, o.WindowList, TmuxWindow.equal)
+}
+
+// equal reports whether two windows match, including panes.
+func (w TmuxWindow) equal(o TmuxWindow) bool {
+	return w.Index == o.Index && w.Name == o.Name && w.Active == o.Active &&
+		w.Layout == o.Layout && slices.Equal(w.Panes, o.Panes)
+}
+
+// clone returns a deep copy, so callers can't mutate shared window or pane slices.
+func (s TmuxSessionInfo) clone() TmuxSessionInfo {
+	if s.WindowList != nil {
+		windows := make([]TmuxWindow, len(s.WindowList))
+		for i, w := range s.WindowList {
+			w.Panes = slices.Clone(w.Panes)
+			windows[i] = w
+		}
+		s.WindowList = windows
+	}
+	return s
+}
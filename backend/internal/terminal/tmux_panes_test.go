@@ -0,0 +1,85 @@
+package terminal
+
+import (
+	"testing"
+	"time"
+)
+
+// Test Doc:
+// - Why: The UI offers window/pane attach targets and collectors map panes to processes from this parser
+// - Contract: Panes are grouped by session then window (in output order); malformed lines are skipped; a tab in the cwd survives
+// - Usage Notes: Input comes from `tmux list-panes -a -F listPanesFormat`
+// - Quality Contribution: Catches format/field-order drift between listPanesFormat and the parser
+// - Worked Example: two panes in work:0 and one in work:1 → work has windows [0 (2 panes), 1 (1 pane)]
+func TestParseTmuxPanes(t *testing.T) {
+	output := "work\t0\teditor\t1\tb25d,80x24,0,0{40x24,0,0,1,39x24,41,0,2}\t%1\t0\t1\tvim\t101\t40\t24\t/home/me/src\n" +
+		"work\t0\teditor\t1\tb25d,80x24,0,0{40x24,0,0,1,39x24,41,0,2}\t%2\t1\t0\tbash\t102\t39\t24\t/home/me\n" +
+		"work\t1\tlogs\t0\tc3e1,80x24,0,0,3\t%3\t0\t1\ttail\t103\t80\t24\t/var/log\twith tab\n" +
+		"debug\t2\tgdb\t1\tc3e1,80x24,0,0,4\t%4\t0\t1\tgdb\t104\t80\t24\t/tmp\n" +
+		"broken\tx\tname\t1\tlayout\t%5\t0\t1\tsh\t105\t80\t24\t/\n" +
+		"short\t0\tname\n"
+
+	got := parseTmuxPanes(output)
+	if len(got) != 2 {
+		t.Fatalf("sessions = %d, want 2 (work, debug): %+v", len(got), got)
+	}
+
+	work := got["work"]
+	if len(work) != 2 {
+		t.Fatalf("work windows = %d, want 2", len(work))
+	}
+	editor := work[0]
+	if editor.Index != 0 || editor.Name != "editor" || !editor.Active || editor.Layout != "b25d,80x24,0,0{40x24,0,0,1,39x24,41,0,2}" {
+		t.Errorf("work:0 = %+v", editor)
+	}
+	wantPane := TmuxPane{ID: "%1", Index: 0, Active: true, Command: "vim", Cwd: "/home/me/src", Pid: 101, Width: 40, Height: 24}
+	if len(editor.Panes) != 2 || editor.Panes[0] != wantPane {
+		t.Errorf("work:0 panes = %+v, want first %+v", editor.Panes, wantPane)
+	}
+	if editor.Panes[1].ID != "%2" || editor.Panes[1].Active {
+		t.Errorf("work:0.1 = %+v, want inactive %%2", editor.Panes[1])
+	}
+	if logs := work[1]; logs.Index != 1 || logs.Active || len(logs.Panes) != 1 || logs.Panes[0].Cwd != "/var/log\twith tab" {
+		t.Errorf("work:1 = %+v", logs)
+	}
+	if debug := got["debug"]; len(debug) != 1 || debug[0].Index != 2 || debug[0].Panes[0].Pid != 104 {
+		t.Errorf("debug = %+v", debug)
+	}
+}
+
+// Test Doc:
+// - Why: Pane-level changes (a new split, a different foreground command) must reach the tmux_sessions broadcast
+// - Contract: pollSessions treats window/pane differences as a change; returned lists are deep copies
+// - Usage Notes: FakeTmuxDetector.AddWindow sets the window list and count
+// - Quality Contribution: Guards sessionsEqual against ignoring nested window/pane data
+// - Worked Example: AddWindow(work, 1 pane) → callback; AddWindow(work, another) → callback again
+func TestTmuxMonitor_DetectsWindowChanges(t *testing.T) {
+	registry := NewSessionRegistry()
+	detector := NewFakeTmuxDetector()
+	detector.AddSession("work", 0, 0)
+
+	var calls int
+	monitor := NewTmuxMonitor(detector, registry, time.Hour, nil, func([]TmuxSessionInfo) { calls++ })
+
+	detector.AddWindow("work", TmuxWindow{Index: 0, Name: "vim", Active: true, Panes: []TmuxPane{{ID: "%1", Command: "vim"}}})
+	monitor.pollSessions()
+	monitor.pollSessions()
+	if calls != 1 {
+		t.Fatalf("calls = %d, want 1 (unchanged second poll)", calls)
+	}
+
+	detector.AddWindow("work", TmuxWindow{Index: 1, Name: "logs", Panes: []TmuxPane{{ID: "%2", Command: "tail"}}})
+	monitor.pollSessions()
+	if calls != 2 {
+		t.Fatalf("calls = %d, want 2 after adding a window", calls)
+	}
+
+	sessions := monitor.GetLastSessions()
+	if sessions[0].Windows != 2 || len(sessions[0].WindowList) != 2 {
+		t.Fatalf("session = %+v, want 2 windows", sessions[0])
+	}
+	sessions[0].WindowList[0].Panes[0].Command = "mutated"
+	if again := monitor.GetLastSessions(); again[0].WindowList[0].Panes[0].Command != "vim" {
+		t.Error("GetLastSessions shares pane slices with the cache")
+	}
+}
@@ -0,0 +1,338 @@
+package terminal
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// errTmuxLocalOnly is returned for management calls when no local detector
+// supports them.
+var errTmuxLocalOnly = errors.New("tmux session management is only supported on the local default server")
+
+// SSHTmuxCommand returns the program and arguments that run tmux with args on
+// host: `<sshCommand...> [-t] -- host 'tmux' 'arg'...`. ssh joins the remote
+// arguments into one shell command line, so each is single-quoted. tty
+// requests a remote terminal, as `tmux attach` needs.
+func SSHTmuxCommand(sshCommand []string, host string, tty bool, args ...string) (string, []string) {
+	if len(sshCommand) == 0 {
+		sshCommand = []string{"ssh"}
+	}
+	sshArgs := append([]string{}, sshCommand[1:]...)
+	if tty {
+		sshArgs = append(sshArgs, "-t")
+	}
+	sshArgs = append(sshArgs, "--", host, shellQuote("tmux"))
+	for _, arg := range args {
+		sshArgs = append(sshArgs, shellQuote(arg))
+	}
+	return sshCommand[0], sshArgs
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// tmuxSource is one extra tmux server (another local socket or a remote
+// host) and its last successful listing.
+type tmuxSource struct {
+	host     string // SSH host (empty = local)
+	socket   string // Socket on that host (empty = default server)
+	detector TmuxDetector
+	interval time.Duration // How often Watch polls it
+	listed   bool          // last holds a successful listing
+	last     []TmuxSessionInfo
+}
+
+// name identifies the source in log messages.
+func (s *tmuxSource) name() string {
+	if s.host == "" {
+		return "socket " + s.socket
+	}
+	return s.host
+}
+
+// MultiHostTmuxDetector merges the local default tmux server with other
+// local sockets and remote hosts. ListSessions tags each of their sessions
+// with its Socket or Host. Client tracking covers every local server;
+// session management and pane streaming stay on the default one.
+//
+// Once Watch is running, each extra server is polled in the background at
+// its own interval and ListSessions serves their last listings, so the
+// default server keeps its push updates and a monitor refresh never waits
+// on ssh.
+type MultiHostTmuxDetector struct {
+	local TmuxDetector
+
+	mu       sync.Mutex // protects each source's last listing
+	sources  []*tmuxSource
+	watching atomic.Bool
+}
+
+// Default polling intervals for extra servers while watched.
+const (
+	DefaultTmuxSocketPollInterval = 2 * time.Second
+	DefaultTmuxRemotePollInterval = 30 * time.Second
+)
+
+// NewMultiHostTmuxDetector wraps the default server's detector. Add other
+// servers with AddSocket and AddHost before the monitor starts.
+func NewMultiHostTmuxDetector(local TmuxDetector) *MultiHostTmuxDetector {
+	return &MultiHostTmuxDetector{local: local}
+}
+
+// AddSocket adds a local tmux server whose sessions are listed through
+// detector, polled every interval while watched (<= 0 uses
+// DefaultTmuxSocketPollInterval).
+func (m *MultiHostTmuxDetector) AddSocket(socket string, detector TmuxDetector, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultTmuxSocketPollInterval
+	}
+	m.sources = append(m.sources, &tmuxSource{socket: socket, detector: detector, interval: interval})
+}
+
+// AddHost adds a remote host whose sessions are listed through detector,
+// polled every interval while watched (<= 0 uses DefaultTmuxRemotePollInterval).
+func (m *MultiHostTmuxDetector) AddHost(name string, detector TmuxDetector, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultTmuxRemotePollInterval
+	}
+	m.sources = append(m.sources, &tmuxSource{host: name, detector: detector, interval: interval})
+}
+
+// Hosts returns the configured remote host names.
+func (m *MultiHostTmuxDetector) Hosts() []string {
+	var names []string
+	for _, s := range m.sources {
+		if s.host != "" {
+			names = append(names, s.host)
+		}
+	}
+	return names
+}
+
+// Source returns the detector for one server: the default one for an empty
+// host and socket, otherwise the matching AddSocket or AddHost detector.
+// Returns nil for a server that isn't configured.
+func (m *MultiHostTmuxDetector) Source(host, socket string) TmuxDetector {
+	if host == "" && socket == "" {
+		return m.local
+	}
+	for _, s := range m.sources {
+		if s.host == host && s.socket == socket {
+			return s.detector
+		}
+	}
+	return nil
+}
+
+// IsAvailable returns true if local tmux is available or any other server
+// is configured.
+func (m *MultiHostTmuxDetector) IsAvailable() bool {
+	return m.local.IsAvailable() || len(m.sources) > 0
+}
+
+// ListClients returns the clients of every local server. Remote clients
+// have no local TTY for the monitor to match. A socket whose server isn't
+// running contributes nothing.
+func (m *MultiHostTmuxDetector) ListClients() (map[string]string, error) {
+	clients := make(map[string]string)
+	if m.local.IsAvailable() {
+		local, err := m.local.ListClients()
+		if err != nil {
+			return nil, err
+		}
+		clients = local
+	}
+	for _, s := range m.sources {
+		if s.host != "" {
+			continue
+		}
+		socketClients, err := s.detector.ListClients()
+		if err != nil {
+			log.Printf("tmux list-clients error on %s: %v", s.name(), err)
+			continue
+		}
+		for tty, session := range socketClients {
+			clients[tty] = session
+		}
+	}
+	return clients, nil
+}
+
+// ListSessions lists the default server's sessions followed by each other
+// server's. Before Watch starts the other servers are queried in parallel on
+// every call; afterwards their background listings are used. A server that
+// fails (unreachable host, ssh timeout) keeps its last listing so its
+// sessions don't flicker. An error from the default server is returned
+// as-is, like the plain detector's.
+func (m *MultiHostTmuxDetector) ListSessions() ([]TmuxSessionInfo, error) {
+	var wg sync.WaitGroup
+	if !m.watching.Load() {
+		for _, src := range m.sources {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				m.pollSource(src)
+			}()
+		}
+	}
+
+	var local []TmuxSessionInfo
+	var err error
+	if m.local.IsAvailable() {
+		local, err = m.local.ListSessions()
+	}
+	wg.Wait()
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := local
+	for _, src := range m.sources {
+		for _, s := range src.last {
+			result = append(result, s.clone())
+		}
+	}
+	return result, nil
+}
+
+// pollSource refreshes one server's listing. Returns true if it changed.
+func (m *MultiHostTmuxDetector) pollSource(src *tmuxSource) bool {
+	sessions, err := src.detector.ListSessions()
+	if err != nil {
+		log.Printf("tmux list-sessions error on %s: %v", src.name(), err)
+		return false
+	}
+	for i := range sessions {
+		sessions[i].Host = src.host
+		sessions[i].Socket = src.socket
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if src.listed && sessionsEqual(src.last, sessions) {
+		return false
+	}
+	src.listed = true
+	src.last = sessions
+	return true
+}
+
+// Watch forwards the local detector's notifications (when it is a
+// TmuxWatcher) and polls every other server at its own interval, notifying
+// when a listing changes. The channel is closed when ctx is done.
+func (m *MultiHostTmuxDetector) Watch(ctx context.Context) <-chan struct{} {
+	m.watching.Store(true)
+	events := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case events <- struct{}{}:
+		default:
+		}
+	}
+
+	var wg sync.WaitGroup
+	if watcher, ok := m.local.(TmuxWatcher); ok {
+		local := watcher.Watch(ctx)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range local {
+				notify()
+			}
+		}()
+	}
+	for _, src := range m.sources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(src.interval)
+			defer ticker.Stop()
+			for {
+				if m.pollSource(src) {
+					notify()
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+
+	go func() {
+		<-ctx.Done()
+		wg.Wait()
+		close(events)
+	}()
+	return events
+}
+
+// Connected reports whether the default server's notifications are being
+// received. Other servers are polled by Watch itself, so they don't keep the
+// monitor polling.
+func (m *MultiHostTmuxDetector) Connected() bool {
+	watcher, ok := m.local.(TmuxWatcher)
+	return ok && watcher.Connected()
+}
+
+// localManager returns the local detector's TmuxSessionManager.
+func (m *MultiHostTmuxDetector) localManager() (TmuxSessionManager, error) {
+	manager, ok := m.local.(TmuxSessionManager)
+	if !ok {
+		return nil, errTmuxLocalOnly
+	}
+	return manager, nil
+}
+
+// NewSession creates a session on the default server.
+func (m *MultiHostTmuxDetector) NewSession(name, cwd, command string) error {
+	manager, err := m.localManager()
+	if err != nil {
+		return err
+	}
+	return manager.NewSession(name, cwd, command)
+}
+
+// RenameSession renames a session on the default server.
+func (m *MultiHostTmuxDetector) RenameSession(oldName, newName string) error {
+	manager, err := m.localManager()
+	if err != nil {
+		return err
+	}
+	return manager.RenameSession(oldName, newName)
+}
+
+// KillSession kills a session on the default server.
+func (m *MultiHostTmuxDetector) KillSession(name string) error {
+	manager, err := m.localManager()
+	if err != nil {
+		return err
+	}
+	return manager.KillSession(name)
+}
+
+// OpenPane streams a pane of the default server.
+func (m *MultiHostTmuxDetector) OpenPane(sessionName, paneID string) (PTY, error) {
+	opener, ok := m.local.(TmuxPaneOpener)
+	if !ok {
+		return nil, errTmuxLocalOnly
+	}
+	return opener.OpenPane(sessionName, paneID)
+}
+
+// Verify interface compliance at compile time.
+var (
+	_ TmuxDetector       = (*MultiHostTmuxDetector)(nil)
+	_ TmuxWatcher        = (*MultiHostTmuxDetector)(nil)
+	_ TmuxSessionManager = (*MultiHostTmuxDetector)(nil)
+	_ TmuxPaneOpener     = (*MultiHostTmuxDetector)(nil)
+)
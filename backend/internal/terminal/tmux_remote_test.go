@@ -0,0 +1,258 @@
+package terminal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeRemoteTmuxScript stands in for tmux on the remote host: it logs each
+// argument on its own line and prints canned list and capture output.
+const fakeRemoteTmuxScript = `#!/bin/sh
+for arg in "$@"; do printf '%s\n' "$arg"; done >> "$(dirname "$0")/tmux.log"
+case "$1" in
+list-sessions) printf 'build\t1\t0\n' ;;
+list-panes) printf 'build\t0\tmake\t1\t*\t%%4\t0\t1\tmake\t900\t80\t24\t/src\n' ;;
+capture-pane) printf 'make: ok\n\033[31mFAIL\033[0m\n' ;;
+esac
+`
+
+// writeFakeSSH puts FakeSSHScript and a fake tmux script in a temp dir on PATH and
+// returns the dir.
+func writeFakeSSH(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, script := range map[string]string{"ssh": FakeSSHScript, "tmux": fakeRemoteTmuxScript} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return dir
+}
+
+// Test Doc:
+// - Why: ssh joins remote arguments into one shell command line, so tmux arguments must survive the remote shell unchanged
+// - Contract: SSHTmuxCommand returns the ssh program, its options, optional -t, "--", the host and each tmux argument single-quoted
+// - Usage Notes: An empty ssh command falls back to plain "ssh"
+// - Quality Contribution: Catches quoting regressions that would let names with spaces or quotes break (or inject into) the remote command
+// - Worked Example: (["ssh"], "dev", true, "attach", "-t", "my work") → ssh -t -- dev 'tmux' 'attach' '-t' 'my work'
+func TestSSHTmuxCommand(t *testing.T) {
+	name, args := SSHTmuxCommand([]string{"ssh", "-o", "BatchMode=yes"}, "dev", true, "attach", "-t", "it's")
+	want := []string{"-o", "BatchMode=yes", "-t", "--", "dev", "'tmux'", "'attach'", "'-t'", `'it'\''s'`}
+	if name != "ssh" || !slices.Equal(args, want) {
+		t.Errorf("SSHTmuxCommand = %s %q, want ssh %q", name, args, want)
+	}
+
+	name, args = SSHTmuxCommand(nil, "me@dev", false, "list-sessions")
+	if name != "ssh" || !slices.Equal(args, []string{"--", "me@dev", "'tmux'", "'list-sessions'"}) {
+		t.Errorf("SSHTmuxCommand(nil) = %s %q", name, args)
+	}
+}
+
+// Test Doc:
+// - Why: Remote hosts are listed and managed through the same RealTmuxDetector code as local tmux, just wrapped in ssh
+// - Contract: With Host set, tmux runs through SSHCommand; output parses as usual and arguments arrive intact on the remote side
+// - Usage Notes: Uses a fake ssh wrapper and a fake tmux script on PATH; no sshd or tmux needed
+// - Quality Contribution: Proves the ssh wrapping end to end, including quoting of awkward session names
+// - Worked Example: ListSessions → [build with window 0 "make" and pane %4]; NewSession("it's here") → tmux receives the name unchanged
+func TestRemoteTmuxDetector_FakeSSH(t *testing.T) {
+	dir := writeFakeSSH(t)
+	d := NewRemoteTmuxDetector("devvm", []string{filepath.Join(dir, "ssh"), "-o", "BatchMode=yes"}, 5*time.Second)
+
+	if !d.IsAvailable() {
+		t.Error("IsAvailable() = false with ssh on PATH")
+	}
+
+	sessions, err := d.ListSessions()
+	if err != nil {
+		t.Fatalf("ListSessions error: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].Name != "build" || len(sessions[0].WindowList) != 1 {
+		t.Fatalf("ListSessions = %+v, want build with one window", sessions)
+	}
+	if pane := sessions[0].WindowList[0].Panes[0]; pane.ID != "%4" || pane.Cwd != "/src" {
+		t.Errorf("pane = %+v, want %%4 in /src", pane)
+	}
+
+	os.Remove(filepath.Join(dir, "tmux.log"))
+	if err := d.NewSession("it's here", "", ""); err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+	logged, _ := os.ReadFile(filepath.Join(dir, "tmux.log"))
+	if got := strings.Split(strings.TrimSpace(string(logged)), "\n"); !slices.Equal(got, []string{"new-session", "-d", "-s", "it's here"}) {
+		t.Errorf("remote tmux args = %q", got)
+	}
+}
+
+// Test Doc:
+// - Why: The session list merges local tmux with every configured remote host
+// - Contract: ListSessions returns local sessions then each host's tagged with Host; a failing host keeps its last listing; a local error is returned; clients, management and panes stay local; Connected follows the local watcher even with hosts configured
+// - Usage Notes: FakeTmuxDetector stands in for both local and remote detectors
+// - Quality Contribution: Prevents one unreachable host from blanking or flickering the list
+// - Worked Example: local [dev] + devvm [build] → [dev, build@devvm]; devvm errors → still [dev, build@devvm]
+func TestMultiHostTmuxDetector(t *testing.T) {
+	local := NewFakeTmuxDetector()
+	local.AddSession("dev", 1, 0)
+	local.AddClient("/dev/pts/1", "dev")
+	local.SetConnected(true)
+	remote := NewFakeTmuxDetector()
+	remote.AddSession("build", 2, 0)
+
+	m := NewMultiHostTmuxDetector(local)
+	m.AddHost("devvm", remote, 0)
+	if !slices.Equal(m.Hosts(), []string{"devvm"}) {
+		t.Errorf("Hosts() = %v", m.Hosts())
+	}
+
+	want := []TmuxSessionInfo{{Name: "dev", Windows: 1}, {Name: "build", Windows: 2, Host: "devvm"}}
+	sessions, err := m.ListSessions()
+	if err != nil || !slices.EqualFunc(sessions, want, TmuxSessionInfo.equal) {
+		t.Fatalf("ListSessions = %+v, %v; want %+v", sessions, err, want)
+	}
+
+	remote.SetError(errors.New("ssh: connect timed out"))
+	sessions, err = m.ListSessions()
+	if err != nil || len(sessions) != 2 || sessions[1].Host != "devvm" {
+		t.Errorf("ListSessions with failing host = %+v, %v; want last listing kept", sessions, err)
+	}
+
+	remote.SetError(nil)
+
+	local.SetError(errors.New("tmux broke"))
+	if _, err := m.ListSessions(); err == nil {
+		t.Error("ListSessions error = nil with failing local tmux")
+	}
+	local.SetError(nil)
+
+	clients, _ := m.ListClients()
+	if len(clients) != 1 || clients["/dev/pts/1"] != "dev" {
+		t.Errorf("ListClients = %v, want local clients only", clients)
+	}
+	if !m.Connected() {
+		t.Error("Connected() = false with the local watcher connected and a remote host configured")
+	}
+
+	if err := m.NewSession("work", "", ""); err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+	if localSessions, _ := local.ListSessions(); len(localSessions) != 2 {
+		t.Errorf("local sessions = %+v, want work created locally", localSessions)
+	}
+	if remoteSessions, _ := remote.ListSessions(); len(remoteSessions) != 1 {
+		t.Errorf("remote sessions changed: %+v", remoteSessions)
+	}
+}
+
+// Test Doc:
+// - Why: Separate tmux servers (e.g. `tmux -L agents`) are merged with the default one and attached with the right flag
+// - Contract: TmuxSocketArgs gives -L for names and -S for paths; a socket detector prefixes every tmux command with them; MultiHostTmuxDetector tags socket sessions with Socket and merges their clients for attachment tracking
+// - Usage Notes: A fake tmux script on PATH logs the arguments; FakeTmuxDetector stands in for the socket's server in the merge
+// - Quality Contribution: Catches commands that silently fall back to the default server
+// - Worked Example: socket "agents" → tmux -L agents list-sessions ...; agents session "bot" → {name: bot, socket: agents}
+func TestMultiHostTmuxDetector_Sockets(t *testing.T) {
+	for socket, want := range map[string][]string{"": nil, "agents": {"-L", "agents"}, "/run/trex/humans.sock": {"-S", "/run/trex/humans.sock"}} {
+		if got := TmuxSocketArgs(socket); !slices.Equal(got, want) {
+			t.Errorf("TmuxSocketArgs(%q) = %q, want %q", socket, got, want)
+		}
+	}
+
+	dir := writeFakeSSH(t)
+	if _, err := NewSocketTmuxDetector("agents", 5*time.Second).ListSessions(); err != nil {
+		t.Fatalf("ListSessions error: %v", err)
+	}
+	logged, _ := os.ReadFile(filepath.Join(dir, "tmux.log"))
+	if got := strings.Split(string(logged), "\n"); len(got) < 3 || !slices.Equal(got[:3], []string{"-L", "agents", "list-sessions"}) {
+		t.Errorf("tmux args = %q, want -L agents list-sessions ...", got)
+	}
+
+	local := NewFakeTmuxDetector()
+	local.AddSession("dev", 1, 1)
+	local.AddClient("/dev/pts/1", "dev")
+	agents := NewFakeTmuxDetector()
+	agents.AddSession("bot", 1, 1)
+	agents.AddClient("/dev/pts/2", "bot")
+
+	m := NewMultiHostTmuxDetector(local)
+	m.AddSocket("agents", agents, 0)
+	if len(m.Hosts()) != 0 {
+		t.Errorf("Hosts() = %v, want none for a socket", m.Hosts())
+	}
+
+	want := []TmuxSessionInfo{{Name: "dev", Windows: 1, Attached: 1}, {Name: "bot", Windows: 1, Attached: 1, Socket: "agents"}}
+	if sessions, err := m.ListSessions(); err != nil || !slices.EqualFunc(sessions, want, TmuxSessionInfo.equal) {
+		t.Errorf("ListSessions = %+v, %v; want %+v", sessions, err, want)
+	}
+	clients, err := m.ListClients()
+	if err != nil || len(clients) != 2 || clients["/dev/pts/2"] != "bot" {
+		t.Errorf("ListClients = %v, %v; want clients of both servers", clients, err)
+	}
+
+	// A socket whose server is down doesn't hide the default server's clients
+	agents.SetError(errors.New("no server running"))
+	if clients, err := m.ListClients(); err != nil || len(clients) != 1 {
+		t.Errorf("ListClients with socket down = %v, %v; want the default server's", clients, err)
+	}
+}
+
+// countingTmuxDetector counts ListSessions calls on the wrapped detector.
+type countingTmuxDetector struct {
+	*FakeTmuxDetector
+	lists atomic.Int32
+}
+
+func (c *countingTmuxDetector) ListSessions() ([]TmuxSessionInfo, error) {
+	c.lists.Add(1)
+	return c.FakeTmuxDetector.ListSessions()
+}
+
+// Test Doc:
+// - Why: A remote host must not turn local push updates back into 2s polling, nor put ssh on every refresh
+// - Contract: while Watch runs, local notifications are forwarded, each extra server is polled at its own interval with an event on change, and ListSessions serves the background listing without querying it
+// - Usage Notes: A counting wrapper shows ListSessions doesn't reach the remote detector once watched
+// - Worked Example: devvm gains "deploy" → event within its 20ms interval → ListSessions includes deploy@devvm with no extra remote call
+func TestMultiHostTmuxDetector_WatchPollsSourcesOnTheirOwn(t *testing.T) {
+	local := NewFakeTmuxDetector()
+	local.AddSession("dev", 1, 0)
+	remote := &countingTmuxDetector{FakeTmuxDetector: NewFakeTmuxDetector()}
+	remote.AddSession("build", 1, 0)
+
+	m := NewMultiHostTmuxDetector(local)
+	m.AddHost("devvm", remote, 20*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := m.Watch(ctx)
+	next := func(what string) {
+		t.Helper()
+		select {
+		case <-events:
+		case <-time.After(time.Second):
+			t.Fatalf("no event after %s", what)
+		}
+	}
+	next("first remote listing")
+
+	remote.AddSession("deploy", 1, 0)
+	next("remote change")
+	before := remote.lists.Load()
+	sessions, err := m.ListSessions()
+	if err != nil || len(sessions) != 3 || sessions[2].Name != "deploy" || sessions[2].Host != "devvm" {
+		t.Errorf("ListSessions = %+v, %v; want dev, build@devvm, deploy@devvm", sessions, err)
+	}
+	if after := remote.lists.Load(); after != before {
+		t.Errorf("ListSessions queried the remote while watched (%d → %d calls)", before, after)
+	}
+
+	local.Notify()
+	next("local notification")
+
+	cancel()
+	for range events {
+	}
+}
@@ -0,0 +1,371 @@
+// Package webhook delivers server events to configured HTTP endpoints as
+// signed JSON POSTs, retrying with backoff and recording deliveries that
+// finally fail in a dead-letter log.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Event types delivered to webhooks.
+const (
+	EventSessionCreated      = "session.created"       // Terminal session created
+	EventSessionExited       = "session.exited"        // Session's process exited (data includes exitCode)
+	EventTmuxSessionAppeared = "tmux.session_appeared" // tmux session found by the monitor
+	EventTmuxSessionVanished = "tmux.session_vanished" // tmux session no longer listed
+	EventPluginStatus        = "plugin.status"         // Plugin phase/status transition (e.g. copilot)
+	EventNotification        = "notification"          // Notifier rule fired
+)
+
+// Request headers.
+const (
+	HeaderSignature = "X-Trex-Signature-256" // "sha256=" + hex HMAC-SHA256 of the body
+	HeaderEvent     = "X-Trex-Event"         // Event type
+	HeaderDelivery  = "X-Trex-Delivery"      // Event ID, the same on every retry
+)
+
+const (
+	// queueSize bounds each endpoint's pending deliveries. Events published
+	// while it is full go straight to the dead-letter log.
+	queueSize = 256
+	// requestTimeout bounds one delivery attempt.
+	requestTimeout = 10 * time.Second
+	// maxResponseBody is how much of an error response is kept for the log.
+	maxResponseBody = 512
+)
+
+// Endpoint is one webhook receiver.
+type Endpoint struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret"` // HMAC-SHA256 key; empty sends unsigned requests
+	// Events are the event types sent to this endpoint. A type ending in "."
+	// matches as a prefix (e.g. "session."). Empty means every event.
+	Events []string `json:"events"`
+}
+
+// Wants reports whether the endpoint subscribes to eventType.
+func (e Endpoint) Wants(eventType string) bool {
+	if len(e.Events) == 0 {
+		return true
+	}
+	for _, t := range e.Events {
+		if t == eventType || (strings.HasSuffix(t, ".") && strings.HasPrefix(eventType, t)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Config is the webhooks file ({"endpoints": [...]}).
+type Config struct {
+	Endpoints []Endpoint `json:"endpoints"`
+}
+
+// LoadConfig reads webhook endpoints from a JSON file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse webhooks %s: %w", path, err)
+	}
+	for _, ep := range cfg.Endpoints {
+		if !strings.HasPrefix(ep.URL, "http://") && !strings.HasPrefix(ep.URL, "https://") {
+			return nil, fmt.Errorf("invalid webhook URL %q in %s", ep.URL, path)
+		}
+	}
+	return &cfg, nil
+}
+
+// Event is the JSON body of a webhook request.
+type Event struct {
+	ID   string    `json:"id"`
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	Data any       `json:"data"`
+}
+
+// DeadLetter is one line of the dead-letter log: a delivery that failed
+// every attempt (or was never attempted because the queue was full or the
+// server shut down).
+type DeadLetter struct {
+	Time     time.Time       `json:"time"`
+	URL      string          `json:"url"`
+	Attempts int             `json:"attempts"`
+	Error    string          `json:"error"`
+	Event    json.RawMessage `json:"event"`
+}
+
+// Options configures a Dispatcher. Zero values use the defaults.
+type Options struct {
+	DeadLetterPath string        // JSON-lines dead-letter log; empty only logs failures
+	MaxAttempts    int           // Attempts per delivery (default 5)
+	Backoff        time.Duration // Delay before the first retry, doubled each time (default 1s)
+	MaxBackoff     time.Duration // Cap on the retry delay (default 1m)
+	Client         *http.Client  // Default: http.Client with a 10s timeout
+}
+
+// Stats counts deliveries since the dispatcher started.
+type Stats struct {
+	Delivered    int64 `json:"delivered"`
+	Retried      int64 `json:"retried"`
+	DeadLettered int64 `json:"deadLettered"`
+}
+
+// delivery is one event queued for one endpoint.
+type delivery struct {
+	eventType string
+	id        string
+	body      []byte
+}
+
+// endpointWorker delivers one endpoint's events in order.
+type endpointWorker struct {
+	endpoint Endpoint
+	queue    chan delivery
+}
+
+// Dispatcher fans events out to endpoints. Each endpoint has its own queue
+// and goroutine, so a slow or failing receiver doesn't delay the others. A
+// nil *Dispatcher discards events, so callers don't need to guard Publish
+// when webhooks are disabled.
+type Dispatcher struct {
+	workers []*endpointWorker
+	opts    Options
+	now     func() time.Time
+
+	deadMu sync.Mutex // serialises dead-letter writes
+
+	delivered    atomic.Int64
+	retried      atomic.Int64
+	deadLettered atomic.Int64
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewDispatcher creates a dispatcher for the endpoints. Call Start to begin
+// delivering.
+func NewDispatcher(endpoints []Endpoint, opts Options) *Dispatcher {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = time.Second
+	}
+	if opts.MaxBackoff < opts.Backoff {
+		opts.MaxBackoff = max(time.Minute, opts.Backoff)
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: requestTimeout}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{opts: opts, now: time.Now, ctx: ctx, cancel: cancel}
+	for _, ep := range endpoints {
+		d.workers = append(d.workers, &endpointWorker{endpoint: ep, queue: make(chan delivery, queueSize)})
+	}
+	return d
+}
+
+// Start begins delivering in one goroutine per endpoint.
+func (d *Dispatcher) Start() {
+	for _, w := range d.workers {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			d.run(w)
+		}()
+	}
+}
+
+// Stop abandons retries and waits for the workers to exit. Deliveries still
+// pending are written to the dead-letter log.
+func (d *Dispatcher) Stop() {
+	if d == nil {
+		return
+	}
+	d.cancel()
+	d.wg.Wait()
+}
+
+// Publish queues an event for every endpoint that wants its type.
+func (d *Dispatcher) Publish(eventType string, data any) {
+	if d == nil || d.ctx.Err() != nil {
+		return
+	}
+	event := Event{ID: newEventID(), Type: eventType, Time: d.now().UTC(), Data: data}
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("webhook: marshal %s: %v", eventType, err)
+		return
+	}
+	for _, w := range d.workers {
+		if !w.endpoint.Wants(eventType) {
+			continue
+		}
+		select {
+		case w.queue <- delivery{eventType: eventType, id: event.ID, body: body}:
+		default:
+			d.deadLetter(w.endpoint, body, 0, "queue full")
+		}
+	}
+}
+
+// Stats returns the delivery counters.
+func (d *Dispatcher) Stats() Stats {
+	if d == nil {
+		return Stats{}
+	}
+	return Stats{
+		Delivered:    d.delivered.Load(),
+		Retried:      d.retried.Load(),
+		DeadLettered: d.deadLettered.Load(),
+	}
+}
+
+// run delivers w's queue until Stop, then dead-letters what is left.
+func (d *Dispatcher) run(w *endpointWorker) {
+	for {
+		select {
+		case <-d.ctx.Done():
+			for {
+				select {
+				case del := <-w.queue:
+					d.deadLetter(w.endpoint, del.body, 0, "server shutting down")
+				default:
+					return
+				}
+			}
+		case del := <-w.queue:
+			d.deliver(w.endpoint, del)
+		}
+	}
+}
+
+// deliver attempts one delivery up to MaxAttempts times. Network errors,
+// 429 and 5xx responses are retried; other 4xx responses are not.
+func (d *Dispatcher) deliver(ep Endpoint, del delivery) {
+	delay := d.opts.Backoff
+	var lastErr error
+	for attempt := 1; attempt <= d.opts.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			d.retried.Add(1)
+			select {
+			case <-d.ctx.Done():
+				d.deadLetter(ep, del.body, attempt-1, "server shutting down: "+lastErr.Error())
+				return
+			case <-time.After(delay):
+			}
+			delay = min(delay*2, d.opts.MaxBackoff)
+		}
+
+		retry, err := d.post(ep, del)
+		if err == nil {
+			d.delivered.Add(1)
+			return
+		}
+		lastErr = err
+		if !retry {
+			d.deadLetter(ep, del.body, attempt, err.Error())
+			return
+		}
+	}
+	d.deadLetter(ep, del.body, d.opts.MaxAttempts, lastErr.Error())
+}
+
+// post sends one attempt. Returns whether a failure is worth retrying.
+func (d *Dispatcher) post(ep Endpoint, del delivery) (bool, error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, ep.URL, bytes.NewReader(del.body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "trex-webhook")
+	req.Header.Set(HeaderEvent, del.eventType)
+	req.Header.Set(HeaderDelivery, del.id)
+	if ep.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(ep.Secret, del.body))
+	}
+
+	resp, err := d.opts.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return false, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	err = fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+}
+
+// deadLetter records a delivery that won't be retried.
+func (d *Dispatcher) deadLetter(ep Endpoint, body []byte, attempts int, reason string) {
+	d.deadLettered.Add(1)
+	log.Printf("webhook: giving up on %s after %d attempts: %s", ep.URL, attempts, reason)
+	if d.opts.DeadLetterPath == "" {
+		return
+	}
+	data, err := json.Marshal(DeadLetter{Time: d.now().UTC(), URL: ep.URL, Attempts: attempts, Error: reason, Event: body})
+	if err != nil {
+		return
+	}
+
+	d.deadMu.Lock()
+	defer d.deadMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(d.opts.DeadLetterPath), 0o700); err != nil {
+		log.Printf("webhook: dead-letter log: %v", err)
+		return
+	}
+	f, err := os.OpenFile(d.opts.DeadLetterPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		log.Printf("webhook: dead-letter log: %v", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		log.Printf("webhook: dead-letter log: %v", err)
+	}
+}
+
+// Sign returns the signature header value for body: "sha256=" followed by
+// the hex HMAC-SHA256 of body keyed with secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is a valid Sign(secret, body), in
+// constant time. Receivers written in Go can use it directly.
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// newEventID returns a random event ID.
+func newEventID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return "evt_" + hex.EncodeToString(b)
+}
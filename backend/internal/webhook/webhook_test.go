@@ -0,0 +1,187 @@
+package webhook
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// receiver is a local webhook endpoint that records requests and answers
+// with the next status from statuses (200 once they run out).
+type receiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	statuses []int
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	t.Helper()
+	r := &receiver{statuses: statuses}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		r.requests = append(r.requests, req)
+		r.bodies = append(r.bodies, body)
+		status := http.StatusOK
+		if len(r.statuses) > 0 {
+			status, r.statuses = r.statuses[0], r.statuses[1:]
+		}
+		r.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.requests)
+}
+
+// waitFor polls cond for up to two seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDispatcher_SignedDelivery(t *testing.T) {
+	// Test Doc:
+	// - Why: Chat and CI receivers must be able to trust that an event came from this trex server
+	// - Contract: Each subscribed endpoint gets a JSON POST of {id, type, time, data} with X-Trex-Event, X-Trex-Delivery and an HMAC-SHA256 X-Trex-Signature-256 of the body; unsubscribed types aren't sent
+	// - Worked Example: events ["session."] → session.created delivered, tmux.session_appeared not
+
+	hook := newReceiver(t)
+	d := NewDispatcher([]Endpoint{{URL: hook.URL, Secret: "s3cret", Events: []string{"session."}}}, Options{})
+	d.Start()
+	defer d.Stop()
+
+	d.Publish(EventTmuxSessionAppeared, map[string]string{"name": "work"})
+	d.Publish(EventSessionCreated, map[string]string{"sessionId": "s1"})
+	waitFor(t, "delivery", func() bool { return d.Stats().Delivered == 1 })
+
+	if hook.count() != 1 {
+		t.Fatalf("receiver got %d requests, want 1", hook.count())
+	}
+	req, body := hook.requests[0], hook.bodies[0]
+	if !Verify("s3cret", body, req.Header.Get(HeaderSignature)) {
+		t.Errorf("signature %q doesn't verify", req.Header.Get(HeaderSignature))
+	}
+	var event Event
+	if err := json.Unmarshal(body, &event); err != nil {
+		t.Fatalf("bad body %s: %v", body, err)
+	}
+	if event.Type != EventSessionCreated || req.Header.Get(HeaderEvent) != EventSessionCreated || req.Header.Get(HeaderDelivery) != event.ID {
+		t.Errorf("event %+v with headers %v", event, req.Header)
+	}
+}
+
+func TestDispatcher_RetryAndDeadLetter(t *testing.T) {
+	// Test Doc:
+	// - Why: Receivers restart and rate-limit; events must not be silently lost
+	// - Contract: 5xx/429 are retried with backoff and succeed once the receiver recovers; other 4xx aren't retried; a delivery that never succeeds is appended to the dead-letter log with its attempts and error
+	// - Usage Notes: 1ms backoff keeps the test fast
+
+	flaky := newReceiver(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	rejecting := newReceiver(t, http.StatusBadRequest)
+	down := newReceiver(t, 500, 500, 500)
+	deadPath := filepath.Join(t.TempDir(), "dead.jsonl")
+
+	d := NewDispatcher([]Endpoint{{URL: flaky.URL}, {URL: rejecting.URL}, {URL: down.URL}}, Options{
+		DeadLetterPath: deadPath,
+		MaxAttempts:    3,
+		Backoff:        time.Millisecond,
+	})
+	d.Start()
+	defer d.Stop()
+
+	d.Publish(EventSessionExited, map[string]any{"sessionId": "s1", "exitCode": 2})
+	waitFor(t, "deliveries to settle", func() bool {
+		s := d.Stats()
+		return s.Delivered == 1 && s.DeadLettered == 2
+	})
+	if flaky.count() != 3 || rejecting.count() != 1 || down.count() != 3 {
+		t.Errorf("attempts flaky=%d rejecting=%d down=%d, want 3, 1, 3", flaky.count(), rejecting.count(), down.count())
+	}
+
+	f, err := os.Open(deadPath)
+	if err != nil {
+		t.Fatalf("dead-letter log: %v", err)
+	}
+	defer f.Close()
+	attempts := map[string]int{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var dl DeadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &dl); err != nil {
+			t.Fatalf("bad dead letter %s: %v", scanner.Bytes(), err)
+		}
+		var event Event
+		if json.Unmarshal(dl.Event, &event); event.Type != EventSessionExited {
+			t.Errorf("dead letter event = %s", dl.Event)
+		}
+		attempts[dl.URL] = dl.Attempts
+	}
+	if attempts[rejecting.URL] != 1 || attempts[down.URL] != 3 || len(attempts) != 2 {
+		t.Errorf("dead letters = %v, want rejecting after 1 attempt, down after 3", attempts)
+	}
+}
+
+func TestDispatcher_StopDeadLettersPending(t *testing.T) {
+	// Test Doc:
+	// - Why: Shutting down mid-retry shouldn't drop events without a trace
+	// - Contract: Stop abandons the retry in progress and dead-letters it; Publish after Stop and on a nil Dispatcher are no-ops
+
+	down := newReceiver(t, 500, 500, 500, 500, 500)
+	d := NewDispatcher([]Endpoint{{URL: down.URL}}, Options{Backoff: time.Hour})
+	d.Start()
+	d.Publish(EventNotification, map[string]string{"kind": "match"})
+	waitFor(t, "first attempt", func() bool { return down.count() == 1 })
+	d.Stop()
+
+	if s := d.Stats(); s.DeadLettered != 1 || s.Delivered != 0 {
+		t.Errorf("stats after stop = %+v, want one dead letter", s)
+	}
+	d.Publish(EventNotification, nil)
+	var nilDispatcher *Dispatcher
+	nilDispatcher.Publish(EventNotification, nil)
+	nilDispatcher.Stop()
+	if down.count() != 1 {
+		t.Errorf("requests after stop = %d, want 1", down.count())
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	// Test Doc:
+	// - Contract: Endpoints load from {"endpoints": [...]}; a non-HTTP URL is rejected
+
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	os.WriteFile(path, []byte(`{"endpoints": [{"url": "https://ci.example.com/hook", "secret": "x", "events": ["session.exited"]}]}`), 0o600)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if len(cfg.Endpoints) != 1 || !cfg.Endpoints[0].Wants(EventSessionExited) || cfg.Endpoints[0].Wants(EventSessionCreated) {
+		t.Errorf("endpoints = %+v", cfg.Endpoints)
+	}
+
+	os.WriteFile(path, []byte(`{"endpoints": [{"url": "file:///etc/passwd"}]}`), 0o600)
+	if _, err := LoadConfig(path); err == nil {
+		t.Error("non-HTTP URL accepted")
+	}
+}
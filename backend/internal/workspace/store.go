@@ -0,0 +1,246 @@
+package workspace
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// storeFile is the JSON structure of the workspaces file.
+type storeFile struct {
+	Version int                    `json:"version"`
+	Users   map[string][]Workspace `json:"users"`
+}
+
+// Store holds each user's ordered list of workspaces. Every change is
+// written to the file at path (atomically, via a temp file and rename)
+// before it takes effect; an empty path keeps workspaces in memory only.
+// Users are keyed by username; "" is the local user when auth is disabled.
+type Store struct {
+	mu    sync.Mutex
+	path  string
+	users map[string][]Workspace
+	now   func() time.Time
+}
+
+// NewStore loads the workspaces file at path. A missing file starts empty.
+func NewStore(path string) (*Store, error) {
+	s := &Store{
+		path:  path,
+		users: make(map[string][]Workspace),
+		now:   time.Now,
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file storeFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if file.Users != nil {
+		s.users = file.Users
+	}
+	return s, nil
+}
+
+// List returns user's workspaces in order.
+func (s *Store) List(user string) []Workspace {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]Workspace, 0, len(s.users[user]))
+	for _, w := range s.users[user] {
+		result = append(result, w.clone())
+	}
+	return result
+}
+
+// Get returns one of user's workspaces.
+func (s *Store) Get(user, id string) (Workspace, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.index(user, id)
+	if i < 0 {
+		return Workspace{}, ErrNotFound
+	}
+	return s.users[user][i].clone(), nil
+}
+
+// Create validates w and appends it to user's workspaces. A client-supplied
+// ID is kept (so the frontend's IDs stay stable); otherwise one is generated.
+func (s *Store) Create(user string, w Workspace) (Workspace, error) {
+	if err := w.Validate(); err != nil {
+		return Workspace{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := s.users[user]
+	if len(list) >= MaxWorkspaces {
+		return Workspace{}, ErrTooMany
+	}
+	if w.ID == "" {
+		w.ID = newID()
+	} else if s.index(user, w.ID) >= 0 {
+		return Workspace{}, ErrExists
+	}
+	w = w.clone()
+	w.CreatedAt = s.now().UTC()
+	w.UpdatedAt = w.CreatedAt
+
+	if err := s.commit(user, append(slices.Clone(list), w)); err != nil {
+		return Workspace{}, err
+	}
+	return w.clone(), nil
+}
+
+// Update replaces the name, layout and focus of one of user's workspaces,
+// keeping its ID, creation time and position.
+func (s *Store) Update(user, id string, w Workspace) (Workspace, error) {
+	w.ID = id
+	if err := w.Validate(); err != nil {
+		return Workspace{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.index(user, id)
+	if i < 0 {
+		return Workspace{}, ErrNotFound
+	}
+	list := slices.Clone(s.users[user])
+	w = w.clone()
+	w.CreatedAt = list[i].CreatedAt
+	w.UpdatedAt = s.now().UTC()
+	list[i] = w
+
+	if err := s.commit(user, list); err != nil {
+		return Workspace{}, err
+	}
+	return w.clone(), nil
+}
+
+// Delete removes one of user's workspaces and returns it, so the caller can
+// act on the sessions it held.
+func (s *Store) Delete(user, id string) (Workspace, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.index(user, id)
+	if i < 0 {
+		return Workspace{}, ErrNotFound
+	}
+	removed := s.users[user][i]
+	if err := s.commit(user, slices.Delete(slices.Clone(s.users[user]), i, i+1)); err != nil {
+		return Workspace{}, err
+	}
+	return removed.clone(), nil
+}
+
+// Reorder puts user's workspaces in the order of ids, which must name each
+// of them exactly once.
+func (s *Store) Reorder(user string, ids []string) ([]Workspace, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := s.users[user]
+	if len(ids) != len(list) {
+		return nil, fmt.Errorf("%w: order must list all %d workspaces", ErrInvalid, len(list))
+	}
+	reordered := make([]Workspace, 0, len(ids))
+	for _, id := range ids {
+		i := s.index(user, id)
+		if i < 0 {
+			return nil, fmt.Errorf("%w: unknown workspace %q in order", ErrInvalid, id)
+		}
+		if slices.ContainsFunc(reordered, func(w Workspace) bool { return w.ID == id }) {
+			return nil, fmt.Errorf("%w: workspace %q listed twice in order", ErrInvalid, id)
+		}
+		reordered = append(reordered, list[i])
+	}
+
+	if err := s.commit(user, reordered); err != nil {
+		return nil, err
+	}
+	result := make([]Workspace, 0, len(reordered))
+	for _, w := range reordered {
+		result = append(result, w.clone())
+	}
+	return result, nil
+}
+
+// index returns the position of id in user's list, or -1. Caller holds mu.
+func (s *Store) index(user, id string) int {
+	return slices.IndexFunc(s.users[user], func(w Workspace) bool { return w.ID == id })
+}
+
+// commit saves the store with user's list replaced, then applies it. On a
+// write error nothing changes. Caller holds mu.
+func (s *Store) commit(user string, list []Workspace) error {
+	users := make(map[string][]Workspace, len(s.users)+1)
+	for u, l := range s.users {
+		users[u] = l
+	}
+	if len(list) == 0 {
+		delete(users, user)
+	} else {
+		users[user] = list
+	}
+
+	if s.path != "" {
+		if err := save(s.path, users); err != nil {
+			return err
+		}
+	}
+	s.users = users
+	return nil
+}
+
+// save writes the workspaces file atomically, readable only by its owner.
+func save(path string, users map[string][]Workspace) error {
+	data, err := json.MarshalIndent(storeFile{Version: 1, Users: users}, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".workspaces-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// newID returns a random workspace ID.
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "ws-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,118 @@
+package workspace
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStore_CRUD(t *testing.T) {
+	// Test Doc:
+	// - Why: Each user's workspaces are their own; IDs from the frontend must stay stable
+	// - Contract: Create keeps a given ID (or generates one), rejects duplicates; Update keeps position and CreatedAt; Delete returns the removed workspace; users never see each other's
+
+	s, _ := NewStore("")
+
+	a, err := s.Create("alice", Workspace{ID: "one", Name: "one", Tree: terminal("p1", "s1")})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := s.Create("alice", Workspace{ID: "one", Tree: terminal("p1", "s1")}); !errors.Is(err, ErrExists) {
+		t.Errorf("duplicate Create error = %v, want ErrExists", err)
+	}
+	b, err := s.Create("alice", Workspace{Name: "two", Tree: terminal("p1", "s2")})
+	if err != nil || b.ID == "" {
+		t.Fatalf("Create without ID = %+v, %v; want a generated ID", b, err)
+	}
+
+	updated, err := s.Update("alice", "one", Workspace{Name: "renamed", Tree: terminal("p1", "s3")})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if updated.ID != "one" || !updated.CreatedAt.Equal(a.CreatedAt) {
+		t.Errorf("Update = %+v, want ID and CreatedAt kept", updated)
+	}
+	if list := s.List("alice"); len(list) != 2 || list[0].Name != "renamed" {
+		t.Errorf("List after update = %+v, want renamed first", list)
+	}
+
+	if _, err := s.Get("bob", "one"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("bob Get error = %v, want ErrNotFound", err)
+	}
+	if _, err := s.Delete("bob", "one"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("bob Delete error = %v, want ErrNotFound", err)
+	}
+
+	removed, err := s.Delete("alice", "one")
+	if err != nil || removed.Tree.SessionID != "s3" {
+		t.Errorf("Delete = %+v, %v; want the removed workspace", removed, err)
+	}
+	if list := s.List("alice"); len(list) != 1 || list[0].ID != b.ID {
+		t.Errorf("List after delete = %+v, want only %s", list, b.ID)
+	}
+}
+
+func TestStore_Reorder(t *testing.T) {
+	// Test Doc:
+	// - Why: The workspace list order is user-controlled (drag to reorder)
+	// - Contract: Reorder must name every workspace exactly once; otherwise ErrInvalid and the order is unchanged
+	// - Worked Example: [a b c] + Reorder(c a b) → [c a b]
+
+	s, _ := NewStore("")
+	for _, id := range []string{"a", "b", "c"} {
+		s.Create("alice", Workspace{ID: id, Tree: terminal("p1", id)})
+	}
+
+	for _, order := range [][]string{{"a", "b"}, {"a", "a", "b"}, {"a", "b", "x"}} {
+		if _, err := s.Reorder("alice", order); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Reorder(%v) error = %v, want ErrInvalid", order, err)
+		}
+	}
+
+	list, err := s.Reorder("alice", []string{"c", "a", "b"})
+	if err != nil {
+		t.Fatalf("Reorder: %v", err)
+	}
+	if list[0].ID != "c" || list[1].ID != "a" || list[2].ID != "b" {
+		t.Errorf("Reorder = %v, want c a b", list)
+	}
+}
+
+func TestStore_Persistence(t *testing.T) {
+	// Test Doc:
+	// - Why: Workspaces must survive a server restart (per ADR-0006: XDG data path)
+	// - Contract: Changes are written to the file as they happen (mode 0600); NewStore reloads them; a missing file starts empty; a corrupt file is an error
+	// - Worked Example: Create(alice, dev) → NewStore(same path).List(alice) = [dev]
+
+	path := filepath.Join(t.TempDir(), "trex", "workspaces.json")
+	s, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore on missing file: %v", err)
+	}
+	if _, err := s.Create("alice", Workspace{ID: "dev", Name: "dev", Tree: split(terminal("p1", "s1"), terminal("p2", "s2"))}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("workspaces file not written: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	reloaded, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	list := reloaded.List("alice")
+	if len(list) != 1 || list[0].Name != "dev" || list[0].Tree.Second.SessionID != "s2" {
+		t.Errorf("reloaded = %+v, want dev with both panes", list)
+	}
+
+	os.WriteFile(path, []byte("{not json"), 0o600)
+	if _, err := NewStore(path); err == nil {
+		t.Error("NewStore on corrupt file should fail")
+	}
+}
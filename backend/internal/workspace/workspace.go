@@ -0,0 +1,219 @@
+// Package workspace stores each user's workspaces (named pane layouts) on
+// the server, so layouts follow a user between browsers and the desktop app.
+// The model mirrors the frontend's WorkspaceItem and PaneLayout types.
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Limits on a workspace. MaxTerminalPanes matches the frontend's pane cap.
+const (
+	MaxTerminalPanes = 8
+	MaxPanes         = 32
+	MaxNameLength    = 256
+	MaxWorkspaces    = 100
+)
+
+// Layout node types.
+const (
+	TypeTerminal = "terminal"
+	TypePreview  = "preview"
+	TypeSplit    = "split"
+)
+
+var (
+	// ErrNotFound is returned when a user has no workspace with the given ID.
+	ErrNotFound = errors.New("workspace not found")
+	// ErrExists is returned when creating a workspace whose ID is taken.
+	ErrExists = errors.New("workspace already exists")
+	// ErrTooMany is returned when a user already has MaxWorkspaces workspaces.
+	ErrTooMany = errors.New("too many workspaces")
+	// ErrInvalid wraps validation failures.
+	ErrInvalid = errors.New("invalid workspace")
+)
+
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Layout is one node of a pane layout tree: a terminal pane, a preview pane
+// or a split with two children.
+type Layout struct {
+	Type string `json:"type"`
+
+	// Leaf fields
+	PaneID      string `json:"paneId,omitempty"`
+	SessionID   string `json:"sessionId,omitempty"`   // terminal
+	ContentType string `json:"contentType,omitempty"` // preview: markdown, text or url
+	Source      string `json:"source,omitempty"`      // preview
+
+	// SessionCreatedAt is when the terminal's session was created. Session
+	// IDs start again from s1 when the server restarts, so this tells the
+	// saved session apart from a new one that reused its ID.
+	SessionCreatedAt time.Time `json:"sessionCreatedAt,omitzero"`
+
+	// Split fields
+	Direction string  `json:"direction,omitempty"` // "h" or "v"
+	Ratio     float64 `json:"ratio,omitempty"`
+	First     *Layout `json:"first,omitempty"`
+	Second    *Layout `json:"second,omitempty"`
+}
+
+// Workspace is a named layout.
+type Workspace struct {
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	Tree          *Layout   `json:"tree"`
+	FocusedPaneID string    `json:"focusedPaneId,omitempty"`
+	UserRenamed   bool      `json:"userRenamed,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// SessionRef identifies the session in a terminal pane.
+type SessionRef struct {
+	ID        string
+	CreatedAt time.Time
+}
+
+// Sessions returns the sessions in the workspace's terminal panes, in tree
+// order.
+func (w *Workspace) Sessions() []SessionRef {
+	var refs []SessionRef
+	w.Tree.walk(func(l *Layout) {
+		if l.Type == TypeTerminal {
+			refs = append(refs, SessionRef{ID: l.SessionID, CreatedAt: l.SessionCreatedAt})
+		}
+	})
+	return refs
+}
+
+// StampSessions sets SessionCreatedAt on terminal panes saved without one:
+// from the same pane in prev (the version being replaced, or nil) if it
+// held the same session ID, otherwise from createdAt, which reports a live
+// session's creation time. Panes whose session is in neither stay unset.
+func (w *Workspace) StampSessions(prev *Workspace, createdAt func(id string) (time.Time, bool)) {
+	saved := make(map[string]*Layout)
+	if prev != nil {
+		prev.Tree.walk(func(l *Layout) {
+			if l.Type == TypeTerminal {
+				saved[l.PaneID] = l
+			}
+		})
+	}
+	w.Tree.walk(func(l *Layout) {
+		if l.Type != TypeTerminal || !l.SessionCreatedAt.IsZero() {
+			return
+		}
+		if old := saved[l.PaneID]; old != nil && old.SessionID == l.SessionID {
+			l.SessionCreatedAt = old.SessionCreatedAt
+		} else if t, ok := createdAt(l.SessionID); ok {
+			l.SessionCreatedAt = t.UTC()
+		}
+	})
+}
+
+// Validate checks the workspace's ID (when set), name and layout tree.
+func (w *Workspace) Validate() error {
+	if w.ID != "" && !idPattern.MatchString(w.ID) {
+		return fmt.Errorf("%w: id must be 1-64 letters, digits, '-' or '_'", ErrInvalid)
+	}
+	if len(w.Name) > MaxNameLength {
+		return fmt.Errorf("%w: name longer than %d bytes", ErrInvalid, MaxNameLength)
+	}
+	if w.Tree == nil {
+		return fmt.Errorf("%w: tree is required", ErrInvalid)
+	}
+
+	paneIDs := make(map[string]bool)
+	terminals := 0
+	if err := w.Tree.validate(paneIDs, &terminals); err != nil {
+		return err
+	}
+	if terminals > MaxTerminalPanes {
+		return fmt.Errorf("%w: more than %d terminal panes", ErrInvalid, MaxTerminalPanes)
+	}
+	if w.FocusedPaneID != "" && !paneIDs[w.FocusedPaneID] {
+		return fmt.Errorf("%w: focused pane %q is not in the tree", ErrInvalid, w.FocusedPaneID)
+	}
+	return nil
+}
+
+// validate checks one node and its children, recording pane IDs and
+// counting terminal panes.
+func (l *Layout) validate(paneIDs map[string]bool, terminals *int) error {
+	if l == nil {
+		return fmt.Errorf("%w: split is missing a child", ErrInvalid)
+	}
+	if len(paneIDs) >= MaxPanes {
+		return fmt.Errorf("%w: more than %d panes", ErrInvalid, MaxPanes)
+	}
+
+	switch l.Type {
+	case TypeSplit:
+		if l.Direction != "h" && l.Direction != "v" {
+			return fmt.Errorf("%w: split direction must be \"h\" or \"v\"", ErrInvalid)
+		}
+		if l.Ratio <= 0 || l.Ratio >= 1 {
+			return fmt.Errorf("%w: split ratio must be between 0 and 1", ErrInvalid)
+		}
+		if err := l.First.validate(paneIDs, terminals); err != nil {
+			return err
+		}
+		return l.Second.validate(paneIDs, terminals)
+
+	case TypeTerminal, TypePreview:
+		if l.PaneID == "" {
+			return fmt.Errorf("%w: pane is missing paneId", ErrInvalid)
+		}
+		if paneIDs[l.PaneID] {
+			return fmt.Errorf("%w: duplicate paneId %q", ErrInvalid, l.PaneID)
+		}
+		paneIDs[l.PaneID] = true
+		if l.Type == TypeTerminal {
+			if l.SessionID == "" {
+				return fmt.Errorf("%w: terminal pane %q is missing sessionId", ErrInvalid, l.PaneID)
+			}
+			*terminals++
+			return nil
+		}
+		switch l.ContentType {
+		case "markdown", "text", "url":
+		default:
+			return fmt.Errorf("%w: preview pane %q has unknown contentType %q", ErrInvalid, l.PaneID, l.ContentType)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("%w: unknown layout type %q", ErrInvalid, l.Type)
+	}
+}
+
+// walk calls fn for every node, depth first.
+func (l *Layout) walk(fn func(*Layout)) {
+	if l == nil {
+		return
+	}
+	fn(l)
+	l.First.walk(fn)
+	l.Second.walk(fn)
+}
+
+// clone returns a deep copy of the tree.
+func (l *Layout) clone() *Layout {
+	if l == nil {
+		return nil
+	}
+	c := *l
+	c.First = l.First.clone()
+	c.Second = l.Second.clone()
+	return &c
+}
+
+// clone returns a copy of the workspace that shares no layout nodes.
+func (w Workspace) clone() Workspace {
+	w.Tree = w.Tree.clone()
+	return w
+}
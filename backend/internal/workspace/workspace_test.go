@@ -0,0 +1,90 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+// terminal returns a terminal pane layout.
+func terminal(paneID, sessionID string) *Layout {
+	return &Layout{Type: TypeTerminal, PaneID: paneID, SessionID: sessionID}
+}
+
+// split returns a horizontal 50/50 split.
+func split(first, second *Layout) *Layout {
+	return &Layout{Type: TypeSplit, Direction: "h", Ratio: 0.5, First: first, Second: second}
+}
+
+func TestWorkspace_Validate(t *testing.T) {
+	// Test Doc:
+	// - Why: Layouts come from clients and are replayed into other browsers, so malformed trees must be rejected up front
+	// - Contract: Valid trees pass; bad types, splits, leaves, duplicate panes, too many terminals and unknown focus fail with ErrInvalid
+
+	preview := &Layout{Type: TypePreview, PaneID: "doc", ContentType: "markdown", Source: "README.md"}
+	valid := Workspace{ID: "ws_1", Name: "dev", Tree: split(terminal("p1", "s1"), preview), FocusedPaneID: "p1"}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("valid workspace: %v", err)
+	}
+
+	tooMany := terminal("p0", "s0")
+	for i := 1; i <= MaxTerminalPanes; i++ {
+		tooMany = split(tooMany, terminal(fmt.Sprintf("p%d", i), fmt.Sprintf("s%d", i)))
+	}
+
+	tests := []struct {
+		name string
+		ws   Workspace
+	}{
+		{"no tree", Workspace{Name: "x"}},
+		{"bad id", Workspace{ID: "../etc", Tree: terminal("p1", "s1")}},
+		{"unknown type", Workspace{Tree: &Layout{Type: "grid", PaneID: "p1"}}},
+		{"missing child", Workspace{Tree: split(terminal("p1", "s1"), nil)}},
+		{"bad direction", Workspace{Tree: &Layout{Type: TypeSplit, Direction: "x", Ratio: 0.5, First: terminal("p1", "s1"), Second: terminal("p2", "s2")}}},
+		{"bad ratio", Workspace{Tree: &Layout{Type: TypeSplit, Direction: "v", Ratio: 1, First: terminal("p1", "s1"), Second: terminal("p2", "s2")}}},
+		{"missing session", Workspace{Tree: terminal("p1", "")}},
+		{"duplicate pane", Workspace{Tree: split(terminal("p1", "s1"), terminal("p1", "s2"))}},
+		{"bad preview", Workspace{Tree: &Layout{Type: TypePreview, PaneID: "p1", ContentType: "pdf"}}},
+		{"unknown focus", Workspace{Tree: terminal("p1", "s1"), FocusedPaneID: "p9"}},
+		{"too many terminals", Workspace{Tree: tooMany}},
+	}
+	for _, tt := range tests {
+		if err := tt.ws.Validate(); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: Validate() = %v, want ErrInvalid", tt.name, err)
+		}
+	}
+
+	if got := valid.Sessions(); !slices.Equal(got, []SessionRef{{ID: "s1"}}) {
+		t.Errorf("Sessions() = %v, want [s1]", got)
+	}
+}
+
+func TestWorkspace_StampSessions(t *testing.T) {
+	// Test Doc:
+	// - Why: Session IDs restart from s1 with the server, so a saved pane must remember which s1 it meant
+	// - Contract: A given SessionCreatedAt is kept; otherwise the same pane and session in prev supplies it; otherwise the live session's time; unknown sessions stay zero
+	// - Worked Example: prev p1=s1@boot1, live s1@boot2 → re-saving p1=s1 keeps boot1, so the new s1 is not mistaken for it
+
+	boot1 := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	boot2 := boot1.Add(time.Hour)
+	live := map[string]time.Time{"s1": boot2, "s2": boot2}
+	createdAt := func(id string) (time.Time, bool) {
+		t, ok := live[id]
+		return t, ok
+	}
+
+	prev := Workspace{Tree: split(terminal("p1", "s1"), terminal("p2", "s9"))}
+	prev.Tree.First.SessionCreatedAt = boot1
+
+	given := terminal("p4", "s2")
+	given.SessionCreatedAt = boot1
+	w := Workspace{Tree: split(split(terminal("p1", "s1"), terminal("p2", "s2")), split(terminal("p3", "s7"), given))}
+	w.StampSessions(&prev, createdAt)
+
+	want := []SessionRef{{ID: "s1", CreatedAt: boot1}, {ID: "s2", CreatedAt: boot2}, {ID: "s7"}, {ID: "s2", CreatedAt: boot1}}
+	if got := w.Sessions(); !slices.Equal(got, want) {
+		t.Errorf("Sessions() after stamping = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,68 @@
+# trex shell integration for bash (4.4+).
+#
+# Emits OSC 7 (working directory) and OSC 133 (prompt and command marks) so
+# trex gets instant cwd updates and a command history with exit codes.
+#
+# Usage: add to the end of ~/.bashrc
+#   source /path/to/trex/scripts/shell-integration/trex.bash
+#
+# Other terminals that understand OSC 7 and OSC 133 use the same marks.
+#
+# Installs a DEBUG trap; an existing DEBUG trap is replaced.
+
+if [[ $- != *i* || -n "${__trex_shell_integration:-}" ]]; then
+    return 0 2>/dev/null || exit 0
+fi
+__trex_shell_integration=1
+__trex_at_prompt=0
+__trex_ran=0
+
+# Percent-encode $1 for OSC 7 and cmdline_url.
+__trex_urlencode() {
+    local LC_ALL=C s="$1" out="" c i
+    for (( i = 0; i < ${#s}; i++ )); do
+        c="${s:i:1}"
+        case "$c" in
+            [a-zA-Z0-9.~_/-]) out+="$c" ;;
+            *) printf -v c '%%%02X' "'$c"; out+="$c" ;;
+        esac
+    done
+    printf '%s' "$out"
+}
+
+# First in PROMPT_COMMAND: report the finished command and the cwd.
+__trex_precmd() {
+    local status=$?
+    __trex_at_prompt=0  # An empty command line runs no command
+    if (( __trex_ran )); then
+        printf '\e]133;D;%s\a' "$status"
+        __trex_ran=0
+    fi
+    printf '\e]7;file://%s%s\a' "${HOSTNAME}" "$(__trex_urlencode "$PWD")"
+    return $status
+}
+
+# Last in PROMPT_COMMAND: mark the prompt (A) and where input starts (B).
+__trex_prompt_ready() {
+    if [[ "$PS1" != *'133;A'* ]]; then
+        PS1='\[\e]133;A\a\]'"$PS1"'\[\e]133;B\a\]'
+    fi
+    __trex_at_prompt=1
+}
+
+# DEBUG trap: the first command run from the prompt marks the command (C).
+__trex_preexec() {
+    [[ $__trex_at_prompt == 1 && "$BASH_COMMAND" != __trex_precmd* ]] || return 0
+    [[ -z "${COMP_LINE:-}" ]] || return 0
+    __trex_at_prompt=0
+    __trex_ran=1
+    local cmd
+    cmd="$(HISTTIMEFORMAT= builtin history 1)"
+    cmd="${cmd#"${cmd%%[![:space:]]*}"}"  # leading spaces
+    cmd="${cmd#*[[:space:]]}"              # history number
+    cmd="${cmd#"${cmd%%[![:space:]]*}"}"
+    printf '\e]133;C;cmdline_url=%s\a' "$(__trex_urlencode "$cmd")"
+}
+
+PROMPT_COMMAND="__trex_precmd${PROMPT_COMMAND:+; $PROMPT_COMMAND}; __trex_prompt_ready"
+trap '__trex_preexec' DEBUG